@@ -0,0 +1,82 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command ocifake runs the fake OCI Certificates and Load Balancer services
+// for local development. Point an issuer's endpointOverride at the printed
+// URL.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+)
+
+func main() {
+	var opts ocifake.Options
+	var compartmentID string
+	var subordinate, withLoadBalancer bool
+	flag.StringVar(&opts.Addr, "addr", "127.0.0.1:8090", "The address the fake binds to.")
+	flag.BoolVar(&opts.TLS, "tls", false, "Serve HTTPS with a self-signed certificate.")
+	flag.StringVar(&opts.Realm, "realm", ocifake.DefaultRealm, "The realm of generated OCIDs.")
+	flag.StringVar(&opts.Region, "region", ocifake.DefaultRegion, "The region key of generated OCIDs.")
+	flag.StringVar(&compartmentID, "compartment", "ocid1.compartment.oc1..ocifake", "The compartment of the CAs.")
+	flag.BoolVar(&subordinate, "subordinate", true, "Also create a subordinate CA issued by the root CA.")
+	flag.BoolVar(&withLoadBalancer, "load-balancer", true, "Also create a load balancer with an HTTPS listener named https.")
+	flag.Parse()
+
+	srv := ocifake.NewServer(opts)
+	defer srv.Close()
+	rootID, err := srv.CreateRootCA(compartmentID, "ocifake root")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to create root CA:", err)
+		os.Exit(1)
+	}
+	fmt.Println("endpoint:", srv.URL)
+	fmt.Println("root CA:", rootID)
+	if subordinate {
+		subID, err := srv.CreateSubordinateCA(rootID, "ocifake issuing")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to create subordinate CA:", err)
+			os.Exit(1)
+		}
+		fmt.Println("subordinate CA:", subID)
+	}
+	if withLoadBalancer {
+		lbID := srv.CreateLoadBalancer(compartmentID, "ocifake", loadbalancer.Listener{
+			Name:                  common.String("https"),
+			DefaultBackendSetName: common.String("backends"),
+			Port:                  common.Int(443),
+			Protocol:              common.String("HTTP"),
+			SslConfiguration: &loadbalancer.SslConfiguration{
+				CertificateName:       common.String("ocifake"),
+				VerifyDepth:           common.Int(1),
+				VerifyPeerCertificate: common.Bool(false),
+			},
+		})
+		fmt.Println("load balancer:", lbID)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+}
@@ -19,16 +19,24 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	configv1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/config/v1alpha1"
 	"github.com/william20111/oci-privateca-issuer/pkg/controllers"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocsp"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -43,18 +51,50 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(ocicav1alpha1.AddToScheme(scheme))
+	utilruntime.Must(cmapi.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
+	cfg := configv1alpha1.Default()
+	var configFile string
+	var ocspAddr string
+	var disableApprovedCheck bool
+	var clusterID string
+	var healthCheckInterval time.Duration
+	var ocspOpts ocsp.Options
+	var ociTransport provisioner.Transport
+	var ociNoProxy, ociCABundleFile string
+	flag.StringVar(&configFile, "config", "",
+		"Path to a "+configv1alpha1.Kind+" file. Flags set on the command line override its values.")
+	flag.StringVar(&cfg.Metrics.BindAddress, "metrics-bind-address", cfg.Metrics.BindAddress, "The address the metric endpoint binds to.")
+	flag.StringVar(&cfg.Health.BindAddress, "health-probe-bind-address", cfg.Health.BindAddress, "The address the probe endpoint binds to.")
+	flag.IntVar(&cfg.Webhook.Port, "webhook-port", cfg.Webhook.Port, "The port the webhook server listens on.")
+	flag.BoolVar(&cfg.LeaderElection.LeaderElect, "leader-elect", cfg.LeaderElection.LeaderElect,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&cfg.ClusterResourceNamespace, "cluster-resource-namespace", cfg.ClusterResourceNamespace,
+		"The namespace Secrets referenced by cluster issuers are read from.")
+	flag.BoolVar(&disableApprovedCheck, "disable-approved-check", false,
+		"Sign CertificateRequests without waiting for them to be approved.")
+	flag.Var(cfg.FeatureGateFlag(configv1alpha1.FeatureApprover), "enable-approver",
+		"Approve or deny CertificateRequests for OCICAClusterIssuers according to the issuer policy.")
+	flag.Var(cfg.FeatureGateFlag(configv1alpha1.FeatureSecretExport), "enable-secret-export",
+		"Import the certificates of Secrets annotated with "+ocicav1alpha1.ExportToAnnotation+" into OCI Certificates.")
+	flag.DurationVar(&healthCheckInterval, "issuer-health-check-interval", 5*time.Minute,
+		"How often ready issuers check their certificate authority is still active. Zero disables the check.")
+	flag.StringVar(&clusterID, "cluster-id", "",
+		"Identifies this cluster in the names of OCI certificates, keeping them unique when clusters share a compartment.")
+	flag.StringVar(&ocspAddr, "ocsp-bind-address", "", "The address the OCSP responder binds to. Disabled when empty.")
+	flag.DurationVar(&ocspOpts.RefreshInterval, "ocsp-refresh-interval", ocsp.DefaultRefreshInterval,
+		"How often the OCSP responder reloads certificate status and the CRL from OCI.")
+	flag.DurationVar(&ocspOpts.ResponseValidity, "ocsp-response-validity", ocsp.DefaultResponseValidity,
+		"How long signed OCSP responses are valid for.")
+	flag.StringVar(&ociTransport.ProxyURL, "oci-proxy-url", "",
+		"The HTTP(S) proxy used to reach OCI. Defaults to the proxy environment variables.")
+	flag.StringVar(&ociNoProxy, "oci-no-proxy", "", "Comma separated hosts, domains and CIDRs reached without the OCI proxy.")
+	flag.StringVar(&ociCABundleFile, "oci-ca-bundle", "",
+		"Path to PEM encoded certificates trusted in addition to the system roots when calling OCI.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -63,23 +103,174 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
+	if configFile != "" {
+		if err := configv1alpha1.Load(configFile, cfg, flag.CommandLine); err != nil {
+			setupLog.Error(err, "unable to load configuration file")
+			os.Exit(1)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+
+	if ociNoProxy != "" {
+		ociTransport.NoProxy = strings.Split(ociNoProxy, ",")
+	}
+	if ociCABundleFile != "" {
+		bundle, err := os.ReadFile(ociCABundleFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read OCI CA bundle")
+			os.Exit(1)
+		}
+		ociTransport.CABundle = bundle
+	}
+	if _, err := ociTransport.HTTPClient(); err != nil {
+		setupLog.Error(err, "invalid OCI transport settings")
+		os.Exit(1)
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = cfg.RateLimits.QPS
+	restConfig.Burst = cfg.RateLimits.Burst
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                  scheme,
+		MetricsBindAddress:      cfg.Metrics.BindAddress,
+		Host:                    cfg.Webhook.Host,
+		Port:                    cfg.Webhook.Port,
+		CertDir:                 cfg.Webhook.CertDir,
+		HealthProbeBindAddress:  cfg.Health.BindAddress,
+		LeaderElection:          cfg.LeaderElection.LeaderElect,
+		LeaderElectionID:        cfg.LeaderElection.LeaseName,
+		LeaderElectionNamespace: cfg.LeaderElection.Namespace,
+		LeaseDuration:           &cfg.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline:           &cfg.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:             &cfg.LeaderElection.RetryPeriod.Duration,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+	collection := &provisioner.Collection{}
 	if err = (&controllers.OCICAClusterIssuerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Collection:               collection,
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		ClusterResourceNamespace: cfg.ClusterResourceNamespace,
+		Transport:                ociTransport,
+		DefaultAuth:              cfg.DefaultAuth,
+		HealthCheckInterval:      healthCheckInterval,
+		Clock:                    clock.RealClock{},
+		MaxConcurrentReconciles:  cfg.MaxConcurrentReconciles(configv1alpha1.ControllerOCICAClusterIssuer),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "OCICAClusterIssuer")
 		os.Exit(1)
 	}
+	if err = (&controllers.CertificateRequestReconciler{
+		Collection:             collection,
+		Client:                 mgr.GetClient(),
+		APIReader:              mgr.GetAPIReader(),
+		Log:                    ctrl.Log.WithName("controllers").WithName("CertificateRequest"),
+		Scheme:                 mgr.GetScheme(),
+		Recorder:               mgr.GetEventRecorderFor("oci-privateca-issuer"),
+		Clock:                  clock.RealClock{},
+		CheckApprovedCondition: !disableApprovedCheck,
+		ClusterID:              clusterID,
+
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles(configv1alpha1.ControllerCertificateRequest),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CertificateRequest")
+		os.Exit(1)
+	}
+	if err = (&controllers.OCIManagedCertificateReconciler{
+		Collection: collection,
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("OCIManagedCertificate"),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("oci-privateca-issuer"),
+		Clock:      clock.RealClock{},
+		ClusterID:  clusterID,
+
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles(configv1alpha1.ControllerOCIManagedCertificate),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OCIManagedCertificate")
+		os.Exit(1)
+	}
+	if err = (&controllers.OCICertificateImportReconciler{
+		Collection: collection,
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("OCICertificateImport"),
+		Scheme:     mgr.GetScheme(),
+		Recorder:   mgr.GetEventRecorderFor("oci-privateca-issuer"),
+		Clock:      clock.RealClock{},
+
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles(configv1alpha1.ControllerOCICertificateImport),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OCICertificateImport")
+		os.Exit(1)
+	}
+	if err = (&controllers.OCILoadBalancerBindingReconciler{
+		Collection: collection,
+		Client:     mgr.GetClient(),
+		Log:        ctrl.Log.WithName("controllers").WithName("OCILoadBalancerBinding"),
+		Recorder:   mgr.GetEventRecorderFor("oci-privateca-issuer"),
+		Clock:      clock.RealClock{},
+		ClusterID:  clusterID,
+
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles(configv1alpha1.ControllerOCILoadBalancerBinding),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OCILoadBalancerBinding")
+		os.Exit(1)
+	}
+	if cfg.Enabled(configv1alpha1.FeatureApprover) {
+		if err = (&controllers.ApproverReconciler{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("controllers").WithName("Approver"),
+			Recorder: mgr.GetEventRecorderFor("oci-privateca-issuer-approver"),
+
+			MaxConcurrentReconciles: cfg.MaxConcurrentReconciles(configv1alpha1.ControllerApprover),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Approver")
+			os.Exit(1)
+		}
+	}
+	if cfg.Enabled(configv1alpha1.FeatureSecretExport) {
+		if err = (&controllers.SecretExportReconciler{
+			Collection: collection,
+			Client:     mgr.GetClient(),
+			Log:        ctrl.Log.WithName("controllers").WithName("SecretExport"),
+			Recorder:   mgr.GetEventRecorderFor("oci-privateca-issuer"),
+			ClusterID:  clusterID,
+
+			MaxConcurrentReconciles: cfg.MaxConcurrentReconciles(configv1alpha1.ControllerSecretExport),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "SecretExport")
+			os.Exit(1)
+		}
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&ocicav1alpha1.OCICAClusterIssuer{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "OCICAClusterIssuer")
+			os.Exit(1)
+		}
+		if err = (&ocicav1alpha1.OCIManagedCertificate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "OCIManagedCertificate")
+			os.Exit(1)
+		}
+	}
+	if ocspAddr != "" {
+		handler := ocsp.NewHandler(ctrl.Log.WithName("ocsp"), func(name string) ([]ocsp.Issuer, bool) {
+			authorities, ok := collection.LoadAuthorities(types.NamespacedName{Name: name})
+			if !ok {
+				return nil, false
+			}
+			return authorities.OCSPIssuers(), true
+		}, ocspOpts)
+		if err := mgr.Add(&ocsp.Server{Addr: ocspAddr, Handler: handler}); err != nil {
+			setupLog.Error(err, "unable to set up OCSP responder")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -17,7 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -34,6 +36,11 @@ const (
 	// If the `status` of this condition is `False`, CertificateRequest
 	// controllers should prevent attempts to sign certificates.
 	ConditionReady ConditionType = "Ready"
+
+	// ConditionCARotation reports the progress of the rotation of an issuer
+	// to a new certificate authority. Its reason is the phase of the
+	// rotation, and it is false while the rotation cannot progress.
+	ConditionCARotation ConditionType = "CARotation"
 )
 
 // +kubebuilder:validation:Enum=True;False;Unknown
@@ -49,12 +56,623 @@ const (
 	ConditionUnknown metav1.ConditionStatus = "Unknown"
 )
 
+// +kubebuilder:validation:Enum=ConfigFile;InstancePrincipal;APIKey
+
+// AuthMode selects how the issuer authenticates to OCI.
+type AuthMode string
+
+const (
+	// AuthModeConfigFile reads credentials from the OCI config file of the
+	// controller, or the TF_VAR_ environment variables.
+	AuthModeConfigFile AuthMode = "ConfigFile"
+
+	// AuthModeInstancePrincipal uses the instance principal of the node the
+	// controller runs on.
+	AuthModeInstancePrincipal AuthMode = "InstancePrincipal"
+
+	// AuthModeAPIKey reads an API signing key from a Secret.
+	AuthModeAPIKey AuthMode = "APIKey"
+)
+
+const (
+	// DefaultAuthProfile is the OCI config file profile used when none is set.
+	DefaultAuthProfile = "DEFAULT"
+
+	// DefaultCertificateDuration is the validity of certificates whose
+	// request does not ask for a duration.
+	DefaultCertificateDuration = time.Hour * 24 * 7
+
+	// MinimumCertificateDuration is the shortest duration accepted for
+	// issued certificates.
+	MinimumCertificateDuration = time.Hour
+
+	// DefaultValidityBackdate is how far NotBefore is moved into the past
+	// when the issuer does not set it.
+	DefaultValidityBackdate = 5 * time.Minute
+)
+
+// OCIAuth configures the credentials used to call OCI.
+type OCIAuth struct {
+	// Mode selects how the issuer authenticates to OCI. Defaults to ConfigFile.
+	// +optional
+	Mode AuthMode `json:"mode,omitempty"`
+
+	// Profile is the OCI config file profile used in ConfigFile mode.
+	// Defaults to DEFAULT.
+	// +optional
+	Profile string `json:"profile,omitempty"`
+
+	// SecretName names the Secret holding the API key in APIKey mode. The
+	// Secret must contain the tenancy, user, fingerprint and privateKey keys,
+	// and may contain region and passphrase.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// EndpointOverride replaces the OCI service endpoints derived from the region.
+type EndpointOverride struct {
+	// CertificatesManagement is the base URL of the Certificates Management API.
+	// +optional
+	CertificatesManagement string `json:"certificatesManagement,omitempty"`
+
+	// Certificates is the base URL of the Certificates API used to retrieve
+	// certificate bundles.
+	// +optional
+	Certificates string `json:"certificates,omitempty"`
+
+	// LoadBalancer is the base URL of the Load Balancer API used by
+	// OCILoadBalancerBindings.
+	// +optional
+	LoadBalancer string `json:"loadBalancer,omitempty"`
+}
+
+// OCITransport configures how the issuer connects to OCI. It is combined with
+// the transport flags of the controller, the issuer settings taking precedence.
+type OCITransport struct {
+	// ProxyURL is the HTTP(S) proxy used to reach OCI, for example
+	// http://proxy.example.com:3128. Defaults to the proxy environment
+	// variables of the controller.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// NoProxy lists hosts, domains and CIDRs reached without the proxy.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
+
+	// CABundle references PEM encoded certificates trusted in addition to the
+	// system roots when calling OCI, for proxies that intercept TLS.
+	// +optional
+	CABundle *CABundleSource `json:"caBundle,omitempty"`
+}
+
+// CABundleSource references a CA bundle held in a ConfigMap or a Secret in the
+// cluster resource namespace. Exactly one of them must be set.
+type CABundleSource struct {
+	// ConfigMap holding the CA bundle.
+	// +optional
+	ConfigMap *KeySelector `json:"configMap,omitempty"`
+
+	// Secret holding the CA bundle.
+	// +optional
+	Secret *KeySelector `json:"secret,omitempty"`
+}
+
+// KeySelector selects a key of a ConfigMap or Secret.
+type KeySelector struct {
+	// Name of the ConfigMap or Secret.
+	Name string `json:"name"`
+
+	// Key holding the data. Defaults to ca.crt.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// SubordinateCAPolicy controls CertificateRequests with isCA set.
+type SubordinateCAPolicy struct {
+	// Allow signs CA certificates for requests with isCA set, so that other
+	// issuers such as the cert-manager CA issuer can chain under the OCI
+	// certificate authority. Such requests fail when it is false.
+	// +optional
+	Allow bool `json:"allow,omitempty"`
+
+	// MaxPathLength is the largest path length constraint a signed CA
+	// certificate may carry. When set, CSRs without a path length constraint
+	// are refused. 0 only allows CAs that sign leaf certificates.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxPathLength *int `json:"maxPathLength,omitempty"`
+}
+
+// ValidityExceededAction is what an issuer does with requests whose validity
+// exceeds what its certificate authority allows.
+// +kubebuilder:validation:Enum=Clamp;Reject
+type ValidityExceededAction string
+
+const (
+	// ValidityExceededClamp shortens the validity to the longest allowed.
+	ValidityExceededClamp ValidityExceededAction = "Clamp"
+
+	// ValidityExceededReject fails the request.
+	ValidityExceededReject ValidityExceededAction = "Reject"
+)
+
+// ValidityPolicy controls the validity of issued certificates against the
+// leaf maximum of the certificate authority rules and the expiry of the
+// certificate authority.
+type ValidityPolicy struct {
+	// OnExceeded is what happens to requests longer than the certificate
+	// authority allows. Defaults to Clamp.
+	// +optional
+	OnExceeded ValidityExceededAction `json:"onExceeded,omitempty"`
+
+	// Backdate moves NotBefore into the past to tolerate clock skew.
+	// Defaults to 5m.
+	// +optional
+	Backdate *metav1.Duration `json:"backdate,omitempty"`
+
+	// MinDuration is the shortest validity issued, counted from now. Requests
+	// that would be clamped below it fail instead of producing certificates
+	// cert-manager renews straight away. Defaults to 1h.
+	// +optional
+	MinDuration *metav1.Duration `json:"minDuration,omitempty"`
+}
+
+// IssuancePolicy restricts the certificates an issuer signs. Requests
+// violating it are denied.
+type IssuancePolicy struct {
+	// DNSNames restricts the DNS subject alternative names. Names are matched
+	// in lower case, and in globs * matches within a single label.
+	// +optional
+	DNSNames *NamePolicy `json:"dnsNames,omitempty"`
+
+	// IPAddresses restricts the IP subject alternative names.
+	// +optional
+	IPAddresses *CIDRPolicy `json:"ipAddresses,omitempty"`
+
+	// URIs restricts the URI subject alternative names. In globs, * matches
+	// any characters.
+	// +optional
+	URIs *NamePolicy `json:"uris,omitempty"`
+
+	// EmailAddresses restricts the email subject alternative names. Addresses
+	// are matched in lower case.
+	// +optional
+	EmailAddresses *NamePolicy `json:"emailAddresses,omitempty"`
+
+	// Subject restricts the attributes of the subject.
+	// +optional
+	Subject *SubjectPolicy `json:"subject,omitempty"`
+
+	// AllowWildcards allows wildcard DNS names such as *.example.com, which
+	// are denied when it is false.
+	// +optional
+	AllowWildcards bool `json:"allowWildcards,omitempty"`
+
+	// MaxDuration is the longest duration a request may ask for. Requests
+	// without a duration are checked with the issuer's defaultDuration.
+	// +optional
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// NamePolicy allows and denies values by glob and regular expression. A
+// value is allowed when it matches no deny pattern and, if any allow pattern
+// is set, at least one allow pattern.
+type NamePolicy struct {
+	// Allow are globs of allowed values.
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// AllowRegex are regular expressions of allowed values.
+	// +optional
+	AllowRegex []string `json:"allowRegex,omitempty"`
+
+	// Deny are globs of denied values.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+
+	// DenyRegex are regular expressions of denied values.
+	// +optional
+	DenyRegex []string `json:"denyRegex,omitempty"`
+}
+
+// CIDRPolicy allows and denies IP addresses by CIDR. An address is allowed
+// when it is in no denied range and, if any allowed range is set, in at least
+// one allowed range.
+type CIDRPolicy struct {
+	// Allow are the allowed ranges, for example 10.0.0.0/8.
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny are the denied ranges.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+}
+
+// SubjectPolicy restricts the attributes of the subject. Each attribute is
+// checked like a NamePolicy, where * in globs matches any characters.
+type SubjectPolicy struct {
+	// +optional
+	CommonName *NamePolicy `json:"commonName,omitempty"`
+	// +optional
+	Organizations *NamePolicy `json:"organizations,omitempty"`
+	// +optional
+	OrganizationalUnits *NamePolicy `json:"organizationalUnits,omitempty"`
+	// +optional
+	Countries *NamePolicy `json:"countries,omitempty"`
+	// +optional
+	Localities *NamePolicy `json:"localities,omitempty"`
+	// +optional
+	Provinces *NamePolicy `json:"provinces,omitempty"`
+}
+
+// NamespaceOverride adjusts the issuer for CertificateRequests from the
+// namespaces matching its selector.
+type NamespaceOverride struct {
+	// NamespaceSelector selects the namespaces the override applies to.
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// Policy applies to requests from the selected namespaces in addition
+	// to the issuer's policy, which it can only narrow, for example to a
+	// team's domains and durations.
+	// +optional
+	Policy *IssuancePolicy `json:"policy,omitempty"`
+
+	// CompartmentID is the compartment certificates for the selected
+	// namespaces are created in. Defaults to the issuer's compartment.
+	// +optional
+	CompartmentID string `json:"compartmentID,omitempty"`
+}
+
+// NamespaceCompartmentAnnotation is the namespace annotation choosing the
+// compartment certificates for the namespace are created in, when the issuer
+// allows it with spec.compartments.namespaceAnnotation.
+const NamespaceCompartmentAnnotation = "ocica.cert-manager.io/compartment-id"
+
+// Annotations of Secrets exported to OCI Certificates as imported
+// certificates.
+const (
+	// ExportToAnnotation names the OCICAClusterIssuer whose credentials,
+	// compartment and policy a kubernetes.io/tls Secret is exported with.
+	ExportToAnnotation = "ocica.cert-manager.io/export-to"
+	// ExportedCertificateIDAnnotation is the OCID of the imported
+	// certificate, recorded on the Secret.
+	ExportedCertificateIDAnnotation = "ocica.cert-manager.io/exported-certificate-id"
+	// ExportedSerialAnnotation is the serial number, in hex, of the
+	// certificate last imported from the Secret.
+	ExportedSerialAnnotation = "ocica.cert-manager.io/exported-serial"
+)
+
+// CompartmentRouting chooses the compartment certificates are created in from
+// the namespace of the CertificateRequest, for example so that each team pays
+// for its own certificates.
+type CompartmentRouting struct {
+	// Namespaces maps namespace names to the compartment certificates for
+	// the namespace are created in.
+	// +optional
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+
+	// NamespaceAnnotation lets namespaces missing from Namespaces choose
+	// their compartment with the ocica.cert-manager.io/compartment-id
+	// annotation.
+	// +optional
+	NamespaceAnnotation bool `json:"namespaceAnnotation,omitempty"`
+
+	// Default is the compartment of certificates for other namespaces.
+	// Defaults to compartment_id.
+	// +optional
+	Default string `json:"default,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Priority;Weighted;RoundRobin
+
+// AuthoritySelection chooses which of the available certificate authorities
+// of an issuer signs a request.
+type AuthoritySelection string
+
+const (
+	// AuthoritySelectionPriority signs with the available authority of the
+	// lowest priority, failing over to the next when it is unavailable.
+	AuthoritySelectionPriority AuthoritySelection = "Priority"
+
+	// AuthoritySelectionWeighted spreads requests across the available
+	// authorities in proportion to their weight.
+	AuthoritySelectionWeighted AuthoritySelection = "Weighted"
+
+	// AuthoritySelectionRoundRobin signs with each available authority in
+	// turn.
+	AuthoritySelectionRoundRobin AuthoritySelection = "RoundRobin"
+)
+
+// CertificateAuthority is one of the certificate authorities an issuer signs
+// with.
+type CertificateAuthority struct {
+	// AuthorityID is the OCID of the certificate authority.
+	AuthorityID string `json:"authorityID"`
+
+	// CompartmentID is the compartment certificates signed by the authority
+	// are created in, unless they are routed by namespace. Defaults to
+	// compartment_id.
+	// +optional
+	CompartmentID string `json:"compartmentID,omitempty"`
+
+	// Region is the OCI region of the certificate authority. Defaults to the
+	// region encoded in its OCID.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Priority orders the authorities for failover, lowest first.
+	// Authorities of the same priority keep their order in the list.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// Weight is the share of requests the authority signs with Weighted
+	// selection. Authorities of weight 0 only sign when all those with a
+	// weight are unavailable.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	Weight int32 `json:"weight,omitempty"`
+}
+
+// CARotation moves an issuer from its certificate authorities to a new one,
+// giving clients time to trust the new certificate authority before it signs,
+// and certificates signed by the old ones time to be renewed before they are
+// no longer trusted.
+type CARotation struct {
+	// AuthorityID is the OCID of the certificate authority the issuer
+	// rotates to.
+	AuthorityID string `json:"authorityID"`
+
+	// CompartmentID is the compartment certificates signed by the new
+	// authority are created in, unless they are routed by namespace.
+	// Defaults to compartment_id.
+	// +optional
+	CompartmentID string `json:"compartmentID,omitempty"`
+
+	// Region is the OCI region of the new authority. Defaults to the region
+	// encoded in its OCID.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Failover lets the old authorities sign while the new one is
+	// unavailable, until RetireAt. They stop signing at SignAt otherwise.
+	// +optional
+	Failover bool `json:"failover,omitempty"`
+
+	// Schedule is when the rotation enters each phase.
+	Schedule CARotationSchedule `json:"schedule"`
+}
+
+// CARotationSchedule is when a rotation enters each phase.
+type CARotationSchedule struct {
+	// DistributeAt is when the new authority is added to the trust bundle.
+	// Defaults to straight away.
+	// +optional
+	DistributeAt *metav1.Time `json:"distributeAt,omitempty"`
+
+	// SignAt is when the new authority starts signing, once it is active.
+	// Clients should trust it by then. The old authorities stop signing
+	// then, unless Failover is set.
+	SignAt metav1.Time `json:"signAt"`
+
+	// RetireAt ends the Signing phase, when old authorities allowed to fail
+	// over stop signing. The old authorities stay in the trust bundle until
+	// the last certificate they signed expires, which may be after RetireAt.
+	RetireAt metav1.Time `json:"retireAt"`
+}
+
+// +kubebuilder:validation:Enum=Pending;Distributing;Signing;Completed
+
+// CARotationPhase is the phase of a rotation to a new certificate authority.
+type CARotationPhase string
+
+const (
+	// CARotationPending waits for the rotation to start.
+	CARotationPending CARotationPhase = "Pending"
+
+	// CARotationDistributing trusts the old and new authorities while the
+	// old ones sign.
+	CARotationDistributing CARotationPhase = "Distributing"
+
+	// CARotationSigning trusts the old and new authorities while the new one
+	// signs.
+	CARotationSigning CARotationPhase = "Signing"
+
+	// CARotationCompleted only trusts and signs with the new authority.
+	CARotationCompleted CARotationPhase = "Completed"
+)
+
+// AuthorityIDAnnotation is the CertificateRequest annotation recording the
+// OCID of the certificate authority that signed it.
+const AuthorityIDAnnotation = "ocica.cert-manager.io/authority-id"
+
+// +kubebuilder:validation:Enum=Normal;DryRun;Shadow
+
+// IssuerMode is how an issuer handles the CertificateRequests it approves.
+type IssuerMode string
+
+const (
+	// IssuerModeNormal signs requests.
+	IssuerModeNormal IssuerMode = "Normal"
+
+	// IssuerModeDryRun checks requests and records the certificate they
+	// would be signed with in the DryRunAnnotation, without signing them.
+	IssuerModeDryRun IssuerMode = "DryRun"
+
+	// IssuerModeShadow signs requests, and signs them again in the
+	// background with a shadow issuer to report where its certificates
+	// differ. Each shadowed request creates a second OCI certificate, named
+	// with a -shadow suffix, which counts against the OCI limits of the
+	// shadow issuer's compartment but not against OCICAIssuanceQuotas. It is
+	// scheduled for deletion once it is signed.
+	IssuerModeShadow IssuerMode = "Shadow"
+)
+
+// DryRunAnnotation is the CertificateRequest annotation recording, as JSON,
+// the OCI certificate an issuer in DryRun mode would have created for it.
+const DryRunAnnotation = "ocica.cert-manager.io/dry-run"
+
+// ShadowIssuer is the issuer an issuer in Shadow mode compares its
+// certificates with.
+type ShadowIssuer struct {
+	// Name of the OCICAClusterIssuer signing the shadow certificates. Its
+	// own mode is ignored, but it must be ready and allow the requests.
+	Name string `json:"name"`
+}
+
+// PausedAnnotation is the issuer annotation pausing issuance, for example
+// during a change freeze. It is either true, pausing until it is removed, or
+// an RFC 3339 time issuance resumes at.
+const PausedAnnotation = "ocica.cert-manager.io/paused"
+
+// MaintenanceWindow is a recurring period during which the issuer pauses
+// issuance.
+type MaintenanceWindow struct {
+	// Schedule is the cron schedule of the starts of the window, such as
+	// "0 22 * * SAT".
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window lasts after each start.
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA time zone the schedule is in, for example
+	// Europe/London. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// DefaultCABundleKey is the key read from CA bundle ConfigMaps and Secrets when
+// none is set.
+const DefaultCABundleKey = "ca.crt"
+
 // OCICAClusterIssuerSpec defines the desired state of OCICAClusterIssuer
 type OCICAClusterIssuerSpec struct {
 	// Specifies the OCID of the private CA in OCI
 	TenancyID     string `json:"tenancy_id,omitempty"`
 	CompartmentID string `json:"compartment_id"`
-	AuthorityID   string `json:"authority_id"`
+
+	// AuthorityID is the OCID of the certificate authority. It must be empty
+	// when Authorities is set.
+	// +optional
+	AuthorityID string `json:"authority_id,omitempty"`
+
+	// Authorities lists the certificate authorities the issuer signs with,
+	// so that issuance fails over when one is disabled or its region is
+	// unavailable. Each is validated with the issuer, and only those found
+	// active are used.
+	// +optional
+	Authorities []CertificateAuthority `json:"authorities,omitempty"`
+
+	// AuthoritySelection chooses which available authority signs each
+	// request. Defaults to Priority.
+	// +optional
+	AuthoritySelection AuthoritySelection `json:"authoritySelection,omitempty"`
+
+	// Auth configures how the issuer authenticates to OCI. Defaults to the
+	// default auth of the controller, the DEFAULT profile of its OCI config
+	// file unless configured otherwise.
+	// +optional
+	Auth *OCIAuth `json:"auth,omitempty"`
+
+	// Region is the OCI region of the certificate authority, for example
+	// us-phoenix-1. Defaults to the region encoded in the certificate
+	// authority OCID, whatever region the credentials are configured for.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// RealmDomain is the domain of the OCI realm, for example
+	// oraclegovcloud.com. Only needed for realms the controller does not know,
+	// such as dedicated region realms.
+	// +optional
+	RealmDomain string `json:"realmDomain,omitempty"`
+
+	// EndpointOverride replaces the OCI service endpoints derived from the
+	// region and realm.
+	// +optional
+	EndpointOverride *EndpointOverride `json:"endpointOverride,omitempty"`
+
+	// Transport configures the proxy and additional trust used to reach OCI.
+	// +optional
+	Transport *OCITransport `json:"transport,omitempty"`
+
+	// SubordinateCA controls whether CertificateRequests with isCA set are
+	// signed. They are refused by default.
+	// +optional
+	SubordinateCA *SubordinateCAPolicy `json:"subordinateCA,omitempty"`
+
+	// Validity controls how requested durations are fitted to the rules and
+	// expiry of the certificate authority.
+	// +optional
+	Validity *ValidityPolicy `json:"validity,omitempty"`
+
+	// Policy restricts the names, subjects and durations the issuer signs.
+	// Everything is signed when it is not set.
+	// +optional
+	Policy *IssuancePolicy `json:"policy,omitempty"`
+
+	// NamespaceSelector restricts the namespaces whose CertificateRequests the
+	// issuer signs. Requests from other namespaces are denied. All namespaces
+	// are selected when it is not set.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// NamespaceOverrides adjust the issuer for requests from some namespaces.
+	// The first override whose selector matches the namespace applies.
+	// +optional
+	NamespaceOverrides []NamespaceOverride `json:"namespaceOverrides,omitempty"`
+
+	// Compartments routes certificates to compartments by namespace.
+	// Compartments set by NamespaceOverrides take precedence. All
+	// certificates are created in compartment_id when it is not set.
+	// +optional
+	Compartments *CompartmentRouting `json:"compartments,omitempty"`
+
+	// NameTemplate is a Go template rendering the names of OCI certificates,
+	// which must be unique within a compartment. It is executed with the
+	// fields ClusterID, Kind, Namespace, Name, Certificate and Issuer.
+	// Defaults to the cluster ID, kind, namespace and name joined with
+	// underscores.
+	// +optional
+	NameTemplate string `json:"nameTemplate,omitempty"`
+
+	// DescriptionTemplate is a Go template rendering the descriptions of OCI
+	// certificates, executed with the same fields as NameTemplate.
+	// +optional
+	DescriptionTemplate string `json:"descriptionTemplate,omitempty"`
+
+	// DefaultDuration is the validity of certificates whose request does not
+	// ask for a duration. Defaults to 7 days.
+	// +optional
+	DefaultDuration *metav1.Duration `json:"defaultDuration,omitempty"`
+
+	// TrustBundle is the ConfigMap in the cluster resource namespace the
+	// root certificates of the certificate authorities clients should trust
+	// are written to, for example for trust-manager to distribute. The key
+	// defaults to ca.crt.
+	// +optional
+	TrustBundle *KeySelector `json:"trustBundle,omitempty"`
+
+	// Rotation moves the issuer to a new certificate authority in phases.
+	// It requires TrustBundle.
+	// +optional
+	Rotation *CARotation `json:"rotation,omitempty"`
+
+	// Mode is how CertificateRequests are handled. Normal signs them, DryRun
+	// only checks them and Shadow also signs them with Shadow to compare,
+	// creating a second OCI certificate for every request, which is
+	// scheduled for deletion once signed. Defaults to Normal.
+	// +optional
+	Mode IssuerMode `json:"mode,omitempty"`
+
+	// Shadow is the issuer certificates are compared with in Shadow mode.
+	// +optional
+	Shadow *ShadowIssuer `json:"shadow,omitempty"`
+
+	// MaintenanceWindows are when issuance is paused. Requests whose
+	// existing certificate expires before the window closes are still
+	// signed.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
 }
 
 // OCICAClusterIssuerStatus defines the observed state of OCICAClusterIssuer
@@ -62,9 +680,75 @@ type OCICAClusterIssuerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Authorities is the health of each certificate authority found by the
+	// last validation.
+	// +optional
+	Authorities []AuthorityStatus `json:"authorities,omitempty"`
+
+	// Rotation is the progress of the rotation to a new certificate
+	// authority.
+	// +optional
+	Rotation *CARotationStatus `json:"rotation,omitempty"`
+}
+
+// CARotationStatus is the progress of the rotation to a new certificate
+// authority.
+type CARotationStatus struct {
+	// AuthorityID is the OCID of the certificate authority the issuer
+	// rotates to.
+	AuthorityID string `json:"authorityID"`
+
+	// Phase is the current phase of the rotation.
+	Phase CARotationPhase `json:"phase"`
+
+	// NextPhaseTime is when the rotation enters its next phase.
+	// +optional
+	NextPhaseTime *metav1.Time `json:"nextPhaseTime,omitempty"`
+}
+
+// AuthorityStatus is the health of a certificate authority of an issuer.
+type AuthorityStatus struct {
+	// AuthorityID is the OCID of the certificate authority.
+	AuthorityID string `json:"authorityID"`
+
+	// Ready is set when the authority is active and may sign requests.
+	Ready bool `json:"ready"`
+
+	// Message is why the authority is unavailable.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CertificateAuthorities returns the certificate authorities of spec, with
+// their compartment and region defaulted. It returns authority_id in
+// compartment_id and region when spec lists no authorities.
+func (spec OCICAClusterIssuerSpec) CertificateAuthorities() []CertificateAuthority {
+	if len(spec.Authorities) == 0 {
+		return []CertificateAuthority{{
+			AuthorityID:   spec.AuthorityID,
+			CompartmentID: spec.CompartmentID,
+			Region:        spec.Region,
+			Weight:        1,
+		}}
+	}
+	authorities := make([]CertificateAuthority, len(spec.Authorities))
+	for i, a := range spec.Authorities {
+		if a.CompartmentID == "" {
+			a.CompartmentID = spec.CompartmentID
+		}
+		if a.Region == "" {
+			if authority, err := ocid.ParseType(a.AuthorityID, ocid.CertificateAuthority); err == nil && authority.IsRegional() {
+				a.Region = authority.Region
+			}
+		}
+		authorities[i] = a
+	}
+	return authorities
 }
 
 //+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
 //+kubebuilder:subresource:status
 
 // OCICAClusterIssuer is the Schema for the ocicaclusterissuers API
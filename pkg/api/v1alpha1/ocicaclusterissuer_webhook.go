@@ -0,0 +1,566 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/william20111/oci-privateca-issuer/pkg/naming"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
+	"github.com/william20111/oci-privateca-issuer/pkg/schedule"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net"
+	"net/url"
+	"regexp"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sort"
+	"time"
+)
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks
+// for OCICAClusterIssuer with the manager's webhook server.
+func (r *OCICAClusterIssuer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-ocica-cert-manager-io-v1alpha1-ocicaclusterissuer,mutating=true,failurePolicy=fail,sideEffects=None,groups=ocica.cert-manager.io,resources=ocicaclusterissuers,verbs=create;update,versions=v1alpha1,name=mocicaclusterissuer.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &OCICAClusterIssuer{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+//
+// Issuers without auth are left without it, so that they use the default auth
+// configured for the controller.
+func (r *OCICAClusterIssuer) Default() {
+	if r.Spec.Auth != nil {
+		if r.Spec.Auth.Mode == "" {
+			r.Spec.Auth.Mode = AuthModeConfigFile
+		}
+		if r.Spec.Auth.Mode == AuthModeConfigFile && r.Spec.Auth.Profile == "" {
+			r.Spec.Auth.Profile = DefaultAuthProfile
+		}
+	}
+	if r.Spec.DefaultDuration == nil {
+		r.Spec.DefaultDuration = &metav1.Duration{Duration: DefaultCertificateDuration}
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-ocica-cert-manager-io-v1alpha1-ocicaclusterissuer,mutating=false,failurePolicy=fail,sideEffects=None,groups=ocica.cert-manager.io,resources=ocicaclusterissuers,verbs=create;update,versions=v1alpha1,name=vocicaclusterissuer.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &OCICAClusterIssuer{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *OCICAClusterIssuer) ValidateCreate() error {
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *OCICAClusterIssuer) ValidateUpdate(old runtime.Object) error {
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *OCICAClusterIssuer) ValidateDelete() error {
+	return nil
+}
+
+func (r *OCICAClusterIssuer) validate() error {
+	errs := ValidateSpec(&r.Spec, field.NewPath("spec"))
+	if r.Spec.Shadow != nil && r.Spec.Shadow.Name == r.Name {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "shadow", "name"), r.Spec.Shadow.Name, "must not be the issuer itself"))
+	}
+	if value, ok := r.Annotations[PausedAnnotation]; ok {
+		if _, _, err := ParsePausedAnnotation(value); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("metadata", "annotations").Key(PausedAnnotation), value, err.Error()))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("OCICAClusterIssuer").GroupKind(), r.Name, errs)
+}
+
+// ValidateSpec checks an issuer spec for malformed OCIDs, endpoints that
+// cannot reach the certificate authority, unknown auth modes, invalid
+// durations and templates.
+func ValidateSpec(spec *OCICAClusterIssuerSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	var authorities []ocid.OCID
+	if len(spec.Authorities) == 0 {
+		authority, authorityErrs := validateAuthority(spec.AuthorityID, spec.Region, path.Child("authority_id"), path.Child("region"))
+		errs = append(errs, authorityErrs...)
+		if authority != nil {
+			authorities = append(authorities, *authority)
+		}
+	} else {
+		if spec.AuthorityID != "" {
+			errs = append(errs, field.Forbidden(path.Child("authority_id"), "must be empty when authorities is set"))
+		}
+		if spec.Region != "" {
+			errs = append(errs, field.Forbidden(path.Child("region"), "set the region of each authority instead"))
+		}
+		seen := map[string]bool{}
+		for i, a := range spec.Authorities {
+			authorityPath := path.Child("authorities").Index(i)
+			authority, authorityErrs := validateAuthority(a.AuthorityID, a.Region, authorityPath.Child("authorityID"), authorityPath.Child("region"))
+			errs = append(errs, authorityErrs...)
+			if seen[a.AuthorityID] {
+				errs = append(errs, field.Duplicate(authorityPath.Child("authorityID"), a.AuthorityID))
+			}
+			seen[a.AuthorityID] = true
+			if a.CompartmentID != "" {
+				errs = append(errs, validateCompartment(a.CompartmentID, authority, authorityPath.Child("compartmentID"))...)
+			} else if _, err := ocid.ParseType(spec.CompartmentID, ocid.Compartment, ocid.Tenancy); err == nil && authority != nil {
+				// Malformed compartments are reported once below.
+				errs = append(errs, validateCompartment(spec.CompartmentID, authority, path.Child("compartment_id"))...)
+			}
+			if a.Weight < 0 {
+				errs = append(errs, field.Invalid(authorityPath.Child("weight"), a.Weight, "must not be negative"))
+			}
+			if authority != nil {
+				authorities = append(authorities, *authority)
+			}
+		}
+	}
+	switch spec.AuthoritySelection {
+	case "", AuthoritySelectionPriority, AuthoritySelectionWeighted, AuthoritySelectionRoundRobin:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("authoritySelection"), spec.AuthoritySelection,
+			[]string{string(AuthoritySelectionPriority), string(AuthoritySelectionWeighted), string(AuthoritySelectionRoundRobin)}))
+	}
+	// Compartments shared by all authorities, such as those routed by
+	// namespace, are checked against the first valid one.
+	var authorityRef *ocid.OCID
+	if len(authorities) > 0 {
+		authorityRef = &authorities[0]
+	}
+	if spec.Rotation != nil {
+		rotationAuthority, rotationErrs := validateRotation(spec, path)
+		errs = append(errs, rotationErrs...)
+		if rotationAuthority != nil {
+			authorities = append(authorities, *rotationAuthority)
+		}
+	}
+	if spec.Rotation != nil && spec.TrustBundle == nil {
+		errs = append(errs, field.Required(path.Child("trustBundle"), "required to distribute the certificate authority of the rotation"))
+	}
+	if spec.TrustBundle != nil && spec.TrustBundle.Name == "" {
+		errs = append(errs, field.Required(path.Child("trustBundle", "name"), ""))
+	}
+
+	compartmentAuthority := authorityRef
+	if len(spec.Authorities) > 0 {
+		// Authorities that create certificates in compartment_id checked it
+		// against their own region.
+		compartmentAuthority = nil
+	}
+	errs = append(errs, validateCompartment(spec.CompartmentID, compartmentAuthority, path.Child("compartment_id"))...)
+	if spec.TenancyID != "" {
+		tenancy, err := ocid.ParseType(spec.TenancyID, ocid.Tenancy)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child("tenancy_id"), spec.TenancyID, err.Error()))
+		} else {
+			for _, authority := range authorities {
+				if authority.Realm != tenancy.Realm {
+					errs = append(errs, field.Invalid(path.Child("tenancy_id"), spec.TenancyID,
+						fmt.Sprintf("realm %q does not match the certificate authority realm %q", tenancy.Realm, authority.Realm)))
+					break
+				}
+			}
+		}
+	}
+
+	if spec.RealmDomain != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.RealmDomain) {
+			errs = append(errs, field.Invalid(path.Child("realmDomain"), spec.RealmDomain, msg))
+		}
+	}
+	if spec.EndpointOverride != nil {
+		overridePath := path.Child("endpointOverride")
+		errs = append(errs, validateEndpoint(overridePath.Child("certificatesManagement"), spec.EndpointOverride.CertificatesManagement)...)
+		errs = append(errs, validateEndpoint(overridePath.Child("certificates"), spec.EndpointOverride.Certificates)...)
+	}
+
+	if spec.Transport != nil {
+		errs = append(errs, validateTransport(spec.Transport, path.Child("transport"))...)
+	}
+
+	if spec.Auth != nil {
+		errs = append(errs, ValidateAuth(spec.Auth, path.Child("auth"))...)
+	}
+
+	if spec.SubordinateCA != nil && spec.SubordinateCA.MaxPathLength != nil && *spec.SubordinateCA.MaxPathLength < 0 {
+		errs = append(errs, field.Invalid(path.Child("subordinateCA", "maxPathLength"), *spec.SubordinateCA.MaxPathLength, "must not be negative"))
+	}
+
+	if spec.DefaultDuration != nil && spec.DefaultDuration.Duration < MinimumCertificateDuration {
+		errs = append(errs, field.Invalid(path.Child("defaultDuration"), spec.DefaultDuration.Duration.String(),
+			fmt.Sprintf("must be at least %s", MinimumCertificateDuration)))
+	}
+
+	if spec.Validity != nil {
+		errs = append(errs, validateValidity(spec.Validity, spec.DefaultDuration, path.Child("validity"))...)
+	}
+
+	if spec.Policy != nil {
+		errs = append(errs, validatePolicy(spec.Policy, spec.DefaultDuration, path.Child("policy"))...)
+	}
+
+	if spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(path.Child("namespaceSelector"), spec.NamespaceSelector, err.Error()))
+		}
+	}
+	for i, override := range spec.NamespaceOverrides {
+		overridePath := path.Child("namespaceOverrides").Index(i)
+		if _, err := metav1.LabelSelectorAsSelector(&override.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(overridePath.Child("namespaceSelector"), override.NamespaceSelector, err.Error()))
+		}
+		if override.Policy != nil {
+			errs = append(errs, validatePolicy(override.Policy, spec.DefaultDuration, overridePath.Child("policy"))...)
+		}
+		if override.CompartmentID != "" {
+			errs = append(errs, validateCompartment(override.CompartmentID, authorityRef, overridePath.Child("compartmentID"))...)
+		}
+	}
+	if spec.Compartments != nil {
+		errs = append(errs, validateCompartmentRouting(spec.Compartments, authorityRef, path.Child("compartments"))...)
+	}
+
+	switch spec.Mode {
+	case "", IssuerModeNormal, IssuerModeDryRun:
+		if spec.Shadow != nil {
+			errs = append(errs, field.Forbidden(path.Child("shadow"), "only used when mode is Shadow"))
+		}
+	case IssuerModeShadow:
+		if spec.Shadow == nil {
+			errs = append(errs, field.Required(path.Child("shadow"), "required when mode is Shadow"))
+		} else if spec.Shadow.Name == "" {
+			errs = append(errs, field.Required(path.Child("shadow", "name"), ""))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("mode"), spec.Mode,
+			[]string{string(IssuerModeNormal), string(IssuerModeDryRun), string(IssuerModeShadow)}))
+	}
+
+	for i, window := range spec.MaintenanceWindows {
+		errs = append(errs, validateMaintenanceWindow(window, path.Child("maintenanceWindows").Index(i))...)
+	}
+
+	if _, err := naming.Parse(spec.NameTemplate, ""); err != nil {
+		errs = append(errs, field.Invalid(path.Child("nameTemplate"), spec.NameTemplate, err.Error()))
+	}
+	if _, err := naming.Parse("", spec.DescriptionTemplate); err != nil {
+		errs = append(errs, field.Invalid(path.Child("descriptionTemplate"), spec.DescriptionTemplate, err.Error()))
+	}
+
+	return errs
+}
+
+// validateAuthority checks that id is a regional certificate authority OCID
+// in region, when region is set. It returns the parsed OCID unless it is
+// malformed.
+func validateAuthority(id, region string, idPath, regionPath *field.Path) (*ocid.OCID, field.ErrorList) {
+	authority, err := ocid.ParseType(id, ocid.CertificateAuthority)
+	if err != nil {
+		return nil, field.ErrorList{field.Invalid(idPath, id, err.Error())}
+	}
+	if !authority.IsRegional() {
+		return &authority, field.ErrorList{field.Invalid(idPath, id, "certificate authority OCID has no region")}
+	}
+	if region != "" && common.StringToRegion(region) != common.StringToRegion(authority.Region) {
+		return &authority, field.ErrorList{field.Invalid(regionPath, region,
+			fmt.Sprintf("does not match the certificate authority region %q", authority.Region))}
+	}
+	return &authority, nil
+}
+
+// validateRotation checks that the rotation names a certificate authority the
+// issuer does not already use and that its phases are in order. It returns the parsed OCID
+// of the new authority unless it is malformed.
+func validateRotation(spec *OCICAClusterIssuerSpec, specPath *field.Path) (*ocid.OCID, field.ErrorList) {
+	rotation := spec.Rotation
+	path := specPath.Child("rotation")
+	authority, errs := validateAuthority(rotation.AuthorityID, rotation.Region, path.Child("authorityID"), path.Child("region"))
+	for _, ca := range spec.CertificateAuthorities() {
+		if ca.AuthorityID == rotation.AuthorityID {
+			errs = append(errs, field.Invalid(path.Child("authorityID"), rotation.AuthorityID, "is already a certificate authority of the issuer"))
+			break
+		}
+	}
+	if rotation.CompartmentID != "" {
+		errs = append(errs, validateCompartment(rotation.CompartmentID, authority, path.Child("compartmentID"))...)
+	} else if _, err := ocid.ParseType(spec.CompartmentID, ocid.Compartment, ocid.Tenancy); err == nil && authority != nil {
+		errs = append(errs, validateCompartment(spec.CompartmentID, authority, specPath.Child("compartment_id"))...)
+	}
+
+	schedulePath := path.Child("schedule")
+	schedule := rotation.Schedule
+	if schedule.SignAt.IsZero() {
+		errs = append(errs, field.Required(schedulePath.Child("signAt"), ""))
+	}
+	if schedule.RetireAt.IsZero() {
+		errs = append(errs, field.Required(schedulePath.Child("retireAt"), ""))
+	}
+	if schedule.DistributeAt != nil && schedule.SignAt.Before(schedule.DistributeAt) {
+		errs = append(errs, field.Invalid(schedulePath.Child("signAt"), schedule.SignAt.UTC().Format(time.RFC3339), "must not be before distributeAt"))
+	}
+	if !schedule.SignAt.IsZero() && !schedule.RetireAt.IsZero() && !schedule.SignAt.Before(&schedule.RetireAt) {
+		errs = append(errs, field.Invalid(schedulePath.Child("retireAt"), schedule.RetireAt.UTC().Format(time.RFC3339), "must be after signAt"))
+	}
+	return authority, errs
+}
+
+func validateCompartmentRouting(routing *CompartmentRouting, authority *ocid.OCID, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	namespaces := make([]string, 0, len(routing.Namespaces))
+	for namespace := range routing.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		namespacePath := path.Child("namespaces").Key(namespace)
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(namespacePath, namespace, msg))
+		}
+		errs = append(errs, validateCompartment(routing.Namespaces[namespace], authority, namespacePath)...)
+	}
+	if routing.Default != "" {
+		errs = append(errs, validateCompartment(routing.Default, authority, path.Child("default"))...)
+	}
+	return errs
+}
+
+// ValidateCompartmentAnnotation checks a compartment chosen with the
+// NamespaceCompartmentAnnotation of a namespace as the webhook checks those
+// of the issuer with certificate authority authorityID.
+func ValidateCompartmentAnnotation(id, authorityID string) error {
+	var authority *ocid.OCID
+	if parsed, err := ocid.ParseType(authorityID, ocid.CertificateAuthority); err == nil {
+		authority = &parsed
+	}
+	path := field.NewPath("metadata", "annotations").Key(NamespaceCompartmentAnnotation)
+	return validateCompartment(id, authority, path).ToAggregate()
+}
+
+// ValidateAuth checks the auth configuration of an issuer, or the default the
+// controller uses for issuers without one.
+func ValidateAuth(auth *OCIAuth, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch auth.Mode {
+	case "", AuthModeConfigFile, AuthModeInstancePrincipal:
+	case AuthModeAPIKey:
+		if auth.SecretName == "" {
+			errs = append(errs, field.Required(path.Child("secretName"), "required when mode is APIKey"))
+		}
+	default:
+		errs = append(errs, field.NotSupported(path.Child("mode"), auth.Mode,
+			[]string{string(AuthModeConfigFile), string(AuthModeInstancePrincipal), string(AuthModeAPIKey)}))
+	}
+	if auth.Mode != AuthModeAPIKey && auth.SecretName != "" {
+		errs = append(errs, field.Forbidden(path.Child("secretName"), "only used when mode is APIKey"))
+	}
+	return errs
+}
+
+// ParsePausedAnnotation parses the value of the PausedAnnotation of an
+// issuer. It returns whether issuance is paused and, unless the pause lasts
+// until the annotation is removed, when it resumes.
+func ParsePausedAnnotation(value string) (bool, time.Time, error) {
+	switch value {
+	case "", "false":
+		return false, time.Time{}, nil
+	case "true":
+		return true, time.Time{}, nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("must be true, false or an RFC 3339 time")
+	}
+	return true, until, nil
+}
+
+// maxMaintenanceWindow is the longest a maintenance window may last, so that
+// windows cannot pause issuance for good.
+const maxMaintenanceWindow = 7 * 24 * time.Hour
+
+func validateMaintenanceWindow(window MaintenanceWindow, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if window.Schedule == "" {
+		errs = append(errs, field.Required(path.Child("schedule"), ""))
+	} else if _, err := schedule.Parse(window.Schedule); err != nil {
+		errs = append(errs, field.Invalid(path.Child("schedule"), window.Schedule, err.Error()))
+	}
+	if window.Duration.Duration <= 0 || window.Duration.Duration > maxMaintenanceWindow {
+		errs = append(errs, field.Invalid(path.Child("duration"), window.Duration.Duration.String(),
+			fmt.Sprintf("must be positive and at most %s", maxMaintenanceWindow)))
+	}
+	if window.TimeZone != "" {
+		if _, err := time.LoadLocation(window.TimeZone); err != nil {
+			errs = append(errs, field.Invalid(path.Child("timeZone"), window.TimeZone, err.Error()))
+		}
+	}
+	return errs
+}
+
+// validateCompartment checks that id is a compartment or tenancy OCID in the
+// realm and region of authority, when authority is known.
+func validateCompartment(id string, authority *ocid.OCID, path *field.Path) field.ErrorList {
+	compartment, err := ocid.ParseType(id, ocid.Compartment, ocid.Tenancy)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, id, err.Error())}
+	}
+	if authority == nil {
+		return nil
+	}
+	if authority.Realm != compartment.Realm {
+		return field.ErrorList{field.Invalid(path, id,
+			fmt.Sprintf("realm %q does not match the certificate authority realm %q", compartment.Realm, authority.Realm))}
+	}
+	if !authority.SameRegion(compartment) {
+		return field.ErrorList{field.Invalid(path, id,
+			fmt.Sprintf("region %q does not match the certificate authority region %q", compartment.Region, authority.Region))}
+	}
+	return nil
+}
+
+func validateEndpoint(path *field.Path, endpoint string) field.ErrorList {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, endpoint, err.Error())}
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return field.ErrorList{field.Invalid(path, endpoint, "must be an http or https URL")}
+	}
+	return nil
+}
+
+func validateTransport(transport *OCITransport, path *field.Path) field.ErrorList {
+	errs := validateEndpoint(path.Child("proxyURL"), transport.ProxyURL)
+	if src := transport.CABundle; src != nil {
+		bundlePath := path.Child("caBundle")
+		switch {
+		case src.ConfigMap != nil && src.Secret != nil:
+			errs = append(errs, field.Forbidden(bundlePath, "only one of configMap or secret may be set"))
+		case src.ConfigMap != nil:
+			if src.ConfigMap.Name == "" {
+				errs = append(errs, field.Required(bundlePath.Child("configMap", "name"), ""))
+			}
+		case src.Secret != nil:
+			if src.Secret.Name == "" {
+				errs = append(errs, field.Required(bundlePath.Child("secret", "name"), ""))
+			}
+		default:
+			errs = append(errs, field.Required(bundlePath, "one of configMap or secret must be set"))
+		}
+	}
+	return errs
+}
+
+func validateValidity(validity *ValidityPolicy, defaultDuration *metav1.Duration, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch validity.OnExceeded {
+	case "", ValidityExceededClamp, ValidityExceededReject:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("onExceeded"), validity.OnExceeded,
+			[]string{string(ValidityExceededClamp), string(ValidityExceededReject)}))
+	}
+	if validity.Backdate != nil && validity.Backdate.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("backdate"), validity.Backdate.Duration.String(), "must not be negative"))
+	}
+	if validity.MinDuration != nil {
+		if validity.MinDuration.Duration < 0 {
+			errs = append(errs, field.Invalid(path.Child("minDuration"), validity.MinDuration.Duration.String(), "must not be negative"))
+		} else if defaultDuration != nil && validity.MinDuration.Duration > defaultDuration.Duration {
+			errs = append(errs, field.Invalid(path.Child("minDuration"), validity.MinDuration.Duration.String(),
+				fmt.Sprintf("must not exceed defaultDuration %s", defaultDuration.Duration)))
+		}
+	}
+	return errs
+}
+
+func validatePolicy(policy *IssuancePolicy, defaultDuration *metav1.Duration, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	errs = append(errs, validateNamePolicy(policy.DNSNames, path.Child("dnsNames"))...)
+	errs = append(errs, validateNamePolicy(policy.URIs, path.Child("uris"))...)
+	errs = append(errs, validateNamePolicy(policy.EmailAddresses, path.Child("emailAddresses"))...)
+	if policy.IPAddresses != nil {
+		for i, cidr := range policy.IPAddresses.Allow {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				errs = append(errs, field.Invalid(path.Child("ipAddresses", "allow").Index(i), cidr, err.Error()))
+			}
+		}
+		for i, cidr := range policy.IPAddresses.Deny {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				errs = append(errs, field.Invalid(path.Child("ipAddresses", "deny").Index(i), cidr, err.Error()))
+			}
+		}
+	}
+	if subject := policy.Subject; subject != nil {
+		path := path.Child("subject")
+		errs = append(errs, validateNamePolicy(subject.CommonName, path.Child("commonName"))...)
+		errs = append(errs, validateNamePolicy(subject.Organizations, path.Child("organizations"))...)
+		errs = append(errs, validateNamePolicy(subject.OrganizationalUnits, path.Child("organizationalUnits"))...)
+		errs = append(errs, validateNamePolicy(subject.Countries, path.Child("countries"))...)
+		errs = append(errs, validateNamePolicy(subject.Localities, path.Child("localities"))...)
+		errs = append(errs, validateNamePolicy(subject.Provinces, path.Child("provinces"))...)
+	}
+	if policy.MaxDuration != nil {
+		if policy.MaxDuration.Duration < MinimumCertificateDuration {
+			errs = append(errs, field.Invalid(path.Child("maxDuration"), policy.MaxDuration.Duration.String(),
+				fmt.Sprintf("must be at least %s", MinimumCertificateDuration)))
+		}
+		defaultDuration := defaultDuration
+		if defaultDuration == nil {
+			defaultDuration = &metav1.Duration{Duration: DefaultCertificateDuration}
+		}
+		if defaultDuration.Duration > policy.MaxDuration.Duration {
+			errs = append(errs, field.Invalid(path.Child("maxDuration"), policy.MaxDuration.Duration.String(),
+				fmt.Sprintf("must not be shorter than the default duration %s", defaultDuration.Duration)))
+		}
+	}
+	return errs
+}
+
+func validateNamePolicy(policy *NamePolicy, path *field.Path) field.ErrorList {
+	if policy == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	for i, pattern := range policy.AllowRegex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, field.Invalid(path.Child("allowRegex").Index(i), pattern, err.Error()))
+		}
+	}
+	for i, pattern := range policy.DenyRegex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, field.Invalid(path.Child("denyRegex").Index(i), pattern, err.Error()))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,620 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testTenancyID     = "ocid1.tenancy.oc1..aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+	testCompartmentID = "ocid1.compartment.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+	testAuthorityID   = "ocid1.certificateauthority.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+
+	testAshburnAuthorityID = "ocid1.certificateauthority.oc1.iad.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+)
+
+func TestOCICAClusterIssuer_Default(t *testing.T) {
+	tests := []struct {
+		name string
+		spec OCICAClusterIssuerSpec
+		want OCICAClusterIssuerSpec
+	}{
+		{
+			name: "empty optional fields",
+			spec: OCICAClusterIssuerSpec{},
+			want: OCICAClusterIssuerSpec{
+				DefaultDuration: &metav1.Duration{Duration: DefaultCertificateDuration},
+			},
+		},
+		{
+			name: "auth without mode",
+			spec: OCICAClusterIssuerSpec{Auth: &OCIAuth{}},
+			want: OCICAClusterIssuerSpec{
+				Auth:            &OCIAuth{Mode: AuthModeConfigFile, Profile: DefaultAuthProfile},
+				DefaultDuration: &metav1.Duration{Duration: DefaultCertificateDuration},
+			},
+		},
+		{
+			name: "existing values are kept",
+			spec: OCICAClusterIssuerSpec{
+				Auth:            &OCIAuth{Mode: AuthModeAPIKey, SecretName: "oci"},
+				DefaultDuration: &metav1.Duration{Duration: time.Hour},
+			},
+			want: OCICAClusterIssuerSpec{
+				Auth:            &OCIAuth{Mode: AuthModeAPIKey, SecretName: "oci"},
+				DefaultDuration: &metav1.Duration{Duration: time.Hour},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss := &OCICAClusterIssuer{Spec: tt.spec}
+			iss.Default()
+			if !reflect.DeepEqual(iss.Spec, tt.want) {
+				t.Errorf("Default() got = %+v, want %+v", iss.Spec, tt.want)
+			}
+		})
+	}
+}
+
+func TestOCICAClusterIssuer_ValidateCreate(t *testing.T) {
+	valid := func() OCICAClusterIssuerSpec {
+		return OCICAClusterIssuerSpec{
+			TenancyID:     testTenancyID,
+			CompartmentID: testCompartmentID,
+			AuthorityID:   testAuthorityID,
+		}
+	}
+	tests := []struct {
+		name    string
+		mutate  func(spec *OCICAClusterIssuerSpec)
+		wantErr bool
+	}{
+		{
+			name:    "valid spec",
+			mutate:  func(spec *OCICAClusterIssuerSpec) {},
+			wantErr: false,
+		},
+		{
+			name: "tenancy as root compartment",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.CompartmentID = testTenancyID
+			},
+			wantErr: false,
+		},
+		{
+			name: "authority is not a certificate authority",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = testCompartmentID
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed authority",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = "test"
+			},
+			wantErr: true,
+		},
+		{
+			name: "realm mismatch",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.CompartmentID = "ocid1.compartment.oc2..aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+			},
+			wantErr: true,
+		},
+		{
+			name: "region mismatch",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.CompartmentID = "ocid1.compartment.oc1.iad.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+			},
+			wantErr: true,
+		},
+		{
+			name: "tenancy is not a tenancy",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.TenancyID = testCompartmentID
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown auth mode",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Auth = &OCIAuth{Mode: "Magic"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "api key without secret",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Auth = &OCIAuth{Mode: AuthModeAPIKey}
+			},
+			wantErr: true,
+		},
+		{
+			name: "region matches authority",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Region = "us-phoenix-1"
+			},
+			wantErr: false,
+		},
+		{
+			name: "region does not match authority",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Region = "us-ashburn-1"
+			},
+			wantErr: true,
+		},
+		{
+			name: "authorities",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = ""
+				spec.CompartmentID = testTenancyID
+				spec.Authorities = []CertificateAuthority{
+					{AuthorityID: testAuthorityID, CompartmentID: testCompartmentID, Weight: 3},
+					{AuthorityID: testAshburnAuthorityID, Region: "us-ashburn-1", Priority: 1},
+				}
+				spec.AuthoritySelection = AuthoritySelectionWeighted
+			},
+		},
+		{
+			name: "authorities and authority_id",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Authorities = []CertificateAuthority{{AuthorityID: testAuthorityID}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate authority",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = ""
+				spec.Authorities = []CertificateAuthority{{AuthorityID: testAuthorityID}, {AuthorityID: testAuthorityID, Priority: 1}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "authority region mismatch",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = ""
+				spec.Authorities = []CertificateAuthority{{AuthorityID: testAuthorityID, Region: "us-ashburn-1"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "authority inherits compartment in another region",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = ""
+				spec.Authorities = []CertificateAuthority{{AuthorityID: testAuthorityID}, {AuthorityID: testAshburnAuthorityID}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative authority weight",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthorityID = ""
+				spec.Authorities = []CertificateAuthority{{AuthorityID: testAuthorityID, Weight: -1}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown authority selection",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.AuthoritySelection = "Random"
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed realm domain",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.RealmDomain = "https://oraclecloud.com"
+			},
+			wantErr: true,
+		},
+		{
+			name: "endpoint override",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.EndpointOverride = &EndpointOverride{Certificates: "http://localhost:8080"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "endpoint override without scheme",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.EndpointOverride = &EndpointOverride{CertificatesManagement: "localhost:8080"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "transport",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Transport = &OCITransport{
+					ProxyURL: "http://proxy.example.com:3128",
+					NoProxy:  []string{"10.0.0.0/8"},
+					CABundle: &CABundleSource{ConfigMap: &KeySelector{Name: "proxy-ca"}},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed proxy URL",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Transport = &OCITransport{ProxyURL: "proxy.example.com:3128"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "CA bundle from configmap and secret",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Transport = &OCITransport{CABundle: &CABundleSource{
+					ConfigMap: &KeySelector{Name: "proxy-ca"},
+					Secret:    &KeySelector{Name: "proxy-ca"},
+				}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty CA bundle source",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Transport = &OCITransport{CABundle: &CABundleSource{}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative subordinate CA path length",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				pathLength := -1
+				spec.SubordinateCA = &SubordinateCAPolicy{Allow: true, MaxPathLength: &pathLength}
+			},
+			wantErr: true,
+		},
+		{
+			name: "duration too short",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.DefaultDuration = &metav1.Duration{Duration: time.Minute}
+			},
+			wantErr: true,
+		},
+		{
+			name: "issuance policy",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Policy = &IssuancePolicy{
+					DNSNames:    &NamePolicy{Allow: []string{"*.example.com"}, DenyRegex: []string{`^admin\.`}},
+					IPAddresses: &CIDRPolicy{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.0/24"}},
+					Subject:     &SubjectPolicy{Organizations: &NamePolicy{Allow: []string{"Example"}}},
+					MaxDuration: &metav1.Duration{Duration: 30 * 24 * time.Hour},
+				}
+			},
+		},
+		{
+			name: "invalid policy regex",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Policy = &IssuancePolicy{Subject: &SubjectPolicy{CommonName: &NamePolicy{AllowRegex: []string{"("}}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid policy CIDR",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Policy = &IssuancePolicy{IPAddresses: &CIDRPolicy{Deny: []string{"10.0.0.1"}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "policy max duration below default duration",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Policy = &IssuancePolicy{MaxDuration: &metav1.Duration{Duration: 24 * time.Hour}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "namespace selector and overrides",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"certificates": "oci"}}
+				spec.NamespaceOverrides = []NamespaceOverride{{
+					NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+					Policy:            &IssuancePolicy{DNSNames: &NamePolicy{Allow: []string{"*.a.example.com"}}},
+					CompartmentID:     testTenancyID,
+				}}
+			},
+		},
+		{
+			name: "invalid namespace selector",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "team", Operator: "Like", Values: []string{"a"}},
+				}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "override compartment is not a compartment",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.NamespaceOverrides = []NamespaceOverride{{CompartmentID: testAuthorityID}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid override policy",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.NamespaceOverrides = []NamespaceOverride{{Policy: &IssuancePolicy{IPAddresses: &CIDRPolicy{Allow: []string{"10/8"}}}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "compartment routing",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Compartments = &CompartmentRouting{
+					Namespaces:          map[string]string{"team-a": testCompartmentID, "team-b": testTenancyID},
+					NamespaceAnnotation: true,
+					Default:             testCompartmentID,
+				}
+			},
+		},
+		{
+			name: "routed namespace is not a namespace name",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Compartments = &CompartmentRouting{Namespaces: map[string]string{"Team_A": testCompartmentID}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "routed compartment in another region",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Compartments = &CompartmentRouting{Namespaces: map[string]string{
+					"team-a": "ocid1.compartment.oc1.iad.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+				}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "default compartment is not a compartment",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Compartments = &CompartmentRouting{Default: testAuthorityID}
+			},
+			wantErr: true,
+		},
+		{
+			name: "name and description templates",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.NameTemplate = "{{ .ClusterID }}.{{ .Namespace }}.{{ .Certificate }}"
+				spec.DescriptionTemplate = "Certificate {{ .Namespace }}/{{ .Certificate }} from {{ .Issuer }}"
+			},
+		},
+		{
+			name: "name template with unknown field",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.NameTemplate = "{{ .Owner }}"
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed description template",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.DescriptionTemplate = "{{ .Name"
+			},
+			wantErr: true,
+		},
+		{
+			name: "validity policy",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.DefaultDuration = &metav1.Duration{Duration: 24 * time.Hour}
+				spec.Validity = &ValidityPolicy{
+					OnExceeded:  ValidityExceededReject,
+					Backdate:    &metav1.Duration{Duration: time.Minute},
+					MinDuration: &metav1.Duration{Duration: 2 * time.Hour},
+				}
+			},
+		},
+		{
+			name: "unknown validity action",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Validity = &ValidityPolicy{OnExceeded: "Extend"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative backdate",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Validity = &ValidityPolicy{Backdate: &metav1.Duration{Duration: -time.Minute}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "minimum duration above default duration",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.DefaultDuration = &metav1.Duration{Duration: 2 * time.Hour}
+				spec.Validity = &ValidityPolicy{MinDuration: &metav1.Duration{Duration: 3 * time.Hour}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "dry run",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Mode = IssuerModeDryRun
+			},
+		},
+		{
+			name: "shadow",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Mode = IssuerModeShadow
+				spec.Shadow = &ShadowIssuer{Name: "candidate"}
+			},
+		},
+		{
+			name: "shadow mode without shadow issuer",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Mode = IssuerModeShadow
+			},
+			wantErr: true,
+		},
+		{
+			name: "shadow issuer outside shadow mode",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Shadow = &ShadowIssuer{Name: "candidate"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "shadow issuer is the issuer",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Mode = IssuerModeShadow
+				spec.Shadow = &ShadowIssuer{Name: "issuer"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown mode",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Mode = "Preview"
+			},
+			wantErr: true,
+		},
+		{
+			name: "rotation",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.TrustBundle = &KeySelector{Name: "ca-bundle"}
+				spec.Rotation = testRotation()
+			},
+		},
+		{
+			name: "rotation without trust bundle",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.Rotation = testRotation()
+			},
+			wantErr: true,
+		},
+		{
+			name: "rotation to the current authority",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.TrustBundle = &KeySelector{Name: "ca-bundle"}
+				spec.Rotation = testRotation()
+				spec.Rotation.AuthorityID = testAuthorityID
+			},
+			wantErr: true,
+		},
+		{
+			name: "rotation to an authority outside the compartment region",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.TrustBundle = &KeySelector{Name: "ca-bundle"}
+				spec.Rotation = testRotation()
+				spec.Rotation.AuthorityID = testAshburnAuthorityID
+			},
+			wantErr: true,
+		},
+		{
+			name: "rotation signing before distribution",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.TrustBundle = &KeySelector{Name: "ca-bundle"}
+				spec.Rotation = testRotation()
+				distributeAt := metav1.NewTime(spec.Rotation.Schedule.SignAt.Add(time.Hour))
+				spec.Rotation.Schedule.DistributeAt = &distributeAt
+			},
+			wantErr: true,
+		},
+		{
+			name: "maintenance windows",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.MaintenanceWindows = []MaintenanceWindow{
+					{Schedule: "0 22 * * SAT", Duration: metav1.Duration{Duration: 4 * time.Hour}, TimeZone: "Europe/London"},
+					{Schedule: "0 0 1 * *", Duration: metav1.Duration{Duration: time.Hour}},
+				}
+			},
+		},
+		{
+			name: "maintenance window with invalid schedule",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.MaintenanceWindows = []MaintenanceWindow{{Schedule: "0 22 * SAT", Duration: metav1.Duration{Duration: time.Hour}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "maintenance window without duration",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.MaintenanceWindows = []MaintenanceWindow{{Schedule: "0 22 * * SAT"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "maintenance window longer than a week",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.MaintenanceWindows = []MaintenanceWindow{{Schedule: "0 22 * * SAT", Duration: metav1.Duration{Duration: 8 * 24 * time.Hour}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "maintenance window in unknown time zone",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.MaintenanceWindows = []MaintenanceWindow{{Schedule: "0 22 * * SAT", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Mars/Olympus"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "rotation retiring before signing",
+			mutate: func(spec *OCICAClusterIssuerSpec) {
+				spec.TrustBundle = &KeySelector{Name: "ca-bundle"}
+				spec.Rotation = testRotation()
+				spec.Rotation.Schedule.RetireAt = spec.Rotation.Schedule.SignAt
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := valid()
+			tt.mutate(&spec)
+			iss := &OCICAClusterIssuer{ObjectMeta: metav1.ObjectMeta{Name: "issuer"}, Spec: spec}
+			if err := iss.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func testRotation() *CARotation {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return &CARotation{
+		AuthorityID: "ocid1.certificateauthority.oc1.phx.aaaaaaaanextv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+		Schedule: CARotationSchedule{
+			DistributeAt: &metav1.Time{Time: start},
+			SignAt:       metav1.NewTime(start.Add(7 * 24 * time.Hour)),
+			RetireAt:     metav1.NewTime(start.Add(30 * 24 * time.Hour)),
+		},
+	}
+}
+
+func TestOCICAClusterIssuer_ValidateCreate_paused(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "true"},
+		{value: "false"},
+		{value: "2026-01-01T06:00:00Z"},
+		{value: "yes", wantErr: true},
+		{value: "2026-01-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			iss := &OCICAClusterIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "issuer", Annotations: map[string]string{PausedAnnotation: tt.value}},
+				Spec:       OCICAClusterIssuerSpec{TenancyID: testTenancyID, CompartmentID: testCompartmentID, AuthorityID: testAuthorityID},
+			}
+			if err := iss.ValidateCreate(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCompartmentAnnotation(t *testing.T) {
+	if err := ValidateCompartmentAnnotation(testCompartmentID, testAuthorityID); err != nil {
+		t.Errorf("ValidateCompartmentAnnotation() error = %v", err)
+	}
+	err := ValidateCompartmentAnnotation(testAuthorityID, testAuthorityID)
+	if err == nil || !strings.Contains(err.Error(), NamespaceCompartmentAnnotation) {
+		t.Errorf("ValidateCompartmentAnnotation() of a non compartment error = %v, want it to name the annotation", err)
+	}
+}
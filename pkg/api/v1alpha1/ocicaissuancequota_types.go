@@ -0,0 +1,110 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+// DefaultQuotaWindow is the window of OCICAIssuanceQuotas that do not set one.
+const DefaultQuotaWindow = time.Hour
+
+// QuotaExceededAction is what happens to requests over an
+// OCICAIssuanceQuota.
+// +kubebuilder:validation:Enum=Requeue;Deny
+type QuotaExceededAction string
+
+const (
+	// QuotaExceededRequeue keeps requests pending until the quota allows
+	// them.
+	QuotaExceededRequeue QuotaExceededAction = "Requeue"
+
+	// QuotaExceededDeny fails requests, leaving cert-manager to retry them
+	// with backoff.
+	QuotaExceededDeny QuotaExceededAction = "Deny"
+)
+
+// OCICAIssuanceQuotaSpec limits the certificates OCICAClusterIssuers issue
+// for CertificateRequests in the namespace of the quota.
+type OCICAIssuanceQuotaSpec struct {
+	// IssuerNames limits the quota to requests for these OCICAClusterIssuers.
+	// Requests for any OCICAClusterIssuer count when empty.
+	// +optional
+	IssuerNames []string `json:"issuerNames,omitempty"`
+
+	// MaxIssuances is the number of certificates issued per window.
+	// Unlimited when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxIssuances *int32 `json:"maxIssuances,omitempty"`
+
+	// Window is the period MaxIssuances applies to. Defaults to 1h.
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty"`
+
+	// MaxLiveCertificates is the number of issued certificates that have not
+	// expired yet. Unlimited when unset.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxLiveCertificates *int32 `json:"maxLiveCertificates,omitempty"`
+
+	// OnExceeded is what happens to requests over the quota. Defaults to
+	// Requeue.
+	// +optional
+	OnExceeded QuotaExceededAction `json:"onExceeded,omitempty"`
+}
+
+// IssuedCertificate is a certificate counted against an OCICAIssuanceQuota.
+type IssuedCertificate struct {
+	// Request is the name of the CertificateRequest the certificate was
+	// issued for.
+	Request string `json:"request"`
+
+	// RequestUID is the UID of the CertificateRequest, which tells it apart
+	// from a later request with the same name.
+	// +optional
+	RequestUID types.UID `json:"requestUID,omitempty"`
+
+	// IssuedAt is when the certificate was issued.
+	IssuedAt metav1.Time `json:"issuedAt"`
+
+	// NotAfter is when the certificate expires.
+	NotAfter metav1.Time `json:"notAfter"`
+}
+
+// OCICAIssuanceQuotaStatus defines the observed state of OCICAIssuanceQuota
+type OCICAIssuanceQuotaStatus struct {
+	// Issued are the certificates counted against the quota, oldest first.
+	// Certificates are dropped once they are out of the window and, when
+	// maxLiveCertificates is set, expired.
+	// +optional
+	Issued []IssuedCertificate `json:"issued,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Max Issuances",type=integer,JSONPath=`.spec.maxIssuances`
+//+kubebuilder:printcolumn:name="Window",type=string,JSONPath=`.spec.window`
+//+kubebuilder:printcolumn:name="Max Live",type=integer,JSONPath=`.spec.maxLiveCertificates`
+
+// OCICAIssuanceQuota is the Schema for the ocicaissuancequotas API
+type OCICAIssuanceQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OCICAIssuanceQuotaSpec   `json:"spec,omitempty"`
+	Status OCICAIssuanceQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OCICAIssuanceQuotaList contains a list of OCICAIssuanceQuota
+type OCICAIssuanceQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OCICAIssuanceQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OCICAIssuanceQuota{}, &OCICAIssuanceQuotaList{})
+}
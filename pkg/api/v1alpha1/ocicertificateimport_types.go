@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// DefaultCertificateImportPollInterval is how often OCICertificateImports that
+// do not set a poll interval are checked for new versions.
+const DefaultCertificateImportPollInterval = 10 * time.Minute
+
+// OCICertificateImportSpec copies an OCI certificate that exists already, such
+// as one issued in the OCI console, into a Secret.
+type OCICertificateImportSpec struct {
+	// IssuerName is the OCICAClusterIssuer whose credentials and region are
+	// used to read the certificate. The certificate must be in the
+	// compartment certificates of the namespace are created in, and the
+	// namespace selected by the issuer.
+	IssuerName string `json:"issuerName"`
+
+	// CertificateID is the OCID of the OCI certificate.
+	CertificateID string `json:"certificateId"`
+
+	// SecretName is the kubernetes.io/tls Secret the current version of the
+	// certificate, its chain and private key are written to, in the
+	// namespace of the OCICertificateImport.
+	SecretName string `json:"secretName"`
+
+	// PollInterval is how often the current version of the certificate is
+	// checked. Defaults to 10m.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// OCICertificateImportStatus defines the observed state of
+// OCICertificateImport
+type OCICertificateImportStatus struct {
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Version is the number of the certificate version in the Secret.
+	// +optional
+	Version int64 `json:"version,omitempty"`
+
+	// NotAfter is when the certificate version in the Secret expires.
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// PrivateKey is set when the Secret holds the private key. It is not
+	// when the credentials of the issuer may not read it, or the
+	// certificate was issued from a CSR and OCI has no key.
+	// +optional
+	PrivateKey bool `json:"privateKey,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretName`
+//+kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.status.version`
+//+kubebuilder:printcolumn:name="Private Key",type=boolean,JSONPath=`.status.privateKey`
+//+kubebuilder:printcolumn:name="Not After",type=date,JSONPath=`.status.notAfter`
+
+// OCICertificateImport is the Schema for the ocicertificateimports API
+type OCICertificateImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OCICertificateImportSpec   `json:"spec,omitempty"`
+	Status OCICertificateImportStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OCICertificateImportList contains a list of OCICertificateImport
+type OCICertificateImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OCICertificateImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OCICertificateImport{}, &OCICertificateImportList{})
+}
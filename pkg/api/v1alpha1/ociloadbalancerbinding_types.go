@@ -0,0 +1,125 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// DefaultLoadBalancerBindingRetryInterval is how long an
+// OCILoadBalancerBinding waits after a listener update failed and was rolled
+// back before trying again.
+const DefaultLoadBalancerBindingRetryInterval = 15 * time.Minute
+
+// ListenerCertificate is the certificate a load balancer listener serves,
+// either certificates of OCI Certificates or a certificate uploaded to the
+// load balancer.
+type ListenerCertificate struct {
+	// CertificateIDs are the OCIDs of OCI Certificates certificates.
+	// +optional
+	CertificateIDs []string `json:"certificateIds,omitempty"`
+
+	// CertificateName is the name of a certificate uploaded to the load
+	// balancer.
+	// +optional
+	CertificateName string `json:"certificateName,omitempty"`
+}
+
+// ListenerUpdate is a listener update whose work request has not finished.
+type ListenerUpdate struct {
+	// WorkRequestID is the OCID of the load balancer work request.
+	WorkRequestID string `json:"workRequestId"`
+
+	// CertificateID and Serial are the OCI certificate and the serial
+	// number of its version the listener is updated to.
+	// +optional
+	CertificateID string `json:"certificateId,omitempty"`
+	// +optional
+	Serial string `json:"serial,omitempty"`
+
+	// Previous is the certificate the listener served before the update,
+	// restored when the update fails.
+	Previous ListenerCertificate `json:"previous"`
+
+	// Rollback is set on the update restoring Previous after an update
+	// failed, and Failure is the error of the failed update.
+	// +optional
+	Rollback bool `json:"rollback,omitempty"`
+	// +optional
+	Failure string `json:"failure,omitempty"`
+}
+
+// OCILoadBalancerBindingSpec attaches the certificate of an exported Secret to
+// a listener of an OCI load balancer.
+type OCILoadBalancerBindingSpec struct {
+	// SecretName is a Secret in the namespace of the binding that is exported
+	// to OCI with the ocica.cert-manager.io/export-to annotation. The
+	// credentials of the issuer it is exported with are used to update the
+	// load balancer.
+	SecretName string `json:"secretName"`
+
+	// LoadBalancerID is the OCID of the load balancer. It must be in the
+	// compartment the certificates of the namespace are created in.
+	LoadBalancerID string `json:"loadBalancerId"`
+
+	// ListenerName is the listener whose certificate is replaced. The
+	// listener must already terminate TLS; its other settings are kept.
+	ListenerName string `json:"listenerName"`
+}
+
+// OCILoadBalancerBindingStatus defines the observed state of
+// OCILoadBalancerBinding
+type OCILoadBalancerBindingStatus struct {
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// CertificateID is the OCID of the OCI certificate the listener serves.
+	// +optional
+	CertificateID string `json:"certificateId,omitempty"`
+
+	// Serial is the serial number of the certificate version the listener
+	// was last updated to.
+	// +optional
+	Serial string `json:"serial,omitempty"`
+
+	// Update is the listener update in progress.
+	// +optional
+	Update *ListenerUpdate `json:"update,omitempty"`
+
+	// LastFailureTime is when updating the listener to the certificate
+	// version with serial number FailedSerial last failed. The update is
+	// tried again after the retry interval, or as soon as the Secret is
+	// exported with another version.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+	// +optional
+	FailedSerial string `json:"failedSerial,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretName`
+//+kubebuilder:printcolumn:name="Listener",type=string,JSONPath=`.spec.listenerName`
+//+kubebuilder:printcolumn:name="Certificate",type=string,JSONPath=`.status.certificateId`,priority=1
+
+// OCILoadBalancerBinding is the Schema for the ociloadbalancerbindings API
+type OCILoadBalancerBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OCILoadBalancerBindingSpec   `json:"spec,omitempty"`
+	Status OCILoadBalancerBindingStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OCILoadBalancerBindingList contains a list of OCILoadBalancerBinding
+type OCILoadBalancerBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OCILoadBalancerBinding `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OCILoadBalancerBinding{}, &OCILoadBalancerBindingList{})
+}
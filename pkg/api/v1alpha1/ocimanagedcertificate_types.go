@@ -0,0 +1,147 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// DefaultManagedCertificatePollInterval is how often OCIManagedCertificates
+// that do not set a poll interval are checked for new versions.
+const DefaultManagedCertificatePollInterval = 10 * time.Minute
+
+// Annotations of the Secrets written for OCIManagedCertificates and
+// OCICertificateImports.
+const (
+	// ManagedCertificateIDAnnotation is the OCID of the OCI certificate.
+	ManagedCertificateIDAnnotation = "ocica.cert-manager.io/certificate-id"
+	// ManagedCertificateVersionAnnotation is the number of the certificate
+	// version in the Secret.
+	ManagedCertificateVersionAnnotation = "ocica.cert-manager.io/certificate-version"
+)
+
+// ManagedCertificateFinalizer is set on OCIManagedCertificates before their
+// OCI certificate is created, and removed once it is scheduled for deletion.
+const ManagedCertificateFinalizer = "ocica.cert-manager.io/certificate-deletion"
+
+// ManagedCertificateProfile is the OCI certificate profile of an
+// OCIManagedCertificate, deciding its extended key usages.
+// +kubebuilder:validation:Enum=TLS_SERVER_OR_CLIENT;TLS_SERVER;TLS_CLIENT
+type ManagedCertificateProfile string
+
+// ManagedCertificateKeyAlgorithm is the algorithm of the key OCI generates
+// for an OCIManagedCertificate.
+// +kubebuilder:validation:Enum=RSA2048;RSA4096;ECDSA_P256;ECDSA_P384
+type ManagedCertificateKeyAlgorithm string
+
+// ManagedCertificateRenewal is the rule OCI renews a certificate by.
+type ManagedCertificateRenewal struct {
+	// Interval is how often OCI issues a new version. OCI renews in whole
+	// days.
+	Interval metav1.Duration `json:"interval"`
+
+	// AdvancePeriod is how long before the interval ends the new version is
+	// issued. OCI renews in whole days.
+	AdvancePeriod metav1.Duration `json:"advancePeriod"`
+}
+
+// OCIManagedCertificateSpec describes a certificate issued by the certificate
+// authority of an OCICAClusterIssuer with a private key generated by OCI.
+// The issuer, Secret, subject, names, profile, key algorithm, duration and
+// renewal are fixed once the OCI certificate is created, and the webhook
+// refuses changes to them.
+type OCIManagedCertificateSpec struct {
+	// IssuerName is the OCICAClusterIssuer whose certificate authority issues
+	// the certificate. Its namespace selector, namespace overrides, policy
+	// and compartment routing apply as they do to CertificateRequests.
+	IssuerName string `json:"issuerName"`
+
+	// SecretName is the kubernetes.io/tls Secret the certificate, its chain
+	// and private key are written to, in the namespace of the
+	// OCIManagedCertificate.
+	SecretName string `json:"secretName"`
+
+	// CommonName is the common name of the subject.
+	CommonName string `json:"commonName"`
+
+	// DNSNames are the DNS subject alternative names.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// IPAddresses are the IP subject alternative names.
+	// +optional
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+
+	// Profile decides the extended key usages of the certificate. Defaults
+	// to TLS_SERVER_OR_CLIENT.
+	// +optional
+	Profile ManagedCertificateProfile `json:"profile,omitempty"`
+
+	// KeyAlgorithm is the algorithm of the private key. Defaults to RSA2048.
+	// +optional
+	KeyAlgorithm ManagedCertificateKeyAlgorithm `json:"keyAlgorithm,omitempty"`
+
+	// Duration is the validity of the first version, fitted to the limits of
+	// the certificate authority like the duration of CertificateRequests.
+	// Defaults to the defaultDuration of the issuer.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Renewal has OCI issue new versions of the certificate on a schedule.
+	// Versions issued by OCI, or by hand in the OCI console, are written to
+	// the Secret when the certificate is next polled.
+	// +optional
+	Renewal *ManagedCertificateRenewal `json:"renewal,omitempty"`
+
+	// PollInterval is how often the current version of the certificate is
+	// checked. Defaults to 10m.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// OCIManagedCertificateStatus defines the observed state of
+// OCIManagedCertificate
+type OCIManagedCertificateStatus struct {
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// CertificateID is the OCID of the OCI certificate.
+	// +optional
+	CertificateID string `json:"certificateId,omitempty"`
+
+	// Version is the number of the certificate version in the Secret.
+	// +optional
+	Version int64 `json:"version,omitempty"`
+
+	// NotAfter is when the certificate version in the Secret expires.
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretName`
+//+kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.status.version`
+//+kubebuilder:printcolumn:name="Not After",type=date,JSONPath=`.status.notAfter`
+
+// OCIManagedCertificate is the Schema for the ocimanagedcertificates API
+type OCIManagedCertificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OCIManagedCertificateSpec   `json:"spec,omitempty"`
+	Status OCIManagedCertificateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// OCIManagedCertificateList contains a list of OCIManagedCertificate
+type OCIManagedCertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OCIManagedCertificate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OCIManagedCertificate{}, &OCIManagedCertificateList{})
+}
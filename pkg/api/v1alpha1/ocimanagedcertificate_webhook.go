@@ -0,0 +1,77 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the validating webhook for
+// OCIManagedCertificate with the manager's webhook server.
+func (r *OCIManagedCertificate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-ocica-cert-manager-io-v1alpha1-ocimanagedcertificate,mutating=false,failurePolicy=fail,sideEffects=None,groups=ocica.cert-manager.io,resources=ocimanagedcertificates,verbs=update,versions=v1alpha1,name=vocimanagedcertificate.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &OCIManagedCertificate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *OCIManagedCertificate) ValidateCreate() error {
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered
+// for the type. The fields OCI fixes when the certificate is created cannot
+// change once status.certificateId is set, as the new values would never
+// reach OCI, and neither can the issuer and Secret the certificate belongs
+// to.
+func (r *OCIManagedCertificate) ValidateUpdate(old runtime.Object) error {
+	previous, ok := old.(*OCIManagedCertificate)
+	if !ok || previous.Status.CertificateID == "" {
+		return nil
+	}
+	errs := ValidateManagedCertificateUpdate(&r.Spec, &previous.Spec, field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("OCIManagedCertificate").GroupKind(), r.Name, errs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *OCIManagedCertificate) ValidateDelete() error {
+	return nil
+}
+
+// ValidateManagedCertificateUpdate checks that spec keeps the issuer and
+// Secret of old and the fields OCI fixes when the certificate is created.
+// Delete and recreate the OCIManagedCertificate to change them.
+func ValidateManagedCertificateUpdate(spec, old *OCIManagedCertificateSpec, path *field.Path) field.ErrorList {
+	const immutable = "cannot change once the OCI certificate is created, delete and recreate the OCIManagedCertificate instead"
+	var errs field.ErrorList
+	fields := []struct {
+		name     string
+		new, old interface{}
+	}{
+		{"issuerName", spec.IssuerName, old.IssuerName},
+		{"secretName", spec.SecretName, old.SecretName},
+		{"commonName", spec.CommonName, old.CommonName},
+		{"dnsNames", spec.DNSNames, old.DNSNames},
+		{"ipAddresses", spec.IPAddresses, old.IPAddresses},
+		{"profile", spec.Profile, old.Profile},
+		{"keyAlgorithm", spec.KeyAlgorithm, old.KeyAlgorithm},
+		{"duration", spec.Duration, old.Duration},
+		{"renewal", spec.Renewal, old.Renewal},
+	}
+	for _, f := range fields {
+		if !equality.Semantic.DeepEqual(f.new, f.old) {
+			errs = append(errs, field.Forbidden(path.Child(f.name), immutable))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,101 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestOCIManagedCertificate_ValidateUpdate(t *testing.T) {
+	spec := OCIManagedCertificateSpec{
+		IssuerName: "issuer",
+		SecretName: "app-tls",
+		CommonName: "app.example.com",
+		DNSNames:   []string{"app.example.com"},
+		Duration:   &metav1.Duration{Duration: 24 * time.Hour},
+	}
+	tests := []struct {
+		name     string
+		created  bool
+		update   func(spec *OCIManagedCertificateSpec)
+		wantErrs int
+	}{
+		{
+			name:    "poll interval",
+			created: true,
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.PollInterval = &metav1.Duration{Duration: time.Minute}
+			},
+		},
+		{
+			name:    "empty names",
+			created: true,
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.IPAddresses = []string{}
+			},
+		},
+		{
+			name: "names before the certificate is created",
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.DNSNames = append(spec.DNSNames, "www.example.com")
+			},
+		},
+		{
+			name:    "names",
+			created: true,
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.CommonName = "www.example.com"
+				spec.DNSNames = append(spec.DNSNames, "www.example.com")
+				spec.IPAddresses = []string{"10.0.0.1"}
+			},
+			wantErrs: 3,
+		},
+		{
+			name:    "profile and key",
+			created: true,
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.Profile = "TLS_SERVER"
+				spec.KeyAlgorithm = "ECDSA_P256"
+			},
+			wantErrs: 2,
+		},
+		{
+			name:    "issuer and Secret",
+			created: true,
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.IssuerName = "other"
+				spec.SecretName = "other-tls"
+			},
+			wantErrs: 2,
+		},
+		{
+			name:    "validity",
+			created: true,
+			update: func(spec *OCIManagedCertificateSpec) {
+				spec.Duration = &metav1.Duration{Duration: 48 * time.Hour}
+				spec.Renewal = &ManagedCertificateRenewal{
+					Interval:      metav1.Duration{Duration: 48 * time.Hour},
+					AdvancePeriod: metav1.Duration{Duration: 24 * time.Hour},
+				}
+			},
+			wantErrs: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &OCIManagedCertificate{ObjectMeta: metav1.ObjectMeta{Name: "app"}, Spec: *spec.DeepCopy()}
+			if tt.created {
+				old.Status.CertificateID = "ocid1.certificate.oc1.phx.example"
+			}
+			mc := old.DeepCopy()
+			tt.update(&mc.Spec)
+			err := mc.ValidateUpdate(old)
+			if (err != nil) != (tt.wantErrs > 0) {
+				t.Fatalf("ValidateUpdate() error = %v, want %d errors", err, tt.wantErrs)
+			}
+			if errs := ValidateManagedCertificateUpdate(&mc.Spec, &old.Spec, nil); tt.created && len(errs) != tt.wantErrs {
+				t.Errorf("ValidateManagedCertificateUpdate() = %v, want %d errors", errs, tt.wantErrs)
+			}
+		})
+	}
+}
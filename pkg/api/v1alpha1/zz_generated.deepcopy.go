@@ -26,12 +26,402 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthorityStatus) DeepCopyInto(out *AuthorityStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthorityStatus.
+func (in *AuthorityStatus) DeepCopy() *AuthorityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthorityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CABundleSource) DeepCopyInto(out *CABundleSource) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(KeySelector)
+		**out = **in
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(KeySelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundleSource.
+func (in *CABundleSource) DeepCopy() *CABundleSource {
+	if in == nil {
+		return nil
+	}
+	out := new(CABundleSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CARotation) DeepCopyInto(out *CARotation) {
+	*out = *in
+	in.Schedule.DeepCopyInto(&out.Schedule)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CARotation.
+func (in *CARotation) DeepCopy() *CARotation {
+	if in == nil {
+		return nil
+	}
+	out := new(CARotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CARotationSchedule) DeepCopyInto(out *CARotationSchedule) {
+	*out = *in
+	if in.DistributeAt != nil {
+		in, out := &in.DistributeAt, &out.DistributeAt
+		*out = (*in).DeepCopy()
+	}
+	in.SignAt.DeepCopyInto(&out.SignAt)
+	in.RetireAt.DeepCopyInto(&out.RetireAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CARotationSchedule.
+func (in *CARotationSchedule) DeepCopy() *CARotationSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(CARotationSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CARotationStatus) DeepCopyInto(out *CARotationStatus) {
+	*out = *in
+	if in.NextPhaseTime != nil {
+		in, out := &in.NextPhaseTime, &out.NextPhaseTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CARotationStatus.
+func (in *CARotationStatus) DeepCopy() *CARotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CARotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CIDRPolicy) DeepCopyInto(out *CIDRPolicy) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CIDRPolicy.
+func (in *CIDRPolicy) DeepCopy() *CIDRPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CIDRPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateAuthority) DeepCopyInto(out *CertificateAuthority) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateAuthority.
+func (in *CertificateAuthority) DeepCopy() *CertificateAuthority {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateAuthority)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompartmentRouting) DeepCopyInto(out *CompartmentRouting) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompartmentRouting.
+func (in *CompartmentRouting) DeepCopy() *CompartmentRouting {
+	if in == nil {
+		return nil
+	}
+	out := new(CompartmentRouting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointOverride) DeepCopyInto(out *EndpointOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointOverride.
+func (in *EndpointOverride) DeepCopy() *EndpointOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssuancePolicy) DeepCopyInto(out *IssuancePolicy) {
+	*out = *in
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPAddresses != nil {
+		in, out := &in.IPAddresses, &out.IPAddresses
+		*out = new(CIDRPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.URIs != nil {
+		in, out := &in.URIs, &out.URIs
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EmailAddresses != nil {
+		in, out := &in.EmailAddresses, &out.EmailAddresses
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Subject != nil {
+		in, out := &in.Subject, &out.Subject
+		*out = new(SubjectPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuancePolicy.
+func (in *IssuancePolicy) DeepCopy() *IssuancePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(IssuancePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssuedCertificate) DeepCopyInto(out *IssuedCertificate) {
+	*out = *in
+	in.IssuedAt.DeepCopyInto(&out.IssuedAt)
+	in.NotAfter.DeepCopyInto(&out.NotAfter)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuedCertificate.
+func (in *IssuedCertificate) DeepCopy() *IssuedCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(IssuedCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeySelector) DeepCopyInto(out *KeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeySelector.
+func (in *KeySelector) DeepCopy() *KeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(KeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListenerCertificate) DeepCopyInto(out *ListenerCertificate) {
+	*out = *in
+	if in.CertificateIDs != nil {
+		in, out := &in.CertificateIDs, &out.CertificateIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenerCertificate.
+func (in *ListenerCertificate) DeepCopy() *ListenerCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(ListenerCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ListenerUpdate) DeepCopyInto(out *ListenerUpdate) {
+	*out = *in
+	in.Previous.DeepCopyInto(&out.Previous)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ListenerUpdate.
+func (in *ListenerUpdate) DeepCopy() *ListenerUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(ListenerUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedCertificateRenewal) DeepCopyInto(out *ManagedCertificateRenewal) {
+	*out = *in
+	out.Interval = in.Interval
+	out.AdvancePeriod = in.AdvancePeriod
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedCertificateRenewal.
+func (in *ManagedCertificateRenewal) DeepCopy() *ManagedCertificateRenewal {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedCertificateRenewal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamePolicy) DeepCopyInto(out *NamePolicy) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowRegex != nil {
+		in, out := &in.AllowRegex, &out.AllowRegex
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DenyRegex != nil {
+		in, out := &in.DenyRegex, &out.DenyRegex
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamePolicy.
+func (in *NamePolicy) DeepCopy() *NamePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NamePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceOverride) DeepCopyInto(out *NamespaceOverride) {
+	*out = *in
+	in.NamespaceSelector.DeepCopyInto(&out.NamespaceSelector)
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(IssuancePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceOverride.
+func (in *NamespaceOverride) DeepCopy() *NamespaceOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIAuth) DeepCopyInto(out *OCIAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIAuth.
+func (in *OCIAuth) DeepCopy() *OCIAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OCICAClusterIssuer) DeepCopyInto(out *OCICAClusterIssuer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -88,6 +478,83 @@ func (in *OCICAClusterIssuerList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OCICAClusterIssuerSpec) DeepCopyInto(out *OCICAClusterIssuerSpec) {
 	*out = *in
+	if in.Authorities != nil {
+		in, out := &in.Authorities, &out.Authorities
+		*out = make([]CertificateAuthority, len(*in))
+		copy(*out, *in)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(OCIAuth)
+		**out = **in
+	}
+	if in.EndpointOverride != nil {
+		in, out := &in.EndpointOverride, &out.EndpointOverride
+		*out = new(EndpointOverride)
+		**out = **in
+	}
+	if in.Transport != nil {
+		in, out := &in.Transport, &out.Transport
+		*out = new(OCITransport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SubordinateCA != nil {
+		in, out := &in.SubordinateCA, &out.SubordinateCA
+		*out = new(SubordinateCAPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Validity != nil {
+		in, out := &in.Validity, &out.Validity
+		*out = new(ValidityPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(IssuancePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceOverrides != nil {
+		in, out := &in.NamespaceOverrides, &out.NamespaceOverrides
+		*out = make([]NamespaceOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Compartments != nil {
+		in, out := &in.Compartments, &out.Compartments
+		*out = new(CompartmentRouting)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DefaultDuration != nil {
+		in, out := &in.DefaultDuration, &out.DefaultDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TrustBundle != nil {
+		in, out := &in.TrustBundle, &out.TrustBundle
+		*out = new(KeySelector)
+		**out = **in
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(CARotation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Shadow != nil {
+		in, out := &in.Shadow, &out.Shadow
+		*out = new(ShadowIssuer)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICAClusterIssuerSpec.
@@ -110,6 +577,16 @@ func (in *OCICAClusterIssuerStatus) DeepCopyInto(out *OCICAClusterIssuerStatus)
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Authorities != nil {
+		in, out := &in.Authorities, &out.Authorities
+		*out = make([]AuthorityStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(CARotationStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICAClusterIssuerStatus.
@@ -121,3 +598,584 @@ func (in *OCICAClusterIssuerStatus) DeepCopy() *OCICAClusterIssuerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICAIssuanceQuota) DeepCopyInto(out *OCICAIssuanceQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICAIssuanceQuota.
+func (in *OCICAIssuanceQuota) DeepCopy() *OCICAIssuanceQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICAIssuanceQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCICAIssuanceQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICAIssuanceQuotaList) DeepCopyInto(out *OCICAIssuanceQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OCICAIssuanceQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICAIssuanceQuotaList.
+func (in *OCICAIssuanceQuotaList) DeepCopy() *OCICAIssuanceQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICAIssuanceQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCICAIssuanceQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICAIssuanceQuotaSpec) DeepCopyInto(out *OCICAIssuanceQuotaSpec) {
+	*out = *in
+	if in.IssuerNames != nil {
+		in, out := &in.IssuerNames, &out.IssuerNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxIssuances != nil {
+		in, out := &in.MaxIssuances, &out.MaxIssuances
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxLiveCertificates != nil {
+		in, out := &in.MaxLiveCertificates, &out.MaxLiveCertificates
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICAIssuanceQuotaSpec.
+func (in *OCICAIssuanceQuotaSpec) DeepCopy() *OCICAIssuanceQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICAIssuanceQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICAIssuanceQuotaStatus) DeepCopyInto(out *OCICAIssuanceQuotaStatus) {
+	*out = *in
+	if in.Issued != nil {
+		in, out := &in.Issued, &out.Issued
+		*out = make([]IssuedCertificate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICAIssuanceQuotaStatus.
+func (in *OCICAIssuanceQuotaStatus) DeepCopy() *OCICAIssuanceQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICAIssuanceQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICertificateImport) DeepCopyInto(out *OCICertificateImport) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICertificateImport.
+func (in *OCICertificateImport) DeepCopy() *OCICertificateImport {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICertificateImport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCICertificateImport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICertificateImportList) DeepCopyInto(out *OCICertificateImportList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OCICertificateImport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICertificateImportList.
+func (in *OCICertificateImportList) DeepCopy() *OCICertificateImportList {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICertificateImportList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCICertificateImportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICertificateImportSpec) DeepCopyInto(out *OCICertificateImportSpec) {
+	*out = *in
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICertificateImportSpec.
+func (in *OCICertificateImportSpec) DeepCopy() *OCICertificateImportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICertificateImportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCICertificateImportStatus) DeepCopyInto(out *OCICertificateImportStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCICertificateImportStatus.
+func (in *OCICertificateImportStatus) DeepCopy() *OCICertificateImportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OCICertificateImportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCILoadBalancerBinding) DeepCopyInto(out *OCILoadBalancerBinding) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCILoadBalancerBinding.
+func (in *OCILoadBalancerBinding) DeepCopy() *OCILoadBalancerBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(OCILoadBalancerBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCILoadBalancerBinding) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCILoadBalancerBindingList) DeepCopyInto(out *OCILoadBalancerBindingList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OCILoadBalancerBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCILoadBalancerBindingList.
+func (in *OCILoadBalancerBindingList) DeepCopy() *OCILoadBalancerBindingList {
+	if in == nil {
+		return nil
+	}
+	out := new(OCILoadBalancerBindingList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCILoadBalancerBindingList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCILoadBalancerBindingSpec) DeepCopyInto(out *OCILoadBalancerBindingSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCILoadBalancerBindingSpec.
+func (in *OCILoadBalancerBindingSpec) DeepCopy() *OCILoadBalancerBindingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OCILoadBalancerBindingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCILoadBalancerBindingStatus) DeepCopyInto(out *OCILoadBalancerBindingStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Update != nil {
+		in, out := &in.Update, &out.Update
+		*out = new(ListenerUpdate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCILoadBalancerBindingStatus.
+func (in *OCILoadBalancerBindingStatus) DeepCopy() *OCILoadBalancerBindingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OCILoadBalancerBindingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIManagedCertificate) DeepCopyInto(out *OCIManagedCertificate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIManagedCertificate.
+func (in *OCIManagedCertificate) DeepCopy() *OCIManagedCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIManagedCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCIManagedCertificate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIManagedCertificateList) DeepCopyInto(out *OCIManagedCertificateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OCIManagedCertificate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIManagedCertificateList.
+func (in *OCIManagedCertificateList) DeepCopy() *OCIManagedCertificateList {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIManagedCertificateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OCIManagedCertificateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIManagedCertificateSpec) DeepCopyInto(out *OCIManagedCertificateSpec) {
+	*out = *in
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddresses != nil {
+		in, out := &in.IPAddresses, &out.IPAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Renewal != nil {
+		in, out := &in.Renewal, &out.Renewal
+		*out = new(ManagedCertificateRenewal)
+		**out = **in
+	}
+	if in.PollInterval != nil {
+		in, out := &in.PollInterval, &out.PollInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIManagedCertificateSpec.
+func (in *OCIManagedCertificateSpec) DeepCopy() *OCIManagedCertificateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIManagedCertificateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIManagedCertificateStatus) DeepCopyInto(out *OCIManagedCertificateStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIManagedCertificateStatus.
+func (in *OCIManagedCertificateStatus) DeepCopy() *OCIManagedCertificateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIManagedCertificateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCITransport) DeepCopyInto(out *OCITransport) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = new(CABundleSource)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCITransport.
+func (in *OCITransport) DeepCopy() *OCITransport {
+	if in == nil {
+		return nil
+	}
+	out := new(OCITransport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ShadowIssuer) DeepCopyInto(out *ShadowIssuer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ShadowIssuer.
+func (in *ShadowIssuer) DeepCopy() *ShadowIssuer {
+	if in == nil {
+		return nil
+	}
+	out := new(ShadowIssuer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubjectPolicy) DeepCopyInto(out *SubjectPolicy) {
+	*out = *in
+	if in.CommonName != nil {
+		in, out := &in.CommonName, &out.CommonName
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Organizations != nil {
+		in, out := &in.Organizations, &out.Organizations
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OrganizationalUnits != nil {
+		in, out := &in.OrganizationalUnits, &out.OrganizationalUnits
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Countries != nil {
+		in, out := &in.Countries, &out.Countries
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Localities != nil {
+		in, out := &in.Localities, &out.Localities
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Provinces != nil {
+		in, out := &in.Provinces, &out.Provinces
+		*out = new(NamePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubjectPolicy.
+func (in *SubjectPolicy) DeepCopy() *SubjectPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SubjectPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubordinateCAPolicy) DeepCopyInto(out *SubordinateCAPolicy) {
+	*out = *in
+	if in.MaxPathLength != nil {
+		in, out := &in.MaxPathLength, &out.MaxPathLength
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubordinateCAPolicy.
+func (in *SubordinateCAPolicy) DeepCopy() *SubordinateCAPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SubordinateCAPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ValidityPolicy) DeepCopyInto(out *ValidityPolicy) {
+	*out = *in
+	if in.Backdate != nil {
+		in, out := &in.Backdate, &out.Backdate
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MinDuration != nil {
+		in, out := &in.MinDuration, &out.MinDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ValidityPolicy.
+func (in *ValidityPolicy) DeepCopy() *ValidityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ValidityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,223 @@
+package v1alpha1
+
+import (
+	"flag"
+	"fmt"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net"
+	"os"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// controllers are the names of the controllers.
+var controllers = []string{
+	ControllerOCICAClusterIssuer,
+	ControllerCertificateRequest,
+	ControllerOCIManagedCertificate,
+	ControllerOCICertificateImport,
+	ControllerOCILoadBalancerBinding,
+	ControllerApprover,
+	ControllerSecretExport,
+}
+
+// featureGates are the feature gates and whether they are enabled by default.
+var featureGates = map[string]bool{
+	FeatureApprover:     false,
+	FeatureSecretExport: false,
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *ControllerConfiguration {
+	return &ControllerConfiguration{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		Metrics:  MetricsConfiguration{BindAddress: ":8080"},
+		Health:   HealthConfiguration{BindAddress: ":8081"},
+		Webhook:  WebhookConfiguration{Port: 9443},
+		LeaderElection: LeaderElectionConfiguration{
+			LeaseName:     "oci-privateca-issuer",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+		ClusterResourceNamespace: "oci-private-issuer",
+		RateLimits:               RateLimitConfiguration{QPS: 20, Burst: 30},
+	}
+}
+
+// Load reads the configuration file at path over cfg, then sets the flags of
+// fs that were set on the command line again, so that they override the
+// file. Fields missing from the file keep their value in cfg.
+func Load(path string, cfg *ControllerConfiguration, fs *flag.FlagSet) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	if typeMeta.APIVersion != APIVersion || typeMeta.Kind != Kind {
+		return fmt.Errorf("configuration file %s is %s %s, want %s %s", path, typeMeta.APIVersion, typeMeta.Kind, APIVersion, Kind)
+	}
+
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("flag --%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks cfg for invalid addresses, durations and names, and for
+// unknown controllers and feature gates.
+func (cfg *ControllerConfiguration) Validate() error {
+	var errs field.ErrorList
+	errs = append(errs, validateAddress(cfg.Metrics.BindAddress, field.NewPath("metrics", "bindAddress"))...)
+	errs = append(errs, validateAddress(cfg.Health.BindAddress, field.NewPath("health", "bindAddress"))...)
+	if port := cfg.Webhook.Port; port < 1 || port > 65535 {
+		errs = append(errs, field.Invalid(field.NewPath("webhook", "port"), port, "must be between 1 and 65535"))
+	}
+
+	le, lePath := cfg.LeaderElection, field.NewPath("leaderElection")
+	for _, msg := range validation.IsDNS1123Subdomain(le.LeaseName) {
+		errs = append(errs, field.Invalid(lePath.Child("leaseName"), le.LeaseName, msg))
+	}
+	if le.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(le.Namespace) {
+			errs = append(errs, field.Invalid(lePath.Child("namespace"), le.Namespace, msg))
+		}
+	}
+	if d := le.RetryPeriod.Duration; d <= 0 {
+		errs = append(errs, field.Invalid(lePath.Child("retryPeriod"), d.String(), "must be positive"))
+	}
+	if le.LeaseDuration.Duration <= le.RenewDeadline.Duration {
+		errs = append(errs, field.Invalid(lePath.Child("leaseDuration"), le.LeaseDuration.Duration.String(), "must be longer than renewDeadline"))
+	}
+	if le.RenewDeadline.Duration <= le.RetryPeriod.Duration {
+		errs = append(errs, field.Invalid(lePath.Child("renewDeadline"), le.RenewDeadline.Duration.String(), "must be longer than retryPeriod"))
+	}
+
+	for _, msg := range validation.IsDNS1123Label(cfg.ClusterResourceNamespace) {
+		errs = append(errs, field.Invalid(field.NewPath("clusterResourceNamespace"), cfg.ClusterResourceNamespace, msg))
+	}
+	if cfg.DefaultAuth != nil {
+		errs = append(errs, ocicav1alpha1.ValidateAuth(cfg.DefaultAuth, field.NewPath("defaultAuth"))...)
+	}
+
+	if cfg.RateLimits.QPS <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("rateLimits", "qps"), cfg.RateLimits.QPS, "must be positive"))
+	}
+	if cfg.RateLimits.Burst < 1 {
+		errs = append(errs, field.Invalid(field.NewPath("rateLimits", "burst"), cfg.RateLimits.Burst, "must be positive"))
+	}
+
+	for _, name := range controllers {
+		if n := cfg.Controllers[name].MaxConcurrentReconciles; n < 0 {
+			errs = append(errs, field.Invalid(field.NewPath("controllers").Key(name).Child("maxConcurrentReconciles"), n, "must not be negative"))
+		}
+	}
+	var unknown []string
+	for name := range cfg.Controllers {
+		if !contains(controllers, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, field.NotSupported(field.NewPath("controllers").Key(name), name, controllers))
+	}
+	unknown = nil
+	for name := range cfg.FeatureGates {
+		if _, ok := featureGates[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, field.NotSupported(field.NewPath("featureGates").Key(name), name, []string{FeatureApprover, FeatureSecretExport}))
+	}
+	return errs.ToAggregate()
+}
+
+// validateAddress checks that address is a host and port, or 0 to disable the
+// endpoint.
+func validateAddress(address string, path *field.Path) field.ErrorList {
+	if address == "0" {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(address); err != nil {
+		return field.ErrorList{field.Invalid(path, address, err.Error())}
+	} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return field.ErrorList{field.Invalid(path, address, "invalid port")}
+	}
+	return nil
+}
+
+// Enabled reports whether the feature gate is enabled.
+func (cfg *ControllerConfiguration) Enabled(gate string) bool {
+	if enabled, ok := cfg.FeatureGates[gate]; ok {
+		return enabled
+	}
+	return featureGates[gate]
+}
+
+// MaxConcurrentReconciles returns how many objects the controller called name
+// reconciles at once, or 0 for the default.
+func (cfg *ControllerConfiguration) MaxConcurrentReconciles(name string) int {
+	return cfg.Controllers[name].MaxConcurrentReconciles
+}
+
+// FeatureGateFlag returns a boolean flag enabling or disabling gate in cfg.
+func (cfg *ControllerConfiguration) FeatureGateFlag(gate string) flag.Value {
+	return &gateFlag{cfg: cfg, gate: gate}
+}
+
+type gateFlag struct {
+	cfg  *ControllerConfiguration
+	gate string
+}
+
+func (f *gateFlag) String() string {
+	if f.cfg == nil {
+		return "false"
+	}
+	return strconv.FormatBool(f.cfg.Enabled(f.gate))
+}
+
+func (f *gateFlag) Set(s string) error {
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if f.cfg.FeatureGates == nil {
+		f.cfg.FeatureGates = map[string]bool{}
+	}
+	f.cfg.FeatureGates[f.gate] = enabled
+	return nil
+}
+
+func (f *gateFlag) IsBoolFlag() bool {
+	return true
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package v1alpha1
+
+import (
+	"flag"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		check   func(t *testing.T, cfg *ControllerConfiguration)
+		wantErr bool
+	}{
+		{
+			name: "file values",
+			file: `apiVersion: config.ocica.cert-manager.io/v1alpha1
+kind: ControllerConfiguration
+webhook:
+  port: 10250
+leaderElection:
+  leaderElect: true
+  leaseDuration: 30s
+defaultAuth:
+  mode: InstancePrincipal
+controllers:
+  CertificateRequest:
+    maxConcurrentReconciles: 4
+featureGates:
+  Approver: true
+`,
+			check: func(t *testing.T, cfg *ControllerConfiguration) {
+				if cfg.Webhook.Port != 10250 || !cfg.LeaderElection.LeaderElect || cfg.LeaderElection.LeaseDuration.Duration != 30*time.Second {
+					t.Errorf("Load() webhook = %+v, leader election = %+v", cfg.Webhook, cfg.LeaderElection)
+				}
+				if cfg.DefaultAuth == nil || cfg.DefaultAuth.Mode != ocicav1alpha1.AuthModeInstancePrincipal {
+					t.Errorf("Load() default auth = %+v", cfg.DefaultAuth)
+				}
+				if cfg.MaxConcurrentReconciles(ControllerCertificateRequest) != 4 || !cfg.Enabled(FeatureApprover) {
+					t.Errorf("Load() controllers = %+v, feature gates = %+v", cfg.Controllers, cfg.FeatureGates)
+				}
+				if cfg.Metrics.BindAddress != ":8080" || cfg.LeaderElection.RenewDeadline.Duration != 10*time.Second {
+					t.Errorf("Load() did not keep the defaults missing from the file")
+				}
+			},
+		},
+		{
+			name: "flags override the file",
+			file: `apiVersion: config.ocica.cert-manager.io/v1alpha1
+kind: ControllerConfiguration
+metrics:
+  bindAddress: ":9090"
+clusterResourceNamespace: from-file
+featureGates:
+  Approver: true
+`,
+			args: []string{"--cluster-resource-namespace=from-flag", "--enable-approver=false"},
+			check: func(t *testing.T, cfg *ControllerConfiguration) {
+				if cfg.ClusterResourceNamespace != "from-flag" || cfg.Enabled(FeatureApprover) {
+					t.Errorf("Load() namespace = %s, approver = %t, want the flag values", cfg.ClusterResourceNamespace, cfg.Enabled(FeatureApprover))
+				}
+				if cfg.Metrics.BindAddress != ":9090" {
+					t.Errorf("Load() metrics = %s, want the file value", cfg.Metrics.BindAddress)
+				}
+			},
+		},
+		{
+			name: "unknown field",
+			file: `apiVersion: config.ocica.cert-manager.io/v1alpha1
+kind: ControllerConfiguration
+webhook:
+  prot: 9443
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown version",
+			file: `apiVersion: config.ocica.cert-manager.io/v1beta9
+kind: ControllerConfiguration
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg := Default()
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.StringVar(&cfg.Metrics.BindAddress, "metrics-bind-address", cfg.Metrics.BindAddress, "")
+			fs.StringVar(&cfg.ClusterResourceNamespace, "cluster-resource-namespace", cfg.ClusterResourceNamespace, "")
+			fs.Var(cfg.FeatureGateFlag(FeatureApprover), "enable-approver", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			err := Load(path, cfg, fs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				tt.check(t, cfg)
+			}
+		})
+	}
+}
+
+func TestControllerConfiguration_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(cfg *ControllerConfiguration)
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			mutate: func(cfg *ControllerConfiguration) {},
+		},
+		{
+			name: "disabled metrics",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.Metrics.BindAddress = "0"
+			},
+		},
+		{
+			name: "invalid probe address",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.Health.BindAddress = "8081"
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid webhook port",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.Webhook.Port = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "lease shorter than renew deadline",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.LeaderElection.LeaseDuration.Duration = 5 * time.Second
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid lease namespace",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.LeaderElection.Namespace = "Kube_System"
+			},
+			wantErr: true,
+		},
+		{
+			name: "API key default auth without secret",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.DefaultAuth = &ocicav1alpha1.OCIAuth{Mode: ocicav1alpha1.AuthModeAPIKey}
+			},
+			wantErr: true,
+		},
+		{
+			name: "no rate",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.RateLimits.QPS = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown controller",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.Controllers = map[string]ControllerOptions{"Certificate": {MaxConcurrentReconciles: 2}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative concurrency",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.Controllers = map[string]ControllerOptions{ControllerCertificateRequest: {MaxConcurrentReconciles: -1}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown feature gate",
+			mutate: func(cfg *ControllerConfiguration) {
+				cfg.FeatureGates = map[string]bool{"OCSP": true}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.mutate(cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
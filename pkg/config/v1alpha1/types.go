@@ -0,0 +1,177 @@
+// Package v1alpha1 is version v1alpha1 of the configuration file of the
+// controller manager, loaded with --config. Flags set on the command line
+// take precedence over the file.
+//
+// A minimal file only sets the version:
+//
+//	apiVersion: config.ocica.cert-manager.io/v1alpha1
+//	kind: ControllerConfiguration
+package v1alpha1
+
+import (
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// APIVersion is the apiVersion of configuration files of this version.
+	APIVersion = "config.ocica.cert-manager.io/v1alpha1"
+
+	// Kind is the kind of configuration files.
+	Kind = "ControllerConfiguration"
+)
+
+// Feature gates enabling optional parts of the controller manager.
+const (
+	// FeatureApprover approves or denies CertificateRequests for
+	// OCICAClusterIssuers according to the issuer policy.
+	FeatureApprover = "Approver"
+
+	// FeatureSecretExport imports the certificates of Secrets annotated with
+	// ocica.cert-manager.io/export-to into OCI Certificates.
+	FeatureSecretExport = "SecretExport"
+)
+
+// Names of the controllers, the keys of ControllerConfiguration.Controllers.
+const (
+	ControllerOCICAClusterIssuer     = "OCICAClusterIssuer"
+	ControllerCertificateRequest     = "CertificateRequest"
+	ControllerOCIManagedCertificate  = "OCIManagedCertificate"
+	ControllerOCICertificateImport   = "OCICertificateImport"
+	ControllerOCILoadBalancerBinding = "OCILoadBalancerBinding"
+	ControllerApprover               = "Approver"
+	ControllerSecretExport           = "SecretExport"
+)
+
+// ControllerConfiguration configures the controller manager.
+type ControllerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Metrics configures the metrics endpoint.
+	// +optional
+	Metrics MetricsConfiguration `json:"metrics,omitempty"`
+
+	// Health configures the health probe endpoint.
+	// +optional
+	Health HealthConfiguration `json:"health,omitempty"`
+
+	// Webhook configures the webhook server.
+	// +optional
+	Webhook WebhookConfiguration `json:"webhook,omitempty"`
+
+	// LeaderElection configures the election of the active controller
+	// manager when several replicas run.
+	// +optional
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+
+	// ClusterResourceNamespace is the namespace Secrets and ConfigMaps
+	// referenced by cluster issuers are read from. Defaults to
+	// oci-private-issuer.
+	// +optional
+	ClusterResourceNamespace string `json:"clusterResourceNamespace,omitempty"`
+
+	// DefaultAuth is how issuers without auth authenticate to OCI. Defaults
+	// to the DEFAULT profile of the OCI config file. In APIKey mode, the
+	// Secret is read from the cluster resource namespace.
+	// +optional
+	DefaultAuth *ocicav1alpha1.OCIAuth `json:"defaultAuth,omitempty"`
+
+	// RateLimits limits the requests to the Kubernetes API.
+	// +optional
+	RateLimits RateLimitConfiguration `json:"rateLimits,omitempty"`
+
+	// Controllers configures each controller by name, such as
+	// CertificateRequest.
+	// +optional
+	Controllers map[string]ControllerOptions `json:"controllers,omitempty"`
+
+	// FeatureGates enables or disables optional features by name, such as
+	// Approver.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// MetricsConfiguration configures the metrics endpoint.
+type MetricsConfiguration struct {
+	// BindAddress is the address the metrics endpoint binds to, or 0 to
+	// disable it. Defaults to :8080.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// HealthConfiguration configures the health probe endpoint.
+type HealthConfiguration struct {
+	// BindAddress is the address the liveness and readiness probes bind
+	// to. Defaults to :8081.
+	// +optional
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// WebhookConfiguration configures the webhook server.
+type WebhookConfiguration struct {
+	// Port is the port the webhook server listens on. Defaults to 9443.
+	// +optional
+	Port int `json:"port,omitempty"`
+
+	// Host is the address the webhook server binds to. Defaults to all
+	// addresses.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// CertDir is the directory holding the tls.crt and tls.key of the
+	// webhook server. Defaults to /tmp/k8s-webhook-server/serving-certs.
+	// +optional
+	CertDir string `json:"certDir,omitempty"`
+}
+
+// LeaderElectionConfiguration configures leader election.
+type LeaderElectionConfiguration struct {
+	// LeaderElect enables leader election, so that only one replica of the
+	// controller manager is active.
+	// +optional
+	LeaderElect bool `json:"leaderElect,omitempty"`
+
+	// LeaseName is the name of the Lease the replicas compete for. Defaults
+	// to oci-privateca-issuer.
+	// +optional
+	LeaseName string `json:"leaseName,omitempty"`
+
+	// Namespace is the namespace of the Lease. Defaults to the namespace the
+	// controller manager runs in.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// LeaseDuration is how long replicas wait before taking over from a
+	// leader that stopped renewing the Lease. Defaults to 15s.
+	// +optional
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+
+	// RenewDeadline is how long the leader tries to renew the Lease before
+	// giving up leadership. Defaults to 10s.
+	// +optional
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+
+	// RetryPeriod is how long replicas wait between attempts to acquire or
+	// renew the Lease. Defaults to 2s.
+	// +optional
+	RetryPeriod metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// RateLimitConfiguration limits the requests to the Kubernetes API.
+type RateLimitConfiguration struct {
+	// QPS is the sustained rate of requests per second. Defaults to 20.
+	// +optional
+	QPS float32 `json:"qps,omitempty"`
+
+	// Burst is how many requests may exceed QPS in bursts. Defaults to 30.
+	// +optional
+	Burst int `json:"burst,omitempty"`
+}
+
+// ControllerOptions configures a controller.
+type ControllerOptions struct {
+	// MaxConcurrentReconciles is how many objects the controller reconciles
+	// at once. Defaults to 1.
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+}
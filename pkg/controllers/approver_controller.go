@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ApproverReconciler approves or denies CertificateRequests referencing an
+// OCICAClusterIssuer according to the namespace selector and policy of the
+// issuer, in place of a separate cert-manager approver. Approving needs the
+// signers permission granted by config/rbac/role-approver.yml.
+type ApproverReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests/status,verbs=update
+
+// Reconcile sets the Approved or Denied condition of CertificateRequests that
+// have neither.
+func (r *ApproverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("certificaterequest", req.NamespacedName)
+	cr := new(cmapi.CertificateRequest)
+	if err := r.Client.Get(ctx, req.NamespacedName, cr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if cr.Spec.IssuerRef.Group != ocicav1alpha1.GroupVersion.Group || cr.Spec.IssuerRef.Kind != OCICAClusterIssuerKind {
+		return ctrl.Result{}, nil
+	}
+	if cmutil.CertificateRequestIsApproved(cr) || cmutil.CertificateRequestIsDenied(cr) || finished(cr) {
+		return ctrl.Result{}, nil
+	}
+
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: cr.Spec.IssuerRef.Name}, iss); err != nil {
+		if apierrors.IsNotFound(err) {
+			// Decided once the issuer is created.
+			log.V(4).Info("issuer not found", "issuer", cr.Spec.IssuerRef.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	d, err := decide(ctx, r.Client, cr, iss)
+	if err != nil {
+		log.Error(err, "failed to check request against the issuer")
+		return ctrl.Result{}, err
+	}
+
+	condition, eventType := cmapi.CertificateRequestConditionApproved, core.EventTypeNormal
+	if !d.allowed {
+		condition, eventType = cmapi.CertificateRequestConditionDenied, core.EventTypeWarning
+	}
+	log.Info("deciding CertificateRequest", "condition", condition, "reason", d.reason)
+	cmutil.SetCertificateRequestCondition(cr, condition, cmmeta.ConditionTrue, d.reason, d.message)
+	if err := r.Client.Status().Update(ctx, cr); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(cr, eventType, d.reason, d.message)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Requests are
+// decided again when their issuer changes or the labels of their namespace
+// change, until they are approved or denied.
+func (r *ApproverReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("certificaterequest-approver").
+		For(&cmapi.CertificateRequest{}).
+		Watches(&source.Kind{Type: &ocicav1alpha1.OCICAClusterIssuer{}}, handler.EnqueueRequestsFromMapFunc(r.undecidedRequestsForIssuer)).
+		Watches(&source.Kind{Type: &core.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.undecidedRequestsInNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+func (r *ApproverReconciler) undecidedRequestsForIssuer(obj client.Object) []reconcile.Request {
+	return r.undecidedRequests(func(cr *cmapi.CertificateRequest) bool {
+		return cr.Spec.IssuerRef.Kind == OCICAClusterIssuerKind && cr.Spec.IssuerRef.Name == obj.GetName()
+	})
+}
+
+func (r *ApproverReconciler) undecidedRequestsInNamespace(obj client.Object) []reconcile.Request {
+	return r.undecidedRequests(func(cr *cmapi.CertificateRequest) bool {
+		return cr.Namespace == obj.GetName()
+	}, client.InNamespace(obj.GetName()))
+}
+
+// undecidedRequests lists the CertificateRequests of this group matching
+// match that are neither approved nor denied.
+func (r *ApproverReconciler) undecidedRequests(match func(*cmapi.CertificateRequest) bool, opts ...client.ListOption) []reconcile.Request {
+	crs := new(cmapi.CertificateRequestList)
+	if err := r.Client.List(context.Background(), crs, opts...); err != nil {
+		r.Log.Error(err, "failed to list CertificateRequests")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+		if cr.Spec.IssuerRef.Group != ocicav1alpha1.GroupVersion.Group || !match(cr) ||
+			cmutil.CertificateRequestIsApproved(cr) || cmutil.CertificateRequestIsDenied(cr) || finished(cr) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApproverReconciler_Reconcile(t *testing.T) {
+	ctx := context.TODO()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"app.example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(group string) *cmapi.CertificateRequest {
+		return &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "cr1"},
+			Spec: cmapi.CertificateRequestSpec{
+				Request:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+				IssuerRef: cmmeta.ObjectReference{Group: group, Kind: OCICAClusterIssuerKind, Name: "issuer1"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		cr   *cmapi.CertificateRequest
+		// issuer is nil when the issuer does not exist.
+		issuer        func(spec *v1alpha1.OCICAClusterIssuerSpec)
+		wantCondition cmapi.CertificateRequestConditionType
+		wantReason    string
+		wantMessage   string
+	}{
+		{
+			name:          "no policy",
+			cr:            newRequest(v1alpha1.GroupVersion.Group),
+			issuer:        func(spec *v1alpha1.OCICAClusterIssuerSpec) {},
+			wantCondition: cmapi.CertificateRequestConditionApproved,
+			wantReason:    ReasonNoPolicy,
+		},
+		{
+			name: "allowed by policy",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"*.example.com"}}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionApproved,
+			wantReason:    ReasonIssuerPolicy,
+			wantMessage:   "spec.policy",
+		},
+		{
+			name: "denied by policy",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Deny: []string{"app.example.com"}}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionDenied,
+			wantReason:    "PolicyDNSNames",
+			wantMessage:   "spec.policy.dnsNames",
+		},
+		{
+			name: "denied by max duration",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{MaxDuration: &metav1.Duration{Duration: 1}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionDenied,
+			wantReason:    "PolicyMaxDuration",
+			wantMessage:   "spec.policy.maxDuration",
+		},
+		{
+			name: "namespace not selected",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionDenied,
+			wantReason:    ReasonNamespaceSelector,
+			wantMessage:   "spec.namespaceSelector",
+		},
+		{
+			name: "allowed by namespace override",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"*.example.com", "*.example.org"}}}
+				spec.NamespaceOverrides = []v1alpha1.NamespaceOverride{{
+					NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+					Policy:            &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"*.example.com"}}},
+				}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionApproved,
+			wantReason:    ReasonNamespaceOverride,
+			wantMessage:   "spec.namespaceOverrides[0].policy",
+		},
+		{
+			name: "namespace override does not widen the policy",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"*.example.org"}}}
+				spec.NamespaceOverrides = []v1alpha1.NamespaceOverride{{
+					NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+					Policy:            &v1alpha1.IssuancePolicy{MaxDuration: &metav1.Duration{Duration: 90 * 24 * time.Hour}},
+				}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionDenied,
+			wantReason:    "PolicyDNSNames",
+			wantMessage:   "spec.policy.dnsNames",
+		},
+		{
+			name: "already approved",
+			cr: func() *cmapi.CertificateRequest {
+				cr := newRequest(v1alpha1.GroupVersion.Group)
+				cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "test", "approved")
+				return cr
+			}(),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Deny: []string{"*"}}}
+			},
+			wantCondition: cmapi.CertificateRequestConditionApproved,
+			wantReason:    "test",
+		},
+		{
+			name:   "other issuer group",
+			cr:     newRequest("cert-manager.io"),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {},
+		},
+		{
+			name: "issuer not found",
+			cr:   newRequest(v1alpha1.GroupVersion.Group),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = core.AddToScheme(scheme)
+			_ = cmapi.AddToScheme(scheme)
+			_ = v1alpha1.AddToScheme(scheme)
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{"team": "a"}}},
+				tt.cr.DeepCopy(),
+			)
+			if tt.issuer != nil {
+				iss := &v1alpha1.OCICAClusterIssuer{ObjectMeta: metav1.ObjectMeta{Name: "issuer1"}}
+				tt.issuer(&iss.Spec)
+				builder = builder.WithObjects(iss)
+			}
+			c := builder.Build()
+			r := &ApproverReconciler{Client: c, Log: logr.Discard(), Recorder: record.NewFakeRecorder(10)}
+			key := types.NamespacedName{Namespace: tt.cr.Namespace, Name: tt.cr.Name}
+			if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			cr := new(cmapi.CertificateRequest)
+			if err := c.Get(ctx, key, cr); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCondition == "" {
+				if len(cr.Status.Conditions) != 0 {
+					t.Errorf("Reconcile() conditions = %v, want none", cr.Status.Conditions)
+				}
+				return
+			}
+			if len(cr.Status.Conditions) != 1 {
+				t.Fatalf("Reconcile() conditions = %v, want one %s", cr.Status.Conditions, tt.wantCondition)
+			}
+			cond := cr.Status.Conditions[0]
+			if cond.Type != tt.wantCondition || cond.Status != cmmeta.ConditionTrue || cond.Reason != tt.wantReason {
+				t.Errorf("Reconcile() condition = %s=%s (%s), want %s=True (%s)", cond.Type, cond.Status, cond.Reason, tt.wantCondition, tt.wantReason)
+			}
+			if !strings.Contains(cond.Message, tt.wantMessage) {
+				t.Errorf("Reconcile() message = %q, want it to contain %q", cond.Message, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/policy"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// Reasons of the decisions taken on CertificateRequests, naming the issuer
+// rule that decided.
+const (
+	ReasonNoPolicy           = "NoPolicy"
+	ReasonIssuerPolicy       = "IssuerPolicy"
+	ReasonNamespaceOverride  = "NamespaceOverride"
+	ReasonNamespaceSelector  = "NamespaceSelector"
+	ReasonInvalidCSR         = "InvalidCSR"
+	ReasonInvalidCompartment = "InvalidCompartment"
+)
+
+// policyReasons are the reasons of denials by each rule of a policy.
+var policyReasons = map[string]string{
+	policy.RuleDNSNames:       "PolicyDNSNames",
+	policy.RuleIPAddresses:    "PolicyIPAddresses",
+	policy.RuleURIs:           "PolicyURIs",
+	policy.RuleEmailAddresses: "PolicyEmailAddresses",
+	policy.RuleSubject:        "PolicySubject",
+	policy.RuleAllowWildcards: "PolicyWildcards",
+	policy.RuleMaxDuration:    "PolicyMaxDuration",
+}
+
+// decision is the outcome of checking a certificate against the
+// namespace selector and policy of its issuer.
+type decision struct {
+	allowed bool
+	// reason names the rule that decided.
+	reason  string
+	message string
+	// compartmentID is the compartment to create the certificate in, or
+	// empty for the compartment of the issuer.
+	compartmentID string
+}
+
+// decide checks cr against the namespace selector, namespace overrides and
+// policy of iss. Errors are failures to decide, such as failing to read the
+// namespace of cr.
+func decide(ctx context.Context, c client.Client, cr *cmapi.CertificateRequest, iss *ocicav1alpha1.OCICAClusterIssuer) (decision, error) {
+	request := func() (*x509.CertificateRequest, error) {
+		return pki.DecodeX509CertificateRequestBytes(cr.Spec.Request)
+	}
+	return decideFor(ctx, c, cr.Namespace, request, cr.Spec.Duration, iss)
+}
+
+// decideFor checks a certificate for namespace against iss. request returns
+// the names the certificate is for, and is only called when a policy applies;
+// its errors are reported as invalid CSRs. duration is the requested
+// duration, or nil for the default of iss.
+func decideFor(ctx context.Context, c client.Client, namespace string, request func() (*x509.CertificateRequest, error),
+	duration *metav1.Duration, iss *ocicav1alpha1.OCICAClusterIssuer) (decision, error) {
+	rules := issuerRules(iss)
+	var ns *core.Namespace
+	if usesNamespaces(iss) {
+		ns = new(core.Namespace)
+		if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			return decision{}, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+		}
+		var selected bool
+		var err error
+		if rules, selected, err = rulesForNamespace(iss, ns); err != nil {
+			return decision{}, fmt.Errorf("invalid namespace selector on issuer %s: %w", iss.Name, err)
+		}
+		if !selected {
+			return decision{
+				reason:  ReasonNamespaceSelector,
+				message: fmt.Sprintf("Namespace %s is not selected by spec.namespaceSelector of issuer %s", namespace, iss.Name),
+			}, nil
+		}
+	}
+
+	if rules.compartmentID == "" && iss.Spec.Compartments != nil {
+		var err error
+		if rules.compartmentID, err = routeCompartment(iss, namespace, ns); err != nil {
+			return decision{
+				reason:  ReasonInvalidCompartment,
+				message: fmt.Sprintf("Namespace %s chooses an invalid compartment: %s", namespace, err),
+			}, nil
+		}
+	}
+
+	d := decision{allowed: true, compartmentID: rules.compartmentID}
+	if len(rules.policies) == 0 {
+		d.reason = ReasonNoPolicy
+		d.message = fmt.Sprintf("Issuer %s has no policy", iss.Name)
+		return d, nil
+	}
+	csr, err := request()
+	if err != nil {
+		return decision{reason: ReasonInvalidCSR, message: fmt.Sprintf("Failed to decode CSR: %s", err)}, nil
+	}
+	requested := provisioner.DefaultDurationInterval
+	if duration != nil {
+		requested = duration.Duration
+	} else if iss.Spec.DefaultDuration != nil {
+		requested = iss.Spec.DefaultDuration.Duration
+	}
+	var messages, paths []string
+	reason := ""
+	for _, scoped := range rules.policies {
+		paths = append(paths, scoped.path)
+		err := policy.Evaluate(scoped.policy, csr, requested)
+		if err == nil {
+			continue
+		}
+		var violations policy.Violations
+		if !errors.As(err, &violations) {
+			return decision{}, fmt.Errorf("failed to evaluate %s of issuer %s: %w", scoped.path, iss.Name, err)
+		}
+		for _, v := range violations {
+			messages = append(messages, scoped.path+"."+v.Rule+": "+v.Message)
+		}
+		if reason == "" {
+			reason = policyReasons[strings.SplitN(violations[0].Rule, ".", 2)[0]]
+		}
+	}
+	if len(messages) > 0 {
+		return decision{
+			reason:  reason,
+			message: fmt.Sprintf("Denied by the policy of issuer %s: %s", iss.Name, strings.Join(messages, "; ")),
+		}, nil
+	}
+	d.reason = ReasonIssuerPolicy
+	if paths[len(paths)-1] != "spec.policy" {
+		d.reason = ReasonNamespaceOverride
+	}
+	d.message = fmt.Sprintf("Allowed by %s of issuer %s", strings.Join(paths, " and "), iss.Name)
+	return d, nil
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func Test_decide_compartment(t *testing.T) {
+	const (
+		authorityID  = "ocid1.certificateauthority.oc1.phx.aaaa"
+		teamA        = "ocid1.compartment.oc1.phx.teama"
+		teamB        = "ocid1.compartment.oc1.phx.teamb"
+		shared       = "ocid1.compartment.oc1.phx.shared"
+		overrideComp = "ocid1.compartment.oc1.phx.override"
+	)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routing := func(annotation bool) *v1alpha1.CompartmentRouting {
+		return &v1alpha1.CompartmentRouting{
+			Namespaces:          map[string]string{"team-a": teamA},
+			NamespaceAnnotation: annotation,
+			Default:             shared,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotation  string
+		spec        func(spec *v1alpha1.OCICAClusterIssuerSpec)
+		wantAllowed bool
+		wantID      string
+	}{
+		{name: "no routing", namespace: "team-a", spec: func(spec *v1alpha1.OCICAClusterIssuerSpec) {}, wantAllowed: true},
+		{
+			name:        "mapped namespace",
+			namespace:   "team-a",
+			spec:        func(spec *v1alpha1.OCICAClusterIssuerSpec) { spec.Compartments = routing(true) },
+			wantAllowed: true,
+			wantID:      teamA,
+		},
+		{
+			name:        "map wins over annotation",
+			namespace:   "team-a",
+			annotation:  teamB,
+			spec:        func(spec *v1alpha1.OCICAClusterIssuerSpec) { spec.Compartments = routing(true) },
+			wantAllowed: true,
+			wantID:      teamA,
+		},
+		{
+			name:        "annotated namespace",
+			namespace:   "team-b",
+			annotation:  teamB,
+			spec:        func(spec *v1alpha1.OCICAClusterIssuerSpec) { spec.Compartments = routing(true) },
+			wantAllowed: true,
+			wantID:      teamB,
+		},
+		{
+			name:        "annotation not allowed",
+			namespace:   "team-b",
+			annotation:  teamB,
+			spec:        func(spec *v1alpha1.OCICAClusterIssuerSpec) { spec.Compartments = routing(false) },
+			wantAllowed: true,
+			wantID:      shared,
+		},
+		{
+			name:        "default",
+			namespace:   "team-c",
+			spec:        func(spec *v1alpha1.OCICAClusterIssuerSpec) { spec.Compartments = routing(true) },
+			wantAllowed: true,
+			wantID:      shared,
+		},
+		{
+			name:       "invalid annotation",
+			namespace:  "team-b",
+			annotation: "ocid1.compartment.oc1.iad.teamb",
+			spec:       func(spec *v1alpha1.OCICAClusterIssuerSpec) { spec.Compartments = routing(true) },
+		},
+		{
+			name:      "namespace override wins",
+			namespace: "team-a",
+			spec: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Compartments = routing(true)
+				spec.NamespaceOverrides = []v1alpha1.NamespaceOverride{{CompartmentID: overrideComp}}
+			},
+			wantAllowed: true,
+			wantID:      overrideComp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = core.AddToScheme(scheme)
+			ns := &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace}}
+			if tt.annotation != "" {
+				ns.Annotations = map[string]string{v1alpha1.NamespaceCompartmentAnnotation: tt.annotation}
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns).Build()
+			iss := &v1alpha1.OCICAClusterIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "issuer1"},
+				Spec:       v1alpha1.OCICAClusterIssuerSpec{AuthorityID: authorityID},
+			}
+			tt.spec(&iss.Spec)
+			cr := &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace, Name: "cr1"},
+				Spec:       cmapi.CertificateRequestSpec{Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+			}
+			d, err := decide(context.TODO(), c, cr, iss)
+			if err != nil {
+				t.Fatalf("decide() error = %v", err)
+			}
+			if d.allowed != tt.wantAllowed {
+				t.Fatalf("decide() allowed = %v (%s: %s), want %v", d.allowed, d.reason, d.message, tt.wantAllowed)
+			}
+			if !d.allowed && d.reason != ReasonInvalidCompartment {
+				t.Errorf("decide() reason = %s, want %s", d.reason, ReasonInvalidCompartment)
+			}
+			if d.compartmentID != tt.wantID {
+				t.Errorf("decide() compartment = %q, want %q", d.compartmentID, tt.wantID)
+			}
+		})
+	}
+}
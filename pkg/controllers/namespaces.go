@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// namespaceRules are the parts of an issuer that apply to requests from one
+// namespace.
+type namespaceRules struct {
+	// policies must all allow a request.
+	policies      []scopedPolicy
+	compartmentID string
+}
+
+// scopedPolicy is a policy and the field it was taken from.
+type scopedPolicy struct {
+	policy *ocicav1alpha1.IssuancePolicy
+	path   string
+}
+
+// issuerRules returns the rules of iss for requests from any namespace.
+func issuerRules(iss *ocicav1alpha1.OCICAClusterIssuer) namespaceRules {
+	var rules namespaceRules
+	if iss.Spec.Policy != nil {
+		rules.policies = []scopedPolicy{{policy: iss.Spec.Policy, path: "spec.policy"}}
+	}
+	return rules
+}
+
+// rulesForNamespace returns the rules of iss for requests from ns, and false
+// when the namespace selector of iss does not select ns. The policy of a
+// matching override applies in addition to spec.policy, so that it can only
+// narrow what the issuer allows.
+func rulesForNamespace(iss *ocicav1alpha1.OCICAClusterIssuer, ns *core.Namespace) (namespaceRules, bool, error) {
+	rules := issuerRules(iss)
+	if iss.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(iss.Spec.NamespaceSelector)
+		if err != nil {
+			return rules, false, err
+		}
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			return rules, false, nil
+		}
+	}
+	for i, override := range iss.Spec.NamespaceOverrides {
+		override := override
+		selector, err := metav1.LabelSelectorAsSelector(&override.NamespaceSelector)
+		if err != nil {
+			return rules, false, err
+		}
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			continue
+		}
+		if override.Policy != nil {
+			rules.policies = append(rules.policies, scopedPolicy{policy: override.Policy, path: fmt.Sprintf("spec.namespaceOverrides[%d].policy", i)})
+		}
+		rules.compartmentID = override.CompartmentID
+		break
+	}
+	return rules, true, nil
+}
+
+// routeCompartment returns the compartment spec.compartments of iss creates
+// certificates for requests from namespace in, or empty for compartment_id.
+// ns is only read for its annotation, and may be nil when iss does not allow
+// it.
+func routeCompartment(iss *ocicav1alpha1.OCICAClusterIssuer, namespace string, ns *core.Namespace) (string, error) {
+	routing := iss.Spec.Compartments
+	if id, ok := routing.Namespaces[namespace]; ok {
+		return id, nil
+	}
+	if routing.NamespaceAnnotation && ns != nil {
+		if id := ns.Annotations[ocicav1alpha1.NamespaceCompartmentAnnotation]; id != "" {
+			if err := ocicav1alpha1.ValidateCompartmentAnnotation(id, iss.Spec.CertificateAuthorities()[0].AuthorityID); err != nil {
+				return "", err
+			}
+			return id, nil
+		}
+	}
+	return routing.Default, nil
+}
+
+// annotatedCompartments returns the compartments namespaces route the
+// certificates of iss to with their annotation. Invalid annotations are left
+// out, as requests from their namespace are refused.
+func annotatedCompartments(ctx context.Context, c client.Client, iss *ocicav1alpha1.OCICAClusterIssuer) ([]string, error) {
+	if iss.Spec.Compartments == nil || !iss.Spec.Compartments.NamespaceAnnotation {
+		return nil, nil
+	}
+	namespaces := new(core.NamespaceList)
+	if err := c.List(ctx, namespaces); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+		if id, err := routeCompartment(iss, ns.Name, ns); err == nil && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// usesNamespaces reports whether iss needs the namespace of a request to
+// decide how to sign it.
+func usesNamespaces(iss *ocicav1alpha1.OCICAClusterIssuer) bool {
+	return iss.Spec.NamespaceSelector != nil || len(iss.Spec.NamespaceOverrides) > 0 ||
+		(iss.Spec.Compartments != nil && iss.Spec.Compartments.NamespaceAnnotation)
+}
+
+// pendingRequestsInNamespace maps a Namespace to the CertificateRequests in it
+// that reference an issuer of this group and are not finished, so that they
+// are evaluated again when the labels or annotations of the namespace change.
+func (r *CertificateRequestReconciler) pendingRequestsInNamespace(obj client.Object) []reconcile.Request {
+	return r.pendingRequests(obj.GetName())
+}
+
+// pendingRequests lists the CertificateRequests in namespace that reference
+// an issuer of this group and are not finished.
+func (r *CertificateRequestReconciler) pendingRequests(namespace string) []reconcile.Request {
+	crs := new(cmapi.CertificateRequestList)
+	if err := r.Client.List(context.Background(), crs, client.InNamespace(namespace)); err != nil {
+		r.Log.Error(err, "failed to list CertificateRequests", "namespace", namespace)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+		if cr.Spec.IssuerRef.Group != ocicav1alpha1.GroupVersion.Group || finished(cr) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}})
+	}
+	return requests
+}
+
+// finished reports whether cr is issued, failed or denied.
+func finished(cr *cmapi.CertificateRequest) bool {
+	cond := cmutil.GetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionReady)
+	if cond == nil {
+		return false
+	}
+	return cond.Status == cmmeta.ConditionTrue ||
+		cond.Reason == cmapi.CertificateRequestReasonFailed ||
+		cond.Reason == cmapi.CertificateRequestReasonDenied
+}
@@ -2,42 +2,75 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
 	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
 	core "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/clock"
+	"net"
+	"net/http"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
 )
 
 const (
 	OCICAClusterIssuerKind = "OCICAClusterIssuer"
 )
 
-// CertificateRequestReconciler reconciles a AWSPCAIssuer object
+// CertificateRequestReconciler reconciles CertificateRequests referencing an
+// OCICAClusterIssuer
 type CertificateRequestReconciler struct {
+	Collection *provisioner.Collection
 	client.Client
 	Log      logr.Logger
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
 
+	// APIReader reads OCICAIssuanceQuotas uncached before they are updated.
+	// Defaults to Client.
+	APIReader client.Reader
+
+	// Shadows signs requests of issuers in Shadow mode again. SetupWithManager
+	// adds a default queue to the manager when it is nil.
+	Shadows *ShadowQueue
+
 	Clock                  clock.Clock
 	CheckApprovedCondition bool
+	// ClusterID identifies the cluster in the names and descriptions of
+	// OCI certificates.
+	ClusterID string
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
 }
 
-// +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicaissuancequotas,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicaissuancequotas/status,verbs=get;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -48,7 +81,7 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 	log := r.Log.WithValues("certificaterequest", req.NamespacedName)
 	cr := new(cmapi.CertificateRequest)
 	if err := r.Client.Get(ctx, req.NamespacedName, cr); err != nil {
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			return ctrl.Result{}, client.IgnoreNotFound(err)
 		}
 
@@ -121,13 +154,277 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 		issuerName.Namespace = ""
 	}
 
-	return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionTrue, cmapi.CertificateRequestReasonIssued, "certificate issued")
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, issuerName, iss); err != nil {
+		log.Error(err, "failed to get issuer")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to get issuer %s: %s", issuerName, err)
+		return ctrl.Result{}, err
+	}
+	if !issuerReady(iss) {
+		err := fmt.Errorf("issuer %s is not ready", issuerName)
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Issuer %s is not ready", issuerName)
+		return ctrl.Result{}, err
+	}
+	d, err := decide(ctx, r.Client, cr, iss)
+	if err != nil {
+		log.Error(err, "failed to check request against the issuer")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to check request against issuer %s: %s", issuerName, err)
+		return ctrl.Result{}, err
+	}
+	if !d.allowed {
+		// Failed rather than Denied: cert-manager only backs off and creates
+		// a new request for Failed requests, which is signed once the issuer
+		// allows it. Denied is left to approval controllers.
+		log.Info("CertificateRequest is refused by the issuer", "reason", d.reason)
+		if cr.Status.FailureTime == nil {
+			nowTime := metav1.NewTime(r.Clock.Now())
+			cr.Status.FailureTime = &nowTime
+		}
+		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "%s", d.message)
+	}
+
+	now := r.Clock.Now()
+	p, err := issuerPause(iss, now)
+	if err != nil {
+		log.Error(err, "failed to check whether the issuer is paused")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to check whether issuer %s is paused: %s", issuerName, err)
+		return ctrl.Result{}, err
+	}
+	if p != nil {
+		notAfter, err := r.existingNotAfter(ctx, cr)
+		if err != nil {
+			log.Error(err, "failed to get the certificate being renewed")
+			_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to get the certificate being renewed: %s", err)
+			return ctrl.Result{}, err
+		}
+		if held, retry := p.holds(notAfter, now); held {
+			log.Info("CertificateRequest waits for the issuer pause to end", "until", p.until)
+			if paused(cr, p.message) {
+				return ctrl.Result{RequeueAfter: retry}, nil
+			}
+			return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, ReasonPaused, "%s", p.message)
+		}
+		log.Info("Issuer is paused, but the certificate being renewed expires before the pause ends", "notAfter", notAfter)
+	}
+
+	var candidates []*provisioner.Authority
+	if authorities, ok := r.Collection.LoadAuthorities(issuerName); ok {
+		candidates = authorities.Candidates()
+	}
+	if len(candidates) == 0 {
+		err := fmt.Errorf("provisioner for %s not found", issuerName)
+		log.Error(err, "failed to load provisioner")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to load provisioner for issuer %s", issuerName)
+		return ctrl.Result{}, err
+	}
+
+	exceeded, err := r.checkQuotas(ctx, cr, now, reservedValidity(cr, iss), iss.Spec.Mode != ocicav1alpha1.IssuerModeDryRun)
+	if err != nil {
+		log.Error(err, "failed to check quotas")
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to check quotas: %s", err)
+		return ctrl.Result{}, err
+	}
+	if exceeded != nil {
+		log.Info("CertificateRequest is over quota", "quota", exceeded.quota.Name, "retryAt", exceeded.retryAt)
+		if exceeded.quota.Spec.OnExceeded == ocicav1alpha1.QuotaExceededDeny {
+			// Failed rather than a reason of our own, so that cert-manager
+			// backs off and retries with a new request.
+			if cr.Status.FailureTime == nil {
+				nowTime := metav1.NewTime(now)
+				cr.Status.FailureTime = &nowTime
+			}
+			return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "%s: quota %s: %s", ReasonQuotaExceeded, exceeded.quota.Name, exceeded.message)
+		}
+		err := r.setStatus(ctx, cr, cmmeta.ConditionFalse, ReasonQuotaExceeded, "Waiting for quota %s until %s: %s", exceeded.quota.Name, exceeded.retryAt.UTC().Format(time.RFC3339), exceeded.message)
+		return ctrl.Result{RequeueAfter: exceeded.retryAt.Sub(now)}, err
+	}
+
+	opts := provisioner.SignOptions{CompartmentID: d.compartmentID, ClusterID: r.ClusterID}
+	if iss.Spec.Mode == ocicav1alpha1.IssuerModeDryRun {
+		return r.dryRun(ctx, cr, candidates[0], opts, log)
+	}
+	cert, ca, authorityID, err := sign(ctx, cr, candidates, opts, log)
+	if err != nil {
+		if err := r.releaseQuotas(ctx, cr); err != nil {
+			log.Error(err, "failed to release quotas")
+		}
+		return r.signFailed(ctx, cr, err, log)
+	}
+	cr.Status.Certificate = cert
+	cr.Status.CA = ca
+
+	if err := r.setStatus(ctx, cr, cmmeta.ConditionTrue, cmapi.CertificateRequestReasonIssued, "certificate issued by %s", authorityID); err != nil {
+		return ctrl.Result{}, err
+	}
+	// The request is not signed again once issued, so failures here only
+	// leave the expiry of the certificate or its authority unrecorded.
+	if err := r.recordIssuance(ctx, cr, now); err != nil {
+		log.Error(err, "failed to count certificate against quotas")
+	}
+	patch := client.MergeFrom(cr.DeepCopy())
+	metav1.SetMetaDataAnnotation(&cr.ObjectMeta, ocicav1alpha1.AuthorityIDAnnotation, authorityID)
+	if err := r.Client.Patch(ctx, cr, patch); err != nil {
+		log.Error(err, "failed to record certificate authority on CertificateRequest")
+	}
+	if iss.Spec.Mode == ocicav1alpha1.IssuerModeShadow && iss.Spec.Shadow != nil {
+		r.queueShadow(cr.DeepCopy(), iss.Spec.Shadow.Name, cert, ca, log)
+	}
+	return ctrl.Result{}, nil
+}
+
+// signFailed records why cr could not be signed. Requests are retried when the
+// error may go away on its own, and failed otherwise.
+func (r *CertificateRequestReconciler) signFailed(ctx context.Context, cr *cmapi.CertificateRequest, err error, log logr.Logger) (ctrl.Result, error) {
+	log.Error(err, "failed to request certificate from OCI")
+	if retryable(err) {
+		_ = r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "Failed to request certificate from OCI: %s", err)
+		return ctrl.Result{}, err
+	}
+	if cr.Status.FailureTime == nil {
+		nowTime := metav1.NewTime(r.Clock.Now())
+		cr.Status.FailureTime = &nowTime
+	}
+	if errors.Is(err, provisioner.ErrUnsupportedUsages) {
+		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Requested usages are not supported by OCI: %s", err)
+	}
+	if errors.Is(err, provisioner.ErrIncompatibleCSR) {
+		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "CSR cannot be signed by OCI: %s", err)
+	}
+	if errors.Is(err, provisioner.ErrCertificateExists) {
+		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Certificate name is taken in OCI: %s", err)
+	}
+	if errors.Is(err, provisioner.ErrPolicyViolation) {
+		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Request violates the issuer policy: %s", err)
+	}
+	if errors.Is(err, provisioner.ErrNotAuthorized) {
+		return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed,
+			"Issuer credentials may not create certificates in the compartment of the request, check their OCI policies: %s", err)
+	}
+	return ctrl.Result{}, r.setStatus(ctx, cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "Failed to request certificate from OCI: %s", err)
+}
+
+// dryRun checks cr as authority would sign it and records the certificate it
+// would create in the DryRunAnnotation, leaving cr unsigned. Requests are
+// checked once, until the issuer leaves DryRun mode.
+func (r *CertificateRequestReconciler) dryRun(ctx context.Context, cr *cmapi.CertificateRequest, authority *provisioner.Authority, opts provisioner.SignOptions, log logr.Logger) (ctrl.Result, error) {
+	if cmutil.CertificateRequestHasCondition(cr, cmapi.CertificateRequestCondition{
+		Type:   cmapi.CertificateRequestConditionReady,
+		Status: cmmeta.ConditionFalse,
+		Reason: ReasonDryRun,
+	}) {
+		return ctrl.Result{}, nil
+	}
+	plan, err := authority.Provisioner.Plan(ctx, cr, opts)
+	if err != nil {
+		return r.signFailed(ctx, cr, err, log)
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// The condition is set first so that the update of the annotation does
+	// not check the request again.
+	if err := r.setStatus(ctx, cr, cmmeta.ConditionFalse, ReasonDryRun, "Dry run: certificate %s would be signed by %s in compartment %s",
+		plan.Name, plan.AuthorityID, plan.CompartmentID); err != nil {
+		return ctrl.Result{}, err
+	}
+	patch := client.MergeFrom(cr.DeepCopy())
+	metav1.SetMetaDataAnnotation(&cr.ObjectMeta, ocicav1alpha1.DryRunAnnotation, string(data))
+	return ctrl.Result{}, r.Client.Patch(ctx, cr, patch)
+}
+
+// sign signs cr with the first of candidates that does not fail with an error
+// that may go away on its own, and returns the OCID of the certificate
+// authority that signed it. A certificate an earlier attempt created with
+// any of candidates is returned instead of signing cr again.
+func sign(ctx context.Context, cr *cmapi.CertificateRequest, candidates []*provisioner.Authority, opts provisioner.SignOptions, log logr.Logger) ([]byte, []byte, string, error) {
+	var err error
+	for _, authority := range candidates {
+		authorityLog := log.WithValues("authority", authority.ID)
+		var cert, ca []byte
+		cert, ca, err = authority.Provisioner.Sign(ctx, cr, opts, authorityLog)
+		if err == nil {
+			return cert, ca, authority.ID, nil
+		}
+		if errors.Is(err, provisioner.ErrCertificateExists) {
+			// Authorities sharing a compartment name certificates the
+			// same, so the name may be taken by the certificate another
+			// of them signed before failing over.
+			return signedByOther(ctx, cr, candidates, authority, opts, log, err)
+		}
+		if !retryable(err) {
+			return nil, nil, "", err
+		}
+		// The certificate may have been created even though signing
+		// failed, and failing over would sign cr twice.
+		cert, ca, signedErr := authority.Provisioner.Signed(ctx, cr, opts, authorityLog)
+		if signedErr == nil && cert != nil {
+			return cert, ca, authority.ID, nil
+		}
+		authorityLog.Error(err, "failed to request certificate from certificate authority, failing over")
+	}
+	return nil, nil, "", err
+}
+
+// signedByOther returns the certificate an earlier attempt created for cr with
+// one of candidates other than taken, whose certificate name is taken. It
+// returns err when there is none.
+func signedByOther(ctx context.Context, cr *cmapi.CertificateRequest, candidates []*provisioner.Authority, taken *provisioner.Authority,
+	opts provisioner.SignOptions, log logr.Logger, err error) ([]byte, []byte, string, error) {
+	for _, authority := range candidates {
+		if authority == taken {
+			continue
+		}
+		cert, ca, signedErr := authority.Provisioner.Signed(ctx, cr, opts, log.WithValues("authority", authority.ID))
+		if signedErr != nil {
+			return nil, nil, "", signedErr
+		}
+		if cert != nil {
+			return cert, ca, authority.ID, nil
+		}
+	}
+	return nil, nil, "", err
+}
+
+// issuerReady reports whether iss has a true Ready condition.
+func issuerReady(iss *ocicav1alpha1.OCICAClusterIssuer) bool {
+	for _, condition := range iss.Status.Conditions {
+		if condition.Type == string(ocicav1alpha1.ConditionReady) {
+			return condition.Status == metav1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// retryable reports whether a signing error may go away on its own, such as
+// throttling or an OCI outage, rather than needing a new request.
+func retryable(err error) bool {
+	serviceErr, ok := common.IsServiceError(err)
+	if !ok {
+		var netErr net.Error
+		return errors.As(err, &netErr)
+	}
+	status := serviceErr.GetHTTPStatusCode()
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *CertificateRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Shadows == nil {
+		r.Shadows = &ShadowQueue{}
+	}
+	if err := mgr.Add(r.Shadows); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&cmapi.CertificateRequest{}).
+		Watches(&source.Kind{Type: &core.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.pendingRequestsInNamespace),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Watches(&source.Kind{Type: &ocicav1alpha1.OCICAIssuanceQuota{}}, handler.EnqueueRequestsFromMapFunc(r.pendingRequestsForQuota),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &ocicav1alpha1.OCICAClusterIssuer{}}, handler.EnqueueRequestsFromMapFunc(r.pendingRequestsForIssuer),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
@@ -2,15 +2,37 @@ package controllers
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+	"net/http"
 	"reflect"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCertificateRequestReconciler_Reconcile(t *testing.T) {
@@ -36,16 +58,20 @@ func TestCertificateRequestReconciler_Reconcile(t *testing.T) {
 		{
 			name: "valid sign",
 			fields: fields{
-				Log:                    logr.Logger{},
+				Log:                    logr.Discard(),
 				Scheme:                 runtime.NewScheme(),
 				Recorder:               record.NewFakeRecorder(10),
 				Clock:                  nil,
 				CheckApprovedCondition: false,
 			},
+			args: args{
+				ctx: context.TODO(),
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			cmapi.AddToScheme(tt.fields.Scheme)
 			r := &CertificateRequestReconciler{
 				Client: fake.NewClientBuilder().
 					WithScheme(tt.fields.Scheme).
@@ -68,3 +94,844 @@ func TestCertificateRequestReconciler_Reconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestCertificateRequestReconciler_Reconcile_sign(t *testing.T) {
+	ctx := context.TODO()
+	fakeOCI := ocifake.NewServer(ocifake.Options{})
+	defer fakeOCI.Close()
+	authorityID, err := fakeOCI.CreateRootCA("ocid1.compartment.oc1..aaaa", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fakeOCI.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer1"},
+		Spec: v1alpha1.OCICAClusterIssuerSpec{
+			TenancyID:     "ocid1.tenancy.oc1..aaaa",
+			CompartmentID: "ocid1.compartment.oc1..aaaa",
+			AuthorityID:   authorityID,
+			EndpointOverride: &v1alpha1.EndpointOverride{
+				CertificatesManagement: fakeOCI.URL,
+				Certificates:           fakeOCI.URL,
+			},
+		},
+		Status: v1alpha1.OCICAClusterIssuerStatus{Conditions: []metav1.Condition{
+			{Type: string(v1alpha1.ConditionReady), Status: metav1.ConditionTrue},
+		}},
+	}
+	p, err := provisioner.New(logr.Discard(), *iss, configProvider, provisioner.Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection := &provisioner.Collection{}
+	collection.Store(types.NamespacedName{Name: iss.Name}, p)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(name string, approved bool) *cmapi.CertificateRequest {
+		cr := &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name, UID: types.UID(name + "-uid")},
+			Spec: cmapi.CertificateRequestSpec{
+				Request:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+				IssuerRef: cmmeta.ObjectReference{Group: v1alpha1.GroupVersion.Group, Kind: OCICAClusterIssuerKind, Name: iss.Name},
+			},
+		}
+		if approved {
+			cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "test", "approved")
+		}
+		return cr
+	}
+
+	one := int32(1)
+	newQuota := func(spec v1alpha1.OCICAIssuanceQuotaSpec, issued ...string) *v1alpha1.OCICAIssuanceQuota {
+		q := &v1alpha1.OCICAIssuanceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "quota"}, Spec: spec}
+		for _, name := range issued {
+			q.Status.Issued = append(q.Status.Issued, v1alpha1.IssuedCertificate{
+				Request:    name,
+				RequestUID: types.UID(name + "-uid"),
+				IssuedAt:   metav1.NewTime(time.Now().Add(-time.Minute)),
+				NotAfter:   metav1.NewTime(time.Now().Add(time.Hour)),
+			})
+		}
+		return q
+	}
+
+	tests := []struct {
+		name      string
+		cr        *cmapi.CertificateRequest
+		disableCA bool
+		// fault is injected into the fake OCI while the request is signed.
+		fault  *ocifake.Fault
+		issuer func(spec *v1alpha1.OCICAClusterIssuerSpec)
+		// quota is created in the namespace of the request when set.
+		quota      *v1alpha1.OCICAIssuanceQuota
+		wantErr    bool
+		wantReason string
+		// wantMessage is contained in the Ready message when set.
+		wantMessage string
+		wantCert    bool
+		wantRequeue bool
+		// wantIssued is the number of certificates recorded in quota.
+		wantIssued int
+	}{
+		{name: "approved", cr: newRequest("approved", true), wantReason: cmapi.CertificateRequestReasonIssued, wantCert: true},
+		{name: "not approved", cr: newRequest("pending", false)},
+		{
+			name:       "CA disabled",
+			cr:         newRequest("disabled", true),
+			disableCA:  true,
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name:        "not authorized to create certificates",
+			cr:          newRequest("read-only", true),
+			fault:       &ocifake.Fault{Operation: "CreateCertificate", Status: http.StatusNotFound, Code: "NotAuthorizedOrNotFound"},
+			wantReason:  cmapi.CertificateRequestReasonFailed,
+			wantMessage: "Issuer credentials may not create certificates",
+		},
+		{
+			name: "unsupported usages",
+			cr: func() *cmapi.CertificateRequest {
+				cr := newRequest("email", true)
+				cr.Spec.Usages = []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageEmailProtection}
+				return cr
+			}(),
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name: "incompatible CSR",
+			cr: func() *cmapi.CertificateRequest {
+				cr := newRequest("p224", true)
+				p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, p224)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cr.Spec.Request = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+				return cr
+			}(),
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name: "policy allows",
+			cr:   newRequest("allowed", true),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"example.com"}}}
+			},
+			wantReason: cmapi.CertificateRequestReasonIssued,
+			wantCert:   true,
+		},
+		{
+			name: "policy denies",
+			cr:   newRequest("denied", true),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Deny: []string{"*.com"}}}
+			},
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name: "namespace selected",
+			cr:   newRequest("selected", true),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+			},
+			wantReason: cmapi.CertificateRequestReasonIssued,
+			wantCert:   true,
+		},
+		{
+			name: "namespace not selected",
+			cr:   newRequest("not-selected", true),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}}
+			},
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name: "namespace override policy",
+			cr:   newRequest("override", true),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"example.com"}}}
+				spec.NamespaceOverrides = []v1alpha1.NamespaceOverride{
+					{
+						NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}},
+					},
+					{
+						NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+						Policy:            &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"*.a.example.com"}}},
+					},
+				}
+			},
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name:       "under quota",
+			cr:         newRequest("under-quota", true),
+			quota:      newQuota(v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: &one}),
+			wantReason: cmapi.CertificateRequestReasonIssued,
+			wantCert:   true,
+			wantIssued: 1,
+		},
+		{
+			name:        "over quota",
+			cr:          newRequest("over-quota", true),
+			quota:       newQuota(v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: &one}, "earlier"),
+			wantReason:  ReasonQuotaExceeded,
+			wantRequeue: true,
+			wantIssued:  1,
+		},
+		{
+			name:       "over quota denies",
+			cr:         newRequest("over-quota-deny", true),
+			quota:      newQuota(v1alpha1.OCICAIssuanceQuotaSpec{MaxLiveCertificates: &one, OnExceeded: v1alpha1.QuotaExceededDeny}, "earlier"),
+			wantReason: cmapi.CertificateRequestReasonFailed,
+			wantIssued: 1,
+		},
+		{
+			name:       "quota released when signing fails",
+			cr:         newRequest("failed-quota", true),
+			disableCA:  true,
+			quota:      newQuota(v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: &one}),
+			wantReason: cmapi.CertificateRequestReasonFailed,
+		},
+		{
+			name:       "quota of another issuer",
+			cr:         newRequest("other-quota", true),
+			quota:      newQuota(v1alpha1.OCICAIssuanceQuotaSpec{IssuerNames: []string{"other"}, MaxIssuances: &one}, "earlier"),
+			wantReason: cmapi.CertificateRequestReasonIssued,
+			wantCert:   true,
+			wantIssued: 1,
+		},
+		{
+			name: "unknown issuer",
+			cr: func() *cmapi.CertificateRequest {
+				cr := newRequest("unknown", true)
+				cr.Spec.IssuerRef.Name = "missing"
+				return cr
+			}(),
+			wantErr:    true,
+			wantReason: cmapi.CertificateRequestReasonPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := certificatesmanagement.CertificateAuthorityLifecycleStateActive
+			if tt.disableCA {
+				state = certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion
+			}
+			if err := fakeOCI.SetCertificateAuthorityState(authorityID, state); err != nil {
+				t.Fatal(err)
+			}
+			if tt.fault != nil {
+				fakeOCI.InjectFault(*tt.fault)
+				defer fakeOCI.ClearFaults()
+			}
+			scheme := runtime.NewScheme()
+			_ = core.AddToScheme(scheme)
+			_ = cmapi.AddToScheme(scheme)
+			_ = v1alpha1.AddToScheme(scheme)
+			iss := iss.DeepCopy()
+			if tt.issuer != nil {
+				tt.issuer(&iss.Spec)
+			}
+			ns := &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1", Labels: map[string]string{"team": "a"}}}
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss, ns, tt.cr.DeepCopy())
+			if tt.quota != nil {
+				builder = builder.WithObjects(tt.quota.DeepCopy())
+			}
+			c := builder.Build()
+			r := &CertificateRequestReconciler{
+				Collection:             collection,
+				Client:                 c,
+				Log:                    logr.Discard(),
+				Scheme:                 scheme,
+				Recorder:               record.NewFakeRecorder(10),
+				Clock:                  clock.RealClock{},
+				CheckApprovedCondition: true,
+			}
+			key := client.ObjectKeyFromObject(tt.cr)
+			result, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if requeue := result.RequeueAfter > 0; requeue != tt.wantRequeue {
+				t.Errorf("Reconcile() RequeueAfter = %s, want requeue %v", result.RequeueAfter, tt.wantRequeue)
+			}
+			got := new(cmapi.CertificateRequest)
+			if err := c.Get(ctx, key, got); err != nil {
+				t.Fatal(err)
+			}
+			reason, message := "", ""
+			if cond := cmutil.GetCertificateRequestCondition(got, cmapi.CertificateRequestConditionReady); cond != nil {
+				reason, message = cond.Reason, cond.Message
+			}
+			if reason != tt.wantReason {
+				t.Errorf("Ready reason got = %q, want %q", reason, tt.wantReason)
+			}
+			if !strings.Contains(message, tt.wantMessage) {
+				t.Errorf("Ready message got = %q, want it to contain %q", message, tt.wantMessage)
+			}
+			if hasCert := len(got.Status.Certificate) > 0 && len(got.Status.CA) > 0; hasCert != tt.wantCert {
+				t.Errorf("signed got = %v, want %v", hasCert, tt.wantCert)
+			}
+			if tt.wantCert && got.Annotations[v1alpha1.AuthorityIDAnnotation] != authorityID {
+				t.Errorf("annotations got = %v, want authority %s", got.Annotations, authorityID)
+			}
+			if tt.quota != nil {
+				q := new(v1alpha1.OCICAIssuanceQuota)
+				if err := c.Get(ctx, client.ObjectKeyFromObject(tt.quota), q); err != nil {
+					t.Fatal(err)
+				}
+				if len(q.Status.Issued) != tt.wantIssued {
+					t.Errorf("quota issued got = %v, want %d certificates", q.Status.Issued, tt.wantIssued)
+				}
+			}
+		})
+	}
+
+	// A request whose certificate was created but could not be fetched
+	// resumes from it, instead of failing on the taken name.
+	scheme := runtime.NewScheme()
+	_ = core.AddToScheme(scheme)
+	_ = cmapi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	cr := newRequest("resumed", true)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss.DeepCopy(), cr).Build()
+	r := &CertificateRequestReconciler{
+		Collection: collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Scheme:     scheme,
+		Recorder:   record.NewFakeRecorder(10),
+		Clock:      clock.RealClock{},
+	}
+	certificates := len(fakeOCI.Certificates())
+	// Twice, as signing looks for the certificate again before giving up.
+	fakeOCI.InjectFault(ocifake.Fault{Operation: "GetCertificateBundle", Status: http.StatusServiceUnavailable, Times: 2})
+	resumed := client.ObjectKeyFromObject(cr)
+	if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: resumed}); err == nil {
+		t.Fatalf("Reconcile() with the bundle unavailable should fail")
+	}
+	if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: resumed}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	got := new(cmapi.CertificateRequest)
+	if err := c.Get(ctx, resumed, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Status.Certificate) == 0 {
+		t.Errorf("resumed request was not signed: %v", got.Status.Conditions)
+	}
+	if created := len(fakeOCI.Certificates()) - certificates; created != 1 {
+		t.Errorf("certificates created got = %d, want 1", created)
+	}
+}
+
+func TestCertificateRequestReconciler_Reconcile_failover(t *testing.T) {
+	ctx := context.TODO()
+	withTestOCIConfig(t)
+	fakeOCI := ocifake.NewServer(ocifake.Options{})
+	defer fakeOCI.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	primaryID, err := fakeOCI.CreateRootCA(compartmentID, "primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondaryID, err := fakeOCI.CreateRootCA(compartmentID, "secondary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(name string) *cmapi.CertificateRequest {
+		cr := &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name},
+			Spec: cmapi.CertificateRequestSpec{
+				Request:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+				IssuerRef: cmmeta.ObjectReference{Group: v1alpha1.GroupVersion.Group, Kind: OCICAClusterIssuerKind, Name: "issuer1"},
+			},
+		}
+		cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "test", "approved")
+		return cr
+	}
+	iss := &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer1"},
+		Spec: v1alpha1.OCICAClusterIssuerSpec{
+			TenancyID:     "ocid1.tenancy.oc1..aaaa",
+			CompartmentID: compartmentID,
+			Authorities: []v1alpha1.CertificateAuthority{
+				{AuthorityID: primaryID, Weight: 1},
+				{AuthorityID: secondaryID, Priority: 1, Weight: 1},
+			},
+			EndpointOverride: &v1alpha1.EndpointOverride{
+				CertificatesManagement: fakeOCI.URL,
+				Certificates:           fakeOCI.URL,
+			},
+		},
+	}
+	crs := []*cmapi.CertificateRequest{newRequest("disabled"), newRequest("unavailable"), newRequest("created"), newRequest("conflict")}
+
+	scheme := runtime.NewScheme()
+	_ = core.AddToScheme(scheme)
+	_ = cmapi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss, crs[0], crs[1], crs[2], crs[3]).Build()
+	collection := &provisioner.Collection{}
+	issuerReconciler := &OCICAClusterIssuerReconciler{Collection: collection, Client: c, Scheme: scheme, Clock: clock.RealClock{}}
+	r := &CertificateRequestReconciler{
+		Collection: collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Scheme:     scheme,
+		Recorder:   record.NewFakeRecorder(10),
+		Clock:      clock.RealClock{},
+	}
+	// reconcile validates the issuer, then signs cr and returns the
+	// authority that signed it.
+	reconcile := func(cr *cmapi.CertificateRequest, wantIssuerReason string) string {
+		t.Helper()
+		if _, err := issuerReconciler.Reconcile(ctx, controllerruntime.Request{NamespacedName: client.ObjectKeyFromObject(iss)}); err != nil {
+			t.Fatalf("issuer Reconcile() error = %v", err)
+		}
+		got := new(v1alpha1.OCICAClusterIssuer)
+		if err := c.Get(ctx, client.ObjectKeyFromObject(iss), got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Status.Conditions) != 1 || got.Status.Conditions[0].Reason != wantIssuerReason {
+			t.Errorf("issuer conditions = %v, want %s", got.Status.Conditions, wantIssuerReason)
+		}
+		if len(got.Status.Authorities) != 2 || got.Status.Authorities[1].AuthorityID != secondaryID || !got.Status.Authorities[1].Ready {
+			t.Errorf("issuer authorities = %+v, want %s ready", got.Status.Authorities, secondaryID)
+		}
+
+		key := client.ObjectKeyFromObject(cr)
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		signed := new(cmapi.CertificateRequest)
+		if err := c.Get(ctx, key, signed); err != nil {
+			t.Fatal(err)
+		}
+		if len(signed.Status.Certificate) == 0 {
+			t.Fatalf("%s was not signed: %v", cr.Name, signed.Status.Conditions)
+		}
+		return signed.Annotations[v1alpha1.AuthorityIDAnnotation]
+	}
+
+	if err := fakeOCI.SetCertificateAuthorityState(primaryID, certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion); err != nil {
+		t.Fatal(err)
+	}
+	if got := reconcile(crs[0], "Degraded"); got != secondaryID {
+		t.Errorf("with the primary authority disabled, signed by %s, want %s", got, secondaryID)
+	}
+
+	if err := fakeOCI.SetCertificateAuthorityState(primaryID, certificatesmanagement.CertificateAuthorityLifecycleStateActive); err != nil {
+		t.Fatal(err)
+	}
+	fakeOCI.InjectFault(ocifake.Fault{Operation: "CreateCertificate", Status: http.StatusServiceUnavailable, Times: 1})
+	if got := reconcile(crs[1], "Verified"); got != secondaryID {
+		t.Errorf("with the primary authority unavailable, signed by %s, want %s", got, secondaryID)
+	}
+
+	// A certificate the primary authority created before failing is used
+	// rather than signing the request again with the secondary.
+	created := len(fakeOCI.Certificates())
+	fakeOCI.InjectFault(ocifake.Fault{Operation: "GetCertificateBundle", Status: http.StatusServiceUnavailable, Times: 1})
+	if got := reconcile(crs[2], "Verified"); got != primaryID {
+		t.Errorf("with the certificate created by the primary authority, signed by %s, want %s", got, primaryID)
+	}
+	if got := len(fakeOCI.Certificates()) - created; got != 1 {
+		t.Errorf("with the certificate created by the primary authority, %d certificates created, want 1", got)
+	}
+
+	// When the certificate of the primary authority cannot be fetched
+	// before failing over, the secondary finds its name taken by it.
+	created = len(fakeOCI.Certificates())
+	fakeOCI.InjectFault(ocifake.Fault{Operation: "GetCertificateBundle", Status: http.StatusServiceUnavailable, Times: 2})
+	if got := reconcile(crs[3], "Verified"); got != primaryID {
+		t.Errorf("with the name taken by the primary authority, signed by %s, want %s", got, primaryID)
+	}
+	if got := len(fakeOCI.Certificates()) - created; got != 1 {
+		t.Errorf("with the name taken by the primary authority, %d certificates created, want 1", got)
+	}
+}
+
+func TestCertificateRequestReconciler_Reconcile_modes(t *testing.T) {
+	ctx := context.TODO()
+	withTestOCIConfig(t)
+	fakeOCI := ocifake.NewServer(ocifake.Options{})
+	defer fakeOCI.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	authorityID, err := fakeOCI.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAuthorityID, err := fakeOCI.CreateRootCA(compartmentID, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(name string) *cmapi.CertificateRequest {
+		cr := &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name},
+			Spec: cmapi.CertificateRequestSpec{
+				Request:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+				IssuerRef: cmmeta.ObjectReference{Group: v1alpha1.GroupVersion.Group, Kind: OCICAClusterIssuerKind, Name: "issuer1"},
+			},
+		}
+		cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "test", "approved")
+		return cr
+	}
+	newIssuer := func(name, authorityID string) *v1alpha1.OCICAClusterIssuer {
+		return &v1alpha1.OCICAClusterIssuer{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.OCICAClusterIssuerSpec{
+				TenancyID:     "ocid1.tenancy.oc1..aaaa",
+				CompartmentID: compartmentID,
+				AuthorityID:   authorityID,
+				EndpointOverride: &v1alpha1.EndpointOverride{
+					CertificatesManagement: fakeOCI.URL,
+					Certificates:           fakeOCI.URL,
+				},
+			},
+		}
+	}
+	iss := newIssuer("issuer1", authorityID)
+	iss.Spec.Mode = v1alpha1.IssuerModeDryRun
+	same, other := newIssuer("same", authorityID), newIssuer("other", otherAuthorityID)
+	crs := []*cmapi.CertificateRequest{newRequest("dry-run"), newRequest("shadow-same"), newRequest("shadow-other")}
+
+	scheme := runtime.NewScheme()
+	_ = core.AddToScheme(scheme)
+	_ = cmapi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss, same, other, crs[0], crs[1], crs[2]).Build()
+	collection := &provisioner.Collection{}
+	issuerReconciler := &OCICAClusterIssuerReconciler{Collection: collection, Client: c, Scheme: scheme, Clock: clock.RealClock{}}
+	for _, i := range []*v1alpha1.OCICAClusterIssuer{iss, same, other} {
+		if _, err := issuerReconciler.Reconcile(ctx, controllerruntime.Request{NamespacedName: client.ObjectKeyFromObject(i)}); err != nil {
+			t.Fatalf("issuer Reconcile() error = %v", err)
+		}
+	}
+	recorder := record.NewFakeRecorder(10)
+	shadows := &ShadowQueue{Workers: 1}
+	shadowCtx, stopShadows := context.WithCancel(ctx)
+	defer stopShadows()
+	go func() { _ = shadows.Start(shadowCtx) }()
+	r := &CertificateRequestReconciler{
+		Collection: collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Scheme:     scheme,
+		Recorder:   recorder,
+		Clock:      clock.RealClock{},
+		Shadows:    shadows,
+	}
+	reconcile := func(cr *cmapi.CertificateRequest) *cmapi.CertificateRequest {
+		t.Helper()
+		key := client.ObjectKeyFromObject(cr)
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		got := new(cmapi.CertificateRequest)
+		if err := c.Get(ctx, key, got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	// event waits for the event of reason recorded for a shadow certificate.
+	event := func(reason string) string {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case e := <-recorder.Events:
+				if strings.Contains(e, " "+reason+" ") {
+					return e
+				}
+				if strings.Contains(e, "Shadow") {
+					t.Fatalf("got event %q, want %s", e, reason)
+				}
+			case <-timeout:
+				t.Fatalf("no %s event", reason)
+			}
+		}
+	}
+
+	dryRun := reconcile(crs[0])
+	if len(dryRun.Status.Certificate) != 0 {
+		t.Errorf("dry run signed the request")
+	}
+	if !cmutil.CertificateRequestHasCondition(dryRun, cmapi.CertificateRequestCondition{
+		Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionFalse, Reason: ReasonDryRun,
+	}) {
+		t.Errorf("dry run conditions = %v, want %s", dryRun.Status.Conditions, ReasonDryRun)
+	}
+	var plan provisioner.CertificatePlan
+	if err := json.Unmarshal([]byte(dryRun.Annotations[v1alpha1.DryRunAnnotation]), &plan); err != nil {
+		t.Fatalf("dry run annotation %q: %v", dryRun.Annotations[v1alpha1.DryRunAnnotation], err)
+	}
+	if plan.AuthorityID != authorityID || plan.CompartmentID != compartmentID || plan.Name != "CertificateRequest_ns1_dry-run" || !plan.NotAfter.After(plan.NotBefore) {
+		t.Errorf("dry run plan = %+v", plan)
+	}
+	if again := reconcile(dryRun); again.Annotations[v1alpha1.DryRunAnnotation] != dryRun.Annotations[v1alpha1.DryRunAnnotation] {
+		t.Errorf("dry run checked the request again")
+	}
+
+	iss = new(v1alpha1.OCICAClusterIssuer)
+	if err := c.Get(ctx, types.NamespacedName{Name: "issuer1"}, iss); err != nil {
+		t.Fatal(err)
+	}
+	iss.Spec.Mode = v1alpha1.IssuerModeShadow
+	iss.Spec.Shadow = &v1alpha1.ShadowIssuer{Name: "same"}
+	if err := c.Update(ctx, iss); err != nil {
+		t.Fatal(err)
+	}
+	if signed := reconcile(crs[1]); len(signed.Status.Certificate) == 0 {
+		t.Fatalf("shadow mode did not sign the request: %v", signed.Status.Conditions)
+	}
+	event(ReasonShadowMatched)
+	configProvider, err := fakeOCI.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caClient, err := certificatesmanagement.NewCertificatesManagementClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caClient.Host = fakeOCI.URL
+	shadowCerts, err := caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId: common.String(compartmentID),
+		Name:          common.String("CertificateRequest_ns1_shadow-same-shadow"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shadowCerts.Items) != 1 || shadowCerts.Items[0].LifecycleState != certificatesmanagement.CertificateLifecycleStatePendingDeletion {
+		t.Errorf("shadow certificates = %+v, want one pending deletion", shadowCerts.Items)
+	}
+
+	iss.Spec.Shadow.Name = "other"
+	if err := c.Update(ctx, iss); err != nil {
+		t.Fatal(err)
+	}
+	if signed := reconcile(crs[2]); len(signed.Status.Certificate) == 0 {
+		t.Fatalf("shadow mode did not sign the request: %v", signed.Status.Conditions)
+	}
+	if e := event(ReasonShadowDiverged); !strings.Contains(e, "root CAs differ") {
+		t.Errorf("divergence event %q does not report the root CA", e)
+	}
+}
+
+func TestCertificateRequestReconciler_Reconcile_paused(t *testing.T) {
+	ctx := context.TODO()
+	withTestOCIConfig(t)
+	fakeOCI := ocifake.NewServer(ocifake.Options{})
+	defer fakeOCI.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	authorityID, err := fakeOCI.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An hour into the Saturday night maintenance window.
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	for now.Weekday() != time.Saturday {
+		now = now.Add(24 * time.Hour)
+	}
+	now = now.Add(23 * time.Hour)
+	windowEnd := now.Add(3 * time.Hour)
+
+	newRequest := func(name, certificate string) *cmapi.CertificateRequest {
+		cr := &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name},
+			Spec: cmapi.CertificateRequestSpec{
+				Request:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+				IssuerRef: cmmeta.ObjectReference{Group: v1alpha1.GroupVersion.Group, Kind: OCICAClusterIssuerKind, Name: "issuer1"},
+			},
+		}
+		if certificate != "" {
+			metav1.SetMetaDataAnnotation(&cr.ObjectMeta, cmapi.CertificateNameKey, certificate)
+		}
+		cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "test", "approved")
+		return cr
+	}
+	newCertificate := func(name string, notAfter time.Time) *cmapi.Certificate {
+		return &cmapi.Certificate{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name},
+			Status:     cmapi.CertificateStatus{NotAfter: &metav1.Time{Time: notAfter}},
+		}
+	}
+	iss := &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer1"},
+		Spec: v1alpha1.OCICAClusterIssuerSpec{
+			TenancyID:     "ocid1.tenancy.oc1..aaaa",
+			CompartmentID: compartmentID,
+			AuthorityID:   authorityID,
+			EndpointOverride: &v1alpha1.EndpointOverride{
+				CertificatesManagement: fakeOCI.URL,
+				Certificates:           fakeOCI.URL,
+			},
+			MaintenanceWindows: []v1alpha1.MaintenanceWindow{
+				{Schedule: "0 22 * * SAT", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+			},
+		},
+	}
+	crs := []*cmapi.CertificateRequest{
+		newRequest("new", ""),
+		newRequest("expiring", "expiring"),
+		newRequest("valid", "valid"),
+	}
+
+	scheme := runtime.NewScheme()
+	_ = core.AddToScheme(scheme)
+	_ = cmapi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss, crs[0], crs[1], crs[2],
+		newCertificate("expiring", now.Add(time.Hour)), newCertificate("valid", now.Add(30*24*time.Hour))).Build()
+	collection := &provisioner.Collection{}
+	issuerReconciler := &OCICAClusterIssuerReconciler{Collection: collection, Client: c, Scheme: scheme, Clock: clock.RealClock{}}
+	if _, err := issuerReconciler.Reconcile(ctx, controllerruntime.Request{NamespacedName: client.ObjectKeyFromObject(iss)}); err != nil {
+		t.Fatalf("issuer Reconcile() error = %v", err)
+	}
+	r := &CertificateRequestReconciler{
+		Collection: collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Scheme:     scheme,
+		Recorder:   record.NewFakeRecorder(20),
+		Clock:      clocktesting.NewFakeClock(now),
+	}
+	reconcile := func(cr *cmapi.CertificateRequest) (*cmapi.CertificateRequest, time.Duration) {
+		t.Helper()
+		key := client.ObjectKeyFromObject(cr)
+		result, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		got := new(cmapi.CertificateRequest)
+		if err := c.Get(ctx, key, got); err != nil {
+			t.Fatal(err)
+		}
+		return got, result.RequeueAfter
+	}
+	wantPaused := func(cr *cmapi.CertificateRequest) {
+		t.Helper()
+		if len(cr.Status.Certificate) != 0 || !cmutil.CertificateRequestHasCondition(cr, cmapi.CertificateRequestCondition{
+			Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionFalse, Reason: ReasonPaused,
+		}) {
+			t.Errorf("request %s conditions = %v, want %s", cr.Name, cr.Status.Conditions, ReasonPaused)
+		}
+	}
+
+	got, requeue := reconcile(crs[0])
+	wantPaused(got)
+	if requeue != windowEnd.Sub(now) {
+		t.Errorf("requeue after %s, want the end of the window in %s", requeue, windowEnd.Sub(now))
+	}
+	if got, _ := reconcile(crs[1]); len(got.Status.Certificate) == 0 {
+		t.Errorf("renewal of a certificate expiring in the window was paused: %v", got.Status.Conditions)
+	}
+	got, _ = reconcile(crs[2])
+	wantPaused(got)
+
+	// Paused with the annotation once the window is over.
+	r.Clock = clocktesting.NewFakeClock(windowEnd)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(iss), iss); err != nil {
+		t.Fatal(err)
+	}
+	metav1.SetMetaDataAnnotation(&iss.ObjectMeta, v1alpha1.PausedAnnotation, "true")
+	if err := c.Update(ctx, iss); err != nil {
+		t.Fatal(err)
+	}
+	got, requeue = reconcile(crs[2])
+	wantPaused(got)
+	if want := 29*24*time.Hour - 3*time.Hour; requeue != want {
+		t.Errorf("requeue after %s, want a day before the certificate expires in %s", requeue, want)
+	}
+	if cond := cmutil.GetCertificateRequestCondition(got, cmapi.CertificateRequestConditionReady); !strings.Contains(cond.Message, v1alpha1.PausedAnnotation) {
+		t.Errorf("paused message = %q, want it to name the annotation", cond.Message)
+	}
+
+	delete(iss.Annotations, v1alpha1.PausedAnnotation)
+	if err := c.Update(ctx, iss); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.pendingRequestsForIssuer(iss)) != 2 {
+		t.Errorf("pendingRequestsForIssuer() = %v, want the paused requests", r.pendingRequestsForIssuer(iss))
+	}
+	for _, cr := range []*cmapi.CertificateRequest{crs[0], crs[2]} {
+		if got, _ := reconcile(cr); len(got.Status.Certificate) == 0 {
+			t.Errorf("request %s was not signed after the pause: %v", cr.Name, got.Status.Conditions)
+		}
+	}
+}
+
+func TestCertificateRequestReconciler_pendingRequestsInNamespace(t *testing.T) {
+	newRequest := func(namespace, name, group, reason string) *cmapi.CertificateRequest {
+		cr := &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       cmapi.CertificateRequestSpec{IssuerRef: cmmeta.ObjectReference{Group: group, Name: "issuer1"}},
+		}
+		if reason != "" {
+			status := cmmeta.ConditionFalse
+			if reason == cmapi.CertificateRequestReasonIssued {
+				status = cmmeta.ConditionTrue
+			}
+			cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionReady, status, reason, "")
+		}
+		return cr
+	}
+	scheme := runtime.NewScheme()
+	_ = cmapi.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newRequest("ns1", "new", v1alpha1.GroupVersion.Group, ""),
+		newRequest("ns1", "pending", v1alpha1.GroupVersion.Group, cmapi.CertificateRequestReasonPending),
+		newRequest("ns1", "issued", v1alpha1.GroupVersion.Group, cmapi.CertificateRequestReasonIssued),
+		newRequest("ns1", "denied", v1alpha1.GroupVersion.Group, cmapi.CertificateRequestReasonDenied),
+		newRequest("ns1", "other-issuer", "cert-manager.io", ""),
+		newRequest("ns2", "other-namespace", v1alpha1.GroupVersion.Group, ""),
+	).Build()
+	r := &CertificateRequestReconciler{Client: c, Log: logr.Discard()}
+
+	var got []string
+	for _, req := range r.pendingRequestsInNamespace(&core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}}) {
+		got = append(got, req.Name)
+	}
+	sort.Strings(got)
+	if want := []string{"new", "pending"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pendingRequestsInNamespace() got = %v, want %v", got, want)
+	}
+}
@@ -19,27 +19,59 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
 	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
 	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
 	"time"
 )
 
 // OCICAClusterIssuerReconciler reconciles a OCICAClusterIssuer object
 type OCICAClusterIssuerReconciler struct {
-	collection *provisioner.Collection
+	Collection *provisioner.Collection
 	client.Client
 	Scheme *runtime.Scheme
+
+	// ClusterResourceNamespace is where Secrets and ConfigMaps referenced by
+	// issuers are read from.
+	ClusterResourceNamespace string
+
+	// Transport holds the proxy and trust settings shared by all issuers.
+	Transport provisioner.Transport
+
+	// DefaultAuth is how issuers without auth authenticate to OCI. They use
+	// the DEFAULT profile of the OCI config file when it is nil.
+	DefaultAuth *ocicav1alpha1.OCIAuth
+
+	// HealthCheckInterval is how often ready issuers check their certificate
+	// authority is still active. Zero disables the periodic check.
+	HealthCheckInterval time.Duration
+
+	Clock clock.Clock
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
 }
 
 //+kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicaclusterissuers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicaclusterissuers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicaclusterissuers/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -55,8 +87,12 @@ func (r *OCICAClusterIssuerReconciler) Reconcile(ctx context.Context, req ctrl.R
 	iss := new(ocicav1alpha1.OCICAClusterIssuer)
 	err := r.Client.Get(ctx, req.NamespacedName, iss)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Collection.Delete(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
 		logger.Error(err, "failed fetch oci issuer")
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		return ctrl.Result{}, err
 	}
 	err = validateIssuer(iss.Spec)
 	if err != nil {
@@ -64,51 +100,244 @@ func (r *OCICAClusterIssuerReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	p, err := provisioner.New(logger, *iss)
+	configProvider, err := r.configurationProvider(ctx, iss)
+	if err != nil {
+		logger.Error(err, "failed to load OCI credentials")
+		_ = r.setStatus(ctx, iss, ocicav1alpha1.ConditionFalse, "Error", fmt.Sprintf("Failed to load OCI credentials: %s", err))
+		return reconcile.Result{}, err
+	}
+
+	transport, err := r.transport(ctx, iss)
+	if err != nil {
+		logger.Error(err, "failed to load OCI transport settings")
+		_ = r.setStatus(ctx, iss, ocicav1alpha1.ConditionFalse, "Error", fmt.Sprintf("Failed to load OCI transport settings: %s", err))
+		return reconcile.Result{}, err
+	}
+
+	var phase ocicav1alpha1.CARotationPhase
+	var nextPhaseTime *metav1.Time
+	signing := iss
+	var retired *ocicav1alpha1.OCICAClusterIssuer
+	if iss.Spec.Rotation != nil {
+		phase, nextPhaseTime = rotationPhase(iss.Spec.Rotation, r.Clock.Now())
+		signing = rotatedIssuer(iss, phase)
+		retired = retiredIssuer(iss, phase)
+	}
+	authorities, err := provisioner.NewAuthorities(logger, *signing, configProvider, transport)
+	if err == nil && retired != nil {
+		var retiredAuthorities *provisioner.Authorities
+		retiredAuthorities, err = provisioner.NewAuthorities(logger, *retired, configProvider, transport)
+		authorities.Retire(retiredAuthorities)
+	}
 	if err != nil {
 		logger.Error(err, "failed to create provisioner")
 		_ = r.setStatus(ctx, iss, ocicav1alpha1.ConditionFalse, "Error", "Failed initialize provisioner")
 		return reconcile.Result{}, err
 	}
-	r.collection.Store(req.NamespacedName, p)
-	return reconcile.Result{}, r.setStatus(ctx, iss, ocicav1alpha1.ConditionTrue, "Verified", "OCI issuer verified and ready to sign certificates")
+	// Certificates are also looked up in the compartments namespaces choose,
+	// which the spec does not name.
+	compartments, listErr := annotatedCompartments(ctx, r.Client, iss)
+	if listErr != nil {
+		logger.Error(listErr, "failed to list the compartments of namespaces")
+	}
+	authorities.AddRoutedCompartments(compartments)
+	// Requests only fail over to the authorities found available here, so
+	// the periodic check also brings recovered authorities back.
+	err = authorities.Validate(ctx)
+	iss.Status.Authorities = authorities.Status()
+
+	// Trust bundles are only updated with the roots of every authority, so
+	// that an outage never removes one clients still need.
+	var bundleErr error
+	var trustedUntil *metav1.Time
+	if iss.Spec.TrustBundle != nil {
+		trustedUntil, bundleErr = r.writeTrustBundle(ctx, logger, iss, phase, authorities, configProvider, transport)
+		if bundleErr != nil {
+			logger.Error(bundleErr, "failed to update trust bundle")
+		}
+	}
+	r.setRotationStatus(iss, phase, nextPhaseTime, trustedUntil, bundleErr)
+	if nextPhaseTime == nil {
+		nextPhaseTime = trustedUntil
+	}
+
+	if err != nil {
+		r.Collection.Delete(req.NamespacedName)
+		_ = r.setStatus(ctx, iss, ocicav1alpha1.ConditionFalse, "Unavailable", fmt.Sprintf("Certificate authority is unavailable: %s", err))
+		return reconcile.Result{}, err
+	}
+	r.Collection.StoreAuthorities(req.NamespacedName, authorities)
+	result := reconcile.Result{RequeueAfter: rotationRequeue(r.HealthCheckInterval, nextPhaseTime, r.Clock.Now())}
+	if unavailable := authorities.Unavailable(); len(unavailable) > 0 {
+		err = r.setStatus(ctx, iss, ocicav1alpha1.ConditionTrue, "Degraded",
+			fmt.Sprintf("OCI issuer ready to sign certificates, but certificate authorities %s are unavailable", strings.Join(unavailable, ", ")))
+	} else {
+		err = r.setStatus(ctx, iss, ocicav1alpha1.ConditionTrue, "Verified", "OCI issuer verified and ready to sign certificates")
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, bundleErr
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *OCICAClusterIssuerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&ocicav1alpha1.OCICAClusterIssuer{}).
+		Watches(&source.Kind{Type: &core.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.issuersReferencing)).
+		Watches(&source.Kind{Type: &core.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.issuersReferencing)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
-// setStatus is a function to set the issuer status
-func (r *OCICAClusterIssuerReconciler) setStatus(ctx context.Context, iss *ocicav1alpha1.OCICAClusterIssuer, status metav1.ConditionStatus, reason, message string) error {
-	now := metav1.NewTime(time.Now())
-	c := metav1.Condition{
-		Type:               string(ocicav1alpha1.ConditionReady),
-		Status:             status,
-		Reason:             reason,
-		Message:            message,
-		LastTransitionTime: now,
+// issuersReferencing maps a Secret or ConfigMap to the issuers reading or
+// writing it, so that rotated credentials and CA bundles are picked up and
+// trust bundles are restored.
+func (r *OCICAClusterIssuerReconciler) issuersReferencing(obj client.Object) []reconcile.Request {
+	issuers := new(ocicav1alpha1.OCICAClusterIssuerList)
+	if err := r.Client.List(context.Background(), issuers); err != nil {
+		return nil
 	}
-
-	for i, condition := range iss.Status.Conditions {
-		if condition.Type != string(ocicav1alpha1.ConditionReady) {
+	_, isSecret := obj.(*core.Secret)
+	var requests []reconcile.Request
+	for i := range issuers.Items {
+		iss := &issuers.Items[i]
+		if r.secretNamespace(iss) != obj.GetNamespace() {
 			continue
 		}
+		if references(r.withDefaultAuth(iss.Spec), obj.GetName(), isSecret) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(iss)})
+		}
+	}
+	return requests
+}
+
+// references reports whether spec reads the Secret, or reads or writes the
+// ConfigMap when isSecret is false, called name.
+func references(spec ocicav1alpha1.OCICAClusterIssuerSpec, name string, isSecret bool) bool {
+	if isSecret && spec.Auth != nil && spec.Auth.Mode == ocicav1alpha1.AuthModeAPIKey && spec.Auth.SecretName == name {
+		return true
+	}
+	if !isSecret && spec.TrustBundle != nil && spec.TrustBundle.Name == name {
+		return true
+	}
+	if spec.Transport == nil || spec.Transport.CABundle == nil {
+		return false
+	}
+	if isSecret {
+		return spec.Transport.CABundle.Secret != nil && spec.Transport.CABundle.Secret.Name == name
+	}
+	return spec.Transport.CABundle.ConfigMap != nil && spec.Transport.CABundle.ConfigMap.Name == name
+}
 
-		if condition.Status == status {
-			c.LastTransitionTime = condition.LastTransitionTime
+// configurationProvider returns the OCI credentials for the issuer, reading
+// the API key Secret when the issuer uses one.
+func (r *OCICAClusterIssuerReconciler) configurationProvider(ctx context.Context, iss *ocicav1alpha1.OCICAClusterIssuer) (common.ConfigurationProvider, error) {
+	spec := r.withDefaultAuth(iss.Spec)
+	var data map[string][]byte
+	if spec.Auth != nil && spec.Auth.Mode == ocicav1alpha1.AuthModeAPIKey {
+		secret := new(core.Secret)
+		name := types.NamespacedName{Namespace: r.secretNamespace(iss), Name: spec.Auth.SecretName}
+		if err := r.Client.Get(ctx, name, secret); err != nil {
+			return nil, fmt.Errorf("failed to get API key secret %s: %w", name, err)
 		}
-		iss.Status.Conditions[i] = c
+		data = secret.Data
 	}
+	return provisioner.ConfigurationProvider(spec, data)
+}
 
-	iss.Status.Conditions = append(iss.Status.Conditions, c)
+// withDefaultAuth returns spec with the DefaultAuth when it has no auth.
+func (r *OCICAClusterIssuerReconciler) withDefaultAuth(spec ocicav1alpha1.OCICAClusterIssuerSpec) ocicav1alpha1.OCICAClusterIssuerSpec {
+	if spec.Auth == nil && r.DefaultAuth != nil {
+		spec.Auth = r.DefaultAuth
+	}
+	return spec
+}
+
+// transport returns the global transport settings overlaid with those of the
+// issuer, reading its CA bundle from the referenced ConfigMap or Secret.
+func (r *OCICAClusterIssuerReconciler) transport(ctx context.Context, iss *ocicav1alpha1.OCICAClusterIssuer) (provisioner.Transport, error) {
+	spec := iss.Spec.Transport
+	if spec == nil {
+		return r.Transport, nil
+	}
+	t := provisioner.Transport{ProxyURL: spec.ProxyURL, NoProxy: spec.NoProxy}
+	if spec.CABundle != nil {
+		bundle, err := r.caBundle(ctx, r.secretNamespace(iss), spec.CABundle)
+		if err != nil {
+			return provisioner.Transport{}, err
+		}
+		t.CABundle = bundle
+	}
+	return r.Transport.Merge(t), nil
+}
+
+// caBundle reads the CA bundle referenced by src from namespace.
+func (r *OCICAClusterIssuerReconciler) caBundle(ctx context.Context, namespace string, src *ocicav1alpha1.CABundleSource) ([]byte, error) {
+	switch {
+	case src.ConfigMap != nil:
+		cm := new(core.ConfigMap)
+		name := types.NamespacedName{Namespace: namespace, Name: src.ConfigMap.Name}
+		if err := r.Client.Get(ctx, name, cm); err != nil {
+			return nil, fmt.Errorf("failed to get CA bundle configmap %s: %w", name, err)
+		}
+		key := caBundleKey(src.ConfigMap)
+		data, ok := cm.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("CA bundle configmap %s is missing %q", name, key)
+		}
+		return []byte(data), nil
+	case src.Secret != nil:
+		secret := new(core.Secret)
+		name := types.NamespacedName{Namespace: namespace, Name: src.Secret.Name}
+		if err := r.Client.Get(ctx, name, secret); err != nil {
+			return nil, fmt.Errorf("failed to get CA bundle secret %s: %w", name, err)
+		}
+		key := caBundleKey(src.Secret)
+		data, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("CA bundle secret %s is missing %q", name, key)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("CA bundle must reference a configmap or a secret")
+}
+
+func caBundleKey(sel *ocicav1alpha1.KeySelector) string {
+	if sel.Key == "" {
+		return ocicav1alpha1.DefaultCABundleKey
+	}
+	return sel.Key
+}
+
+// secretNamespace returns the namespace Secrets referenced by iss live in.
+func (r *OCICAClusterIssuerReconciler) secretNamespace(iss *ocicav1alpha1.OCICAClusterIssuer) string {
+	if iss.Namespace != "" {
+		return iss.Namespace
+	}
+	return r.ClusterResourceNamespace
+}
+
+// setStatus is a function to set the issuer status
+func (r *OCICAClusterIssuerReconciler) setStatus(ctx context.Context, iss *ocicav1alpha1.OCICAClusterIssuer, status metav1.ConditionStatus, reason, message string) error {
+	setCondition(&iss.Status.Conditions, ocicav1alpha1.ConditionReady, status, reason, message, r.Clock.Now())
 	return r.Client.Status().Update(ctx, iss)
 }
 
+// setCondition sets the condition of type conditionType, keeping its last
+// transition time unless its status changes.
+func setCondition(conditions *[]metav1.Condition, conditionType ocicav1alpha1.ConditionType, status metav1.ConditionStatus, reason, message string, now time.Time) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               string(conditionType),
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.NewTime(now),
+	})
+}
+
 func validateIssuer(spec ocicav1alpha1.OCICAClusterIssuerSpec) error {
-	if spec.AuthorityID == "" {
+	if spec.AuthorityID == "" && len(spec.Authorities) == 0 {
 		return fmt.Errorf("authority id cant be empty")
 	}
 	if spec.CompartmentID == "" {
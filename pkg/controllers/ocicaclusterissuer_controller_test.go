@@ -2,18 +2,33 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
 	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
 	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+	"os"
+	"path/filepath"
 	"reflect"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_validateIssuer(t *testing.T) {
@@ -71,9 +86,44 @@ func Test_validateIssuer(t *testing.T) {
 	}
 }
 
+// withTestOCIConfig points the OCI SDK at a throwaway API key config file.
+func withTestOCIConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`[DEFAULT]
+user=ocid1.user.oc1..test
+fingerprint=00:11:22:33:44:55:66:77:88:99:aa:bb:cc:dd:ee:ff
+tenancy=ocid1.tenancy.oc1..test
+region=us-phoenix-1
+key_file=%s
+`, keyFile)
+	configFile := filepath.Join(dir, "config")
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("OCI_CONFIG_FILE", configFile)
+}
+
 func TestOCICAClusterIssuerReconciler_Reconcile(t *testing.T) {
+	withTestOCIConfig(t)
+	fakeOCI := ocifake.NewServer(ocifake.Options{})
+	defer fakeOCI.Close()
+	authorityID, err := fakeOCI.CreateRootCA("ocid1.compartment.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
 	type fields struct {
-		collection *provisioner.Collection
+		Collection *provisioner.Collection
 		Scheme     *runtime.Scheme
 	}
 	type args struct {
@@ -87,11 +137,14 @@ func TestOCICAClusterIssuerReconciler_Reconcile(t *testing.T) {
 		objects []client.Object
 		want    controllerruntime.Result
 		wantErr bool
+		// wantOCSPCompartment is a compartment the OCSP responder looks
+		// certificates up in.
+		wantOCSPCompartment string
 	}{
 		{
 			name: "valid sign",
 			fields: fields{
-				collection: &provisioner.Collection{},
+				Collection: &provisioner.Collection{},
 				Scheme:     runtime.NewScheme(),
 			},
 			args: args{
@@ -116,7 +169,12 @@ func TestOCICAClusterIssuerReconciler_Reconcile(t *testing.T) {
 					Spec: v1alpha1.OCICAClusterIssuerSpec{
 						TenancyID:     "ocid1.tenancy.oc1..aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
 						CompartmentID: "ocid1.compartment.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
-						AuthorityID:   "ocid1.certificateauthority.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+						AuthorityID:   authorityID,
+						EndpointOverride: &v1alpha1.EndpointOverride{
+							CertificatesManagement: fakeOCI.URL,
+							Certificates:           fakeOCI.URL,
+						},
+						Compartments: &v1alpha1.CompartmentRouting{NamespaceAnnotation: true},
 					},
 					Status: v1alpha1.OCICAClusterIssuerStatus{
 						Conditions: []metav1.Condition{
@@ -127,9 +185,15 @@ func TestOCICAClusterIssuerReconciler_Reconcile(t *testing.T) {
 						},
 					},
 				},
+				&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "team-b",
+					Annotations: map[string]string{v1alpha1.NamespaceCompartmentAnnotation: "ocid1.compartment.oc1..aaaaaaaateamb6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"},
+				}},
 			},
 			wantErr: false,
 			want:    controllerruntime.Result{},
+
+			wantOCSPCompartment: "ocid1.compartment.oc1..aaaaaaaateamb6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
 		},
 	}
 	for _, tt := range tests {
@@ -138,12 +202,13 @@ func TestOCICAClusterIssuerReconciler_Reconcile(t *testing.T) {
 			v1.AddToScheme(tt.fields.Scheme)
 			v1alpha1.AddToScheme(tt.fields.Scheme)
 			r := &OCICAClusterIssuerReconciler{
-				collection: tt.fields.collection,
+				Collection: tt.fields.Collection,
 				Client: fake.NewClientBuilder().
 					WithScheme(tt.fields.Scheme).
 					WithObjects(tt.objects...).
 					Build(),
 				Scheme: tt.fields.Scheme,
+				Clock:  clock.RealClock{},
 			}
 			got, err := r.Reconcile(tt.args.ctx, tt.args.req)
 			if (err != nil) != tt.wantErr {
@@ -153,6 +218,226 @@ func TestOCICAClusterIssuerReconciler_Reconcile(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Reconcile() got = %v, want %v", got, tt.want)
 			}
+			authorities, ok := tt.fields.Collection.LoadAuthorities(tt.args.req.NamespacedName)
+			if !ok {
+				t.Fatalf("Reconcile() did not store a provisioner for %s", tt.args.req.NamespacedName)
+			}
+			if tt.wantOCSPCompartment != "" {
+				issuer := authorities.OCSPIssuers()[0]
+				found := false
+				for _, id := range issuer.CompartmentIDs {
+					found = found || id == tt.wantOCSPCompartment
+				}
+				if !found {
+					t.Errorf("OCSP responder looks up compartments %v, want %s", issuer.CompartmentIDs, tt.wantOCSPCompartment)
+				}
+			}
 		})
 	}
 }
+
+func TestOCICAClusterIssuerReconciler_Reconcile_rotation(t *testing.T) {
+	withTestOCIConfig(t)
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clocktesting.NewFakeClock(start)
+	fakeOCI := ocifake.NewServer(ocifake.Options{Now: fakeClock.Now})
+	defer fakeOCI.Close()
+	compartmentID := "ocid1.compartment.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+	oldID, err := fakeOCI.CreateRootCA(compartmentID, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newID, err := fakeOCI.CreateRootCA(compartmentID, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	distributeAt := metav1.NewTime(start.Add(time.Hour))
+	iss := &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer1", UID: "issuer1-uid"},
+		Spec: v1alpha1.OCICAClusterIssuerSpec{
+			TenancyID:     "ocid1.tenancy.oc1..aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+			CompartmentID: compartmentID,
+			AuthorityID:   oldID,
+			EndpointOverride: &v1alpha1.EndpointOverride{
+				CertificatesManagement: fakeOCI.URL,
+				Certificates:           fakeOCI.URL,
+			},
+			TrustBundle: &v1alpha1.KeySelector{Name: "trust-bundle"},
+			Rotation: &v1alpha1.CARotation{
+				AuthorityID: newID,
+				Schedule: v1alpha1.CARotationSchedule{
+					DistributeAt: &distributeAt,
+					SignAt:       metav1.NewTime(start.Add(2 * time.Hour)),
+					RetireAt:     metav1.NewTime(start.Add(3 * time.Hour)),
+				},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(iss).Build()
+	collection := &provisioner.Collection{}
+	r := &OCICAClusterIssuerReconciler{
+		Collection:               collection,
+		Client:                   c,
+		Scheme:                   scheme,
+		ClusterResourceNamespace: "cert-manager",
+		HealthCheckInterval:      time.Hour,
+		Clock:                    fakeClock,
+	}
+	req := controllerruntime.Request{NamespacedName: types.NamespacedName{Name: "issuer1"}}
+
+	// A certificate the old authority signed before the rotation keeps it
+	// trusted until the certificate expires.
+	configProvider, err := fakeOCI.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caClient, err := certificatesmanagement.NewCertificatesManagementClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caClient.Host = fakeOCI.URL
+	oldExpiry := start.Add(24 * time.Hour)
+	_, err = caClient.CreateCertificate(context.TODO(), certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          common.String("signed-by-old"),
+			CompartmentId: common.String(compartmentID),
+			CertificateConfig: certificatesmanagement.CreateCertificateIssuedByInternalCaConfigDetails{
+				IssuerCertificateAuthorityId: common.String(oldID),
+				Subject:                      &certificatesmanagement.CertificateSubject{CommonName: common.String("old.example.com")},
+				CertificateProfileType:       certificatesmanagement.CertificateProfileTypeTlsServer,
+				Validity: &certificatesmanagement.Validity{
+					TimeOfValidityNotBefore: &common.SDKTime{Time: start},
+					TimeOfValidityNotAfter:  &common.SDKTime{Time: oldExpiry},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		at             time.Time
+		failover       bool
+		wantPhase      v1alpha1.CARotationPhase
+		wantTrusted    int
+		wantCandidates []string
+		wantOCSP       int
+		wantRequeue    time.Duration
+	}{
+		{name: "pending", at: start, wantPhase: v1alpha1.CARotationPending, wantTrusted: 1, wantCandidates: []string{oldID}, wantOCSP: 1, wantRequeue: time.Hour},
+		{name: "distributing", at: start.Add(90 * time.Minute), wantPhase: v1alpha1.CARotationDistributing, wantTrusted: 2, wantCandidates: []string{oldID}, wantOCSP: 1, wantRequeue: 30 * time.Minute},
+		{name: "signing", at: start.Add(2 * time.Hour), wantPhase: v1alpha1.CARotationSigning, wantTrusted: 2, wantCandidates: []string{newID}, wantOCSP: 2, wantRequeue: time.Hour},
+		{name: "signing with failover", at: start.Add(2 * time.Hour), failover: true, wantPhase: v1alpha1.CARotationSigning, wantTrusted: 2, wantCandidates: []string{newID, oldID}, wantOCSP: 2, wantRequeue: time.Hour},
+		{name: "completed", at: start.Add(3 * time.Hour), wantPhase: v1alpha1.CARotationCompleted, wantTrusted: 2, wantCandidates: []string{newID}, wantOCSP: 2, wantRequeue: time.Hour},
+		{name: "completed before old certificates expire", at: oldExpiry.Add(-10 * time.Minute), wantPhase: v1alpha1.CARotationCompleted, wantTrusted: 2, wantCandidates: []string{newID}, wantOCSP: 2, wantRequeue: 10 * time.Minute},
+		{name: "completed after old certificates expire", at: oldExpiry, wantPhase: v1alpha1.CARotationCompleted, wantTrusted: 1, wantCandidates: []string{newID}, wantOCSP: 2, wantRequeue: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClock.SetTime(tt.at)
+			current := new(v1alpha1.OCICAClusterIssuer)
+			if err := c.Get(context.TODO(), req.NamespacedName, current); err != nil {
+				t.Fatal(err)
+			}
+			if current.Spec.Rotation.Failover != tt.failover {
+				current.Spec.Rotation.Failover = tt.failover
+				if err := c.Update(context.TODO(), current); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := r.Reconcile(context.TODO(), req)
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if got.RequeueAfter != tt.wantRequeue {
+				t.Errorf("Reconcile() requeue after %s, want %s", got.RequeueAfter, tt.wantRequeue)
+			}
+
+			updated := new(v1alpha1.OCICAClusterIssuer)
+			if err := c.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+				t.Fatal(err)
+			}
+			if updated.Status.Rotation == nil || updated.Status.Rotation.Phase != tt.wantPhase {
+				t.Errorf("rotation status = %+v, want phase %s", updated.Status.Rotation, tt.wantPhase)
+			}
+			condition := meta.FindStatusCondition(updated.Status.Conditions, string(v1alpha1.ConditionCARotation))
+			if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != string(tt.wantPhase) {
+				t.Errorf("%s condition = %+v, want true with reason %s", v1alpha1.ConditionCARotation, condition, tt.wantPhase)
+			}
+
+			cm := new(v1.ConfigMap)
+			if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "cert-manager", Name: "trust-bundle"}, cm); err != nil {
+				t.Fatal(err)
+			}
+			if n := strings.Count(cm.Data[v1alpha1.DefaultCABundleKey], "-----BEGIN CERTIFICATE-----"); n != tt.wantTrusted {
+				t.Errorf("trust bundle holds %d certificates, want %d", n, tt.wantTrusted)
+			}
+			if !metav1.IsControlledBy(cm, iss) {
+				t.Errorf("trust bundle is not controlled by the issuer")
+			}
+
+			authorities, ok := collection.LoadAuthorities(req.NamespacedName)
+			if !ok {
+				t.Fatal("Reconcile() did not store a provisioner")
+			}
+			var candidates []string
+			for _, authority := range authorities.Candidates() {
+				candidates = append(candidates, authority.ID)
+			}
+			if !reflect.DeepEqual(candidates, tt.wantCandidates) {
+				t.Errorf("signing authorities = %v, want %v", candidates, tt.wantCandidates)
+			}
+			if n := len(authorities.OCSPIssuers()); n != tt.wantOCSP {
+				t.Errorf("OCSP responder answers for %d authorities, want %d", n, tt.wantOCSP)
+			}
+		})
+	}
+
+	t.Run("removed", func(t *testing.T) {
+		updated := new(v1alpha1.OCICAClusterIssuer)
+		if err := c.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.AuthorityID = newID
+		updated.Spec.Rotation = nil
+		if err := c.Update(context.TODO(), updated); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		if err := c.Get(context.TODO(), req.NamespacedName, updated); err != nil {
+			t.Fatal(err)
+		}
+		if updated.Status.Rotation != nil || meta.FindStatusCondition(updated.Status.Conditions, string(v1alpha1.ConditionCARotation)) != nil {
+			t.Errorf("rotation status = %+v, conditions = %+v, want none", updated.Status.Rotation, updated.Status.Conditions)
+		}
+	})
+}
+
+func TestOCICAClusterIssuerReconciler_issuersReferencing(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1alpha1.AddToScheme(scheme)
+	defaulted := &v1alpha1.OCICAClusterIssuer{ObjectMeta: metav1.ObjectMeta{Name: "defaulted"}}
+	own := &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "own"},
+		Spec:       v1alpha1.OCICAClusterIssuerSpec{Auth: &v1alpha1.OCIAuth{Mode: v1alpha1.AuthModeAPIKey, SecretName: "own-key"}},
+	}
+	r := &OCICAClusterIssuerReconciler{
+		Client:                   fake.NewClientBuilder().WithScheme(scheme).WithObjects(defaulted, own).Build(),
+		ClusterResourceNamespace: "oci-private-issuer",
+		DefaultAuth:              &v1alpha1.OCIAuth{Mode: v1alpha1.AuthModeAPIKey, SecretName: "default-key"},
+	}
+	for secret, want := range map[string]string{"default-key": "defaulted", "own-key": "own"} {
+		got := r.issuersReferencing(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "oci-private-issuer", Name: secret}})
+		if len(got) != 1 || got[0].Name != want {
+			t.Errorf("issuersReferencing(%s) = %v, want %s", secret, got, want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"time"
+)
+
+// Reasons of the Ready condition of OCICertificateImports, besides
+// ReasonPending, ReasonFailed, ReasonInvalidSpec, ReasonSecretConflict and the
+// reasons of decisions taken against the issuer.
+const (
+	ReasonImported               = "Imported"
+	ReasonCertificateCompartment = "CertificateCompartment"
+	ReasonCertificateNamespace   = "CertificateNamespace"
+)
+
+// OCICertificateImportReconciler reconciles OCICertificateImports, writing the
+// current version of OCI certificates created outside the cluster to Secrets.
+type OCICertificateImportReconciler struct {
+	Collection *provisioner.Collection
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Clock    clock.Clock
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicertificateimports,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicertificateimports/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocicertificateimports/finalizers,verbs=update
+
+// Reconcile polls the current version of the OCI certificate of an
+// OCICertificateImport and writes it to its Secret. The issuer's namespace
+// selector, policy and compartment routing are checked on every poll, so
+// that namespaces only read certificates they could have been issued.
+func (r *OCICertificateImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("ocicertificateimport", req.NamespacedName)
+	ci := new(ocicav1alpha1.OCICertificateImport)
+	if err := r.Client.Get(ctx, req.NamespacedName, ci); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get OCICertificateImport")
+		return ctrl.Result{}, err
+	}
+	if !ci.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	poll := importPollInterval(ci)
+
+	if err := validateCertificateImport(ci.Spec); err != nil {
+		return ctrl.Result{}, r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonInvalidSpec, "Invalid spec: %s", err)
+	}
+
+	issuerName := types.NamespacedName{Name: ci.Spec.IssuerName}
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, issuerName, iss); err != nil {
+		log.Error(err, "failed to get issuer")
+		_ = r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonPending, "Failed to get issuer %s: %s", issuerName.Name, err)
+		return ctrl.Result{}, err
+	}
+	if !issuerReady(iss) {
+		_ = r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonPending, "Issuer %s is not ready", issuerName.Name)
+		return ctrl.Result{}, fmt.Errorf("issuer %s is not ready", issuerName.Name)
+	}
+	p, ok := r.Collection.Load(issuerName)
+	if !ok {
+		_ = r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonPending, "Failed to load provisioner for issuer %s", issuerName.Name)
+		return ctrl.Result{}, fmt.Errorf("provisioner for %s not found", issuerName)
+	}
+
+	// Certificates the controller created for other namespaces are refused
+	// before their private key is read.
+	compartmentID, err := p.CertificateCompartment(ctx, ci.Spec.CertificateID, ci.Namespace)
+	if errors.Is(err, provisioner.ErrOtherNamespace) {
+		log.Info("OCICertificateImport names a certificate of another namespace", "certificateID", ci.Spec.CertificateID)
+		return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonCertificateNamespace, "%s", err)
+	}
+	if err != nil {
+		return r.readFailed(ctx, log, ci, err)
+	}
+	bundle, err := p.CertificateBundle(ctx, ci.Spec.CertificateID)
+	if err != nil {
+		return r.readFailed(ctx, log, ci, err)
+	}
+
+	// The certificate is read before it is checked, but only written once
+	// the namespace could have been issued it.
+	leaf, err := pki.DecodeX509CertificateBytes(bundle.Certificate)
+	if err != nil {
+		return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonFailed,
+			"OCI certificate %s is invalid: %s", ci.Spec.CertificateID, err)
+	}
+	d, err := decideForCertificate(ctx, r.Client, ci.Namespace, leaf, iss)
+	if err != nil {
+		log.Error(err, "failed to check certificate against the issuer")
+		_ = r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonPending, "Failed to check certificate against issuer %s: %s", iss.Name, err)
+		return ctrl.Result{}, err
+	}
+	if !d.allowed {
+		log.Info("OCICertificateImport is refused by the issuer", "reason", d.reason)
+		return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionFalse, d.reason, "%s", d.message)
+	}
+	want := d.compartmentID
+	if want == "" {
+		want = p.CompartmentID()
+	}
+	if compartmentID != want {
+		return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonCertificateCompartment,
+			"OCI certificate %s is in compartment %s, not %s", ci.Spec.CertificateID, compartmentID, want)
+	}
+
+	written, err := writeCertificateSecret(ctx, r.Client, r.Scheme, ci, ci.Spec.SecretName, ci.Spec.CertificateID, bundle)
+	if err != nil {
+		var conflict *secretConflictError
+		if errors.As(err, &conflict) {
+			return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonSecretConflict, "%s", err)
+		}
+		log.Error(err, "failed to write Secret")
+		_ = r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonPending, "Failed to write Secret %s: %s", ci.Spec.SecretName, err)
+		return ctrl.Result{}, err
+	}
+	if written && ci.Status.Version != 0 && ci.Status.Version != bundle.Version {
+		r.Recorder.Eventf(ci, core.EventTypeNormal, "Renewed", "Wrote version %d of OCI certificate %s to Secret %s",
+			bundle.Version, ci.Spec.CertificateID, ci.Spec.SecretName)
+	}
+	ci.Status.Version = bundle.Version
+	notAfter := metav1.NewTime(bundle.NotAfter)
+	ci.Status.NotAfter = &notAfter
+	ci.Status.PrivateKey = bundle.PrivateKey != nil
+	if !ci.Status.PrivateKey {
+		return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionTrue, ReasonImported,
+			"Version %d of OCI certificate %s is in Secret %s without its private key, which OCI does not hold or issuer %s may not read",
+			bundle.Version, ci.Spec.CertificateID, ci.Spec.SecretName, iss.Name)
+	}
+	return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, ci, metav1.ConditionTrue, ReasonImported,
+		"Version %d of OCI certificate %s is in Secret %s", bundle.Version, ci.Spec.CertificateID, ci.Spec.SecretName)
+}
+
+// readFailed records a failure to read the certificate of ci from OCI.
+func (r *OCICertificateImportReconciler) readFailed(ctx context.Context, log logr.Logger, ci *ocicav1alpha1.OCICertificateImport, err error) (ctrl.Result, error) {
+	log.Error(err, "failed to get certificate from OCI")
+	if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+		return ctrl.Result{RequeueAfter: importPollInterval(ci)}, r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonFailed,
+			"OCI certificate %s was not found, or issuer %s may not read it", ci.Spec.CertificateID, ci.Spec.IssuerName)
+	}
+	_ = r.setStatus(ctx, ci, metav1.ConditionFalse, ReasonPending, "Failed to get certificate from OCI: %s", err)
+	return ctrl.Result{}, err
+}
+
+// validateCertificateImport checks what the schema of OCICertificateImports
+// cannot.
+func validateCertificateImport(spec ocicav1alpha1.OCICertificateImportSpec) error {
+	if spec.IssuerName == "" || spec.SecretName == "" {
+		return fmt.Errorf("issuerName and secretName are required")
+	}
+	if _, err := ocid.ParseType(spec.CertificateID, ocid.Certificate); err != nil {
+		return fmt.Errorf("certificateId: %s", err)
+	}
+	if spec.PollInterval != nil && spec.PollInterval.Duration <= 0 {
+		return fmt.Errorf("pollInterval must be positive")
+	}
+	return nil
+}
+
+// importPollInterval returns how often ci is checked for new versions.
+func importPollInterval(ci *ocicav1alpha1.OCICertificateImport) time.Duration {
+	if ci.Spec.PollInterval != nil && ci.Spec.PollInterval.Duration > 0 {
+		return ci.Spec.PollInterval.Duration
+	}
+	return ocicav1alpha1.DefaultCertificateImportPollInterval
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OCICertificateImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&ocicav1alpha1.OCICertificateImport{}).
+		Owns(&core.Secret{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// setStatus sets the Ready condition of ci and updates the status of ci.
+func (r *OCICertificateImportReconciler) setStatus(ctx context.Context, ci *ocicav1alpha1.OCICertificateImport, status metav1.ConditionStatus, reason, message string, args ...interface{}) error {
+	return setReadyCondition(ctx, r.Client, r.Recorder, r.Clock.Now(), ci, &ci.Status.Conditions, status, reason, message, args...)
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+// createCertificate creates an OCI certificate named name with a key
+// generated by OCI, as if it had been issued in the OCI console, and returns
+// its OCID.
+func createCertificate(t *testing.T, f *managedCertificateFixture, name, compartmentID string) string {
+	t.Helper()
+	id := createManagedCertificate(t, f, "console", name, compartmentID)
+	if err := f.oci.SetCertificateTags(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// createManagedCertificate creates the OCI certificate of the
+// OCIManagedCertificate namespace/name and returns its OCID.
+func createManagedCertificate(t *testing.T, f *managedCertificateFixture, namespace, name, compartmentID string) string {
+	t.Helper()
+	p, ok := f.collection.Load(types.NamespacedName{Name: f.issuer.Name})
+	if !ok {
+		t.Fatal("no provisioner")
+	}
+	mc := newManagedCertificate(nil)
+	mc.Namespace = namespace
+	mc.Name = name
+	id, err := p.CreateManagedCertificate(context.TODO(), mc, provisioner.SignOptions{CompartmentID: compartmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func newCertificateImport(certificateID string) *v1alpha1.OCICertificateImport {
+	return &v1alpha1.OCICertificateImport{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "app", UID: "ci-uid"},
+		Spec: v1alpha1.OCICertificateImportSpec{
+			IssuerName:    "issuer1",
+			CertificateID: certificateID,
+			SecretName:    "app-tls",
+			PollInterval:  &metav1.Duration{Duration: time.Minute},
+		},
+	}
+}
+
+func (f *managedCertificateFixture) importReconciler(objs ...client.Object) (*OCICertificateImportReconciler, client.Client) {
+	c, scheme := f.client(objs...)
+	return &OCICertificateImportReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Scheme:     scheme,
+		Recorder:   record.NewFakeRecorder(10),
+		Clock:      clock.RealClock{},
+	}, c
+}
+
+func TestOCICertificateImportReconciler_Reconcile(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	withKey := createCertificate(t, f, "with-key", "")
+	keyDenied := createCertificate(t, f, "key-denied", "")
+	f.oci.DenyPrivateKey(keyDenied)
+	elsewhere := createCertificate(t, f, "elsewhere", "ocid1.compartment.oc1..other")
+	sameNamespace := createManagedCertificate(t, f, "ns1", "same-namespace", "")
+	otherNamespace := createManagedCertificate(t, f, "ns2", "other-namespace", "")
+
+	tests := []struct {
+		name   string
+		ci     *v1alpha1.OCICertificateImport
+		issuer func(spec *v1alpha1.OCICAClusterIssuerSpec)
+		// wantReason is the reason of the Ready condition, which is true
+		// only for ReasonImported.
+		wantReason     string
+		wantPrivateKey bool
+	}{
+		{
+			name:           "imported with private key",
+			ci:             newCertificateImport(withKey),
+			wantReason:     ReasonImported,
+			wantPrivateKey: true,
+		},
+		{
+			name:       "private key denied",
+			ci:         newCertificateImport(keyDenied),
+			wantReason: ReasonImported,
+		},
+		{
+			name:       "certificate in another compartment",
+			ci:         newCertificateImport(elsewhere),
+			wantReason: ReasonCertificateCompartment,
+		},
+		{
+			name:           "managed certificate of the namespace",
+			ci:             newCertificateImport(sameNamespace),
+			wantReason:     ReasonImported,
+			wantPrivateKey: true,
+		},
+		{
+			name:       "managed certificate of another namespace",
+			ci:         newCertificateImport(otherNamespace),
+			wantReason: ReasonCertificateNamespace,
+		},
+		{
+			name: "denied by policy",
+			ci:   newCertificateImport(withKey),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Deny: []string{"*.example.com"}}}
+			},
+			wantReason: "PolicyDNSNames",
+		},
+		{
+			name:       "certificate not found",
+			ci:         newCertificateImport("ocid1.certificate.oc1.phx.missing"),
+			wantReason: ReasonFailed,
+		},
+		{
+			name:       "invalid certificate OCID",
+			ci:         newCertificateImport(f.issuer.Spec.AuthorityID),
+			wantReason: ReasonInvalidSpec,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss := f.issuer.DeepCopy()
+			if tt.issuer != nil {
+				tt.issuer(&iss.Spec)
+			}
+			r, c := f.importReconciler(iss, tt.ci.DeepCopy())
+			key := client.ObjectKeyFromObject(tt.ci)
+			if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			ci := new(v1alpha1.OCICertificateImport)
+			if err := c.Get(ctx, key, ci); err != nil {
+				t.Fatal(err)
+			}
+			if len(ci.Status.Conditions) != 1 || ci.Status.Conditions[0].Reason != tt.wantReason {
+				t.Fatalf("Reconcile() conditions = %v, want %s", ci.Status.Conditions, tt.wantReason)
+			}
+			secret := new(core.Secret)
+			err := c.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "app-tls"}, secret)
+			if tt.wantReason != ReasonImported {
+				if err == nil {
+					t.Errorf("Reconcile() wrote Secret %s", secret.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ci.Status.Version != 1 || ci.Status.PrivateKey != tt.wantPrivateKey {
+				t.Errorf("Reconcile() status = %+v, want version 1 with private key %t", ci.Status, tt.wantPrivateKey)
+			}
+			if !metav1.IsControlledBy(secret, ci) || secret.Annotations[v1alpha1.ManagedCertificateIDAnnotation] != tt.ci.Spec.CertificateID {
+				t.Errorf("Secret owners = %v, annotations = %v, want the import", secret.OwnerReferences, secret.Annotations)
+			}
+			if leaf := parseSecretCertificate(t, secret); leaf.Subject.CommonName != "app.example.com" {
+				t.Errorf("certificate subject = %s", leaf.Subject)
+			}
+			if got := len(secret.Data[core.TLSPrivateKeyKey]) > 0; got != tt.wantPrivateKey {
+				t.Errorf("Secret has private key %t, want %t", got, tt.wantPrivateKey)
+			}
+			if len(secret.Data["ca.crt"]) == 0 {
+				t.Errorf("Secret has no CA")
+			}
+		})
+	}
+}
+
+func TestOCICertificateImportReconciler_Reconcile_renewal(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	id := createCertificate(t, f, "console", "")
+	r, c := f.importReconciler(f.issuer.DeepCopy(), newCertificateImport(id))
+	key := types.NamespacedName{Namespace: "ns1", Name: "app"}
+	reconcile := func() (*v1alpha1.OCICertificateImport, *core.Secret) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		ci := new(v1alpha1.OCICertificateImport)
+		if err := c.Get(ctx, key, ci); err != nil {
+			t.Fatal(err)
+		}
+		secret := new(core.Secret)
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "app-tls"}, secret); err != nil {
+			t.Fatal(err)
+		}
+		return ci, secret
+	}
+
+	_, secret := reconcile()
+	first := parseSecretCertificate(t, secret)
+	if _, err := f.oci.RenewCertificate(id); err != nil {
+		t.Fatal(err)
+	}
+	ci, secret := reconcile()
+	if ci.Status.Version != 2 || secret.Annotations[v1alpha1.ManagedCertificateVersionAnnotation] != "2" {
+		t.Errorf("after renewal version = %d, Secret annotations = %v, want 2", ci.Status.Version, secret.Annotations)
+	}
+	if parseSecretCertificate(t, secret).SerialNumber.Cmp(first.SerialNumber) == 0 {
+		t.Errorf("Secret still holds the first version")
+	}
+}
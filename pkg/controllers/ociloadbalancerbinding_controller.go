@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
+)
+
+// Reasons of the Ready condition of OCILoadBalancerBindings, besides
+// ReasonPending, ReasonFailed, ReasonInvalidSpec and the reasons of decisions
+// taken against the issuer.
+const (
+	ReasonAttached                = "Attached"
+	ReasonUpdating                = "Updating"
+	ReasonRollingBack             = "RollingBack"
+	ReasonRolledBack              = "RolledBack"
+	ReasonRollbackFailed          = "RollbackFailed"
+	ReasonNotExported             = "NotExported"
+	ReasonListenerNotFound        = "ListenerNotFound"
+	ReasonLoadBalancerCompartment = "LoadBalancerCompartment"
+)
+
+// workRequestPollInterval is how often load balancer work requests are polled
+// until they finish.
+const workRequestPollInterval = 10 * time.Second
+
+// OCILoadBalancerBindingReconciler reconciles OCILoadBalancerBindings,
+// pointing load balancer listeners at the OCI certificates Secrets are
+// exported to.
+type OCILoadBalancerBindingReconciler struct {
+	Collection *provisioner.Collection
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Clock    clock.Clock
+
+	// ClusterID identifies the cluster in the names of OCI certificates, to
+	// check the certificates Secrets were exported to.
+	ClusterID string
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ociloadbalancerbindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ociloadbalancerbindings/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ociloadbalancerbindings/finalizers,verbs=update
+
+// Reconcile updates the listener of an OCILoadBalancerBinding to serve the OCI
+// certificate its Secret is exported to whenever the exported certificate
+// changes, and restores the certificate the listener served before when the
+// update fails.
+func (r *OCILoadBalancerBindingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("ociloadbalancerbinding", req.NamespacedName)
+	b := new(ocicav1alpha1.OCILoadBalancerBinding)
+	if err := r.Client.Get(ctx, req.NamespacedName, b); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get OCILoadBalancerBinding")
+		return ctrl.Result{}, err
+	}
+	if !b.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	retry := ocicav1alpha1.DefaultLoadBalancerBindingRetryInterval
+
+	if err := validateLoadBalancerBinding(b.Spec); err != nil {
+		return ctrl.Result{}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonInvalidSpec, "Invalid spec: %s", err)
+	}
+
+	// Secrets are watched, so missing or unexported ones are not retried.
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.SecretName}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Secret %s not found", b.Spec.SecretName)
+		}
+		log.Error(err, "failed to get Secret")
+		return ctrl.Result{}, err
+	}
+	issuerName := secret.Annotations[ocicav1alpha1.ExportToAnnotation]
+	if issuerName == "" {
+		return ctrl.Result{}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonNotExported,
+			"Secret %s has no %s annotation", b.Spec.SecretName, ocicav1alpha1.ExportToAnnotation)
+	}
+	certificateID := secret.Annotations[ocicav1alpha1.ExportedCertificateIDAnnotation]
+	serial := secret.Annotations[ocicav1alpha1.ExportedSerialAnnotation]
+	if certificateID == "" || serial == "" {
+		return ctrl.Result{}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonNotExported,
+			"Secret %s has not been exported to OCI yet", b.Spec.SecretName)
+	}
+
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: issuerName}, iss); err != nil {
+		log.Error(err, "failed to get issuer")
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to get issuer %s: %s", issuerName, err)
+		return ctrl.Result{}, err
+	}
+	if !issuerReady(iss) {
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Issuer %s is not ready", issuerName)
+		return ctrl.Result{}, fmt.Errorf("issuer %s is not ready", issuerName)
+	}
+	p, ok := r.Collection.Load(types.NamespacedName{Name: issuerName})
+	if !ok {
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to load provisioner for issuer %s", issuerName)
+		return ctrl.Result{}, fmt.Errorf("provisioner for %s not found", issuerName)
+	}
+
+	if b.Status.Update != nil {
+		return r.pollUpdate(ctx, log, b, p)
+	}
+
+	l, err := p.LoadBalancerListener(ctx, b.Spec.LoadBalancerID, b.Spec.ListenerName)
+	if err != nil {
+		log.Error(err, "failed to get load balancer listener")
+		if errors.Is(err, provisioner.ErrListenerNotFound) {
+			return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonListenerNotFound,
+				"Load balancer has no listener %s", b.Spec.ListenerName)
+		}
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonFailed,
+				"Load balancer %s was not found", b.Spec.LoadBalancerID)
+		}
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to get load balancer listener: %s", err)
+		return ctrl.Result{}, err
+	}
+	if l.Certificate == nil {
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonFailed,
+			"Listener %s does not terminate TLS", b.Spec.ListenerName)
+	}
+
+	// Load balancers are updated with the issuer's credentials, so only
+	// those in the compartment the namespace's certificates go to are. The
+	// OCID comes from an annotation anyone who may edit the Secret can
+	// change, so the certificate it names must be the one the Secret was
+	// exported to, and its current version is checked against the issuer.
+	leaf, err := p.ExportedLeaf(ctx, certificateID)
+	if err != nil {
+		log.Error(err, "failed to get exported certificate")
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonNotExported,
+				"OCI certificate %s of Secret %s was not found", certificateID, b.Spec.SecretName)
+		}
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to get OCI certificate %s: %s", certificateID, err)
+		return ctrl.Result{}, err
+	}
+	d, err := decideForCertificate(ctx, r.Client, b.Namespace, leaf, iss)
+	if err != nil {
+		log.Error(err, "failed to check certificate against the issuer")
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to check certificate against issuer %s: %s", issuerName, err)
+		return ctrl.Result{}, err
+	}
+	if !d.allowed {
+		log.Info("OCILoadBalancerBinding is refused by the issuer", "reason", d.reason)
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, d.reason, "%s", d.message)
+	}
+	err = p.CheckExportedCertificate(ctx, certificateID, b.Namespace, b.Spec.SecretName,
+		provisioner.SignOptions{CompartmentID: d.compartmentID, ClusterID: r.ClusterID})
+	if err != nil {
+		log.Error(err, "failed to check exported certificate")
+		if errors.Is(err, provisioner.ErrNotExported) {
+			return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonNotExported,
+				"Secret %s names an OCI certificate it was not exported to: %s", b.Spec.SecretName, err)
+		}
+		_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to check OCI certificate %s: %s", certificateID, err)
+		return ctrl.Result{}, err
+	}
+	compartmentID := d.compartmentID
+	if compartmentID == "" {
+		compartmentID = p.CompartmentID()
+	}
+	if l.CompartmentID != compartmentID {
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonLoadBalancerCompartment,
+			"Load balancer is in compartment %s, not %s", l.CompartmentID, compartmentID)
+	}
+
+	if servesOnly(l.Certificate, certificateID) && b.Status.Serial == serial {
+		// Requeued to put back a listener changed outside the cluster.
+		b.Status.CertificateID = certificateID
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionTrue, ReasonAttached,
+			"Listener %s serves OCI certificate %s", b.Spec.ListenerName, certificateID)
+	}
+	if last := b.Status.LastFailureTime; last != nil && b.Status.FailedSerial == serial {
+		if wait := last.Add(retry).Sub(r.Clock.Now()); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	wr, err := p.SetListenerCertificate(ctx, b.Spec.LoadBalancerID, l, ocicav1alpha1.ListenerCertificate{CertificateIDs: []string{certificateID}})
+	if err != nil {
+		log.Error(err, "failed to update load balancer listener")
+		if retryable(err) {
+			_ = r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending, "Failed to update listener %s: %s", b.Spec.ListenerName, err)
+			return ctrl.Result{}, err
+		}
+		r.failed(b, serial)
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonFailed,
+			"Failed to update listener %s: %s", b.Spec.ListenerName, err)
+	}
+	b.Status.Update = &ocicav1alpha1.ListenerUpdate{
+		WorkRequestID: wr,
+		CertificateID: certificateID,
+		Serial:        serial,
+		Previous:      *l.Certificate,
+	}
+	return ctrl.Result{RequeueAfter: workRequestPollInterval}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonUpdating,
+		"Updating listener %s to OCI certificate %s in work request %s", b.Spec.ListenerName, certificateID, wr)
+}
+
+// pollUpdate polls the work request of the listener update in progress of b.
+// A failed update is rolled back to the certificate the listener served
+// before; the next update is tried after the retry interval.
+func (r *OCILoadBalancerBindingReconciler) pollUpdate(ctx context.Context, log logr.Logger, b *ocicav1alpha1.OCILoadBalancerBinding, p *provisioner.Provisioner) (ctrl.Result, error) {
+	retry := ocicav1alpha1.DefaultLoadBalancerBindingRetryInterval
+	u := b.Status.Update
+	st, err := p.LoadBalancerWorkRequest(ctx, u.WorkRequestID)
+	if err != nil {
+		log.Error(err, "failed to get load balancer work request")
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			// Checked again against the listener from scratch.
+			b.Status.Update = nil
+			return ctrl.Result{Requeue: true}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonPending,
+				"Work request %s was not found", u.WorkRequestID)
+		}
+		return ctrl.Result{}, err
+	}
+	if !st.Done {
+		return ctrl.Result{RequeueAfter: workRequestPollInterval}, nil
+	}
+
+	switch {
+	case !u.Rollback && st.Failure == "":
+		b.Status.Update = nil
+		b.Status.CertificateID = u.CertificateID
+		b.Status.Serial = u.Serial
+		b.Status.LastFailureTime = nil
+		b.Status.FailedSerial = ""
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionTrue, ReasonAttached,
+			"Listener %s serves OCI certificate %s", b.Spec.ListenerName, u.CertificateID)
+	case !u.Rollback && servesOnly(&u.Previous, u.CertificateID):
+		// New versions of a certificate are deployed under the same OCID,
+		// so there is no previous reference to restore.
+		r.failed(b, u.Serial)
+		b.Status.Update = nil
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonFailed,
+			"Updating listener %s to OCI certificate %s with serial %s failed: %s",
+			b.Spec.ListenerName, u.CertificateID, u.Serial, st.Failure)
+	case !u.Rollback:
+		r.failed(b, u.Serial)
+		l, err := p.LoadBalancerListener(ctx, b.Spec.LoadBalancerID, b.Spec.ListenerName)
+		if err != nil {
+			log.Error(err, "failed to get load balancer listener to roll back")
+			return ctrl.Result{}, err
+		}
+		wr, err := p.SetListenerCertificate(ctx, b.Spec.LoadBalancerID, l, u.Previous)
+		if err != nil {
+			// The failed work request is polled again, retrying the
+			// rollback.
+			log.Error(err, "failed to roll back load balancer listener")
+			return ctrl.Result{}, err
+		}
+		b.Status.Update = &ocicav1alpha1.ListenerUpdate{
+			WorkRequestID: wr,
+			CertificateID: u.CertificateID,
+			Serial:        u.Serial,
+			Previous:      u.Previous,
+			Rollback:      true,
+			Failure:       st.Failure,
+		}
+		return ctrl.Result{RequeueAfter: workRequestPollInterval}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonRollingBack,
+			"Updating listener %s to OCI certificate %s failed, rolling back: %s", b.Spec.ListenerName, u.CertificateID, st.Failure)
+	case st.Failure == "":
+		b.Status.Update = nil
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonRolledBack,
+			"Updating listener %s to OCI certificate %s with serial %s failed and was rolled back: %s",
+			b.Spec.ListenerName, u.CertificateID, u.Serial, u.Failure)
+	default:
+		b.Status.Update = nil
+		return ctrl.Result{RequeueAfter: retry}, r.setStatus(ctx, b, metav1.ConditionFalse, ReasonRollbackFailed,
+			"Rolling back listener %s failed: %s; the update to OCI certificate %s with serial %s failed: %s",
+			b.Spec.ListenerName, st.Failure, u.CertificateID, u.Serial, u.Failure)
+	}
+}
+
+// failed records that updating the listener of b to the certificate version
+// serial failed.
+func (r *OCILoadBalancerBindingReconciler) failed(b *ocicav1alpha1.OCILoadBalancerBinding, serial string) {
+	now := metav1.NewTime(r.Clock.Now())
+	b.Status.LastFailureTime = &now
+	b.Status.FailedSerial = serial
+}
+
+// servesOnly reports whether cert is certificateID alone.
+func servesOnly(cert *ocicav1alpha1.ListenerCertificate, certificateID string) bool {
+	return cert.CertificateName == "" && len(cert.CertificateIDs) == 1 && cert.CertificateIDs[0] == certificateID
+}
+
+// validateLoadBalancerBinding checks what the schema of
+// OCILoadBalancerBindings cannot.
+func validateLoadBalancerBinding(spec ocicav1alpha1.OCILoadBalancerBindingSpec) error {
+	if spec.SecretName == "" || spec.ListenerName == "" {
+		return fmt.Errorf("secretName and listenerName are required")
+	}
+	if _, err := ocid.ParseType(spec.LoadBalancerID, ocid.LoadBalancer); err != nil {
+		return fmt.Errorf("loadBalancerId: %s", err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OCILoadBalancerBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&ocicav1alpha1.OCILoadBalancerBinding{}).
+		Watches(&source.Kind{Type: &core.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.bindingsForSecret)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// bindingsForSecret returns the OCILoadBalancerBindings of a Secret, so that
+// they follow it as it is exported again.
+func (r *OCILoadBalancerBindingReconciler) bindingsForSecret(obj client.Object) []reconcile.Request {
+	bindings := new(ocicav1alpha1.OCILoadBalancerBindingList)
+	if err := r.Client.List(context.Background(), bindings, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range bindings.Items {
+		if bindings.Items[i].Spec.SecretName == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&bindings.Items[i])})
+		}
+	}
+	return requests
+}
+
+// setStatus sets the Ready condition of b and updates the status of b.
+func (r *OCILoadBalancerBindingReconciler) setStatus(ctx context.Context, b *ocicav1alpha1.OCILoadBalancerBinding, status metav1.ConditionStatus, reason, message string, args ...interface{}) error {
+	return setReadyCondition(ctx, r.Client, r.Recorder, r.Clock.Now(), b, &b.Status.Conditions, status, reason, message, args...)
+}
@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+// httpsListener is a TLS listener serving a certificate uploaded to the load
+// balancer.
+func httpsListener() loadbalancer.Listener {
+	return loadbalancer.Listener{
+		Name:                  common.String("https"),
+		DefaultBackendSetName: common.String("backends"),
+		Port:                  common.Int(443),
+		Protocol:              common.String("HTTP"),
+		SslConfiguration:      &loadbalancer.SslConfiguration{CertificateName: common.String("uploaded")},
+	}
+}
+
+// exportSecret exports a Secret named name to OCI and returns it with the
+// annotations of the export.
+func exportSecret(t *testing.T, f *managedCertificateFixture, c client.Client, name string, serial int64) *core.Secret {
+	t.Helper()
+	ctx := context.TODO()
+	key := client.ObjectKey{Namespace: "ns1", Name: name}
+	secret := new(core.Secret)
+	if err := c.Get(ctx, key, secret); err != nil {
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name,
+				Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+			Type: core.SecretTypeTLS,
+		}
+		secret.Data = f.tlsData(t, "app.example.com", serial)
+		if err := c.Create(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		secret.Data = f.tlsData(t, "app.example.com", serial)
+		if err := c.Update(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &SecretExportReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Recorder:   record.NewFakeRecorder(10),
+	}
+	if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("exporting Secret: %v", err)
+	}
+	if err := c.Get(ctx, key, secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] == "" {
+		t.Fatalf("Secret annotations = %v, want it exported", secret.Annotations)
+	}
+	return secret
+}
+
+func newLoadBalancerBinding(loadBalancerID string) *v1alpha1.OCILoadBalancerBinding {
+	return &v1alpha1.OCILoadBalancerBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "app"},
+		Spec: v1alpha1.OCILoadBalancerBindingSpec{
+			SecretName:     "app-tls",
+			LoadBalancerID: loadBalancerID,
+			ListenerName:   "https",
+		},
+	}
+}
+
+func TestOCILoadBalancerBindingReconciler_Reconcile(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	lbID := f.oci.CreateLoadBalancer(f.issuer.Spec.CompartmentID, "lb", httpsListener())
+	otherLBID := f.oci.CreateLoadBalancer("ocid1.compartment.oc1..other", "other", httpsListener())
+
+	tests := []struct {
+		name    string
+		binding *v1alpha1.OCILoadBalancerBinding
+		// exported is whether the Secret is exported before reconciling.
+		exported bool
+		// tamper changes the exported Secret or the issuer before
+		// reconciling.
+		tamper     func(t *testing.T, c client.Client)
+		wantReason string
+	}{
+		{
+			name:       "updating",
+			binding:    newLoadBalancerBinding(lbID),
+			exported:   true,
+			wantReason: ReasonUpdating,
+		},
+		{
+			name:       "Secret not exported",
+			binding:    newLoadBalancerBinding(lbID),
+			wantReason: ReasonPending,
+		},
+		{
+			name: "listener not found",
+			binding: func() *v1alpha1.OCILoadBalancerBinding {
+				b := newLoadBalancerBinding(lbID)
+				b.Spec.ListenerName = "http"
+				return b
+			}(),
+			exported:   true,
+			wantReason: ReasonListenerNotFound,
+		},
+		{
+			name:       "load balancer in another compartment",
+			binding:    newLoadBalancerBinding(otherLBID),
+			exported:   true,
+			wantReason: ReasonLoadBalancerCompartment,
+		},
+		{
+			name:     "Secret names another exported certificate",
+			binding:  newLoadBalancerBinding(lbID),
+			exported: true,
+			tamper: func(t *testing.T, c client.Client) {
+				other := exportSecret(t, f, c, "other-tls", 2)
+				secret := new(core.Secret)
+				if err := c.Get(ctx, client.ObjectKey{Namespace: "ns1", Name: "app-tls"}, secret); err != nil {
+					t.Fatal(err)
+				}
+				secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] = other.Annotations[v1alpha1.ExportedCertificateIDAnnotation]
+				if err := c.Update(ctx, secret); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantReason: ReasonNotExported,
+		},
+		{
+			name:     "exported certificate violates the policy",
+			binding:  newLoadBalancerBinding(lbID),
+			exported: true,
+			tamper: func(t *testing.T, c client.Client) {
+				// The Secret is allowed, but the certificate in OCI is not.
+				secret := new(core.Secret)
+				if err := c.Get(ctx, client.ObjectKey{Namespace: "ns1", Name: "app-tls"}, secret); err != nil {
+					t.Fatal(err)
+				}
+				secret.Data = f.tlsData(t, "www.example.com", 2)
+				if err := c.Update(ctx, secret); err != nil {
+					t.Fatal(err)
+				}
+				iss := new(v1alpha1.OCICAClusterIssuer)
+				if err := c.Get(ctx, client.ObjectKeyFromObject(f.issuer), iss); err != nil {
+					t.Fatal(err)
+				}
+				iss.Spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"www.example.com"}}}
+				if err := c.Update(ctx, iss); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantReason: "PolicyDNSNames",
+		},
+		{
+			name:       "invalid load balancer OCID",
+			binding:    newLoadBalancerBinding("ocid1.certificate.oc1..aaaa"),
+			wantReason: ReasonInvalidSpec,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := f.client(f.issuer.DeepCopy(), tt.binding.DeepCopy())
+			if tt.exported {
+				exportSecret(t, f, c, "app-tls", 1)
+			}
+			if tt.tamper != nil {
+				tt.tamper(t, c)
+			}
+			r := &OCILoadBalancerBindingReconciler{
+				Collection: f.collection,
+				Client:     c,
+				Log:        logr.Discard(),
+				Recorder:   record.NewFakeRecorder(10),
+				Clock:      clock.RealClock{},
+			}
+			key := client.ObjectKeyFromObject(tt.binding)
+			if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			b := new(v1alpha1.OCILoadBalancerBinding)
+			if err := c.Get(ctx, key, b); err != nil {
+				t.Fatal(err)
+			}
+			if len(b.Status.Conditions) != 1 || b.Status.Conditions[0].Reason != tt.wantReason {
+				t.Fatalf("Reconcile() conditions = %v, want %s", b.Status.Conditions, tt.wantReason)
+			}
+			if (b.Status.Update != nil) != (tt.wantReason == ReasonUpdating) {
+				t.Errorf("Reconcile() update = %+v", b.Status.Update)
+			}
+		})
+	}
+}
+
+func TestOCILoadBalancerBindingReconciler_Reconcile_rotation(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	lbID := f.oci.CreateLoadBalancer(f.issuer.Spec.CompartmentID, "lb", httpsListener())
+	binding := newLoadBalancerBinding(lbID)
+	c, _ := f.client(f.issuer.DeepCopy(), binding)
+	r := &OCILoadBalancerBindingReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Recorder:   record.NewFakeRecorder(20),
+		Clock:      clock.RealClock{},
+	}
+	key := client.ObjectKeyFromObject(binding)
+	reconcile := func() (*v1alpha1.OCILoadBalancerBinding, loadbalancer.Listener) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		b := new(v1alpha1.OCILoadBalancerBinding)
+		if err := c.Get(ctx, key, b); err != nil {
+			t.Fatal(err)
+		}
+		l, err := f.oci.LoadBalancerListener(lbID, "https")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b, l
+	}
+	wantReady := func(b *v1alpha1.OCILoadBalancerBinding, reason string) {
+		t.Helper()
+		if len(b.Status.Conditions) != 1 || b.Status.Conditions[0].Reason != reason {
+			t.Fatalf("conditions = %v, want %s", b.Status.Conditions, reason)
+		}
+	}
+
+	secret := exportSecret(t, f, c, "app-tls", 1)
+	id := secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation]
+	b, _ := reconcile()
+	wantReady(b, ReasonUpdating)
+	b, l := reconcile()
+	wantReady(b, ReasonAttached)
+	if b.Status.CertificateID != id || b.Status.Serial != "1" || b.Status.Update != nil {
+		t.Errorf("after attaching status = %+v, want %s with serial 1", b.Status, id)
+	}
+	if got := l.SslConfiguration.CertificateIds; len(got) != 1 || got[0] != id || l.SslConfiguration.CertificateName != nil {
+		t.Errorf("listener certificates = %v, want %s", got, id)
+	}
+	updates := f.oci.Calls("UpdateListener")
+	reconcile()
+	if got := f.oci.Calls("UpdateListener"); got != updates {
+		t.Errorf("attached listener was updated again")
+	}
+
+	// A new version of the exported certificate is deployed again.
+	exportSecret(t, f, c, "app-tls", 2)
+	b, _ = reconcile()
+	wantReady(b, ReasonUpdating)
+	b, _ = reconcile()
+	wantReady(b, ReasonAttached)
+	if b.Status.Serial != "2" {
+		t.Errorf("after rotation serial = %s, want 2", b.Status.Serial)
+	}
+	b, _ = reconcile()
+	wantReady(b, ReasonAttached)
+
+	// Versions are deployed under the same OCID, so a version the load
+	// balancer cannot deploy leaves nothing to roll back to.
+	f.oci.RejectCertificate(id)
+	exportSecret(t, f, c, "app-tls", 3)
+	b, _ = reconcile()
+	wantReady(b, ReasonUpdating)
+	b, _ = reconcile()
+	wantReady(b, ReasonFailed)
+	if b.Status.Serial != "2" || b.Status.FailedSerial != "3" || b.Status.Update != nil {
+		t.Errorf("after failing status = %+v, want serial 2 and failed serial 3", b.Status)
+	}
+}
+
+func TestOCILoadBalancerBindingReconciler_Reconcile_rollback(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	lbID := f.oci.CreateLoadBalancer(f.issuer.Spec.CompartmentID, "lb", httpsListener())
+	binding := newLoadBalancerBinding(lbID)
+	c, _ := f.client(f.issuer.DeepCopy(), binding)
+	r := &OCILoadBalancerBindingReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Recorder:   record.NewFakeRecorder(20),
+		Clock:      clock.RealClock{},
+	}
+	key := client.ObjectKeyFromObject(binding)
+	reconcile := func(want string) (*v1alpha1.OCILoadBalancerBinding, loadbalancer.Listener) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		b := new(v1alpha1.OCILoadBalancerBinding)
+		if err := c.Get(ctx, key, b); err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Status.Conditions) != 1 || b.Status.Conditions[0].Reason != want {
+			t.Fatalf("conditions = %v, want %s", b.Status.Conditions, want)
+		}
+		l, err := f.oci.LoadBalancerListener(lbID, "https")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b, l
+	}
+
+	// A certificate the load balancer cannot deploy is rolled back to the
+	// certificate the listener served, and not tried again until the
+	// retry interval.
+	secret := exportSecret(t, f, c, "app-tls", 1)
+	f.oci.RejectCertificate(secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation])
+	reconcile(ReasonUpdating)
+	b, _ := reconcile(ReasonRollingBack)
+	if b.Status.Update == nil || !b.Status.Update.Rollback || b.Status.FailedSerial != "1" {
+		t.Errorf("after failing status = %+v, want a rollback of serial 1", b.Status)
+	}
+	b, l := reconcile(ReasonRolledBack)
+	if ssl := l.SslConfiguration; len(ssl.CertificateIds) != 0 || ssl.CertificateName == nil || *ssl.CertificateName != "uploaded" {
+		t.Errorf("after rollback listener certificate = %+v, want the uploaded certificate", ssl)
+	}
+	if b.Status.CertificateID != "" || b.Status.Update != nil || b.Status.LastFailureTime == nil {
+		t.Errorf("after rollback status = %+v, want the failure time", b.Status)
+	}
+	updates := f.oci.Calls("UpdateListener")
+	reconcile(ReasonRolledBack)
+	if got := f.oci.Calls("UpdateListener"); got != updates {
+		t.Errorf("failed certificate was tried again before the retry interval")
+	}
+}
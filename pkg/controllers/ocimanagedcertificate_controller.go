@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"net"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strconv"
+	"time"
+)
+
+// Reasons of the Ready condition of OCIManagedCertificates, besides the
+// reasons of decisions taken against the issuer.
+const (
+	ReasonIssued         = "Issued"
+	ReasonPending        = "Pending"
+	ReasonFailed         = "Failed"
+	ReasonInvalidSpec    = "InvalidSpec"
+	ReasonSecretConflict = "SecretConflict"
+)
+
+// OCIManagedCertificateReconciler reconciles OCIManagedCertificates, creating
+// OCI certificates with keys generated by OCI and writing their current
+// version to a Secret.
+type OCIManagedCertificateReconciler struct {
+	Collection *provisioner.Collection
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Clock    clock.Clock
+
+	// ClusterID identifies the cluster in the names and descriptions of
+	// OCI certificates.
+	ClusterID string
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocimanagedcertificates,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocimanagedcertificates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=ocica.cert-manager.io,resources=ocimanagedcertificates/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+// Reconcile creates the OCI certificate of an OCIManagedCertificate once, then
+// polls its current version, which changes when OCI renews it, and writes it
+// to the Secret of the OCIManagedCertificate.
+func (r *OCIManagedCertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("ocimanagedcertificate", req.NamespacedName)
+	mc := new(ocicav1alpha1.OCIManagedCertificate)
+	if err := r.Client.Get(ctx, req.NamespacedName, mc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get OCIManagedCertificate")
+		return ctrl.Result{}, err
+	}
+	if !mc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, log, mc)
+	}
+	poll := pollInterval(mc)
+
+	if err := validateManagedCertificate(mc.Spec); err != nil {
+		return ctrl.Result{}, r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonInvalidSpec, "Invalid spec: %s", err)
+	}
+
+	issuerName := types.NamespacedName{Name: mc.Spec.IssuerName}
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, issuerName, iss); err != nil {
+		log.Error(err, "failed to get issuer")
+		_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Failed to get issuer %s: %s", issuerName.Name, err)
+		return ctrl.Result{}, err
+	}
+	if !issuerReady(iss) {
+		_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Issuer %s is not ready", issuerName.Name)
+		return ctrl.Result{}, fmt.Errorf("issuer %s is not ready", issuerName.Name)
+	}
+	p, ok := r.Collection.Load(issuerName)
+	if !ok {
+		_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Failed to load provisioner for issuer %s", issuerName.Name)
+		return ctrl.Result{}, fmt.Errorf("provisioner for %s not found", issuerName)
+	}
+
+	// Set before the certificate is created, so that it is deleted with mc.
+	if controllerutil.AddFinalizer(mc, ocicav1alpha1.ManagedCertificateFinalizer) {
+		if err := r.Client.Update(ctx, mc); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if mc.Status.CertificateID == "" {
+		// The issuer is only consulted when the certificate is created:
+		// versions OCI issues later are for the same names.
+		request := func() (*x509.CertificateRequest, error) {
+			return managedCertificateRequest(mc.Spec), nil
+		}
+		d, err := decideFor(ctx, r.Client, mc.Namespace, request, mc.Spec.Duration, iss)
+		if err != nil {
+			log.Error(err, "failed to check certificate against the issuer")
+			_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Failed to check certificate against issuer %s: %s", issuerName.Name, err)
+			return ctrl.Result{}, err
+		}
+		if !d.allowed {
+			log.Info("OCIManagedCertificate is refused by the issuer", "reason", d.reason)
+			return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, mc, metav1.ConditionFalse, d.reason, "%s", d.message)
+		}
+		id, err := p.CreateManagedCertificate(ctx, mc, provisioner.SignOptions{CompartmentID: d.compartmentID, ClusterID: r.ClusterID})
+		if err != nil {
+			log.Error(err, "failed to create certificate in OCI")
+			if retryable(err) {
+				_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Failed to create certificate in OCI: %s", err)
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonFailed, "Failed to create certificate in OCI: %s", err)
+		}
+		// Recorded before anything else can fail, so that the certificate
+		// is not created twice.
+		mc.Status.CertificateID = id
+		if err := r.Client.Status().Update(ctx, mc); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(mc, core.EventTypeNormal, "Created", "Created OCI certificate %s", id)
+	}
+
+	bundle, err := p.ManagedCertificateBundle(ctx, mc.Status.CertificateID)
+	if err != nil {
+		log.Error(err, "failed to get certificate bundle from OCI")
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonFailed,
+				"OCI certificate %s was not found, delete this OCIManagedCertificate to create a new one", mc.Status.CertificateID)
+		}
+		_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Failed to get certificate from OCI: %s", err)
+		return ctrl.Result{}, err
+	}
+
+	written, err := writeCertificateSecret(ctx, r.Client, r.Scheme, mc, mc.Spec.SecretName, mc.Status.CertificateID, bundle)
+	if err != nil {
+		var conflict *secretConflictError
+		if errors.As(err, &conflict) {
+			return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonSecretConflict, "%s", err)
+		}
+		log.Error(err, "failed to write Secret")
+		_ = r.setStatus(ctx, mc, metav1.ConditionFalse, ReasonPending, "Failed to write Secret %s: %s", mc.Spec.SecretName, err)
+		return ctrl.Result{}, err
+	}
+	if written && mc.Status.Version != 0 && mc.Status.Version != bundle.Version {
+		r.Recorder.Eventf(mc, core.EventTypeNormal, "Renewed", "Wrote version %d of OCI certificate %s to Secret %s",
+			bundle.Version, mc.Status.CertificateID, mc.Spec.SecretName)
+	}
+	mc.Status.Version = bundle.Version
+	notAfter := metav1.NewTime(bundle.NotAfter)
+	mc.Status.NotAfter = &notAfter
+	return ctrl.Result{RequeueAfter: poll}, r.setStatus(ctx, mc, metav1.ConditionTrue, ReasonIssued,
+		"Version %d of OCI certificate %s is in Secret %s", bundle.Version, mc.Status.CertificateID, mc.Spec.SecretName)
+}
+
+// finalize schedules the deletion of the OCI certificate of mc, which is
+// being deleted, and removes the finalizer of mc. The certificate is left in
+// OCI when the issuer is gone, as there are no credentials to delete it with.
+func (r *OCIManagedCertificateReconciler) finalize(ctx context.Context, log logr.Logger, mc *ocicav1alpha1.OCIManagedCertificate) error {
+	if !controllerutil.ContainsFinalizer(mc, ocicav1alpha1.ManagedCertificateFinalizer) {
+		return nil
+	}
+	if mc.Status.CertificateID != "" {
+		issuerName := types.NamespacedName{Name: mc.Spec.IssuerName}
+		err := r.Client.Get(ctx, issuerName, new(ocicav1alpha1.OCICAClusterIssuer))
+		switch {
+		case apierrors.IsNotFound(err):
+			log.Info("issuer is gone, leaving OCI certificate", "certificateID", mc.Status.CertificateID)
+			r.Recorder.Eventf(mc, core.EventTypeWarning, "Orphaned", "Issuer %s is gone, delete OCI certificate %s in OCI",
+				issuerName.Name, mc.Status.CertificateID)
+		case err != nil:
+			return err
+		default:
+			p, ok := r.Collection.Load(issuerName)
+			if !ok {
+				return fmt.Errorf("provisioner for %s not found", issuerName)
+			}
+			if err := p.DeleteManagedCertificate(ctx, mc.Status.CertificateID); err != nil {
+				log.Error(err, "failed to schedule deletion of OCI certificate")
+				r.Recorder.Eventf(mc, core.EventTypeWarning, "DeleteFailed", "Failed to schedule deletion of OCI certificate %s: %s",
+					mc.Status.CertificateID, err)
+				return err
+			}
+			r.Recorder.Eventf(mc, core.EventTypeNormal, "Deleted", "Scheduled deletion of OCI certificate %s", mc.Status.CertificateID)
+		}
+	}
+	controllerutil.RemoveFinalizer(mc, ocicav1alpha1.ManagedCertificateFinalizer)
+	return r.Client.Update(ctx, mc)
+}
+
+// secretConflictError is returned when the Secret of an OCIManagedCertificate
+// or OCICertificateImport belongs to something else.
+type secretConflictError struct {
+	secret string
+	reason string
+}
+
+func (e *secretConflictError) Error() string {
+	return fmt.Sprintf("Secret %s %s", e.secret, e.reason)
+}
+
+// writeCertificateSecret writes bundle, a version of the OCI certificate
+// certificateID, to the Secret secretName in the namespace of owner, creating
+// it controlled by owner. It reports whether the Secret changed.
+func writeCertificateSecret(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object,
+	secretName, certificateID string, bundle *provisioner.ManagedCertificate) (bool, error) {
+	secret := new(core.Secret)
+	err := c.Get(ctx, types.NamespacedName{Namespace: owner.GetNamespace(), Name: secretName}, secret)
+	exists := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	if exists {
+		if controller := metav1.GetControllerOf(secret); controller != nil && controller.UID != owner.GetUID() {
+			return false, &secretConflictError{secret: secret.Name, reason: fmt.Sprintf("is controlled by %s %s", controller.Kind, controller.Name)}
+		}
+		if secret.Type != core.SecretTypeTLS {
+			return false, &secretConflictError{secret: secret.Name, reason: fmt.Sprintf("has type %s, not %s", secret.Type, core.SecretTypeTLS)}
+		}
+	} else {
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: owner.GetNamespace(), Name: secretName},
+			Type:       core.SecretTypeTLS,
+		}
+	}
+
+	// kubernetes.io/tls Secrets need tls.key even when there is no key.
+	key := bundle.PrivateKey
+	if key == nil {
+		key = []byte{}
+	}
+	data := map[string][]byte{
+		core.TLSCertKey:       bundle.Certificate,
+		core.TLSPrivateKeyKey: key,
+		"ca.crt":              bundle.CA,
+	}
+	version := strconv.FormatInt(bundle.Version, 10)
+	if exists && metav1.IsControlledBy(secret, owner) && sameData(secret.Data, data) &&
+		secret.Annotations[ocicav1alpha1.ManagedCertificateIDAnnotation] == certificateID &&
+		secret.Annotations[ocicav1alpha1.ManagedCertificateVersionAnnotation] == version {
+		return false, nil
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[ocicav1alpha1.ManagedCertificateIDAnnotation] = certificateID
+	secret.Annotations[ocicav1alpha1.ManagedCertificateVersionAnnotation] = version
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range data {
+		secret.Data[k] = v
+	}
+	if err := controllerutil.SetControllerReference(owner, secret, scheme); err != nil {
+		return false, err
+	}
+	if exists {
+		return true, c.Update(ctx, secret)
+	}
+	return true, c.Create(ctx, secret)
+}
+
+// sameData reports whether every key of want is in got with the same value.
+func sameData(got, want map[string][]byte) bool {
+	for k, v := range want {
+		if !bytes.Equal(got[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// setReadyCondition sets the Ready condition of obj, whose conditions are
+// conditions, and updates the status of obj. Changes of the condition are
+// recorded as events, so that polls that find nothing new are not.
+func setReadyCondition(ctx context.Context, c client.Client, recorder record.EventRecorder, now time.Time, obj client.Object,
+	conditions *[]metav1.Condition, status metav1.ConditionStatus, reason, message string, args ...interface{}) error {
+	completeMessage := fmt.Sprintf(message, args...)
+	cond := metav1.Condition{
+		Type:               string(ocicav1alpha1.ConditionReady),
+		Status:             status,
+		Reason:             reason,
+		Message:            completeMessage,
+		ObservedGeneration: obj.GetGeneration(),
+		LastTransitionTime: metav1.NewTime(now),
+	}
+	var previous *metav1.Condition
+	for i := range *conditions {
+		if (*conditions)[i].Type == cond.Type {
+			previous = &(*conditions)[i]
+		}
+	}
+	if previous != nil && previous.Status == status {
+		cond.LastTransitionTime = previous.LastTransitionTime
+	}
+	if previous == nil || previous.Status != status || previous.Reason != reason || previous.Message != completeMessage {
+		eventType := core.EventTypeNormal
+		if status == metav1.ConditionFalse {
+			eventType = core.EventTypeWarning
+		}
+		recorder.Event(obj, eventType, reason, completeMessage)
+	}
+	if previous != nil {
+		*previous = cond
+	} else {
+		*conditions = append(*conditions, cond)
+	}
+	return c.Status().Update(ctx, obj)
+}
+
+// managedCertificateRequest returns the names of spec as a CSR, for checking
+// against issuer policies.
+func managedCertificateRequest(spec ocicav1alpha1.OCIManagedCertificateSpec) *x509.CertificateRequest {
+	csr := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: spec.CommonName},
+		DNSNames: spec.DNSNames,
+	}
+	for _, ip := range spec.IPAddresses {
+		csr.IPAddresses = append(csr.IPAddresses, net.ParseIP(ip))
+	}
+	return csr
+}
+
+// validateManagedCertificate checks what the schema of OCIManagedCertificates
+// cannot.
+func validateManagedCertificate(spec ocicav1alpha1.OCIManagedCertificateSpec) error {
+	if spec.IssuerName == "" || spec.SecretName == "" || spec.CommonName == "" {
+		return fmt.Errorf("issuerName, secretName and commonName are required")
+	}
+	for _, ip := range spec.IPAddresses {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address %q", ip)
+		}
+	}
+	if renewal := spec.Renewal; renewal != nil {
+		const day = 24 * time.Hour
+		if renewal.Interval.Duration < day || renewal.Interval.Duration%day != 0 ||
+			renewal.AdvancePeriod.Duration < day || renewal.AdvancePeriod.Duration%day != 0 {
+			return fmt.Errorf("renewal interval and advancePeriod must be whole days")
+		}
+		if renewal.AdvancePeriod.Duration >= renewal.Interval.Duration {
+			return fmt.Errorf("renewal advancePeriod must be shorter than the interval")
+		}
+	}
+	if spec.PollInterval != nil && spec.PollInterval.Duration <= 0 {
+		return fmt.Errorf("pollInterval must be positive")
+	}
+	return nil
+}
+
+// pollInterval returns how often mc is checked for new versions.
+func pollInterval(mc *ocicav1alpha1.OCIManagedCertificate) time.Duration {
+	if mc.Spec.PollInterval != nil && mc.Spec.PollInterval.Duration > 0 {
+		return mc.Spec.PollInterval.Duration
+	}
+	return ocicav1alpha1.DefaultManagedCertificatePollInterval
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OCIManagedCertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&ocicav1alpha1.OCIManagedCertificate{}).
+		Owns(&core.Secret{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// setStatus sets the Ready condition of mc and updates the status of mc.
+func (r *OCIManagedCertificateReconciler) setStatus(ctx context.Context, mc *ocicav1alpha1.OCIManagedCertificate, status metav1.ConditionStatus, reason, message string, args ...interface{}) error {
+	return setReadyCondition(ctx, r.Client, r.Recorder, r.Clock.Now(), mc, &mc.Status.Conditions, status, reason, message, args...)
+}
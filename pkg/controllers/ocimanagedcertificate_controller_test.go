@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// managedCertificateFixture is an OCI fake with a ready issuer whose
+// provisioner is in collection.
+type managedCertificateFixture struct {
+	oci        *ocifake.Server
+	issuer     *v1alpha1.OCICAClusterIssuer
+	collection *provisioner.Collection
+}
+
+func newManagedCertificateFixture(t *testing.T) *managedCertificateFixture {
+	t.Helper()
+	fakeOCI := ocifake.NewServer(ocifake.Options{})
+	t.Cleanup(fakeOCI.Close)
+	authorityID, err := fakeOCI.CreateRootCA("ocid1.compartment.oc1..aaaa", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fakeOCI.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer1"},
+		Spec: v1alpha1.OCICAClusterIssuerSpec{
+			TenancyID:     "ocid1.tenancy.oc1..aaaa",
+			CompartmentID: "ocid1.compartment.oc1..aaaa",
+			AuthorityID:   authorityID,
+			EndpointOverride: &v1alpha1.EndpointOverride{
+				CertificatesManagement: fakeOCI.URL,
+				Certificates:           fakeOCI.URL,
+				LoadBalancer:           fakeOCI.URL,
+			},
+		},
+		Status: v1alpha1.OCICAClusterIssuerStatus{Conditions: []metav1.Condition{
+			{Type: string(v1alpha1.ConditionReady), Status: metav1.ConditionTrue},
+		}},
+	}
+	p, err := provisioner.New(logr.Discard(), *iss, configProvider, provisioner.Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	collection := &provisioner.Collection{}
+	collection.Store(types.NamespacedName{Name: iss.Name}, p)
+	return &managedCertificateFixture{oci: fakeOCI, issuer: iss, collection: collection}
+}
+
+// client returns a fake client holding namespace ns1 and objs.
+func (f *managedCertificateFixture) client(objs ...client.Object) (client.Client, *runtime.Scheme) {
+	scheme := runtime.NewScheme()
+	_ = core.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}},
+	).WithObjects(objs...).Build(), scheme
+}
+
+func (f *managedCertificateFixture) reconciler(objs ...client.Object) (*OCIManagedCertificateReconciler, client.Client) {
+	c, scheme := f.client(objs...)
+	return &OCIManagedCertificateReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Scheme:     scheme,
+		Recorder:   record.NewFakeRecorder(10),
+		Clock:      clock.RealClock{},
+	}, c
+}
+
+func newManagedCertificate(spec func(spec *v1alpha1.OCIManagedCertificateSpec)) *v1alpha1.OCIManagedCertificate {
+	mc := &v1alpha1.OCIManagedCertificate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "app", UID: "mc-uid"},
+		Spec: v1alpha1.OCIManagedCertificateSpec{
+			IssuerName:   "issuer1",
+			SecretName:   "app-tls",
+			CommonName:   "app.example.com",
+			DNSNames:     []string{"app.example.com"},
+			KeyAlgorithm: "ECDSA_P256",
+			PollInterval: &metav1.Duration{Duration: time.Minute},
+		},
+	}
+	if spec != nil {
+		spec(&mc.Spec)
+	}
+	return mc
+}
+
+func TestOCIManagedCertificateReconciler_Reconcile(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	otherOwner := true
+
+	tests := []struct {
+		name   string
+		mc     *v1alpha1.OCIManagedCertificate
+		issuer func(spec *v1alpha1.OCICAClusterIssuerSpec)
+		secret *core.Secret
+		// wantReason is the reason of the Ready condition, which is true
+		// only for ReasonIssued.
+		wantReason  string
+		wantCreated bool
+	}{
+		{
+			name:        "issued",
+			mc:          newManagedCertificate(nil),
+			wantReason:  ReasonIssued,
+			wantCreated: true,
+		},
+		{
+			name: "issued with IP addresses",
+			mc: newManagedCertificate(func(spec *v1alpha1.OCIManagedCertificateSpec) {
+				spec.IPAddresses = []string{"10.0.0.1"}
+				spec.Renewal = &v1alpha1.ManagedCertificateRenewal{
+					Interval:      metav1.Duration{Duration: 60 * 24 * time.Hour},
+					AdvancePeriod: metav1.Duration{Duration: 10 * 24 * time.Hour},
+				}
+			}),
+			wantReason:  ReasonIssued,
+			wantCreated: true,
+		},
+		{
+			name: "denied by policy",
+			mc:   newManagedCertificate(nil),
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Deny: []string{"*.example.com"}}}
+			},
+			wantReason: "PolicyDNSNames",
+		},
+		{
+			name: "invalid IP address",
+			mc: newManagedCertificate(func(spec *v1alpha1.OCIManagedCertificateSpec) {
+				spec.IPAddresses = []string{"not-an-ip"}
+			}),
+			wantReason: ReasonInvalidSpec,
+		},
+		{
+			name: "renewal in hours",
+			mc: newManagedCertificate(func(spec *v1alpha1.OCIManagedCertificateSpec) {
+				spec.Renewal = &v1alpha1.ManagedCertificateRenewal{
+					Interval:      metav1.Duration{Duration: 36 * time.Hour},
+					AdvancePeriod: metav1.Duration{Duration: 24 * time.Hour},
+				}
+			}),
+			wantReason: ReasonInvalidSpec,
+		},
+		{
+			name: "Secret controlled by something else",
+			mc:   newManagedCertificate(nil),
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "app-tls", OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "cert-manager.io/v1", Kind: "Certificate", Name: "app", UID: "other", Controller: &otherOwner,
+				}}},
+				Type: core.SecretTypeTLS,
+			},
+			wantReason:  ReasonSecretConflict,
+			wantCreated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Certificates are named after the OCIManagedCertificate, so
+			// every case uses a name of its own.
+			tt.mc.Name = strings.ReplaceAll(strings.ToLower(tt.name), " ", "-")
+			iss := f.issuer.DeepCopy()
+			if tt.issuer != nil {
+				tt.issuer(&iss.Spec)
+			}
+			objs := []client.Object{iss, tt.mc.DeepCopy()}
+			if tt.secret != nil {
+				objs = append(objs, tt.secret)
+			}
+			r, c := f.reconciler(objs...)
+			before := len(f.oci.Certificates())
+			key := client.ObjectKeyFromObject(tt.mc)
+			res, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			mc := new(v1alpha1.OCIManagedCertificate)
+			if err := c.Get(ctx, key, mc); err != nil {
+				t.Fatal(err)
+			}
+			wantCreated := 0
+			if tt.wantCreated {
+				wantCreated = 1
+			}
+			if got := len(f.oci.Certificates()) - before; got != wantCreated {
+				t.Errorf("Reconcile() created %d OCI certificates, want %d", got, wantCreated)
+			}
+			if len(mc.Status.Conditions) != 1 {
+				t.Fatalf("Reconcile() conditions = %v, want Ready", mc.Status.Conditions)
+			}
+			cond := mc.Status.Conditions[0]
+			wantStatus := metav1.ConditionFalse
+			if tt.wantReason == ReasonIssued {
+				wantStatus = metav1.ConditionTrue
+			}
+			if cond.Status != wantStatus || cond.Reason != tt.wantReason {
+				t.Fatalf("Reconcile() Ready = %s (%s: %s), want %s (%s)", cond.Status, cond.Reason, cond.Message, wantStatus, tt.wantReason)
+			}
+			if tt.wantReason != ReasonIssued {
+				return
+			}
+
+			if res.RequeueAfter != time.Minute {
+				t.Errorf("Reconcile() RequeueAfter = %s, want the poll interval", res.RequeueAfter)
+			}
+			if mc.Status.Version != 1 || mc.Status.NotAfter == nil {
+				t.Errorf("Reconcile() status = %+v, want version 1", mc.Status)
+			}
+			secret := new(core.Secret)
+			if err := c.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "app-tls"}, secret); err != nil {
+				t.Fatal(err)
+			}
+			if !metav1.IsControlledBy(secret, mc) {
+				t.Errorf("Secret owners = %v, want the OCIManagedCertificate", secret.OwnerReferences)
+			}
+			if secret.Annotations[v1alpha1.ManagedCertificateIDAnnotation] != mc.Status.CertificateID {
+				t.Errorf("Secret annotations = %v, want the certificate ID", secret.Annotations)
+			}
+			leaf := parseSecretCertificate(t, secret)
+			if leaf.Subject.CommonName != "app.example.com" || len(leaf.IPAddresses) != len(tt.mc.Spec.IPAddresses) {
+				t.Errorf("certificate subject = %s, IPs = %v", leaf.Subject, leaf.IPAddresses)
+			}
+			if block, _ := pem.Decode(secret.Data[core.TLSPrivateKeyKey]); block == nil {
+				t.Errorf("Secret has no private key")
+			} else if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+				t.Errorf("Secret private key: %v", err)
+			}
+			if len(secret.Data["ca.crt"]) == 0 {
+				t.Errorf("Secret has no CA")
+			}
+		})
+	}
+}
+
+func TestOCIManagedCertificateReconciler_Reconcile_renewal(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	r, c := f.reconciler(f.issuer.DeepCopy(), newManagedCertificate(nil))
+	key := types.NamespacedName{Namespace: "ns1", Name: "app"}
+	reconcile := func() (*v1alpha1.OCIManagedCertificate, *core.Secret) {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		mc := new(v1alpha1.OCIManagedCertificate)
+		if err := c.Get(ctx, key, mc); err != nil {
+			t.Fatal(err)
+		}
+		secret := new(core.Secret)
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "ns1", Name: "app-tls"}, secret); err != nil {
+			t.Fatal(err)
+		}
+		return mc, secret
+	}
+
+	mc, secret := reconcile()
+	first := parseSecretCertificate(t, secret)
+	if _, err := f.oci.RenewCertificate(mc.Status.CertificateID); err != nil {
+		t.Fatal(err)
+	}
+	mc, secret = reconcile()
+	renewed := parseSecretCertificate(t, secret)
+	if mc.Status.Version != 2 || secret.Annotations[v1alpha1.ManagedCertificateVersionAnnotation] != "2" {
+		t.Errorf("after renewal version = %d, Secret annotations = %v, want 2", mc.Status.Version, secret.Annotations)
+	}
+	if renewed.SerialNumber.Cmp(first.SerialNumber) == 0 {
+		t.Errorf("Secret still holds the first version")
+	}
+
+	// A certificate created by an attempt whose status update was lost is
+	// picked up rather than created again.
+	mc.Status = v1alpha1.OCIManagedCertificateStatus{}
+	if err := c.Status().Update(ctx, mc); err != nil {
+		t.Fatal(err)
+	}
+	id := f.oci.Certificates()[0]
+	mc, _ = reconcile()
+	if mc.Status.CertificateID != id || len(f.oci.Certificates()) != 1 {
+		t.Errorf("after losing the status certificate = %s of %v, want %s", mc.Status.CertificateID, f.oci.Certificates(), id)
+	}
+}
+
+func TestOCIManagedCertificateReconciler_Reconcile_deletion(t *testing.T) {
+	ctx := context.TODO()
+	tests := []struct {
+		name string
+		// issuerGone deletes the issuer before the OCIManagedCertificate.
+		issuerGone bool
+		wantState  certificatesmanagement.CertificateLifecycleStateEnum
+	}{
+		{
+			name:      "scheduled for deletion",
+			wantState: certificatesmanagement.CertificateLifecycleStatePendingDeletion,
+		},
+		{
+			name:       "issuer gone",
+			issuerGone: true,
+			wantState:  certificatesmanagement.CertificateLifecycleStateActive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newManagedCertificateFixture(t)
+			iss := f.issuer.DeepCopy()
+			r, c := f.reconciler(iss, newManagedCertificate(nil))
+			key := types.NamespacedName{Namespace: "ns1", Name: "app"}
+			if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			mc := new(v1alpha1.OCIManagedCertificate)
+			if err := c.Get(ctx, key, mc); err != nil {
+				t.Fatal(err)
+			}
+			if !controllerutil.ContainsFinalizer(mc, v1alpha1.ManagedCertificateFinalizer) {
+				t.Fatalf("finalizers = %v, want %s", mc.Finalizers, v1alpha1.ManagedCertificateFinalizer)
+			}
+
+			if tt.issuerGone {
+				if err := c.Delete(ctx, iss); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := c.Delete(ctx, mc); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if err := c.Get(ctx, key, mc); !apierrors.IsNotFound(err) {
+				t.Errorf("after deletion Get() error = %v, finalizers = %v, want not found", err, mc.Finalizers)
+			}
+
+			configProvider, err := f.oci.ConfigurationProvider()
+			if err != nil {
+				t.Fatal(err)
+			}
+			caClient, err := certificatesmanagement.NewCertificatesManagementClientWithConfigurationProvider(configProvider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			caClient.Host = f.oci.URL
+			res, err := caClient.GetCertificate(ctx, certificatesmanagement.GetCertificateRequest{CertificateId: common.String(mc.Status.CertificateID)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.LifecycleState != tt.wantState {
+				t.Errorf("OCI certificate state = %s, want %s", res.LifecycleState, tt.wantState)
+			}
+		})
+	}
+}
+
+func parseSecretCertificate(t *testing.T, secret *core.Secret) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(secret.Data[core.TLSCertKey])
+	if block == nil {
+		t.Fatalf("Secret %s has no certificate", secret.Name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/schedule"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+// ReasonPaused is the reason of CertificateRequests waiting for the pause of
+// their issuer to end.
+const ReasonPaused = "Paused"
+
+// pauseHorizon is how far ahead certificates must not expire for their
+// renewal to wait for a pause without an end.
+const pauseHorizon = 24 * time.Hour
+
+// pause is why and until when an issuer does not sign requests.
+type pause struct {
+	// until is when issuance resumes, or zero when the pause lasts until
+	// the PausedAnnotation is removed.
+	until   time.Time
+	message string
+}
+
+// issuerPause returns the pause of iss at now from its PausedAnnotation and
+// maintenance windows, or nil when it signs requests.
+func issuerPause(iss *ocicav1alpha1.OCICAClusterIssuer, now time.Time) (*pause, error) {
+	var p *pause
+	if value, ok := iss.Annotations[ocicav1alpha1.PausedAnnotation]; ok {
+		paused, until, err := ocicav1alpha1.ParsePausedAnnotation(value)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s: %w", ocicav1alpha1.PausedAnnotation, err)
+		}
+		switch {
+		case paused && until.IsZero():
+			return &pause{message: fmt.Sprintf("Issuer %s is paused until the %s annotation is removed", iss.Name, ocicav1alpha1.PausedAnnotation)}, nil
+		case paused && now.Before(until):
+			p = &pause{until: until, message: fmt.Sprintf("Issuer %s is paused until %s", iss.Name, until.UTC().Format(time.RFC3339))}
+		}
+	}
+	for _, window := range iss.Spec.MaintenanceWindows {
+		s, err := schedule.Parse(window.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		loc, err := time.LoadLocation(window.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		end, ok := s.Active(now.In(loc), window.Duration.Duration)
+		if ok && (p == nil || end.After(p.until)) {
+			p = &pause{until: end, message: fmt.Sprintf("Issuer %s is in the maintenance window %q until %s", iss.Name, window.Schedule, end.UTC().Format(time.RFC3339))}
+		}
+	}
+	return p, nil
+}
+
+// existingNotAfter returns when the certificate cr renews expires, or zero
+// when cr issues a new certificate.
+func (r *CertificateRequestReconciler) existingNotAfter(ctx context.Context, cr *cmapi.CertificateRequest) (time.Time, error) {
+	name, ok := cr.Annotations[cmapi.CertificateNameKey]
+	if !ok {
+		return time.Time{}, nil
+	}
+	cert := new(cmapi.Certificate)
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: cr.Namespace, Name: name}, cert); err != nil {
+		if apierrors.IsNotFound(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if cert.Status.NotAfter == nil {
+		return time.Time{}, nil
+	}
+	return cert.Status.NotAfter.Time, nil
+}
+
+// holds reports whether p holds back cr, whose existing certificate expires
+// at notAfter, and when to check it again. Renewals of certificates expiring
+// before p ends are let through so that a pause never causes an outage.
+func (p *pause) holds(notAfter, now time.Time) (bool, time.Duration) {
+	if p.until.IsZero() {
+		if notAfter.IsZero() {
+			return true, 0
+		}
+		retry := notAfter.Add(-pauseHorizon)
+		return now.Before(retry), retry.Sub(now)
+	}
+	if !notAfter.IsZero() && !notAfter.After(p.until) {
+		return false, 0
+	}
+	return true, p.until.Sub(now)
+}
+
+// paused reports whether cr is already held back with message.
+func paused(cr *cmapi.CertificateRequest, message string) bool {
+	cond := cmutil.GetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionReady)
+	return cond != nil && cond.Reason == ReasonPaused && cond.Message == message
+}
+
+// pendingRequestsForIssuer maps an issuer to the pending requests, so that
+// they are signed as soon as it is unpaused.
+func (r *CertificateRequestReconciler) pendingRequestsForIssuer(obj client.Object) []reconcile.Request {
+	return r.pendingRequests("")
+}
@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+	"time"
+)
+
+// ReasonQuotaExceeded is the Ready reason of requests held back by an
+// OCICAIssuanceQuota until it allows them.
+const ReasonQuotaExceeded = "QuotaExceeded"
+
+// quotaExceeded describes the first quota a request is over.
+type quotaExceeded struct {
+	quota   *ocicav1alpha1.OCICAIssuanceQuota
+	message string
+	// retryAt is when the quota allows another certificate.
+	retryAt time.Time
+}
+
+// quotaApplies reports whether requests for the issuer of cr count against q.
+func quotaApplies(q *ocicav1alpha1.OCICAIssuanceQuota, cr *cmapi.CertificateRequest) bool {
+	if cr.Spec.IssuerRef.Kind != OCICAClusterIssuerKind {
+		return false
+	}
+	if len(q.Spec.IssuerNames) == 0 {
+		return true
+	}
+	for _, name := range q.Spec.IssuerNames {
+		if name == cr.Spec.IssuerRef.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaWindow returns the window of q.
+func quotaWindow(q *ocicav1alpha1.OCICAIssuanceQuota) time.Duration {
+	if q.Spec.Window != nil {
+		return q.Spec.Window.Duration
+	}
+	return ocicav1alpha1.DefaultQuotaWindow
+}
+
+// checkQuota returns why q does not allow another certificate at now, or nil
+// when it does.
+func checkQuota(q *ocicav1alpha1.OCICAIssuanceQuota, now time.Time) *quotaExceeded {
+	var exceeded *quotaExceeded
+	raise := func(message string, retryAt time.Time) {
+		if exceeded == nil {
+			exceeded = &quotaExceeded{quota: q, message: message, retryAt: retryAt}
+			return
+		}
+		exceeded.message += "; " + message
+		if retryAt.After(exceeded.retryAt) {
+			exceeded.retryAt = retryAt
+		}
+	}
+
+	if q.Spec.MaxIssuances != nil {
+		window := quotaWindow(q)
+		var inWindow []time.Time
+		for _, issued := range q.Status.Issued {
+			if issued.IssuedAt.Time.After(now.Add(-window)) {
+				inWindow = append(inWindow, issued.IssuedAt.Time)
+			}
+		}
+		if max := int(*q.Spec.MaxIssuances); len(inWindow) >= max {
+			sort.Slice(inWindow, func(i, j int) bool { return inWindow[i].Before(inWindow[j]) })
+			retryAt := now.Add(window)
+			if max > 0 {
+				retryAt = inWindow[len(inWindow)-max].Add(window)
+			}
+			raise(fmt.Sprintf("%d certificates issued in the last %s, the maximum is %d", len(inWindow), window, max), retryAt)
+		}
+	}
+
+	if q.Spec.MaxLiveCertificates != nil {
+		var live []time.Time
+		for _, issued := range q.Status.Issued {
+			if issued.NotAfter.Time.After(now) {
+				live = append(live, issued.NotAfter.Time)
+			}
+		}
+		if max := int(*q.Spec.MaxLiveCertificates); len(live) >= max {
+			sort.Slice(live, func(i, j int) bool { return live[i].Before(live[j]) })
+			// With no live certificate allowed at all, check again when
+			// the quota may have been changed.
+			retryAt := now.Add(quotaWindow(q))
+			if max > 0 {
+				retryAt = live[len(live)-max]
+			}
+			raise(fmt.Sprintf("%d certificates have not expired, the maximum is %d", len(live), max), retryAt)
+		}
+	}
+	return exceeded
+}
+
+// pruneIssued drops the certificates of q that no longer count against it:
+// those out of the window, unless MaxLiveCertificates is set and they have
+// not expired, so that the status of quotas without it stays bounded by the
+// window.
+func pruneIssued(q *ocicav1alpha1.OCICAIssuanceQuota, now time.Time) {
+	start := now.Add(-quotaWindow(q))
+	issued := q.Status.Issued[:0]
+	for _, c := range q.Status.Issued {
+		if c.IssuedAt.Time.After(start) || (q.Spec.MaxLiveCertificates != nil && c.NotAfter.Time.After(now)) {
+			issued = append(issued, c)
+		}
+	}
+	q.Status.Issued = issued
+}
+
+// reservedValidity is how long the certificate of cr is counted as live
+// while it is reserved, until the validity of the issued certificate is
+// known.
+func reservedValidity(cr *cmapi.CertificateRequest, iss *ocicav1alpha1.OCICAClusterIssuer) time.Duration {
+	if cr.Spec.Duration != nil {
+		return cr.Spec.Duration.Duration
+	}
+	if iss.Spec.DefaultDuration != nil {
+		return iss.Spec.DefaultDuration.Duration
+	}
+	return ocicav1alpha1.DefaultCertificateDuration
+}
+
+// issuedFor returns the index of the certificate of cr in q, or -1. Requests
+// are matched by UID, as a request deleted and created again with the same
+// name is a new request.
+func issuedFor(q *ocicav1alpha1.OCICAIssuanceQuota, cr *cmapi.CertificateRequest) int {
+	for i, issued := range q.Status.Issued {
+		if issued.RequestUID == cr.UID {
+			return i
+		}
+	}
+	return -1
+}
+
+// apiReader returns the reader quotas are read with before they are updated.
+func (r *CertificateRequestReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// applicableQuotas lists the OCICAIssuanceQuotas in the namespace of cr that
+// its certificate counts against.
+func (r *CertificateRequestReconciler) applicableQuotas(ctx context.Context, cr *cmapi.CertificateRequest) ([]*ocicav1alpha1.OCICAIssuanceQuota, error) {
+	quotas := new(ocicav1alpha1.OCICAIssuanceQuotaList)
+	if err := r.Client.List(ctx, quotas, client.InNamespace(cr.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list quotas in %s: %w", cr.Namespace, err)
+	}
+	var applicable []*ocicav1alpha1.OCICAIssuanceQuota
+	for i := range quotas.Items {
+		if quotaApplies(&quotas.Items[i], cr) {
+			applicable = append(applicable, &quotas.Items[i])
+		}
+	}
+	return applicable, nil
+}
+
+// updateQuota reads q through the APIReader, applies update to it and writes
+// its status back, retrying when q changed in between. update returns false
+// to leave q unchanged.
+func (r *CertificateRequestReconciler) updateQuota(ctx context.Context, q *ocicav1alpha1.OCICAIssuanceQuota, update func(q *ocicav1alpha1.OCICAIssuanceQuota) bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.apiReader().Get(ctx, client.ObjectKeyFromObject(q), q); err != nil {
+			return err
+		}
+		if !update(q) {
+			return nil
+		}
+		return r.Client.Status().Update(ctx, q)
+	})
+}
+
+// checkQuotas returns the first OCICAIssuanceQuota in the namespace of cr
+// that does not allow it to be signed at now, or nil. With reserve, the
+// certificate of cr is counted against every quota before it is signed, so
+// that concurrent requests cannot take the same slot; it is released again
+// when a quota does not allow it. Quotas are read uncached and updated with
+// their resourceVersion.
+func (r *CertificateRequestReconciler) checkQuotas(ctx context.Context, cr *cmapi.CertificateRequest, now time.Time, validity time.Duration, reserve bool) (*quotaExceeded, error) {
+	quotas, err := r.applicableQuotas(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range quotas {
+		var exceeded *quotaExceeded
+		err := r.updateQuota(ctx, q, func(q *ocicav1alpha1.OCICAIssuanceQuota) bool {
+			exceeded = nil
+			// Reserved by an earlier attempt at signing cr.
+			if issuedFor(q, cr) >= 0 {
+				return false
+			}
+			if exceeded = checkQuota(q, now); exceeded != nil || !reserve {
+				return false
+			}
+			pruneIssued(q, now)
+			q.Status.Issued = append(q.Status.Issued, ocicav1alpha1.IssuedCertificate{
+				Request:    cr.Name,
+				RequestUID: cr.UID,
+				IssuedAt:   metav1.NewTime(now),
+				NotAfter:   metav1.NewTime(now.Add(validity)),
+			})
+			return true
+		})
+		if err == nil && exceeded != nil && reserve {
+			err = r.releaseQuotas(ctx, cr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to reserve certificate in quota %s: %w", client.ObjectKeyFromObject(q), err)
+		}
+		if exceeded != nil {
+			return exceeded, nil
+		}
+	}
+	return nil, nil
+}
+
+// releaseQuotas drops the certificate reserved for cr from every
+// OCICAIssuanceQuota it counts against, when cr is not signed.
+func (r *CertificateRequestReconciler) releaseQuotas(ctx context.Context, cr *cmapi.CertificateRequest) error {
+	quotas, err := r.applicableQuotas(ctx, cr)
+	if err != nil {
+		return err
+	}
+	for _, q := range quotas {
+		err := r.updateQuota(ctx, q, func(q *ocicav1alpha1.OCICAIssuanceQuota) bool {
+			i := issuedFor(q, cr)
+			if i < 0 {
+				return false
+			}
+			q.Status.Issued = append(q.Status.Issued[:i], q.Status.Issued[i+1:]...)
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("failed to release certificate in quota %s: %w", client.ObjectKeyFromObject(q), err)
+		}
+	}
+	return nil
+}
+
+// recordIssuance records when the certificate reserved for cr expires in
+// every OCICAIssuanceQuota it counts against, now that it is issued.
+// Certificates are recorded once per request.
+func (r *CertificateRequestReconciler) recordIssuance(ctx context.Context, cr *cmapi.CertificateRequest, now time.Time) error {
+	quotas, err := r.applicableQuotas(ctx, cr)
+	if err != nil || len(quotas) == 0 {
+		return err
+	}
+	cert, err := pki.DecodeX509CertificateBytes(cr.Status.Certificate)
+	if err != nil {
+		return fmt.Errorf("failed to decode issued certificate: %w", err)
+	}
+	for _, q := range quotas {
+		err := r.updateQuota(ctx, q, func(q *ocicav1alpha1.OCICAIssuanceQuota) bool {
+			issued := ocicav1alpha1.IssuedCertificate{
+				Request:    cr.Name,
+				RequestUID: cr.UID,
+				IssuedAt:   metav1.NewTime(now),
+				NotAfter:   metav1.NewTime(cert.NotAfter),
+			}
+			i := issuedFor(q, cr)
+			if i < 0 {
+				pruneIssued(q, now)
+				q.Status.Issued = append(q.Status.Issued, issued)
+				return true
+			}
+			if q.Status.Issued[i].NotAfter.Equal(&issued.NotAfter) {
+				return false
+			}
+			issued.IssuedAt = q.Status.Issued[i].IssuedAt
+			q.Status.Issued[i] = issued
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record issuance in quota %s: %w", client.ObjectKeyFromObject(q), err)
+		}
+	}
+	return nil
+}
+
+// pendingRequestsForQuota maps an OCICAIssuanceQuota to the unfinished
+// CertificateRequests in its namespace, so that requests held back by it are
+// evaluated again when it changes.
+func (r *CertificateRequestReconciler) pendingRequestsForQuota(obj client.Object) []reconcile.Request {
+	return r.pendingRequests(obj.GetNamespace())
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_checkQuota(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	issued := func(ago, validFor time.Duration) v1alpha1.IssuedCertificate {
+		return v1alpha1.IssuedCertificate{
+			Request:  "cr",
+			IssuedAt: metav1.NewTime(now.Add(-ago)),
+			NotAfter: metav1.NewTime(now.Add(-ago + validFor)),
+		}
+	}
+	limit := func(n int32) *int32 { return &n }
+
+	tests := []struct {
+		name        string
+		spec        v1alpha1.OCICAIssuanceQuotaSpec
+		issued      []v1alpha1.IssuedCertificate
+		wantAllowed bool
+		wantRetryAt time.Time
+	}{
+		{
+			name:        "no limits",
+			issued:      []v1alpha1.IssuedCertificate{issued(time.Minute, time.Hour)},
+			wantAllowed: true,
+		},
+		{
+			name:        "under issuances",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: limit(2)},
+			issued:      []v1alpha1.IssuedCertificate{issued(2*time.Hour, 24*time.Hour), issued(time.Minute, time.Hour)},
+			wantAllowed: true,
+		},
+		{
+			name:        "over issuances",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: limit(2)},
+			issued:      []v1alpha1.IssuedCertificate{issued(30*time.Minute, time.Hour), issued(20*time.Minute, time.Hour), issued(10*time.Minute, time.Hour)},
+			wantRetryAt: now.Add(40 * time.Minute),
+		},
+		{
+			name:        "over issuances in custom window",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: limit(1), Window: &metav1.Duration{Duration: 24 * time.Hour}},
+			issued:      []v1alpha1.IssuedCertificate{issued(2*time.Hour, time.Hour)},
+			wantRetryAt: now.Add(22 * time.Hour),
+		},
+		{
+			name:        "under live certificates",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxLiveCertificates: limit(1)},
+			issued:      []v1alpha1.IssuedCertificate{issued(2*time.Hour, time.Hour)},
+			wantAllowed: true,
+		},
+		{
+			name:        "over live certificates",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxLiveCertificates: limit(1)},
+			issued:      []v1alpha1.IssuedCertificate{issued(2*time.Hour, 5*time.Hour), issued(time.Hour, 2*time.Hour)},
+			wantRetryAt: now.Add(3 * time.Hour),
+		},
+		{
+			name:        "over both waits for the later",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: limit(1), MaxLiveCertificates: limit(1)},
+			issued:      []v1alpha1.IssuedCertificate{issued(30*time.Minute, 2*time.Hour)},
+			wantRetryAt: now.Add(90 * time.Minute),
+		},
+		{
+			name:        "zero issuances",
+			spec:        v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: limit(0)},
+			wantRetryAt: now.Add(v1alpha1.DefaultQuotaWindow),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &v1alpha1.OCICAIssuanceQuota{Spec: tt.spec, Status: v1alpha1.OCICAIssuanceQuotaStatus{Issued: tt.issued}}
+			exceeded := checkQuota(q, now)
+			if (exceeded == nil) != tt.wantAllowed {
+				t.Fatalf("checkQuota() = %+v, want allowed %v", exceeded, tt.wantAllowed)
+			}
+			if exceeded != nil && !exceeded.retryAt.Equal(tt.wantRetryAt) {
+				t.Errorf("checkQuota() retryAt = %s, want %s", exceeded.retryAt, tt.wantRetryAt)
+			}
+		})
+	}
+}
+
+func Test_pruneIssued(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	ten := int32(10)
+	tests := []struct {
+		name string
+		spec v1alpha1.OCICAIssuanceQuotaSpec
+		want []string
+	}{
+		{
+			name: "max live certificates",
+			spec: v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: &ten, MaxLiveCertificates: &ten},
+			want: []string{"old-live", "recent-expired"},
+		},
+		{
+			name: "max issuances only",
+			spec: v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: &ten},
+			want: []string{"recent-expired"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &v1alpha1.OCICAIssuanceQuota{Spec: tt.spec, Status: v1alpha1.OCICAIssuanceQuotaStatus{Issued: []v1alpha1.IssuedCertificate{
+				{Request: "old-expired", IssuedAt: metav1.NewTime(now.Add(-3 * time.Hour)), NotAfter: metav1.NewTime(now.Add(-time.Hour))},
+				{Request: "old-live", IssuedAt: metav1.NewTime(now.Add(-3 * time.Hour)), NotAfter: metav1.NewTime(now.Add(time.Hour))},
+				{Request: "recent-expired", IssuedAt: metav1.NewTime(now.Add(-30 * time.Minute)), NotAfter: metav1.NewTime(now.Add(-time.Minute))},
+			}}}
+			pruneIssued(q, now)
+			var got []string
+			for _, issued := range q.Status.Issued {
+				got = append(got, issued.Request)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("pruneIssued() kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_issuedFor(t *testing.T) {
+	q := &v1alpha1.OCICAIssuanceQuota{Status: v1alpha1.OCICAIssuanceQuotaStatus{Issued: []v1alpha1.IssuedCertificate{
+		{Request: "cr", RequestUID: "first-uid"},
+	}}}
+	recreated := &cmapi.CertificateRequest{ObjectMeta: metav1.ObjectMeta{Name: "cr", UID: "second-uid"}}
+	if i := issuedFor(q, recreated); i != -1 {
+		t.Errorf("issuedFor() = %d for a request recreated with the same name, want -1", i)
+	}
+	recreated.UID = "first-uid"
+	if i := issuedFor(q, recreated); i != 0 {
+		t.Errorf("issuedFor() = %d, want 0", i)
+	}
+}
+
+func TestCertificateRequestReconciler_checkQuotas_concurrent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = cmapi.AddToScheme(scheme)
+	_ = v1alpha1.AddToScheme(scheme)
+	max := int32(3)
+	q := &v1alpha1.OCICAIssuanceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "quota"},
+		Spec:       v1alpha1.OCICAIssuanceQuotaSpec{MaxIssuances: &max},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(q).Build()
+	r := &CertificateRequestReconciler{Client: c, APIReader: c}
+	now := time.Now()
+
+	const requests = 10
+	var wg sync.WaitGroup
+	allowed := make(chan string, requests)
+	for i := 0; i < requests; i++ {
+		cr := &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: fmt.Sprintf("cr-%d", i), UID: types.UID(fmt.Sprintf("cr-%d-uid", i))},
+			Spec:       cmapi.CertificateRequestSpec{IssuerRef: cmmeta.ObjectReference{Kind: OCICAClusterIssuerKind, Name: "issuer"}},
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exceeded, err := r.checkQuotas(context.Background(), cr, now, time.Hour, true)
+			if err != nil {
+				t.Errorf("checkQuotas(%s) error = %v", cr.Name, err)
+				return
+			}
+			if exceeded == nil {
+				allowed <- cr.Name
+			}
+		}()
+	}
+	wg.Wait()
+	close(allowed)
+
+	var got []string
+	for name := range allowed {
+		got = append(got, name)
+	}
+	if len(got) != int(max) {
+		t.Errorf("checkQuotas() allowed %v, want %d requests", got, max)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(q), q); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Status.Issued) != int(max) {
+		t.Errorf("quota issued got = %v, want %d certificates", q.Status.Issued, max)
+	}
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+// rotationPhase returns the phase rotation is scheduled to be in at now, and
+// when it enters the next one, or nil once it is completed.
+func rotationPhase(rotation *ocicav1alpha1.CARotation, now time.Time) (ocicav1alpha1.CARotationPhase, *metav1.Time) {
+	schedule := rotation.Schedule
+	switch {
+	case schedule.DistributeAt != nil && now.Before(schedule.DistributeAt.Time):
+		return ocicav1alpha1.CARotationPending, schedule.DistributeAt.DeepCopy()
+	case now.Before(schedule.SignAt.Time):
+		return ocicav1alpha1.CARotationDistributing, schedule.SignAt.DeepCopy()
+	case now.Before(schedule.RetireAt.Time):
+		return ocicav1alpha1.CARotationSigning, schedule.RetireAt.DeepCopy()
+	}
+	return ocicav1alpha1.CARotationCompleted, nil
+}
+
+// rotatedIssuer returns iss with the certificate authorities that sign during
+// phase of its rotation. The new authority signs from the Signing phase on,
+// failing over to the old ones until they are retired only when the rotation
+// allows it.
+func rotatedIssuer(iss *ocicav1alpha1.OCICAClusterIssuer, phase ocicav1alpha1.CARotationPhase) *ocicav1alpha1.OCICAClusterIssuer {
+	rotation := iss.Spec.Rotation
+	if rotation == nil || phase == ocicav1alpha1.CARotationPending || phase == ocicav1alpha1.CARotationDistributing {
+		return iss
+	}
+	next := ocicav1alpha1.CertificateAuthority{
+		AuthorityID:   rotation.AuthorityID,
+		CompartmentID: rotation.CompartmentID,
+		Region:        rotation.Region,
+		Weight:        1,
+	}
+	var authorities []ocicav1alpha1.CertificateAuthority
+	if phase == ocicav1alpha1.CARotationSigning && rotation.Failover {
+		authorities = iss.Spec.CertificateAuthorities()
+		for _, ca := range authorities {
+			if ca.Priority <= next.Priority {
+				next.Priority = ca.Priority - 1
+			}
+		}
+	}
+	rotated := iss.DeepCopy()
+	rotated.Spec.AuthorityID = ""
+	rotated.Spec.Region = ""
+	rotated.Spec.Authorities = append([]ocicav1alpha1.CertificateAuthority{next}, authorities...)
+	rotated.Spec.AuthoritySelection = ocicav1alpha1.AuthoritySelectionPriority
+	return rotated
+}
+
+// retiredIssuer returns iss with the old certificate authorities of its
+// rotation once they no longer sign during phase, or nil while they do.
+func retiredIssuer(iss *ocicav1alpha1.OCICAClusterIssuer, phase ocicav1alpha1.CARotationPhase) *ocicav1alpha1.OCICAClusterIssuer {
+	rotation := iss.Spec.Rotation
+	switch {
+	case rotation == nil || phase == ocicav1alpha1.CARotationPending || phase == ocicav1alpha1.CARotationDistributing:
+		return nil
+	case phase == ocicav1alpha1.CARotationSigning && rotation.Failover:
+		return nil
+	}
+	return iss
+}
+
+// writeTrustBundle writes the root certificates of the authorities clients
+// should trust during phase to the trust bundle ConfigMap of iss: the signing
+// authorities, the new authority of the rotation while it is distributed
+// before signing, and the old authorities until the last certificate they
+// signed expires. It returns when the old authorities leave the bundle of a
+// completed rotation, or nil once they have.
+func (r *OCICAClusterIssuerReconciler) writeTrustBundle(ctx context.Context, logger logr.Logger, iss *ocicav1alpha1.OCICAClusterIssuer,
+	phase ocicav1alpha1.CARotationPhase, signing *provisioner.Authorities, configProvider common.ConfigurationProvider, transport provisioner.Transport) (*metav1.Time, error) {
+	anchors, err := signing.TrustAnchors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trusted := signing.Retired()
+	if iss.Spec.Rotation != nil && phase == ocicav1alpha1.CARotationDistributing {
+		trusted, err = provisioner.NewAuthorities(logger, *rotatedIssuer(iss, ocicav1alpha1.CARotationCompleted), configProvider, transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var trustedUntil *metav1.Time
+	if trusted != nil && phase == ocicav1alpha1.CARotationCompleted {
+		lastExpiry, err := trusted.LastExpiry(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if r.Clock.Now().Before(lastExpiry) {
+			trustedUntil = &metav1.Time{Time: lastExpiry}
+		} else {
+			trusted = nil
+		}
+	}
+	if trusted != nil {
+		more, err := trusted.TrustAnchors(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, anchor := range more {
+			if !containsAnchor(anchors, anchor) {
+				anchors = append(anchors, anchor)
+			}
+		}
+	}
+	bundle := string(bytes.Join(anchors, nil))
+
+	cm := new(core.ConfigMap)
+	name := types.NamespacedName{Namespace: r.secretNamespace(iss), Name: iss.Spec.TrustBundle.Name}
+	err = r.Client.Get(ctx, name, cm)
+	exists := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if exists {
+		if controller := metav1.GetControllerOf(cm); controller != nil && controller.UID != iss.UID {
+			return nil, fmt.Errorf("trust bundle configmap %s is controlled by %s %s", name, controller.Kind, controller.Name)
+		}
+	} else {
+		cm = &core.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name}}
+	}
+	key := caBundleKey(iss.Spec.TrustBundle)
+	if exists && metav1.IsControlledBy(cm, iss) && cm.Data[key] == bundle {
+		return trustedUntil, nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[key] = bundle
+	if err := controllerutil.SetControllerReference(iss, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	if exists {
+		return trustedUntil, r.Client.Update(ctx, cm)
+	}
+	return trustedUntil, r.Client.Create(ctx, cm)
+}
+
+func containsAnchor(anchors [][]byte, anchor []byte) bool {
+	for _, a := range anchors {
+		if bytes.Equal(a, anchor) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRotationStatus records the progress of the rotation of iss, or clears it
+// when the issuer has no rotation. trustedUntil is when the old authorities
+// leave the trust bundle of a completed rotation, and bundleErr why the trust
+// bundle could not be written.
+func (r *OCICAClusterIssuerReconciler) setRotationStatus(iss *ocicav1alpha1.OCICAClusterIssuer, phase ocicav1alpha1.CARotationPhase,
+	nextPhaseTime, trustedUntil *metav1.Time, bundleErr error) {
+	rotation := iss.Spec.Rotation
+	if rotation == nil {
+		iss.Status.Rotation = nil
+		meta.RemoveStatusCondition(&iss.Status.Conditions, string(ocicav1alpha1.ConditionCARotation))
+		return
+	}
+	iss.Status.Rotation = &ocicav1alpha1.CARotationStatus{AuthorityID: rotation.AuthorityID, Phase: phase, NextPhaseTime: nextPhaseTime}
+
+	status := metav1.ConditionTrue
+	var message string
+	switch phase {
+	case ocicav1alpha1.CARotationPending:
+		message = fmt.Sprintf("Certificate authority %s is added to the trust bundle at %s", rotation.AuthorityID, formatTime(nextPhaseTime))
+	case ocicav1alpha1.CARotationDistributing:
+		message = fmt.Sprintf("Certificate authority %s is in the trust bundle and starts signing at %s", rotation.AuthorityID, formatTime(nextPhaseTime))
+	case ocicav1alpha1.CARotationSigning:
+		if rotation.Failover {
+			message = fmt.Sprintf("Certificate authority %s signs certificates, the old certificate authorities sign while it is unavailable until %s", rotation.AuthorityID, formatTime(nextPhaseTime))
+		} else {
+			message = fmt.Sprintf("Certificate authority %s signs certificates, the old certificate authorities are retired at %s", rotation.AuthorityID, formatTime(nextPhaseTime))
+		}
+	case ocicav1alpha1.CARotationCompleted:
+		if trustedUntil != nil {
+			message = fmt.Sprintf("Certificate authority %s replaced the old certificate authorities, which stay in the trust bundle until the last certificate they signed expires at %s", rotation.AuthorityID, formatTime(trustedUntil))
+		} else {
+			message = fmt.Sprintf("Certificate authority %s replaced the old certificate authorities, make it the authority of the issuer and remove the rotation", rotation.AuthorityID)
+		}
+	}
+	for _, authority := range iss.Status.Authorities {
+		if authority.AuthorityID == rotation.AuthorityID && !authority.Ready {
+			status = metav1.ConditionFalse
+			message = fmt.Sprintf("Certificate authority %s is unavailable: %s", rotation.AuthorityID, authority.Message)
+		}
+	}
+	if bundleErr != nil {
+		status = metav1.ConditionFalse
+		message = fmt.Sprintf("Failed to update the trust bundle: %s", bundleErr)
+	}
+	setCondition(&iss.Status.Conditions, ocicav1alpha1.ConditionCARotation, status, string(phase), message, r.Clock.Now())
+}
+
+func formatTime(t *metav1.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// rotationRequeue returns when the issuer should be reconciled again: after
+// interval, or when its rotation enters the next phase if that is sooner.
+func rotationRequeue(interval time.Duration, nextPhaseTime *metav1.Time, now time.Time) time.Duration {
+	if nextPhaseTime == nil {
+		return interval
+	}
+	next := nextPhaseTime.Sub(now)
+	if next < time.Second {
+		next = time.Second
+	}
+	if interval > 0 && interval < next {
+		return interval
+	}
+	return next
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Reasons of the events recorded on exported Secrets, besides the reasons of
+// decisions taken against the issuer.
+const (
+	ReasonExported        = "Exported"
+	ReasonInvalidSecret   = "InvalidSecret"
+	ReasonUntrustedSecret = "UntrustedSecret"
+	ReasonExportFailed    = "ExportFailed"
+)
+
+// SecretExportReconciler imports the certificates of Secrets annotated with
+// ocica.cert-manager.io/export-to into OCI Certificates, so that OCI services
+// such as load balancers can use certificates cert-manager issued.
+type SecretExportReconciler struct {
+	Collection *provisioner.Collection
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+
+	// ClusterID identifies the cluster in the names and descriptions of
+	// OCI certificates.
+	ClusterID string
+
+	// MaxConcurrentReconciles is how many objects are reconciled at once.
+	// Defaults to 1.
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;patch
+
+// Reconcile imports the certificate of an annotated Secret as a new OCI
+// certificate the first time, and as a new version of it whenever the
+// certificate in the Secret changes. The OCID is recorded on the Secret.
+func (r *SecretExportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("secret", req.NamespacedName)
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, req.NamespacedName, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get Secret")
+		return ctrl.Result{}, err
+	}
+	issuerName := secret.Annotations[ocicav1alpha1.ExportToAnnotation]
+	if issuerName == "" || !secret.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	leaf, imported, err := exportedCertificate(secret)
+	if err != nil {
+		// Retried when cert-manager next writes the Secret.
+		r.Recorder.Eventf(secret, core.EventTypeWarning, ReasonInvalidSecret, "Secret cannot be exported: %s", err)
+		return ctrl.Result{}, nil
+	}
+	certificateID := secret.Annotations[ocicav1alpha1.ExportedCertificateIDAnnotation]
+	serial := leaf.SerialNumber.Text(16)
+	if certificateID != "" && secret.Annotations[ocicav1alpha1.ExportedSerialAnnotation] == serial {
+		return ctrl.Result{}, nil
+	}
+
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: issuerName}, iss); err != nil {
+		log.Error(err, "failed to get issuer")
+		r.Recorder.Eventf(secret, core.EventTypeWarning, ReasonExportFailed, "Failed to get issuer %s: %s", issuerName, err)
+		return ctrl.Result{}, err
+	}
+	if !issuerReady(iss) {
+		return ctrl.Result{}, fmt.Errorf("issuer %s is not ready", issuerName)
+	}
+	authorities, ok := r.Collection.LoadAuthorities(types.NamespacedName{Name: issuerName})
+	if !ok || authorities.Primary() == nil {
+		return ctrl.Result{}, fmt.Errorf("provisioner for %s not found", issuerName)
+	}
+	p := authorities.Primary().Provisioner
+
+	// Only certificates the issuer's authorities signed are exported, so
+	// that neither OCI certificates nor the load balancers bound to them
+	// carry self-signed material or that of another CA.
+	if err := authorities.VerifyImported(ctx, imported); err != nil {
+		if !errors.Is(err, provisioner.ErrUntrustedCertificate) {
+			log.Error(err, "failed to get the trust anchors of the issuer")
+			return ctrl.Result{}, err
+		}
+		log.Info("Secret is not signed by the issuer", "reason", err.Error())
+		r.Recorder.Eventf(secret, core.EventTypeWarning, ReasonUntrustedSecret, "Secret cannot be exported: %s", err)
+		return ctrl.Result{}, nil
+	}
+
+	// Secrets are exported into the issuer's compartment under its namespace
+	// selector and policy, as if the issuer had signed them, and checked
+	// again on every rotation.
+	d, err := decideForCertificate(ctx, r.Client, secret.Namespace, leaf, iss)
+	if err != nil {
+		log.Error(err, "failed to check certificate against the issuer")
+		return ctrl.Result{}, err
+	}
+	if !d.allowed {
+		log.Info("Secret export is refused by the issuer", "reason", d.reason)
+		r.Recorder.Eventf(secret, core.EventTypeWarning, d.reason, "Secret cannot be exported: %s", d.message)
+		return ctrl.Result{}, nil
+	}
+	opts := provisioner.SignOptions{CompartmentID: d.compartmentID, ClusterID: r.ClusterID}
+	if certificateID == "" {
+		certificateID, err = p.ImportCertificate(ctx, secret.Namespace, secret.Name, imported, opts)
+		if err != nil {
+			return r.exportFailed(log, secret, err)
+		}
+	} else {
+		err := p.CheckExportedCertificate(ctx, certificateID, secret.Namespace, secret.Name, opts)
+		if err == nil {
+			err = p.UpdateImportedCertificate(ctx, certificateID, imported)
+		}
+		if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+			r.Recorder.Eventf(secret, core.EventTypeWarning, ReasonExportFailed,
+				"OCI certificate %s was not found, remove the %s annotation to import a new one",
+				certificateID, ocicav1alpha1.ExportedCertificateIDAnnotation)
+			return ctrl.Result{}, nil
+		}
+		if errors.Is(err, provisioner.ErrNotExported) {
+			log.Info("Secret names an OCI certificate it was not exported to", "certificateID", certificateID, "reason", err.Error())
+			r.Recorder.Eventf(secret, core.EventTypeWarning, ReasonExportFailed,
+				"Refusing to update OCI certificate %s: %s; remove the %s annotation to import a new one",
+				certificateID, err, ocicav1alpha1.ExportedCertificateIDAnnotation)
+			return ctrl.Result{}, nil
+		}
+		if err != nil {
+			return r.exportFailed(log, secret, err)
+		}
+	}
+
+	// Patched rather than updated, so that writes by cert-manager in the
+	// meantime do not conflict.
+	patch := client.MergeFrom(secret.DeepCopy())
+	secret.Annotations[ocicav1alpha1.ExportedCertificateIDAnnotation] = certificateID
+	secret.Annotations[ocicav1alpha1.ExportedSerialAnnotation] = serial
+	if err := r.Client.Patch(ctx, secret, patch); err != nil {
+		log.Error(err, "failed to record exported certificate on Secret")
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Eventf(secret, core.EventTypeNormal, ReasonExported, "Imported certificate with serial %s into OCI certificate %s", serial, certificateID)
+	return ctrl.Result{}, nil
+}
+
+// exportFailed records a failure to import into OCI, retrying errors that
+// may go away on their own.
+func (r *SecretExportReconciler) exportFailed(log logr.Logger, secret *core.Secret, err error) (ctrl.Result, error) {
+	log.Error(err, "failed to import certificate into OCI")
+	r.Recorder.Eventf(secret, core.EventTypeWarning, ReasonExportFailed, "Failed to import certificate into OCI: %s", err)
+	if retryable(err) {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// decideForCertificate checks an existing certificate for namespace against
+// iss, with its validity as the requested duration.
+func decideForCertificate(ctx context.Context, c client.Client, namespace string, cert *x509.Certificate,
+	iss *ocicav1alpha1.OCICAClusterIssuer) (decision, error) {
+	request := func() (*x509.CertificateRequest, error) {
+		return &x509.CertificateRequest{
+			Subject:        cert.Subject,
+			DNSNames:       cert.DNSNames,
+			IPAddresses:    cert.IPAddresses,
+			URIs:           cert.URIs,
+			EmailAddresses: cert.EmailAddresses,
+		}, nil
+	}
+	duration := &metav1.Duration{Duration: cert.NotAfter.Sub(cert.NotBefore)}
+	return decideFor(ctx, c, namespace, request, duration, iss)
+}
+
+// exportedCertificate returns the leaf certificate of secret, and the
+// certificate, chain and private key to import. The chain is the
+// intermediates of tls.crt followed by ca.crt; self-signed certificates are
+// their own chain.
+func exportedCertificate(secret *core.Secret) (*x509.Certificate, provisioner.ImportedCertificate, error) {
+	if secret.Type != core.SecretTypeTLS {
+		return nil, provisioner.ImportedCertificate{}, fmt.Errorf("type is %s, not %s", secret.Type, core.SecretTypeTLS)
+	}
+	certs, err := pki.DecodeX509CertificateChainBytes(secret.Data[core.TLSCertKey])
+	if err != nil {
+		return nil, provisioner.ImportedCertificate{}, fmt.Errorf("invalid %s: %s", core.TLSCertKey, err)
+	}
+	if len(secret.Data[core.TLSPrivateKeyKey]) == 0 {
+		return nil, provisioner.ImportedCertificate{}, fmt.Errorf("%s is empty", core.TLSPrivateKeyKey)
+	}
+	var chain []byte
+	for _, cert := range certs[1:] {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	if ca := secret.Data["ca.crt"]; len(ca) > 0 && !bytes.Contains(chain, ca) {
+		chain = append(chain, ca...)
+	}
+	leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0].Raw})
+	if len(chain) == 0 {
+		chain = leaf
+	}
+	return certs[0], provisioner.ImportedCertificate{
+		Certificate: leaf,
+		Chain:       chain,
+		PrivateKey:  secret.Data[core.TLSPrivateKeyKey],
+	}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SecretExportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	exported := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		_, ok := o.GetAnnotations()[ocicav1alpha1.ExportToAnnotation]
+		return ok
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("secretexport").
+		For(&core.Secret{}, builder.WithPredicates(exported)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
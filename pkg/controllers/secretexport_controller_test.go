@@ -0,0 +1,369 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/go-logr/logr"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"math/big"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+// tlsData returns the tls.crt, tls.key and ca.crt of a certificate for cn
+// signed by the issuer's certificate authority.
+func (f *managedCertificateFixture) tlsData(t *testing.T, cn string, serial int64) map[string][]byte {
+	t.Helper()
+	tmpl, key, keyPEM := tlsLeaf(t, cn, serial)
+	cert, chain, err := f.oci.Sign(f.issuer.Spec.AuthorityID, tmpl, key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{
+		core.TLSCertKey:       []byte(cert),
+		core.TLSPrivateKeyKey: keyPEM,
+		"ca.crt":              []byte(chain),
+	}
+}
+
+// untrustedTLSData returns the tls.crt, tls.key and ca.crt of a certificate
+// for cn signed by a new CA, or the tls.crt and tls.key of a self-signed one.
+func untrustedTLSData(t *testing.T, cn string, serial int64, selfSigned bool) map[string][]byte {
+	t.Helper()
+	tmpl, key, keyPEM := tlsLeaf(t, cn, serial)
+	if selfSigned {
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string][]byte{
+			core.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			core.TLSPrivateKeyKey: keyPEM,
+		}
+	}
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caTmpl, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{
+		core.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		core.TLSPrivateKeyKey: keyPEM,
+		"ca.crt":              pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+	}
+}
+
+// tlsLeaf returns the template of a certificate for cn, its key and the PEM
+// encoded key.
+func tlsLeaf(t *testing.T, cn string, serial int64) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	return tmpl, key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestSecretExportReconciler_Reconcile(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+
+	tests := []struct {
+		name   string
+		secret *core.Secret
+		issuer func(spec *v1alpha1.OCICAClusterIssuerSpec)
+		// wantExported is whether the Secret is imported into OCI.
+		wantExported bool
+	}{
+		{
+			name: "exported",
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "exported",
+					Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+				Type: core.SecretTypeTLS,
+				Data: f.tlsData(t, "app.example.com", 10),
+			},
+			wantExported: true,
+		},
+		{
+			name: "not a TLS Secret",
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "opaque",
+					Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+				Type: core.SecretTypeOpaque,
+				Data: f.tlsData(t, "app.example.com", 11),
+			},
+		},
+		{
+			name: "signed by another CA",
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "other-ca",
+					Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+				Type: core.SecretTypeTLS,
+				Data: untrustedTLSData(t, "app.example.com", 14, false),
+			},
+		},
+		{
+			name: "self-signed",
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "self-signed",
+					Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+				Type: core.SecretTypeTLS,
+				Data: untrustedTLSData(t, "app.example.com", 15, true),
+			},
+		},
+		{
+			name: "denied by policy",
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "denied",
+					Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+				Type: core.SecretTypeTLS,
+				Data: f.tlsData(t, "app.example.com", 12),
+			},
+			issuer: func(spec *v1alpha1.OCICAClusterIssuerSpec) {
+				spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Deny: []string{"*.example.com"}}}
+			},
+		},
+		{
+			name: "already exported",
+			secret: &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "unchanged", Annotations: map[string]string{
+					v1alpha1.ExportToAnnotation:              "issuer1",
+					v1alpha1.ExportedCertificateIDAnnotation: "ocid1.certificate.oc1..unchanged",
+					v1alpha1.ExportedSerialAnnotation:        "d",
+				}},
+				Type: core.SecretTypeTLS,
+				Data: f.tlsData(t, "app.example.com", 13),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iss := f.issuer.DeepCopy()
+			if tt.issuer != nil {
+				tt.issuer(&iss.Spec)
+			}
+			c, _ := f.client(iss, tt.secret.DeepCopy())
+			r := &SecretExportReconciler{
+				Collection: f.collection,
+				Client:     c,
+				Log:        logr.Discard(),
+				Recorder:   record.NewFakeRecorder(10),
+			}
+			before := len(f.oci.Certificates())
+			key := client.ObjectKeyFromObject(tt.secret)
+			if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			secret := new(core.Secret)
+			if err := c.Get(ctx, key, secret); err != nil {
+				t.Fatal(err)
+			}
+			created := len(f.oci.Certificates()) - before
+			id := secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation]
+			if !tt.wantExported {
+				if created != 0 || id != tt.secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] {
+					t.Errorf("Reconcile() created %d OCI certificates, Secret annotations = %v, want none", created, secret.Annotations)
+				}
+				return
+			}
+			if created != 1 || id != f.oci.Certificates()[before] {
+				t.Errorf("Reconcile() created %d OCI certificates, Secret annotations = %v, want the new certificate", created, secret.Annotations)
+			}
+			if secret.Annotations[v1alpha1.ExportedSerialAnnotation] != "a" {
+				t.Errorf("Secret annotations = %v, want the exported serial", secret.Annotations)
+			}
+		})
+	}
+}
+
+func TestSecretExportReconciler_Reconcile_rotation(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "app-tls",
+			Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+		Type: core.SecretTypeTLS,
+		Data: f.tlsData(t, "app.example.com", 1),
+	}
+	c, _ := f.client(f.issuer.DeepCopy(), secret)
+	r := &SecretExportReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Recorder:   record.NewFakeRecorder(10),
+	}
+	key := client.ObjectKeyFromObject(secret)
+	reconcile := func() *core.Secret {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		secret := new(core.Secret)
+		if err := c.Get(ctx, key, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+	rotate := func(secret *core.Secret, serial int64) {
+		t.Helper()
+		secret.Data = f.tlsData(t, "app.example.com", serial)
+		if err := c.Update(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	secret = reconcile()
+	id := secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation]
+	if id == "" {
+		t.Fatalf("Secret annotations = %v, want the certificate ID", secret.Annotations)
+	}
+	reconcile()
+	if got := f.oci.Calls("UpdateCertificate"); got != 0 {
+		t.Errorf("unchanged Secret updated the OCI certificate %d times", got)
+	}
+
+	rotate(secret, 2)
+	secret = reconcile()
+	if secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] != id || secret.Annotations[v1alpha1.ExportedSerialAnnotation] != "2" {
+		t.Errorf("after rotation Secret annotations = %v, want %s with serial 2", secret.Annotations, id)
+	}
+	if got := f.oci.Calls("UpdateCertificate"); got != 1 {
+		t.Errorf("rotation updated the OCI certificate %d times, want 1", got)
+	}
+
+	// A certificate imported by an attempt whose annotations were lost is
+	// picked up rather than imported again.
+	delete(secret.Annotations, v1alpha1.ExportedCertificateIDAnnotation)
+	rotate(secret, 3)
+	secret = reconcile()
+	if secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] != id || len(f.oci.Certificates()) != 1 {
+		t.Errorf("after losing the annotations certificate = %s of %v, want %s",
+			secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation], f.oci.Certificates(), id)
+	}
+}
+
+func TestSecretExportReconciler_Reconcile_refused(t *testing.T) {
+	ctx := context.TODO()
+	f := newManagedCertificateFixture(t)
+	newSecret := func(name string) *core.Secret {
+		return &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name,
+				Annotations: map[string]string{v1alpha1.ExportToAnnotation: "issuer1"}},
+			Type: core.SecretTypeTLS,
+			Data: f.tlsData(t, "app.example.com", 1),
+		}
+	}
+	iss := f.issuer.DeepCopy()
+	c, _ := f.client(iss, newSecret("app-tls"), newSecret("other-tls"))
+	r := &SecretExportReconciler{
+		Collection: f.collection,
+		Client:     c,
+		Log:        logr.Discard(),
+		Recorder:   record.NewFakeRecorder(10),
+	}
+	reconcile := func(name string) *core.Secret {
+		t.Helper()
+		key := client.ObjectKey{Namespace: "ns1", Name: name}
+		if _, err := r.Reconcile(ctx, controllerruntime.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		secret := new(core.Secret)
+		if err := c.Get(ctx, key, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+	secret := reconcile("app-tls")
+	id := secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation]
+	otherID := reconcile("other-tls").Annotations[v1alpha1.ExportedCertificateIDAnnotation]
+	if id == "" || otherID == "" || id == otherID {
+		t.Fatalf("exported certificates = %s and %s, want two certificates", id, otherID)
+	}
+	updates := f.oci.Calls("UpdateCertificate")
+
+	// A Secret annotated with the OCID of a certificate exported from another
+	// Secret does not overwrite it.
+	secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] = otherID
+	secret.Data = f.tlsData(t, "app.example.com", 2)
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret = reconcile("app-tls")
+	if got := f.oci.Calls("UpdateCertificate"); got != updates {
+		t.Errorf("tampered annotation updated OCI certificates %d times, want none", got-updates)
+	}
+	if secret.Annotations[v1alpha1.ExportedSerialAnnotation] == "2" {
+		t.Errorf("tampered annotation Secret annotations = %v, want the serial unrecorded", secret.Annotations)
+	}
+
+	// Rotated certificates are checked against the policy again.
+	if err := c.Get(ctx, client.ObjectKeyFromObject(iss), iss); err != nil {
+		t.Fatal(err)
+	}
+	iss.Spec.Policy = &v1alpha1.IssuancePolicy{DNSNames: &v1alpha1.NamePolicy{Allow: []string{"*.example.com"}}}
+	if err := c.Update(ctx, iss); err != nil {
+		t.Fatal(err)
+	}
+	secret.Annotations[v1alpha1.ExportedCertificateIDAnnotation] = id
+	secret.Data = f.tlsData(t, "app.example.org", 3)
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret = reconcile("app-tls")
+	if got := f.oci.Calls("UpdateCertificate"); got != updates {
+		t.Errorf("rotation violating the policy updated OCI certificates %d times, want none", got-updates)
+	}
+	if secret.Annotations[v1alpha1.ExportedSerialAnnotation] == "3" {
+		t.Errorf("rotation violating the policy Secret annotations = %v, want the serial unrecorded", secret.Annotations)
+	}
+
+	// Rotations the policy allows are still exported.
+	secret.Data = f.tlsData(t, "www.example.com", 4)
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	secret = reconcile("app-tls")
+	if got := f.oci.Calls("UpdateCertificate"); got != updates+1 || secret.Annotations[v1alpha1.ExportedSerialAnnotation] != "4" {
+		t.Errorf("allowed rotation updated OCI certificates %d times, Secret annotations = %v, want one update with serial 4",
+			got-updates, secret.Annotations)
+	}
+}
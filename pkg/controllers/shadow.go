@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/go-logr/logr"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons of the events recording how CertificateRequests were handled by an
+// issuer in DryRun or Shadow mode.
+const (
+	ReasonDryRun         = "DryRun"
+	ReasonShadowMatched  = "ShadowMatched"
+	ReasonShadowDiverged = "ShadowDiverged"
+	ReasonShadowFailed   = "ShadowFailed"
+)
+
+const (
+	// shadowTimeout bounds signing a request with a shadow issuer.
+	shadowTimeout = 5 * time.Minute
+
+	// shadowNameSuffix is appended to the names of shadow certificates.
+	shadowNameSuffix = "-shadow"
+
+	// validityTolerance is how much the validity of certificates signed
+	// moments apart may differ before they diverge.
+	validityTolerance = time.Minute
+
+	// DefaultShadowWorkers is how many requests a ShadowQueue signs at once
+	// by default.
+	DefaultShadowWorkers = 2
+	// DefaultShadowQueueSize is how many requests wait for a shadow issuer
+	// by default.
+	DefaultShadowQueueSize = 100
+)
+
+// ShadowQueue signs CertificateRequests again with shadow issuers in the
+// background. A bounded number of workers sign requests, and requests beyond
+// the queue size are dropped rather than piling up behind a slow shadow
+// issuer. It is a manager Runnable, so the workers run only while the manager
+// does, on the leader, and stop with it.
+type ShadowQueue struct {
+	// Workers is how many requests are signed at once. Defaults to
+	// DefaultShadowWorkers.
+	Workers int
+	// Size is how many requests may wait for a worker. Defaults to
+	// DefaultShadowQueueSize.
+	Size int
+
+	once sync.Once
+	jobs chan func(ctx context.Context)
+}
+
+func (q *ShadowQueue) init() {
+	q.once.Do(func() {
+		size := q.Size
+		if size <= 0 {
+			size = DefaultShadowQueueSize
+		}
+		q.jobs = make(chan func(ctx context.Context), size)
+	})
+}
+
+// Start runs the workers until ctx is cancelled, cancelling the requests
+// being signed.
+func (q *ShadowQueue) Start(ctx context.Context) error {
+	q.init()
+	workers := q.Workers
+	if workers <= 0 {
+		workers = DefaultShadowWorkers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-q.jobs:
+					job(ctx)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// enqueue adds job to the queue, and reports false when the queue is full.
+func (q *ShadowQueue) enqueue(job func(ctx context.Context)) bool {
+	q.init()
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// queueShadow queues signing cr again with the shadow issuer called
+// issuerName, recording an event on cr when the queue is full.
+func (r *CertificateRequestReconciler) queueShadow(cr *cmapi.CertificateRequest, issuerName string, cert, ca []byte, log logr.Logger) {
+	queued := r.Shadows != nil && r.Shadows.enqueue(func(ctx context.Context) {
+		r.shadow(ctx, cr, issuerName, cert, ca, log)
+	})
+	if !queued {
+		log.Info("shadow queue is full, not signing with shadow issuer", "shadowIssuer", issuerName)
+		r.Recorder.Eventf(cr, core.EventTypeWarning, ReasonShadowFailed, "Shadow issuer %s was skipped: too many requests are waiting to be signed", issuerName)
+	}
+}
+
+// shadow signs cr again with the shadow issuer called issuerName and records
+// an event on cr comparing the result with cert and ca, the certificate and CA
+// cr was signed with.
+func (r *CertificateRequestReconciler) shadow(ctx context.Context, cr *cmapi.CertificateRequest, issuerName string, cert, ca []byte, log logr.Logger) {
+	log = log.WithValues("shadowIssuer", issuerName)
+	ctx, cancel := context.WithTimeout(ctx, shadowTimeout)
+	defer cancel()
+	shadowCert, shadowCA, err := r.shadowSign(ctx, cr, issuerName, log)
+	if err != nil {
+		log.Error(err, "failed to sign with shadow issuer")
+		r.Recorder.Eventf(cr, core.EventTypeWarning, ReasonShadowFailed, "Shadow issuer %s failed to sign the request: %s", issuerName, err)
+		return
+	}
+	diffs, err := compareCertificates(cert, ca, shadowCert, shadowCA)
+	if err != nil {
+		log.Error(err, "failed to compare with shadow certificate")
+		r.Recorder.Eventf(cr, core.EventTypeWarning, ReasonShadowFailed, "Failed to compare with the certificate of shadow issuer %s: %s", issuerName, err)
+		return
+	}
+	if len(diffs) > 0 {
+		log.Info("shadow certificate diverged", "differences", diffs)
+		r.Recorder.Eventf(cr, core.EventTypeWarning, ReasonShadowDiverged, "Certificate of shadow issuer %s differs: %s", issuerName, strings.Join(diffs, "; "))
+		return
+	}
+	r.Recorder.Eventf(cr, core.EventTypeNormal, ReasonShadowMatched, "Certificate of shadow issuer %s matches", issuerName)
+}
+
+// shadowSign signs cr with the issuer called issuerName as it would if cr
+// referenced it, refusing requests it would refuse.
+func (r *CertificateRequestReconciler) shadowSign(ctx context.Context, cr *cmapi.CertificateRequest, issuerName string, log logr.Logger) ([]byte, []byte, error) {
+	name := types.NamespacedName{Name: issuerName}
+	iss := new(ocicav1alpha1.OCICAClusterIssuer)
+	if err := r.Client.Get(ctx, name, iss); err != nil {
+		return nil, nil, err
+	}
+	if !issuerReady(iss) {
+		return nil, nil, fmt.Errorf("issuer is not ready")
+	}
+	d, err := decide(ctx, r.Client, cr, iss)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !d.allowed {
+		return nil, nil, fmt.Errorf("request would be refused: %s", d.message)
+	}
+	var candidates []*provisioner.Authority
+	if authorities, ok := r.Collection.LoadAuthorities(name); ok {
+		candidates = authorities.Candidates()
+	}
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("no certificate authority is available")
+	}
+	opts := provisioner.SignOptions{CompartmentID: d.compartmentID, ClusterID: r.ClusterID, NameSuffix: shadowNameSuffix}
+	cert, ca, authorityID, err := sign(ctx, cr, candidates, opts, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Shadow certificates are only compared, so they are scheduled for
+	// deletion as soon as they are signed.
+	for _, authority := range candidates {
+		if authority.ID != authorityID {
+			continue
+		}
+		if err := authority.Provisioner.DeleteSigned(ctx, cr, opts); err != nil {
+			log.Error(err, "failed to schedule deletion of shadow certificate")
+			r.Recorder.Eventf(cr, core.EventTypeWarning, ReasonShadowFailed, "Failed to schedule deletion of the certificate of shadow issuer %s: %s", issuerName, err)
+		}
+	}
+	return cert, ca, nil
+}
+
+// compareCertificates returns how the shadow certificate and CA differ from
+// those they are compared with in their subject alternative names, chain and
+// validity.
+func compareCertificates(cert, ca, shadowCert, shadowCA []byte) ([]string, error) {
+	chain, err := pki.DecodeX509CertificateChainBytes(cert)
+	if err != nil {
+		return nil, err
+	}
+	shadowChain, err := pki.DecodeX509CertificateChainBytes(shadowCert)
+	if err != nil {
+		return nil, fmt.Errorf("shadow certificate: %w", err)
+	}
+	leaf, shadowLeaf := chain[0], shadowChain[0]
+
+	var diffs []string
+	compare := func(what string, names, shadowNames []string) {
+		names = sorted(names)
+		shadowNames = sorted(shadowNames)
+		if strings.Join(names, "\n") != strings.Join(shadowNames, "\n") {
+			diffs = append(diffs, fmt.Sprintf("%s are [%s], shadow has [%s]", what, strings.Join(names, ", "), strings.Join(shadowNames, ", ")))
+		}
+	}
+	compare("DNS names", leaf.DNSNames, shadowLeaf.DNSNames)
+	compare("IP addresses", stringsOf(len(leaf.IPAddresses), func(i int) string { return leaf.IPAddresses[i].String() }),
+		stringsOf(len(shadowLeaf.IPAddresses), func(i int) string { return shadowLeaf.IPAddresses[i].String() }))
+	compare("URIs", stringsOf(len(leaf.URIs), func(i int) string { return leaf.URIs[i].String() }),
+		stringsOf(len(shadowLeaf.URIs), func(i int) string { return shadowLeaf.URIs[i].String() }))
+	compare("email addresses", leaf.EmailAddresses, shadowLeaf.EmailAddresses)
+
+	if intermediates, shadowIntermediates := subjects(chain[1:]), subjects(shadowChain[1:]); strings.Join(intermediates, "\n") != strings.Join(shadowIntermediates, "\n") {
+		diffs = append(diffs, fmt.Sprintf("intermediates are [%s], shadow has [%s]", strings.Join(intermediates, "; "), strings.Join(shadowIntermediates, "; ")))
+	}
+	if leaf.Issuer.String() != shadowLeaf.Issuer.String() {
+		diffs = append(diffs, fmt.Sprintf("issuer is %s, shadow has %s", leaf.Issuer, shadowLeaf.Issuer))
+	}
+	if !bytes.Equal(bytes.TrimSpace(ca), bytes.TrimSpace(shadowCA)) {
+		diffs = append(diffs, "root CAs differ")
+	}
+
+	validity := leaf.NotAfter.Sub(leaf.NotBefore)
+	shadowValidity := shadowLeaf.NotAfter.Sub(shadowLeaf.NotBefore)
+	if diff := validity - shadowValidity; diff > validityTolerance || diff < -validityTolerance {
+		diffs = append(diffs, fmt.Sprintf("validity is %s, shadow has %s", validity, shadowValidity))
+	}
+	return diffs, nil
+}
+
+// sorted returns a sorted copy of s.
+func sorted(s []string) []string {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+	return s
+}
+
+func stringsOf(n int, at func(i int) string) []string {
+	s := make([]string, n)
+	for i := range s {
+		s[i] = at(i)
+	}
+	return s
+}
+
+// subjects returns the subjects of certs, in order.
+func subjects(certs []*x509.Certificate) []string {
+	s := make([]string, len(certs))
+	for i, cert := range certs {
+		s[i] = cert.Subject.String()
+	}
+	return s
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_compareCertificates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	newCert := func(mutate func(tmpl *x509.Certificate)) []byte {
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "example.com"},
+			DNSNames:     []string{"example.com", "www.example.com"},
+			NotBefore:    now,
+			NotAfter:     now.Add(24 * time.Hour),
+		}
+		if mutate != nil {
+			mutate(tmpl)
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	cert := newCert(nil)
+	ca := []byte("root")
+
+	tests := []struct {
+		name     string
+		shadow   []byte
+		shadowCA []byte
+		want     []string
+	}{
+		{
+			name: "same names in another order, signed a moment later",
+			shadow: newCert(func(tmpl *x509.Certificate) {
+				tmpl.DNSNames = []string{"www.example.com", "example.com"}
+				tmpl.NotBefore = now.Add(10 * time.Second)
+				tmpl.NotAfter = tmpl.NotBefore.Add(24 * time.Hour)
+			}),
+			shadowCA: ca,
+		},
+		{
+			name: "different names",
+			shadow: newCert(func(tmpl *x509.Certificate) {
+				tmpl.DNSNames = []string{"example.com"}
+				tmpl.EmailAddresses = []string{"admin@example.com"}
+			}),
+			shadowCA: ca,
+			want:     []string{"DNS names", "email addresses"},
+		},
+		{
+			name: "shorter validity and another root",
+			shadow: newCert(func(tmpl *x509.Certificate) {
+				tmpl.NotAfter = now.Add(time.Hour)
+			}),
+			shadowCA: []byte("other root"),
+			want:     []string{"root CAs differ", "validity is 24h0m0s, shadow has 1h0m0s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := compareCertificates(cert, ca, tt.shadow, tt.shadowCA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("compareCertificates() = %q, want %d differences", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("compareCertificates()[%d] = %q, want %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestShadowQueue(t *testing.T) {
+	q := &ShadowQueue{Workers: 1, Size: 1}
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	blocking := func(ctx context.Context) {
+		close(started)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			done <- ctx.Err()
+		}
+	}
+	if !q.enqueue(blocking) {
+		t.Fatal("enqueue() to an empty queue = false")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		_ = q.Start(ctx)
+		close(stopped)
+	}()
+	<-started
+
+	// The only worker is busy, so one request waits and the next is dropped.
+	if !q.enqueue(func(context.Context) {}) {
+		t.Error("enqueue() with room in the queue = false")
+	}
+	if q.enqueue(func(context.Context) {}) {
+		t.Error("enqueue() to a full queue = true")
+	}
+
+	// Stopping the manager cancels the request being signed and the workers.
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("request context error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("request was not cancelled")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Start() did not return once cancelled")
+	}
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	cmutil "github.com/cert-manager/cert-manager/pkg/api/util"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	"github.com/william20111/oci-privateca-issuer/pkg/provisioner"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
+	"os"
+	"path/filepath"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"testing"
+	"time"
+)
+
+const (
+	suiteNamespace     = "oci-private-issuer"
+	suiteAppNamespace  = "apps"
+	suiteCompartmentID = "ocid1.compartment.oc1..suite"
+	suiteTimeout       = 30 * time.Second
+)
+
+// suite is an API server with the CRDs installed, the manager running both
+// reconcilers and a fake OCI backend behind them.
+type suite struct {
+	ctx         context.Context
+	client      client.Client
+	collection  *provisioner.Collection
+	oci         *ocifake.Server
+	authorityID string
+}
+
+// newSuite starts the suite, skipping the test when the envtest binaries
+// are not installed. See https://book.kubebuilder.io/reference/envtest.html.
+func newSuite(t *testing.T) *suite {
+	t.Helper()
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, skipping envtest suite")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd"),
+			filepath.Join("testdata", "crds"),
+		},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := env.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, v1alpha1.AddToScheme, cmapi.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mgr, err := controllerruntime.NewManager(cfg, controllerruntime.Options{Scheme: scheme, MetricsBindAddress: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &suite{collection: &provisioner.Collection{}}
+	if err := (&OCICAClusterIssuerReconciler{
+		Collection:               s.collection,
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		ClusterResourceNamespace: suiteNamespace,
+		HealthCheckInterval:      200 * time.Millisecond,
+		Clock:                    clock.RealClock{},
+	}).SetupWithManager(mgr); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&CertificateRequestReconciler{
+		Collection:             s.collection,
+		Client:                 mgr.GetClient(),
+		Log:                    controllerruntime.Log.WithName("certificaterequest"),
+		Scheme:                 mgr.GetScheme(),
+		Recorder:               mgr.GetEventRecorderFor("oci-privateca-issuer"),
+		Clock:                  clock.RealClock{},
+		CheckApprovedCondition: true,
+	}).SetupWithManager(mgr); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := mgr.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	s.ctx = ctx
+	s.client, err = client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.oci = ocifake.NewServer(ocifake.Options{})
+	t.Cleanup(s.oci.Close)
+	s.authorityID, err = s.oci.CreateRootCA(suiteCompartmentID, "suite-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ns := range []string{suiteNamespace, suiteAppNamespace} {
+		s.create(t, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+	}
+	return s
+}
+
+func (s *suite) create(t *testing.T, obj client.Object) {
+	t.Helper()
+	if err := s.client.Create(s.ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually polls condition until it holds, failing the test after
+// suiteTimeout.
+func (s *suite) eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	if err := wait.PollImmediate(100*time.Millisecond, suiteTimeout, func() (bool, error) {
+		return condition(), nil
+	}); err != nil {
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// apiKeySecret returns an API key Secret holding a fresh private key.
+func apiKeySecret(t *testing.T, name string) *v1.Secret {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: suiteNamespace, Name: name},
+		Data: map[string][]byte{
+			provisioner.SecretTenancyKey:     []byte("ocid1.tenancy.oc1..suite"),
+			provisioner.SecretUserKey:        []byte("ocid1.user.oc1..suite"),
+			provisioner.SecretFingerprintKey: []byte("00:11:22:33:44:55:66:77:88:99:aa:bb:cc:dd:ee:ff"),
+			provisioner.SecretPrivateKeyKey:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+	}
+}
+
+func (s *suite) issuer(name, secretName string) *v1alpha1.OCICAClusterIssuer {
+	return &v1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1alpha1.OCICAClusterIssuerSpec{
+			TenancyID:     "ocid1.tenancy.oc1..suite",
+			CompartmentID: suiteCompartmentID,
+			AuthorityID:   s.authorityID,
+			Auth:          &v1alpha1.OCIAuth{Mode: v1alpha1.AuthModeAPIKey, SecretName: secretName},
+			EndpointOverride: &v1alpha1.EndpointOverride{
+				CertificatesManagement: s.oci.URL,
+				Certificates:           s.oci.URL,
+			},
+		},
+	}
+}
+
+// issuerReadyReason returns the reason of the issuer Ready condition when its
+// status is status, and "" otherwise.
+func (s *suite) issuerReadyReason(name string, status metav1.ConditionStatus) string {
+	iss := new(v1alpha1.OCICAClusterIssuer)
+	if err := s.client.Get(s.ctx, types.NamespacedName{Name: name}, iss); err != nil {
+		return ""
+	}
+	for _, c := range iss.Status.Conditions {
+		if c.Type == string(v1alpha1.ConditionReady) && c.Status == status {
+			return c.Reason
+		}
+	}
+	return ""
+}
+
+// certificateRequest creates a CertificateRequest for issuerName, approved
+// unless approve is false.
+func (s *suite) certificateRequest(t *testing.T, name, issuerName string, approve bool) *cmapi.CertificateRequest {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dnsName := name + ".example.com"
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: dnsName},
+		DNSNames: []string{dnsName},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: suiteAppNamespace, Name: name},
+		Spec: cmapi.CertificateRequestSpec{
+			Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+			IssuerRef: cmmeta.ObjectReference{
+				Group: v1alpha1.GroupVersion.Group,
+				Kind:  OCICAClusterIssuerKind,
+				Name:  issuerName,
+			},
+			Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment},
+		},
+	}
+	s.create(t, cr)
+	if approve {
+		cmutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionApproved, cmmeta.ConditionTrue, "suite", "approved by the test suite")
+		if err := s.client.Status().Update(s.ctx, cr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cr
+}
+
+// readyReason returns the reason of the CertificateRequest Ready condition,
+// and "" when it has none.
+func (s *suite) readyReason(cr *cmapi.CertificateRequest) string {
+	if err := s.client.Get(s.ctx, client.ObjectKeyFromObject(cr), cr); err != nil {
+		return ""
+	}
+	c := cmutil.GetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionReady)
+	if c == nil {
+		return ""
+	}
+	return c.Reason
+}
+
+// verifyIssued checks that cr holds a certificate chaining to its CA.
+func verifyIssued(t *testing.T, cr *cmapi.CertificateRequest) {
+	t.Helper()
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(cr.Status.CA) {
+		t.Fatalf("status.ca of %s holds no certificate", cr.Name)
+	}
+	block, rest := pem.Decode(cr.Status.Certificate)
+	if block == nil {
+		t.Fatalf("status.certificate of %s holds no certificate", cr.Name)
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(rest)
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       cr.Name + ".example.com",
+		Roots:         roots,
+		Intermediates: intermediates,
+	}); err != nil {
+		t.Errorf("certificate of %s does not verify: %v", cr.Name, err)
+	}
+}
+
+func TestSuite(t *testing.T) {
+	s := newSuite(t)
+	s.create(t, apiKeySecret(t, "oci-credentials"))
+	s.create(t, s.issuer("oci", "oci-credentials"))
+
+	t.Run("issuer becomes ready", func(t *testing.T) {
+		s.eventually(t, "the issuer to be verified", func() bool {
+			return s.issuerReadyReason("oci", metav1.ConditionTrue) == "Verified"
+		})
+	})
+
+	t.Run("approved request is signed", func(t *testing.T) {
+		cr := s.certificateRequest(t, "approved", "oci", true)
+		s.eventually(t, "the request to be issued", func() bool {
+			return s.readyReason(cr) == cmapi.CertificateRequestReasonIssued
+		})
+		verifyIssued(t, cr)
+		if got := len(s.oci.Certificates()); got != 1 {
+			t.Errorf("OCI certificates got = %d, want 1", got)
+		}
+	})
+
+	t.Run("unapproved and denied requests are not signed", func(t *testing.T) {
+		unapproved := s.certificateRequest(t, "unapproved", "oci", false)
+		denied := s.certificateRequest(t, "denied", "oci", false)
+		cmutil.SetCertificateRequestCondition(denied, cmapi.CertificateRequestConditionDenied, cmmeta.ConditionTrue, "suite", "denied by the test suite")
+		if err := s.client.Status().Update(s.ctx, denied); err != nil {
+			t.Fatal(err)
+		}
+		s.eventually(t, "the request to be denied", func() bool {
+			return s.readyReason(denied) == cmapi.CertificateRequestReasonDenied
+		})
+		if reason := s.readyReason(unapproved); reason != "" || len(unapproved.Status.Certificate) > 0 {
+			t.Errorf("unapproved request got reason %q, want it left alone", reason)
+		}
+	})
+
+	t.Run("disabled CA", func(t *testing.T) {
+		if err := s.oci.SetCertificateAuthorityState(s.authorityID, certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion); err != nil {
+			t.Fatal(err)
+		}
+		s.eventually(t, "the issuer to report the CA unavailable", func() bool {
+			return s.issuerReadyReason("oci", metav1.ConditionFalse) == "Unavailable"
+		})
+		cr := s.certificateRequest(t, "while-disabled", "oci", true)
+		s.eventually(t, "the request to be pending", func() bool {
+			return s.readyReason(cr) == cmapi.CertificateRequestReasonPending
+		})
+
+		if err := s.oci.SetCertificateAuthorityState(s.authorityID, certificatesmanagement.CertificateAuthorityLifecycleStateActive); err != nil {
+			t.Fatal(err)
+		}
+		s.eventually(t, "the pending request to be issued", func() bool {
+			return s.readyReason(cr) == cmapi.CertificateRequestReasonIssued
+		})
+		verifyIssued(t, cr)
+	})
+
+	t.Run("credentials rotate", func(t *testing.T) {
+		secret := new(v1.Secret)
+		if err := s.client.Get(s.ctx, types.NamespacedName{Namespace: suiteNamespace, Name: "oci-credentials"}, secret); err != nil {
+			t.Fatal(err)
+		}
+		delete(secret.Data, provisioner.SecretPrivateKeyKey)
+		if err := s.client.Update(s.ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+		s.eventually(t, "the issuer to reject the broken credentials", func() bool {
+			return s.issuerReadyReason("oci", metav1.ConditionFalse) == "Error"
+		})
+
+		secret.Data = apiKeySecret(t, secret.Name).Data
+		if err := s.client.Update(s.ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+		s.eventually(t, "the issuer to be verified with the new credentials", func() bool {
+			return s.issuerReadyReason("oci", metav1.ConditionTrue) == "Verified"
+		})
+		cr := s.certificateRequest(t, "rotated", "oci", true)
+		s.eventually(t, "the request to be issued", func() bool {
+			return s.readyReason(cr) == cmapi.CertificateRequestReasonIssued
+		})
+		verifyIssued(t, cr)
+	})
+
+	t.Run("issuer is deleted", func(t *testing.T) {
+		iss := &v1alpha1.OCICAClusterIssuer{ObjectMeta: metav1.ObjectMeta{Name: "oci"}}
+		if err := s.client.Delete(s.ctx, iss); err != nil {
+			t.Fatal(err)
+		}
+		s.eventually(t, "the provisioner to be removed", func() bool {
+			_, ok := s.collection.Load(types.NamespacedName{Name: "oci"})
+			return !ok
+		})
+		cr := s.certificateRequest(t, "after-delete", "oci", true)
+		s.eventually(t, "the request to be pending", func() bool {
+			return s.readyReason(cr) == cmapi.CertificateRequestReasonPending
+		})
+		if len(cr.Status.Certificate) > 0 {
+			t.Errorf("request for a deleted issuer was signed")
+		}
+	})
+}
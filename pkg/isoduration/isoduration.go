@@ -0,0 +1,67 @@
+// Package isoduration parses and formats the ISO 8601 durations OCI uses in
+// certificate authority and renewal rules, such as P90D or PT12H.
+//
+// Calendar units have no fixed length, so a year counts as 365 days and a
+// month as 30 days.
+package isoduration
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var pattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// units are the lengths of the capture groups of pattern, in order.
+var units = []time.Duration{365 * day, 30 * day, 7 * day, day, time.Hour, time.Minute, time.Second}
+
+// Parse parses an ISO 8601 duration without fractions.
+func Parse(s string) (time.Duration, error) {
+	m := pattern.FindStringSubmatch(s)
+	if m == nil || s == "P" || s[len(s)-1] == 'T' {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil || time.Duration(n) > (1<<63-1-d)/unit {
+			return 0, fmt.Errorf("ISO 8601 duration %q is too long", s)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+// Format formats d, rounded down to the second, as an ISO 8601 duration in
+// days, hours, minutes and seconds.
+func Format(d time.Duration) string {
+	if d < time.Second {
+		return "PT0S"
+	}
+	s := "P"
+	if days := d / day; days > 0 {
+		s += strconv.FormatInt(int64(days), 10) + "D"
+		d -= days * day
+	}
+	if d < time.Second {
+		return s
+	}
+	s += "T"
+	for _, unit := range []struct {
+		length time.Duration
+		suffix string
+	}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+		if n := d / unit.length; n > 0 {
+			s += strconv.FormatInt(int64(n), 10) + unit.suffix
+			d -= n * unit.length
+		}
+	}
+	return s
+}
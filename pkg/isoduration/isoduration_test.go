@@ -0,0 +1,63 @@
+package isoduration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "P90D", want: 90 * 24 * time.Hour},
+		{in: "P1Y", want: 365 * 24 * time.Hour},
+		{in: "P1M2W", want: 44 * 24 * time.Hour},
+		{in: "PT12H30M15S", want: 12*time.Hour + 30*time.Minute + 15*time.Second},
+		{in: "P1DT1H", want: 25 * time.Hour},
+		{in: "", wantErr: true},
+		{in: "P", wantErr: true},
+		{in: "PT", wantErr: true},
+		{in: "P1DT", wantErr: true},
+		{in: "90D", wantErr: true},
+		{in: "P1.5D", wantErr: true},
+		{in: "P-1D", wantErr: true},
+		{in: "P999999999Y", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{in: 90 * 24 * time.Hour, want: "P90D"},
+		{in: 25 * time.Hour, want: "P1DT1H"},
+		{in: 12*time.Hour + 30*time.Minute + 15*time.Second, want: "PT12H30M15S"},
+		{in: time.Minute + 500*time.Millisecond, want: "PT1M"},
+		{in: 0, want: "PT0S"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := Format(tt.in)
+			if got != tt.want {
+				t.Errorf("Format() got = %s, want %s", got, tt.want)
+			}
+			if d, err := Parse(got); err != nil || d != tt.in.Truncate(time.Second) {
+				t.Errorf("Parse(Format()) = %s, %v, want %s", d, err, tt.in.Truncate(time.Second))
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package naming renders the names and descriptions of OCI certificates from
+// the templates of an issuer.
+//
+// OCI certificate names must be unique within a compartment, so the default
+// name joins the cluster ID, kind, namespace and name of the object a
+// certificate is for with underscores, which Kubernetes names cannot contain.
+package naming
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const (
+	// DefaultNameTemplate is unique for every object of every kind a
+	// certificate is created for in a cluster, and across clusters with
+	// different IDs.
+	DefaultNameTemplate = `{{ with .ClusterID }}{{ . }}_{{ end }}{{ .Kind }}_{{ .Namespace }}_{{ .Name }}`
+
+	// DefaultDescriptionTemplate names the object a certificate was issued
+	// for.
+	DefaultDescriptionTemplate = `cert-manager {{ .Kind }} {{ .Namespace }}/{{ .Name }}{{ with .ClusterID }} in cluster {{ . }}{{ end }}`
+
+	// MaxNameLength is the longest OCI certificate name.
+	MaxNameLength = 255
+	// MaxDescriptionLength is the longest OCI certificate description.
+	MaxDescriptionLength = 400
+
+	// hashLength is the number of hex digits of the hash appended to names
+	// shortened to MaxNameLength, keeping them unique.
+	hashLength = 8
+)
+
+// Data is what name and description templates are executed with.
+type Data struct {
+	// ClusterID identifies the cluster, from the --cluster-id flag of the
+	// controller. It may be empty.
+	ClusterID string
+	// Kind is the kind of the object the certificate is issued for,
+	// CertificateRequest, OCIManagedCertificate or Secret.
+	Kind string
+	// Namespace and Name are those of the object.
+	Namespace string
+	Name      string
+	// Certificate is the name of the cert-manager Certificate the request
+	// belongs to, or empty.
+	Certificate string
+	// Issuer is the name of the issuer.
+	Issuer string
+}
+
+// example is checked by Parse so that templates referencing unknown fields
+// fail when the issuer is validated rather than when signing.
+var example = Data{ClusterID: "cluster", Kind: "CertificateRequest", Namespace: "namespace", Name: "name", Certificate: "certificate", Issuer: "issuer"}
+
+// Templates are the parsed name and description templates of an issuer.
+type Templates struct {
+	name        *template.Template
+	description *template.Template
+}
+
+// Parse parses the name and description templates of an issuer. Empty
+// templates use the defaults.
+func Parse(nameTemplate, descriptionTemplate string) (*Templates, error) {
+	if nameTemplate == "" {
+		nameTemplate = DefaultNameTemplate
+	}
+	if descriptionTemplate == "" {
+		descriptionTemplate = DefaultDescriptionTemplate
+	}
+	name, err := template.New("name").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	description, err := template.New("description").Parse(descriptionTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid description template: %w", err)
+	}
+	t := &Templates{name: name, description: description}
+	if _, err := t.Name(example); err != nil {
+		return nil, err
+	}
+	if _, err := t.Description(example); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Name renders the OCI certificate name for d, sanitized to the characters
+// and length OCI allows.
+func (t *Templates) Name(d Data) (string, error) {
+	var b bytes.Buffer
+	if err := t.name.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("failed to render name template: %w", err)
+	}
+	name := SanitizeName(b.String())
+	if name == "" {
+		return "", fmt.Errorf("name template renders an empty name")
+	}
+	return name, nil
+}
+
+// Description renders the OCI certificate description for d, without control
+// characters and shortened to MaxDescriptionLength.
+func (t *Templates) Description(d Data) (string, error) {
+	var b bytes.Buffer
+	if err := t.description.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("failed to render description template: %w", err)
+	}
+	description := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, strings.TrimSpace(b.String()))
+	if runes := []rune(description); len(runes) > MaxDescriptionLength {
+		description = string(runes[:MaxDescriptionLength])
+	}
+	return description, nil
+}
+
+// SanitizeName replaces the characters OCI does not allow in certificate
+// names, anything but ASCII letters, digits, hyphens, underscores and
+// periods, with hyphens. Names longer than MaxNameLength are shortened and
+// suffixed with a hash of the whole name so that they stay unique.
+func SanitizeName(s string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(s))
+	if len(name) <= MaxNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(s))
+	return name[:MaxNameLength-hashLength-1] + "-" + hex.EncodeToString(sum[:])[:hashLength]
+}
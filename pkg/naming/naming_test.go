@@ -0,0 +1,82 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	data := Data{ClusterID: "prod", Kind: "CertificateRequest", Namespace: "team-a", Name: "web-tls-1", Certificate: "web-tls", Issuer: "oci"}
+	tests := []struct {
+		name                string
+		nameTemplate        string
+		descriptionTemplate string
+		data                Data
+		wantErr             bool
+		wantName            string
+		wantDescription     string
+	}{
+		{
+			name:            "defaults",
+			data:            data,
+			wantName:        "prod_CertificateRequest_team-a_web-tls-1",
+			wantDescription: "cert-manager CertificateRequest team-a/web-tls-1 in cluster prod",
+		},
+		{
+			name:            "defaults without cluster ID",
+			data:            Data{Kind: "OCIManagedCertificate", Namespace: "team-a", Name: "web-tls-1"},
+			wantName:        "OCIManagedCertificate_team-a_web-tls-1",
+			wantDescription: "cert-manager OCIManagedCertificate team-a/web-tls-1",
+		},
+		{
+			name:                "custom",
+			nameTemplate:        "{{ .Issuer }}-{{ .Namespace }}-{{ .Certificate }}-{{ .Name }}",
+			descriptionTemplate: "Certificate {{ .Certificate }}",
+			data:                data,
+			wantName:            "oci-team-a-web-tls-web-tls-1",
+			wantDescription:     "Certificate web-tls",
+		},
+		{
+			name:         "sanitized",
+			nameTemplate: "{{ .Namespace }}/{{ .Name }} ✓",
+			data:         data,
+			wantName:     "team-a-web-tls-1--",
+			// The default description is kept.
+			wantDescription: "cert-manager CertificateRequest team-a/web-tls-1 in cluster prod",
+		},
+		{name: "unknown field", nameTemplate: "{{ .Owner }}", wantErr: true},
+		{name: "malformed", descriptionTemplate: "{{ .Name", wantErr: true},
+		{name: "empty name", nameTemplate: "{{ if false }}x{{ end }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := Parse(tt.nameTemplate, tt.descriptionTemplate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, err := templates.Name(tt.data); err != nil || got != tt.wantName {
+				t.Errorf("Name() = %q, %v, want %q", got, err, tt.wantName)
+			}
+			if got, err := templates.Description(tt.data); err != nil || got != tt.wantDescription {
+				t.Errorf("Description() = %q, %v, want %q", got, err, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	long := strings.Repeat("a", MaxNameLength+10)
+	got := SanitizeName(long)
+	if len(got) != MaxNameLength {
+		t.Errorf("SanitizeName() of a long name has length %d, want %d", len(got), MaxNameLength)
+	}
+	if other := SanitizeName(long + "b"); other == got {
+		t.Errorf("SanitizeName() of different long names = %q for both", got)
+	}
+	if got := SanitizeName(" a.b_c-D9 "); got != "a.b_c-D9" {
+		t.Errorf("SanitizeName() = %q, want allowed characters kept", got)
+	}
+}
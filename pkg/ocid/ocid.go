@@ -0,0 +1,149 @@
+// Package ocid parses and validates Oracle Cloud IDs.
+//
+// An OCID has the form ocid1.<resource type>.<realm>.[region][.future use].<unique id>.
+// Global resources such as tenancies and compartments leave the region empty.
+package ocid
+
+import (
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"regexp"
+	"strings"
+)
+
+// ResourceType is the resource type segment of an OCID.
+type ResourceType string
+
+const (
+	// CertificateAuthority is the resource type of an OCI Certificates CA.
+	CertificateAuthority ResourceType = "certificateauthority"
+	// Certificate is the resource type of an OCI Certificates certificate.
+	Certificate ResourceType = "certificate"
+	// Compartment is the resource type of a compartment.
+	Compartment ResourceType = "compartment"
+	// LoadBalancer is the resource type of an OCI load balancer.
+	LoadBalancer ResourceType = "loadbalancer"
+	// Tenancy is the resource type of a tenancy, which is also its root
+	// compartment.
+	Tenancy ResourceType = "tenancy"
+)
+
+const version = "ocid1"
+
+var (
+	realmPattern  = regexp.MustCompile(`^oc[0-9]+$`)
+	regionPattern = regexp.MustCompile(`^[a-z0-9-]*$`)
+	uniquePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// OCID is a parsed Oracle Cloud ID.
+type OCID struct {
+	ResourceType ResourceType
+	Realm        string
+	// Region is the region key or name, empty for global resources.
+	Region string
+	// FutureUse is the optional reserved segment.
+	FutureUse string
+	UniqueID  string
+}
+
+// Error describes why a string is not a valid OCID.
+type Error struct {
+	Value  string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid OCID %q: %s", e.Value, e.Reason)
+}
+
+// Parse parses s into its segments.
+func Parse(s string) (OCID, error) {
+	if s == "" {
+		return OCID{}, &Error{Value: s, Reason: "must not be empty"}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 5 || len(parts) > 6 || parts[0] != version {
+		return OCID{}, &Error{Value: s, Reason: "must have the form ocid1.<type>.<realm>.<region>.<unique id>"}
+	}
+
+	id := OCID{
+		ResourceType: ResourceType(parts[1]),
+		Realm:        parts[2],
+		Region:       parts[3],
+		UniqueID:     parts[len(parts)-1],
+	}
+	if len(parts) == 6 {
+		id.FutureUse = parts[4]
+	}
+	if id.ResourceType == "" {
+		return OCID{}, &Error{Value: s, Reason: "missing resource type"}
+	}
+	if !realmPattern.MatchString(id.Realm) {
+		return OCID{}, &Error{Value: s, Reason: fmt.Sprintf("unknown realm %q", id.Realm)}
+	}
+	if !regionPattern.MatchString(id.Region) {
+		return OCID{}, &Error{Value: s, Reason: fmt.Sprintf("malformed region %q", id.Region)}
+	}
+	if !uniquePattern.MatchString(id.UniqueID) {
+		return OCID{}, &Error{Value: s, Reason: "missing or malformed unique id"}
+	}
+	return id, nil
+}
+
+// ParseType parses s and checks that it names one of the given resource types.
+func ParseType(s string, types ...ResourceType) (OCID, error) {
+	id, err := Parse(s)
+	if err != nil {
+		return OCID{}, err
+	}
+	for _, t := range types {
+		if id.ResourceType == t {
+			return id, nil
+		}
+	}
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return OCID{}, &Error{
+		Value:  s,
+		Reason: fmt.Sprintf("must be the OCID of a %s, not a %s", strings.Join(names, " or "), id.ResourceType),
+	}
+}
+
+// String returns the OCID in its canonical form.
+func (o OCID) String() string {
+	parts := []string{version, string(o.ResourceType), o.Realm, o.Region}
+	if o.FutureUse != "" {
+		parts = append(parts, o.FutureUse)
+	}
+	return strings.Join(append(parts, o.UniqueID), ".")
+}
+
+// IsRegional reports whether the OCID names a regional resource.
+func (o OCID) IsRegional() bool {
+	return o.Region != ""
+}
+
+// OCIRegion returns the region the resource lives in, translating region keys
+// such as "phx" to region names such as "us-phoenix-1". It returns an error
+// for global resources.
+func (o OCID) OCIRegion() (common.Region, error) {
+	if !o.IsRegional() {
+		return "", fmt.Errorf("%s %s is not a regional resource", o.ResourceType, o.UniqueID)
+	}
+	return common.StringToRegion(o.Region), nil
+}
+
+// SameRegion reports whether two OCIDs can refer to resources in the same
+// region. Global resources are in every region of their realm.
+func (o OCID) SameRegion(other OCID) bool {
+	if o.Realm != other.Realm {
+		return false
+	}
+	if !o.IsRegional() || !other.IsRegional() {
+		return true
+	}
+	return common.StringToRegion(o.Region) == common.StringToRegion(other.Region)
+}
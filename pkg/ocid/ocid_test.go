@@ -0,0 +1,142 @@
+package ocid
+
+import (
+	"errors"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    OCID
+		wantErr bool
+	}{
+		{
+			name: "regional resource",
+			in:   "ocid1.certificateauthority.oc1.phx.aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+			want: OCID{
+				ResourceType: CertificateAuthority,
+				Realm:        "oc1",
+				Region:       "phx",
+				UniqueID:     "aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+			},
+		},
+		{
+			name: "global resource",
+			in:   "ocid1.tenancy.oc1..aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+			want: OCID{
+				ResourceType: Tenancy,
+				Realm:        "oc1",
+				UniqueID:     "aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq",
+			},
+		},
+		{
+			name: "future use segment",
+			in:   "ocid1.certificate.oc2.us-langley-1.abc.aaaa",
+			want: OCID{
+				ResourceType: Certificate,
+				Realm:        "oc2",
+				Region:       "us-langley-1",
+				FutureUse:    "abc",
+				UniqueID:     "aaaa",
+			},
+		},
+		{name: "empty", in: "", wantErr: true},
+		{name: "opaque string", in: "test", wantErr: true},
+		{name: "wrong version", in: "ocid2.tenancy.oc1..aaaa", wantErr: true},
+		{name: "unknown realm", in: "ocid1.tenancy.xx1..aaaa", wantErr: true},
+		{name: "missing unique id", in: "ocid1.tenancy.oc1..", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %+v, want %+v", got, tt.want)
+			}
+			if err == nil && got.String() != tt.in {
+				t.Errorf("String() got = %s, want %s", got.String(), tt.in)
+			}
+		})
+	}
+}
+
+func TestParseType(t *testing.T) {
+	_, err := ParseType("ocid1.compartment.oc1..aaaa", Compartment, Tenancy)
+	if err != nil {
+		t.Errorf("ParseType() unexpected error = %v", err)
+	}
+	_, err = ParseType("ocid1.compartment.oc1..aaaa", CertificateAuthority)
+	var ocidErr *Error
+	if !errors.As(err, &ocidErr) {
+		t.Fatalf("ParseType() error = %v, want *Error", err)
+	}
+	if want := `invalid OCID "ocid1.compartment.oc1..aaaa": must be the OCID of a certificateauthority, not a compartment`; err.Error() != want {
+		t.Errorf("ParseType() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestOCID_OCIRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    common.Region
+		wantErr bool
+	}{
+		{name: "region key", in: "ocid1.certificateauthority.oc1.phx.aaaa", want: common.RegionPHX},
+		{name: "region name", in: "ocid1.certificateauthority.oc1.eu-frankfurt-1.aaaa", want: common.RegionFRA},
+		{name: "global resource", in: "ocid1.tenancy.oc1..aaaa", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := Parse(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := id.OCIRegion()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("OCIRegion() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("OCIRegion() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOCID_SameRegion(t *testing.T) {
+	phx, _ := Parse("ocid1.certificateauthority.oc1.phx.aaaa")
+	phxName, _ := Parse("ocid1.compartment.oc1.us-phoenix-1.aaaa")
+	iad, _ := Parse("ocid1.compartment.oc1.iad.aaaa")
+	global, _ := Parse("ocid1.compartment.oc1..aaaa")
+	otherRealm, _ := Parse("ocid1.compartment.oc2..aaaa")
+
+	if !phx.SameRegion(phxName) {
+		t.Errorf("region key and name should match")
+	}
+	if phx.SameRegion(iad) {
+		t.Errorf("different regions should not match")
+	}
+	if !phx.SameRegion(global) {
+		t.Errorf("global resources should match any region in the realm")
+	}
+	if phx.SameRegion(otherRealm) {
+		t.Errorf("different realms should not match")
+	}
+}
+
+func TestRealmDomain(t *testing.T) {
+	if got, ok := RealmDomain("oc1"); !ok || got != "oraclecloud.com" {
+		t.Errorf("RealmDomain(oc1) got = %s, %v", got, ok)
+	}
+	if _, ok := RealmDomain("oc99"); ok {
+		t.Errorf("RealmDomain(oc99) should be unknown")
+	}
+}
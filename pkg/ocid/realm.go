@@ -0,0 +1,22 @@
+package ocid
+
+// realmDomains maps OCI realms to the domain their service endpoints live
+// under. Dedicated region realms that are not listed need their domain
+// configured explicitly.
+var realmDomains = map[string]string{
+	"oc1":  "oraclecloud.com",
+	"oc2":  "oraclegovcloud.com",
+	"oc3":  "oraclegovcloud.com",
+	"oc4":  "oraclegovcloud.uk",
+	"oc8":  "oraclecloud8.com",
+	"oc9":  "oraclecloud9.com",
+	"oc10": "oraclecloud10.com",
+	"oc14": "oraclecloud14.com",
+}
+
+// RealmDomain returns the endpoint domain of a realm, and whether the realm is
+// known.
+func RealmDomain(realm string) (string, bool) {
+	domain, ok := realmDomains[realm]
+	return domain, ok
+}
@@ -0,0 +1,406 @@
+package ocifake
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/william20111/oci-privateca-issuer/pkg/isoduration"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	crlPath = "/crl/"
+
+	rootValidity        = time.Hour * 24 * 365 * 10
+	subordinateValidity = time.Hour * 24 * 365 * 5
+	crlValidity         = time.Hour * 24
+)
+
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// authority is a CA of the in-memory hierarchy.
+type authority struct {
+	id            string
+	name          string
+	compartmentID string
+	parent        *authority
+	key           crypto.Signer
+	cert          *x509.Certificate
+	certPEM       string
+	state         certificatesmanagement.CertificateAuthorityLifecycleStateEnum
+	timeCreated   time.Time
+	expiryRule    *certificatesmanagement.CertificateAuthorityIssuanceExpiryRule
+
+	revoked   []pkix.RevokedCertificate
+	crlNumber int64
+}
+
+// CreateRootCA adds a self-signed root CA and returns its OCID.
+func (s *Server) CreateRootCA(compartmentID, name string) (string, error) {
+	return s.createAuthority(compartmentID, name, nil)
+}
+
+// CreateSubordinateCA adds a CA issued by the CA parentID and returns its
+// OCID. The subordinate lives in the compartment of its parent.
+func (s *Server) CreateSubordinateCA(parentID, name string) (string, error) {
+	s.mu.Lock()
+	parent, ok := s.authorities[parentID]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown certificate authority %s", parentID)
+	}
+	return s.createAuthority(parent.compartmentID, name, parent)
+}
+
+func (s *Server) createAuthority(compartmentID, name string, parent *authority) (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	now := s.opts.Now()
+	a := &authority{
+		id:            s.newOCID("certificateauthority"),
+		name:          name,
+		compartmentID: compartmentID,
+		parent:        parent,
+		key:           key,
+		state:         certificatesmanagement.CertificateAuthorityLifecycleStateActive,
+		timeCreated:   now,
+	}
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(rootValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		serial, err := randomSerial()
+		if err != nil {
+			return "", err
+		}
+		tmpl.SerialNumber = serial
+		tmpl.CRLDistributionPoints = []string{s.crlURL(a)}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			return "", err
+		}
+		if a.cert, err = x509.ParseCertificate(der); err != nil {
+			return "", err
+		}
+		a.certPEM = encodeCertificate(der)
+	} else {
+		tmpl.NotAfter = now.Add(subordinateValidity)
+		s.mu.Lock()
+		a.cert, a.certPEM, err = s.issue(parent, tmpl, key.Public())
+		s.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorities[a.id] = a
+	s.order = append(s.order, a.id)
+	return a.id, nil
+}
+
+// SetCertificateAuthorityState moves a CA to a lifecycle state. Only ACTIVE
+// CAs issue certificates.
+func (s *Server) SetCertificateAuthorityState(id string, state certificatesmanagement.CertificateAuthorityLifecycleStateEnum) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[id]
+	if !ok {
+		return fmt.Errorf("unknown certificate authority %s", id)
+	}
+	a.state = state
+	return nil
+}
+
+// SetIssuanceExpiryRule sets the rule limiting the validity of certificates
+// issued by a CA. Certificates longer than its leaf maximum are refused.
+func (s *Server) SetIssuanceExpiryRule(id string, rule certificatesmanagement.CertificateAuthorityIssuanceExpiryRule) error {
+	if rule.LeafCertificateMaxValidityDuration != nil {
+		if _, err := isoduration.Parse(*rule.LeafCertificateMaxValidityDuration); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[id]
+	if !ok {
+		return fmt.Errorf("unknown certificate authority %s", id)
+	}
+	a.expiryRule = &rule
+	return nil
+}
+
+// leafMaxValidity returns the longest validity of certificates a issues, or
+// zero when it is not limited.
+func (a *authority) leafMaxValidity() time.Duration {
+	if a.expiryRule == nil || a.expiryRule.LeafCertificateMaxValidityDuration == nil {
+		return 0
+	}
+	d, _ := isoduration.Parse(*a.expiryRule.LeafCertificateMaxValidityDuration)
+	return d
+}
+
+// CertificateAuthority returns the certificate of a CA.
+func (s *Server) CertificateAuthority(id string) (*x509.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown certificate authority %s", id)
+	}
+	return a.cert, nil
+}
+
+// Sign signs tmpl with the CA for pub outside of OCI, keeping its serial
+// number, as material issued by the CA and then exported would be. It
+// returns the PEM encoded certificate and the chain of the CA up to its root.
+func (s *Server) Sign(id string, tmpl *x509.Certificate, pub crypto.PublicKey) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[id]
+	if !ok {
+		return "", "", fmt.Errorf("unknown certificate authority %s", id)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, pub, a.key)
+	if err != nil {
+		return "", "", err
+	}
+	return encodeCertificate(der), a.chain(), nil
+}
+
+// issue signs tmpl with the CA for pub. Callers hold mu.
+func (s *Server) issue(a *authority, tmpl *x509.Certificate, pub crypto.PublicKey) (*x509.Certificate, string, error) {
+	if a.state != certificatesmanagement.CertificateAuthorityLifecycleStateActive {
+		return nil, "", fmt.Errorf("certificate authority %s is %s", a.id, a.state)
+	}
+	if tmpl.NotAfter.After(a.cert.NotAfter) {
+		return nil, "", fmt.Errorf("validity ends after the issuing certificate authority %s", a.cert.NotAfter.Format(time.RFC3339))
+	}
+	if !tmpl.NotAfter.After(tmpl.NotBefore) {
+		return nil, "", fmt.Errorf("validity must end after it starts")
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, "", err
+	}
+	tmpl.SerialNumber = serial
+	tmpl.CRLDistributionPoints = []string{s.crlURL(a)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, pub, a.key)
+	if err != nil {
+		return nil, "", err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, "", err
+	}
+	return cert, encodeCertificate(der), nil
+}
+
+// crlURL returns where the CRL of certificates issued by a is served.
+func (s *Server) crlURL(a *authority) string {
+	return s.URL + crlPath + a.id + ".crl"
+}
+
+// chain returns the PEM encoded certificates from a up to its root.
+func (a *authority) chain() string {
+	var b strings.Builder
+	for ca := a; ca != nil; ca = ca.parent {
+		b.WriteString(ca.certPEM)
+	}
+	return b.String()
+}
+
+// revoke adds a serial to the CRL of the CA. Callers hold mu.
+func (a *authority) revoke(serial *big.Int, at time.Time, reason certificatesmanagement.RevocationReasonEnum) error {
+	entry := pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: at}
+	if code, ok := crlReasonCodes[reason]; ok {
+		value, err := asn1.Marshal(asn1.Enumerated(code))
+		if err != nil {
+			return err
+		}
+		entry.Extensions = []pkix.Extension{{Id: oidExtensionReasonCode, Value: value}}
+	}
+	a.revoked = append(a.revoked, entry)
+	return nil
+}
+
+// crlReasonCodes maps OCI revocation reasons to RFC 5280 reason codes.
+var crlReasonCodes = map[certificatesmanagement.RevocationReasonEnum]int{
+	certificatesmanagement.RevocationReasonUnspecified:          0,
+	certificatesmanagement.RevocationReasonKeyCompromise:        1,
+	certificatesmanagement.RevocationReasonCaCompromise:         2,
+	certificatesmanagement.RevocationReasonAffiliationChanged:   3,
+	certificatesmanagement.RevocationReasonSuperseded:           4,
+	certificatesmanagement.RevocationReasonCessationOfOperation: 5,
+	certificatesmanagement.RevocationReasonPrivilegeWithdrawn:   9,
+	certificatesmanagement.RevocationReasonAaCompromise:         10,
+}
+
+func (s *Server) serveCRL(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, crlPath), ".crl")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[id]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	a.crlNumber++
+	now := s.opts.Now()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: a.revoked,
+		Number:              big.NewInt(a.crlNumber),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(crlValidity),
+	}, a.cert, a.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	_, _ = w.Write(der)
+}
+
+func (s *Server) listCertificateAuthorities(w http.ResponseWriter, req *http.Request, _ []string) {
+	q := req.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []certificatesmanagement.CertificateAuthoritySummary
+	for _, id := range s.order {
+		a, ok := s.authorities[id]
+		if !ok || (q.Get("compartmentId") != "" && a.compartmentID != q.Get("compartmentId")) ||
+			(q.Get("name") != "" && a.name != q.Get("name")) ||
+			(q.Get("lifecycleState") != "" && string(a.state) != q.Get("lifecycleState")) {
+			continue
+		}
+		items = append(items, a.summary())
+	}
+	start, end, next := s.page(req, len(items))
+	writePage(w, next, items[start:end])
+}
+
+func (s *Server) getCertificateAuthority(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate authority", params[0])
+		return
+	}
+	summary := a.summary()
+	var rules []certificatesmanagement.CertificateAuthorityRule
+	if a.expiryRule != nil {
+		rules = append(rules, *a.expiryRule)
+	}
+	writeJSON(w, http.StatusOK, certificatesmanagement.CertificateAuthority{
+		Id:                           summary.Id,
+		Name:                         summary.Name,
+		TimeCreated:                  summary.TimeCreated,
+		LifecycleState:               summary.LifecycleState,
+		CompartmentId:                summary.CompartmentId,
+		ConfigType:                   summary.ConfigType,
+		IssuerCertificateAuthorityId: summary.IssuerCertificateAuthorityId,
+		SigningAlgorithm:             summary.SigningAlgorithm,
+		CurrentVersion:               a.versionSummary(),
+		Subject:                      summary.Subject,
+		CertificateAuthorityRules:    rules,
+		CertificateRevocationListDetails: &certificatesmanagement.CertificateRevocationListDetails{
+			CustomFormattedUrls: []string{s.crlURL(a)},
+		},
+	})
+}
+
+func (s *Server) getCertificateAuthorityBundle(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.authorities[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate authority", params[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, certificates.CertificateAuthorityBundle{
+		CertificateAuthorityId:   common.String(a.id),
+		CertificateAuthorityName: common.String(a.name),
+		SerialNumber:             common.String(formatSerial(a.cert.SerialNumber)),
+		CertificatePem:           common.String(a.certPEM),
+		TimeCreated:              &common.SDKTime{Time: a.timeCreated},
+		VersionNumber:            common.Int64(1),
+		Validity: &certificates.Validity{
+			TimeOfValidityNotBefore: &common.SDKTime{Time: a.cert.NotBefore},
+			TimeOfValidityNotAfter:  &common.SDKTime{Time: a.cert.NotAfter},
+		},
+		Stages:       []certificates.VersionStageEnum{certificates.VersionStageCurrent, certificates.VersionStageLatest},
+		CertChainPem: common.String(a.chain()),
+	})
+}
+
+func (a *authority) summary() certificatesmanagement.CertificateAuthoritySummary {
+	summary := certificatesmanagement.CertificateAuthoritySummary{
+		Id:               common.String(a.id),
+		Name:             common.String(a.name),
+		TimeCreated:      &common.SDKTime{Time: a.timeCreated},
+		LifecycleState:   a.state,
+		CompartmentId:    common.String(a.compartmentID),
+		ConfigType:       certificatesmanagement.CertificateAuthorityConfigTypeRootCaGeneratedInternally,
+		SigningAlgorithm: certificatesmanagement.SignatureAlgorithmSha256WithEcdsa,
+		Subject:          &certificatesmanagement.CertificateSubject{CommonName: common.String(a.cert.Subject.CommonName)},
+	}
+	if a.parent != nil {
+		summary.ConfigType = certificatesmanagement.CertificateAuthorityConfigTypeSubordinateCaIssuedByInternalCa
+		summary.IssuerCertificateAuthorityId = common.String(a.parent.id)
+	}
+	return summary
+}
+
+func (a *authority) versionSummary() *certificatesmanagement.CertificateAuthorityVersionSummary {
+	return &certificatesmanagement.CertificateAuthorityVersionSummary{
+		CertificateAuthorityId: common.String(a.id),
+		TimeCreated:            &common.SDKTime{Time: a.timeCreated},
+		VersionNumber:          common.Int64(1),
+		Stages:                 []certificatesmanagement.VersionStageEnum{certificatesmanagement.VersionStageCurrent, certificatesmanagement.VersionStageLatest},
+		SerialNumber:           common.String(formatSerial(a.cert.SerialNumber)),
+		Validity: &certificatesmanagement.Validity{
+			TimeOfValidityNotBefore: &common.SDKTime{Time: a.cert.NotBefore},
+			TimeOfValidityNotAfter:  &common.SDKTime{Time: a.cert.NotAfter},
+		},
+	}
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// formatSerial formats a serial number the way OCI does, as colon separated
+// upper case hex bytes.
+func formatSerial(serial *big.Int) string {
+	b := serial.Bytes()
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02X", v)
+	}
+	return strings.Join(parts, ":")
+}
+
+func encodeCertificate(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
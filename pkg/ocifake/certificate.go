@@ -0,0 +1,994 @@
+package ocifake
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultCertificateValidity is the validity of certificates created without
+// one.
+const DefaultCertificateValidity = time.Hour * 24 * 90
+
+var (
+	oidExtensionSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// certificate is a certificate resource and its versions.
+type certificate struct {
+	id            string
+	name          string
+	compartmentID string
+	description   string
+	issuer        *authority
+	configType    certificatesmanagement.CertificateConfigTypeEnum
+	profileType   certificatesmanagement.CertificateProfileTypeEnum
+	keyAlgorithm  certificatesmanagement.KeyAlgorithmEnum
+	subject       *certificatesmanagement.CertificateSubject
+	tags          map[string]string
+	state         certificatesmanagement.CertificateLifecycleStateEnum
+	timeCreated   time.Time
+	deletion      *time.Time
+	versions      []*version
+}
+
+// version is a certificate version. Certificates managed by OCI hold the
+// private key, externally managed ones only the certificate. Imported
+// versions hold the chain they were imported with.
+type version struct {
+	number      int64
+	name        string
+	cert        *x509.Certificate
+	certPEM     string
+	keyPEM      string
+	chainPEM    string
+	stages      []certificatesmanagement.VersionStageEnum
+	revocation  *certificatesmanagement.RevocationStatus
+	timeCreated time.Time
+}
+
+// SetCertificateState moves a certificate to a lifecycle state, for example
+// to simulate a certificate stuck in CREATING or FAILED.
+func (s *Server) SetCertificateState(id string, state certificatesmanagement.CertificateLifecycleStateEnum) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[id]
+	if !ok {
+		return fmt.Errorf("unknown certificate %s", id)
+	}
+	c.state = state
+	return nil
+}
+
+// SetCertificateTags replaces the freeform tags of a certificate, for
+// example to simulate a certificate created in the OCI console.
+func (s *Server) SetCertificateTags(id string, tags map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[id]
+	if !ok {
+		return fmt.Errorf("unknown certificate %s", id)
+	}
+	c.tags = tags
+	return nil
+}
+
+// RenewCertificate issues a new current version of a certificate whose key
+// is generated by the fake, as the renewal rule of a certificate does in
+// OCI, and returns its number.
+func (s *Server) RenewCertificate(id string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[id]
+	if !ok {
+		return 0, fmt.Errorf("unknown certificate %s", id)
+	}
+	if c.configType != certificatesmanagement.CertificateConfigTypeIssuedByInternalCa {
+		return 0, fmt.Errorf("certificate %s is %s", id, c.configType)
+	}
+	v, _, err := s.versionWithKey(c, c.current().sans(), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	v.number = c.versions[len(c.versions)-1].number + 1
+	for _, old := range c.versions {
+		old.stages = removeStage(old.stages, certificatesmanagement.VersionStageLatest)
+	}
+	v.stages = []certificatesmanagement.VersionStageEnum{certificatesmanagement.VersionStageLatest}
+	c.versions = append(c.versions, v)
+	c.promote(v)
+	return v.number, nil
+}
+
+// Certificates returns the OCIDs of all certificates, in creation order.
+func (s *Server) Certificates() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, id := range s.order {
+		if _, ok := s.certificates[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (s *Server) createCertificate(w http.ResponseWriter, req *http.Request, _ []string) {
+	var details certificatesmanagement.CreateCertificateDetails
+	if !decodeJSON(w, req, &details) {
+		return
+	}
+	if details.Name == nil || *details.Name == "" || details.CompartmentId == nil || *details.CompartmentId == "" {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", "name and compartmentId are required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.denied[*details.CompartmentId] {
+		writeNotAuthorized(w, *details.CompartmentId)
+		return
+	}
+	for _, c := range s.certificates {
+		if c.name == *details.Name && c.compartmentID == *details.CompartmentId &&
+			c.state != certificatesmanagement.CertificateLifecycleStateDeleted {
+			writeError(w, http.StatusConflict, "Conflict", fmt.Sprintf("certificate %q already exists", *details.Name))
+			return
+		}
+	}
+
+	now := s.opts.Now()
+	c := &certificate{
+		id:            s.newOCID("certificate"),
+		name:          *details.Name,
+		compartmentID: *details.CompartmentId,
+		description:   stringValue(details.Description),
+		tags:          details.FreeformTags,
+		state:         certificatesmanagement.CertificateLifecycleStateActive,
+		timeCreated:   now,
+	}
+
+	var v *version
+	var status int
+	var err error
+	switch config := details.CertificateConfig.(type) {
+	case certificatesmanagement.CreateCertificateManagedExternallyIssuedByInternalCaConfigDetails:
+		c.configType = certificatesmanagement.CertificateConfigTypeManagedExternallyIssuedByInternalCa
+		if c.issuer, status, err = s.issuerOf(config.IssuerCertificateAuthorityId); err == nil {
+			v, status, err = s.versionFromCSR(c, stringValue(config.CsrPem), config.VersionName, config.Validity)
+		}
+	case certificatesmanagement.CreateCertificateByImportingConfigDetails:
+		c.configType = certificatesmanagement.CertificateConfigTypeImported
+		v, status, err = versionFromImport(c, stringValue(config.CertificatePem), stringValue(config.CertChainPem),
+			stringValue(config.PrivateKeyPem), config.VersionName, s.opts.Now())
+	case certificatesmanagement.CreateCertificateIssuedByInternalCaConfigDetails:
+		c.configType = certificatesmanagement.CertificateConfigTypeIssuedByInternalCa
+		c.profileType = config.CertificateProfileType
+		c.keyAlgorithm = config.KeyAlgorithm
+		c.subject = config.Subject
+		if c.keyAlgorithm == "" {
+			c.keyAlgorithm = certificatesmanagement.KeyAlgorithmRsa2048
+		}
+		if c.issuer, status, err = s.issuerOf(config.IssuerCertificateAuthorityId); err == nil {
+			v, status, err = s.versionWithKey(c, config.SubjectAlternativeNames, config.VersionName, config.Validity)
+		}
+	default:
+		status, err = http.StatusBadRequest, fmt.Errorf("certificate config %T is not supported", details.CertificateConfig)
+	}
+	if err != nil {
+		writeError(w, status, errorCode(status), err.Error())
+		return
+	}
+	v.number = 1
+	v.stages = []certificatesmanagement.VersionStageEnum{certificatesmanagement.VersionStageCurrent, certificatesmanagement.VersionStageLatest}
+	c.versions = []*version{v}
+
+	s.certificates[c.id] = c
+	s.order = append(s.order, c.id)
+	writeJSON(w, http.StatusOK, c.model())
+}
+
+func (s *Server) updateCertificate(w http.ResponseWriter, req *http.Request, params []string) {
+	var details certificatesmanagement.UpdateCertificateDetails
+	if !decodeJSON(w, req, &details) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate", params[0])
+		return
+	}
+	if c.state != certificatesmanagement.CertificateLifecycleStateActive {
+		writeError(w, http.StatusConflict, "IncorrectState", fmt.Sprintf("certificate %s is %s", c.id, c.state))
+		return
+	}
+
+	if details.CertificateConfig != nil {
+		var v *version
+		var status int
+		var err error
+		var stage certificatesmanagement.UpdateCertificateConfigDetailsStageEnum
+		switch config := details.CertificateConfig.(type) {
+		case certificatesmanagement.UpdateCertificateManagedExternallyIssuedByInternalCaConfigDetails:
+			if c.configType != certificatesmanagement.CertificateConfigTypeManagedExternallyIssuedByInternalCa {
+				writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("certificate %s is %s", c.id, c.configType))
+				return
+			}
+			stage = config.Stage
+			v, status, err = s.versionFromCSR(c, stringValue(config.CsrPem), config.VersionName, config.Validity)
+		case certificatesmanagement.UpdateCertificateIssuedByInternalCaConfigDetails:
+			if c.configType != certificatesmanagement.CertificateConfigTypeIssuedByInternalCa {
+				writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("certificate %s is %s", c.id, c.configType))
+				return
+			}
+			stage = config.Stage
+			v, status, err = s.versionWithKey(c, c.current().sans(), config.VersionName, config.Validity)
+		case certificatesmanagement.UpdateCertificateByImportingConfigDetails:
+			if c.configType != certificatesmanagement.CertificateConfigTypeImported {
+				writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("certificate %s is %s", c.id, c.configType))
+				return
+			}
+			stage = config.Stage
+			v, status, err = versionFromImport(c, stringValue(config.CertificatePem), stringValue(config.CertChainPem),
+				stringValue(config.PrivateKeyPem), config.VersionName, s.opts.Now())
+		default:
+			status, err = http.StatusBadRequest, fmt.Errorf("certificate config %T is not supported", details.CertificateConfig)
+		}
+		if err != nil {
+			writeError(w, status, errorCode(status), err.Error())
+			return
+		}
+		v.number = c.versions[len(c.versions)-1].number + 1
+		for _, old := range c.versions {
+			old.stages = removeStage(old.stages, certificatesmanagement.VersionStageLatest)
+		}
+		v.stages = []certificatesmanagement.VersionStageEnum{certificatesmanagement.VersionStageLatest}
+		c.versions = append(c.versions, v)
+		if stage == certificatesmanagement.UpdateCertificateConfigDetailsStagePending {
+			v.stages = append(v.stages, certificatesmanagement.VersionStagePending)
+		} else {
+			c.promote(v)
+		}
+	}
+	if details.CurrentVersionNumber != nil {
+		v := c.version(*details.CurrentVersionNumber)
+		if v == nil {
+			writeNotFound(w, "certificate version", strconv.FormatInt(*details.CurrentVersionNumber, 10))
+			return
+		}
+		c.promote(v)
+	}
+	if details.Description != nil {
+		c.description = *details.Description
+	}
+	if details.FreeformTags != nil {
+		c.tags = details.FreeformTags
+	}
+	writeJSON(w, http.StatusOK, c.model())
+}
+
+func (s *Server) scheduleCertificateDeletion(w http.ResponseWriter, req *http.Request, params []string) {
+	var details certificatesmanagement.ScheduleCertificateDeletionDetails
+	if !decodeJSON(w, req, &details) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate", params[0])
+		return
+	}
+	if c.state != certificatesmanagement.CertificateLifecycleStateActive {
+		writeError(w, http.StatusConflict, "IncorrectState", fmt.Sprintf("certificate %s is %s", c.id, c.state))
+		return
+	}
+	at := s.opts.Now().Add(time.Hour * 24 * 30)
+	if details.TimeOfDeletion != nil {
+		at = details.TimeOfDeletion.Time
+	}
+	c.deletion = &at
+	c.state = certificatesmanagement.CertificateLifecycleStatePendingDeletion
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) cancelCertificateDeletion(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate", params[0])
+		return
+	}
+	if c.state != certificatesmanagement.CertificateLifecycleStatePendingDeletion {
+		writeError(w, http.StatusConflict, "IncorrectState", fmt.Sprintf("certificate %s is %s", c.id, c.state))
+		return
+	}
+	c.deletion = nil
+	c.state = certificatesmanagement.CertificateLifecycleStateActive
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getCertificate(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate", params[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, c.model())
+}
+
+func (s *Server) listCertificates(w http.ResponseWriter, req *http.Request, _ []string) {
+	q := req.URL.Query()
+	if q.Get("compartmentId") == "" && q.Get("certificateId") == "" {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", "compartmentId is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.denied[q.Get("compartmentId")] {
+		writeNotAuthorized(w, q.Get("compartmentId"))
+		return
+	}
+	var items []certificatesmanagement.CertificateSummary
+	for _, id := range s.order {
+		c, ok := s.certificates[id]
+		if !ok || (q.Get("compartmentId") != "" && c.compartmentID != q.Get("compartmentId")) ||
+			(q.Get("certificateId") != "" && c.id != q.Get("certificateId")) ||
+			(q.Get("name") != "" && c.name != q.Get("name")) ||
+			(q.Get("lifecycleState") != "" && string(c.state) != q.Get("lifecycleState")) ||
+			(q.Get("issuerCertificateAuthorityId") != "" && (c.issuer == nil || c.issuer.id != q.Get("issuerCertificateAuthorityId"))) {
+			continue
+		}
+		items = append(items, c.summary())
+	}
+	start, end, next := s.page(req, len(items))
+	writePage(w, next, items[start:end])
+}
+
+func (s *Server) listCertificateVersions(w http.ResponseWriter, req *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate", params[0])
+		return
+	}
+	var items []certificatesmanagement.CertificateVersionSummary
+	for _, v := range c.versions {
+		if n := req.URL.Query().Get("versionNumber"); n != "" && n != strconv.FormatInt(v.number, 10) {
+			continue
+		}
+		items = append(items, v.summary(c))
+	}
+	start, end, next := s.page(req, len(items))
+	writePage(w, next, items[start:end])
+}
+
+func (s *Server) getCertificateVersion(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, v, ok := s.lookupVersion(w, params)
+	if !ok {
+		return
+	}
+	summary := v.summary(c)
+	writeJSON(w, http.StatusOK, certificatesmanagement.CertificateVersion{
+		CertificateId:           summary.CertificateId,
+		TimeCreated:             summary.TimeCreated,
+		VersionNumber:           summary.VersionNumber,
+		Stages:                  summary.Stages,
+		SerialNumber:            summary.SerialNumber,
+		VersionName:             summary.VersionName,
+		SubjectAlternativeNames: summary.SubjectAlternativeNames,
+		Validity:                summary.Validity,
+		RevocationStatus:        summary.RevocationStatus,
+	})
+}
+
+func (s *Server) revokeCertificateVersion(w http.ResponseWriter, req *http.Request, params []string) {
+	var details certificatesmanagement.RevokeCertificateVersionDetails
+	if !decodeJSON(w, req, &details) {
+		return
+	}
+	if details.RevocationReason == "" {
+		details.RevocationReason = certificatesmanagement.RevocationReasonUnspecified
+	}
+	if _, ok := certificatesmanagement.GetMappingRevocationReasonEnum(string(details.RevocationReason)); !ok {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("unknown revocation reason %q", details.RevocationReason))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, v, ok := s.lookupVersion(w, params)
+	if !ok {
+		return
+	}
+	if v.revocation != nil {
+		writeError(w, http.StatusConflict, "IncorrectState", fmt.Sprintf("certificate version %d is already revoked", v.number))
+		return
+	}
+	if c.issuer == nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("certificate %s is %s and cannot be revoked", c.id, c.configType))
+		return
+	}
+	now := s.opts.Now()
+	if err := c.issuer.revoke(v.cert.SerialNumber, now, details.RevocationReason); err != nil {
+		writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+		return
+	}
+	v.revocation = &certificatesmanagement.RevocationStatus{
+		TimeOfRevocation: &common.SDKTime{Time: now},
+		RevocationReason: details.RevocationReason,
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getCertificateBundle(w http.ResponseWriter, req *http.Request, params []string) {
+	q := req.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.certificates[params[0]]
+	if !ok || c.state == certificatesmanagement.CertificateLifecycleStateDeleted {
+		writeNotFound(w, "certificate", params[0])
+		return
+	}
+
+	var v *version
+	switch {
+	case q.Get("versionNumber") != "":
+		n, err := strconv.ParseInt(q.Get("versionNumber"), 10, 64)
+		if err == nil {
+			v = c.version(n)
+		}
+	case q.Get("certificateVersionName") != "":
+		for _, candidate := range c.versions {
+			if candidate.name == q.Get("certificateVersionName") {
+				v = candidate
+			}
+		}
+	default:
+		stage := certificatesmanagement.VersionStageCurrent
+		if q.Get("stage") != "" {
+			stage = certificatesmanagement.VersionStageEnum(q.Get("stage"))
+		}
+		for _, candidate := range c.versions {
+			if hasStage(candidate.stages, stage) {
+				v = candidate
+			}
+		}
+	}
+	if v == nil {
+		writeNotFound(w, "certificate version of", c.id)
+		return
+	}
+
+	validity := &certificates.Validity{
+		TimeOfValidityNotBefore: &common.SDKTime{Time: v.cert.NotBefore},
+		TimeOfValidityNotAfter:  &common.SDKTime{Time: v.cert.NotAfter},
+	}
+	stages := make([]certificates.VersionStageEnum, len(v.stages))
+	for i, stage := range v.stages {
+		stages[i] = certificates.VersionStageEnum(stage)
+	}
+	var revocation *certificates.RevocationStatus
+	if v.revocation != nil {
+		revocation = &certificates.RevocationStatus{
+			TimeRevoked:      v.revocation.TimeOfRevocation,
+			RevocationReason: certificates.RevocationReasonEnum(v.revocation.RevocationReason),
+		}
+	}
+
+	switch q.Get("certificateBundleType") {
+	case "", string(certificates.GetCertificateBundleCertificateBundleTypePublicOnly):
+		writeJSON(w, http.StatusOK, certificates.CertificateBundlePublicOnly{
+			CertificateId:    common.String(c.id),
+			CertificateName:  common.String(c.name),
+			VersionNumber:    common.Int64(v.number),
+			SerialNumber:     common.String(formatSerial(v.cert.SerialNumber)),
+			TimeCreated:      &common.SDKTime{Time: v.timeCreated},
+			Validity:         validity,
+			CertificatePem:   common.String(v.certPEM),
+			CertChainPem:     common.String(c.chain(v)),
+			VersionName:      versionName(v),
+			RevocationStatus: revocation,
+			Stages:           stages,
+		})
+	case string(certificates.GetCertificateBundleCertificateBundleTypeWithPrivateKey):
+		if s.keysDenied[c.id] {
+			writeNotAuthorized(w, c.compartmentID)
+			return
+		}
+		if v.keyPEM == "" {
+			writeError(w, http.StatusBadRequest, "InvalidParameter",
+				fmt.Sprintf("certificate %s is %s and has no private key", c.id, c.configType))
+			return
+		}
+		writeJSON(w, http.StatusOK, certificates.CertificateBundleWithPrivateKey{
+			CertificateId:    common.String(c.id),
+			CertificateName:  common.String(c.name),
+			VersionNumber:    common.Int64(v.number),
+			SerialNumber:     common.String(formatSerial(v.cert.SerialNumber)),
+			TimeCreated:      &common.SDKTime{Time: v.timeCreated},
+			Validity:         validity,
+			PrivateKeyPem:    common.String(v.keyPEM),
+			CertificatePem:   common.String(v.certPEM),
+			CertChainPem:     common.String(c.chain(v)),
+			VersionName:      versionName(v),
+			RevocationStatus: revocation,
+			Stages:           stages,
+		})
+	default:
+		writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("unknown bundle type %q", q.Get("certificateBundleType")))
+	}
+}
+
+// lookupVersion resolves the certificate and version path parameters, writing
+// an error when either does not exist. Callers hold mu.
+func (s *Server) lookupVersion(w http.ResponseWriter, params []string) (*certificate, *version, bool) {
+	c, ok := s.certificates[params[0]]
+	if !ok {
+		writeNotFound(w, "certificate", params[0])
+		return nil, nil, false
+	}
+	n, err := strconv.ParseInt(params[1], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("invalid version number %q", params[1]))
+		return nil, nil, false
+	}
+	v := c.version(n)
+	if v == nil {
+		writeNotFound(w, "certificate version", params[1])
+		return nil, nil, false
+	}
+	return c, v, true
+}
+
+// issuerOf returns the CA with the given OCID. Callers hold mu.
+func (s *Server) issuerOf(id *string) (*authority, int, error) {
+	a, ok := s.authorities[stringValue(id)]
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("certificate authority %s not found", stringValue(id))
+	}
+	if a.state != certificatesmanagement.CertificateAuthorityLifecycleStateActive {
+		return nil, http.StatusConflict, fmt.Errorf("certificate authority %s is %s", a.id, a.state)
+	}
+	return a, 0, nil
+}
+
+// versionFromCSR issues a version of c for a CSR. Callers hold mu.
+func (s *Server) versionFromCSR(c *certificate, csrPEM string, name *string, validity *certificatesmanagement.Validity) (*version, int, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, http.StatusBadRequest, fmt.Errorf("csrPem is not a PEM encoded certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid certificate request: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid certificate request signature: %v", err)
+	}
+	if !supportedKey(csr.PublicKey) {
+		// OCI does not say what is wrong with the key.
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid certificate request")
+	}
+
+	tmpl := &x509.Certificate{
+		Subject:        csr.Subject,
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		EmailAddresses: csr.EmailAddresses,
+		URIs:           csr.URIs,
+		KeyUsage:       keyUsage(csr.PublicKey),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	// Requested extensions, such as extended key usages, are honoured.
+	for _, ext := range csr.Extensions {
+		if ext.Id.Equal(oidExtensionSubjectAltName) {
+			continue
+		}
+		if ext.Id.Equal(oidExtensionExtendedKeyUsage) {
+			tmpl.ExtKeyUsage = nil
+		}
+		tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, ext)
+	}
+	return s.newVersion(c, tmpl, csr.PublicKey, "", name, validity)
+}
+
+// versionWithKey issues a version of c with a key generated by the fake.
+// Callers hold mu.
+func (s *Server) versionWithKey(c *certificate, sans []certificatesmanagement.CertificateSubjectAlternativeName, name *string, validity *certificatesmanagement.Validity) (*version, int, error) {
+	key, err := generateKey(c.keyAlgorithm)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	tmpl := &x509.Certificate{
+		Subject:     subjectName(c.subject),
+		KeyUsage:    keyUsage(key.Public()),
+		ExtKeyUsage: profileKeyUsages[c.profileType],
+	}
+	for _, san := range sans {
+		switch san.Type {
+		case certificatesmanagement.CertificateSubjectAlternativeNameTypeDns:
+			tmpl.DNSNames = append(tmpl.DNSNames, stringValue(san.Value))
+		case certificatesmanagement.CertificateSubjectAlternativeNameTypeIp:
+			ip := net.ParseIP(stringValue(san.Value))
+			if ip == nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("invalid IP address %q", stringValue(san.Value))
+			}
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		}
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	return s.newVersion(c, tmpl, key.Public(), keyPEM, name, validity)
+}
+
+// versionFromImport checks an imported certificate, chain and private key and
+// returns them as a version of c. Callers hold mu.
+func versionFromImport(c *certificate, certPEM, chainPEM, keyPEM string, name *string, now time.Time) (*version, int, error) {
+	if err := checkVersionName(c, name); err != nil {
+		return nil, http.StatusConflict, err
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, http.StatusBadRequest, fmt.Errorf("certificatePem holds no certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid certificate: %v", err)
+	}
+	if chain, _ := pem.Decode([]byte(chainPEM)); chain == nil || chain.Type != "CERTIFICATE" {
+		return nil, http.StatusBadRequest, fmt.Errorf("certChainPem holds no certificate")
+	}
+	key, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(cert.PublicKey) {
+		return nil, http.StatusBadRequest, fmt.Errorf("private key does not match the certificate")
+	}
+	return &version{
+		name:        stringValue(name),
+		cert:        cert,
+		certPEM:     certPEM,
+		keyPEM:      keyPEM,
+		chainPEM:    chainPEM,
+		timeCreated: now,
+	}, 0, nil
+}
+
+// parsePrivateKey parses a PEM encoded PKCS #8, PKCS #1 or SEC 1 private key.
+func parsePrivateKey(keyPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("privateKeyPem holds no private key")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", key)
+	}
+	return signer, nil
+}
+
+// checkVersionName fails when c already has a version named name.
+func checkVersionName(c *certificate, name *string) error {
+	if name == nil {
+		return nil
+	}
+	for _, v := range c.versions {
+		if v.name == *name {
+			return fmt.Errorf("certificate version %q already exists", *name)
+		}
+	}
+	return nil
+}
+
+// newVersion signs tmpl with the issuer of c. Callers hold mu.
+func (s *Server) newVersion(c *certificate, tmpl *x509.Certificate, pub crypto.PublicKey, keyPEM string, name *string, validity *certificatesmanagement.Validity) (*version, int, error) {
+	if err := checkVersionName(c, name); err != nil {
+		return nil, http.StatusConflict, err
+	}
+	now := s.opts.Now()
+	tmpl.NotBefore = now
+	tmpl.NotAfter = now.Add(DefaultCertificateValidity)
+	if validity != nil {
+		if validity.TimeOfValidityNotBefore != nil {
+			tmpl.NotBefore = validity.TimeOfValidityNotBefore.Time
+		}
+		if validity.TimeOfValidityNotAfter != nil {
+			tmpl.NotAfter = validity.TimeOfValidityNotAfter.Time
+		}
+	}
+	if max := c.issuer.leafMaxValidity(); max > 0 && tmpl.NotAfter.Sub(tmpl.NotBefore) > max {
+		return nil, http.StatusBadRequest, fmt.Errorf("validity exceeds the certificate authority maximum of %s",
+			*c.issuer.expiryRule.LeafCertificateMaxValidityDuration)
+	}
+	cert, certPEM, err := s.issue(c.issuer, tmpl, pub)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return &version{
+		name:        stringValue(name),
+		cert:        cert,
+		certPEM:     certPEM,
+		keyPEM:      keyPEM,
+		timeCreated: now,
+	}, 0, nil
+}
+
+// chain returns the chain of v, a version of c.
+func (c *certificate) chain(v *version) string {
+	if v.chainPEM != "" {
+		return v.chainPEM
+	}
+	return c.issuer.chain()
+}
+
+// current returns the CURRENT version of c.
+func (c *certificate) current() *version {
+	for _, v := range c.versions {
+		if hasStage(v.stages, certificatesmanagement.VersionStageCurrent) {
+			return v
+		}
+	}
+	return c.versions[len(c.versions)-1]
+}
+
+func (c *certificate) version(n int64) *version {
+	for _, v := range c.versions {
+		if v.number == n {
+			return v
+		}
+	}
+	return nil
+}
+
+// promote makes v the CURRENT version, moving the previous one to PREVIOUS.
+func (c *certificate) promote(v *version) {
+	for _, old := range c.versions {
+		old.stages = removeStage(old.stages, certificatesmanagement.VersionStagePrevious)
+		if old != v && hasStage(old.stages, certificatesmanagement.VersionStageCurrent) {
+			old.stages = append(removeStage(old.stages, certificatesmanagement.VersionStageCurrent), certificatesmanagement.VersionStagePrevious)
+		}
+	}
+	v.stages = append(removeStage(removeStage(v.stages, certificatesmanagement.VersionStagePending), certificatesmanagement.VersionStageCurrent),
+		certificatesmanagement.VersionStageCurrent)
+}
+
+func (c *certificate) model() certificatesmanagement.Certificate {
+	summary := c.summary()
+	return certificatesmanagement.Certificate{
+		Id:                           summary.Id,
+		Name:                         summary.Name,
+		TimeCreated:                  summary.TimeCreated,
+		LifecycleState:               summary.LifecycleState,
+		CompartmentId:                summary.CompartmentId,
+		ConfigType:                   summary.ConfigType,
+		IssuerCertificateAuthorityId: summary.IssuerCertificateAuthorityId,
+		Description:                  summary.Description,
+		TimeOfDeletion:               summary.TimeOfDeletion,
+		CurrentVersion:               summary.CurrentVersionSummary,
+		Subject:                      summary.Subject,
+		KeyAlgorithm:                 summary.KeyAlgorithm,
+		SignatureAlgorithm:           summary.SignatureAlgorithm,
+		CertificateProfileType:       summary.CertificateProfileType,
+		FreeformTags:                 summary.FreeformTags,
+	}
+}
+
+func (c *certificate) summary() certificatesmanagement.CertificateSummary {
+	current := c.current()
+	summary := certificatesmanagement.CertificateSummary{
+		Id:                     common.String(c.id),
+		Name:                   common.String(c.name),
+		TimeCreated:            &common.SDKTime{Time: c.timeCreated},
+		LifecycleState:         c.state,
+		CompartmentId:          common.String(c.compartmentID),
+		ConfigType:             c.configType,
+		CurrentVersionSummary:  current.summaryPtr(c),
+		Subject:                c.subject,
+		KeyAlgorithm:           c.keyAlgorithm,
+		SignatureAlgorithm:     certificatesmanagement.SignatureAlgorithmSha256WithEcdsa,
+		CertificateProfileType: c.profileType,
+		FreeformTags:           c.tags,
+	}
+	if c.issuer != nil {
+		summary.IssuerCertificateAuthorityId = common.String(c.issuer.id)
+	}
+	if c.description != "" {
+		summary.Description = common.String(c.description)
+	}
+	if c.deletion != nil {
+		summary.TimeOfDeletion = &common.SDKTime{Time: *c.deletion}
+	}
+	if summary.Subject == nil {
+		summary.Subject = &certificatesmanagement.CertificateSubject{CommonName: common.String(current.cert.Subject.CommonName)}
+	}
+	return summary
+}
+
+func (v *version) summary(c *certificate) certificatesmanagement.CertificateVersionSummary {
+	return certificatesmanagement.CertificateVersionSummary{
+		CertificateId:           common.String(c.id),
+		TimeCreated:             &common.SDKTime{Time: v.timeCreated},
+		VersionNumber:           common.Int64(v.number),
+		Stages:                  append([]certificatesmanagement.VersionStageEnum(nil), v.stages...),
+		SerialNumber:            common.String(formatSerial(v.cert.SerialNumber)),
+		IssuerCaVersionNumber:   common.Int64(1),
+		VersionName:             versionName(v),
+		SubjectAlternativeNames: v.sans(),
+		Validity: &certificatesmanagement.Validity{
+			TimeOfValidityNotBefore: &common.SDKTime{Time: v.cert.NotBefore},
+			TimeOfValidityNotAfter:  &common.SDKTime{Time: v.cert.NotAfter},
+		},
+		RevocationStatus: v.revocation,
+	}
+}
+
+func (v *version) summaryPtr(c *certificate) *certificatesmanagement.CertificateVersionSummary {
+	summary := v.summary(c)
+	return &summary
+}
+
+func (v *version) sans() []certificatesmanagement.CertificateSubjectAlternativeName {
+	var sans []certificatesmanagement.CertificateSubjectAlternativeName
+	for _, name := range v.cert.DNSNames {
+		sans = append(sans, certificatesmanagement.CertificateSubjectAlternativeName{
+			Type:  certificatesmanagement.CertificateSubjectAlternativeNameTypeDns,
+			Value: common.String(name),
+		})
+	}
+	for _, ip := range v.cert.IPAddresses {
+		sans = append(sans, certificatesmanagement.CertificateSubjectAlternativeName{
+			Type:  certificatesmanagement.CertificateSubjectAlternativeNameTypeIp,
+			Value: common.String(ip.String()),
+		})
+	}
+	return sans
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func versionName(v *version) *string {
+	if v.name == "" {
+		return nil
+	}
+	return common.String(v.name)
+}
+
+// profileKeyUsages maps certificate profiles to the extended key usages of
+// certificates issued with them.
+var profileKeyUsages = map[certificatesmanagement.CertificateProfileTypeEnum][]x509.ExtKeyUsage{
+	certificatesmanagement.CertificateProfileTypeTlsServerOrClient: {x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	certificatesmanagement.CertificateProfileTypeTlsServer:         {x509.ExtKeyUsageServerAuth},
+	certificatesmanagement.CertificateProfileTypeTlsClient:         {x509.ExtKeyUsageClientAuth},
+	certificatesmanagement.CertificateProfileTypeTlsCodeSign:       {x509.ExtKeyUsageCodeSigning},
+}
+
+func keyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// supportedKey reports whether pub is of one of the OCI key algorithms.
+func supportedKey(pub crypto.PublicKey) bool {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return pub.N.BitLen() == 2048 || pub.N.BitLen() == 4096
+	case *ecdsa.PublicKey:
+		return pub.Curve == elliptic.P256() || pub.Curve == elliptic.P384()
+	}
+	return false
+}
+
+func generateKey(algorithm certificatesmanagement.KeyAlgorithmEnum) (crypto.Signer, error) {
+	switch algorithm {
+	case certificatesmanagement.KeyAlgorithmRsa2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case certificatesmanagement.KeyAlgorithmRsa4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case certificatesmanagement.KeyAlgorithmEcdsaP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case certificatesmanagement.KeyAlgorithmEcdsaP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	}
+	return nil, fmt.Errorf("unsupported key algorithm %q", algorithm)
+}
+
+func subjectName(subject *certificatesmanagement.CertificateSubject) pkix.Name {
+	if subject == nil {
+		return pkix.Name{}
+	}
+	name := pkix.Name{CommonName: stringValue(subject.CommonName)}
+	if subject.Organization != nil {
+		name.Organization = []string{*subject.Organization}
+	}
+	if subject.OrganizationalUnit != nil {
+		name.OrganizationalUnit = []string{*subject.OrganizationalUnit}
+	}
+	if subject.Country != nil {
+		name.Country = []string{*subject.Country}
+	}
+	if subject.LocalityName != nil {
+		name.Locality = []string{*subject.LocalityName}
+	}
+	if subject.StateOrProvinceName != nil {
+		name.Province = []string{*subject.StateOrProvinceName}
+	}
+	return name
+}
+
+func hasStage(stages []certificatesmanagement.VersionStageEnum, stage certificatesmanagement.VersionStageEnum) bool {
+	for _, s := range stages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+func removeStage(stages []certificatesmanagement.VersionStageEnum, stage certificatesmanagement.VersionStageEnum) []certificatesmanagement.VersionStageEnum {
+	out := stages[:0]
+	for _, s := range stages {
+		if s != stage {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "InvalidParameter"
+	case http.StatusNotFound:
+		return "NotAuthorizedOrNotFound"
+	case http.StatusConflict:
+		return "IncorrectState"
+	}
+	return "InternalServerError"
+}
@@ -0,0 +1,27 @@
+package ocifake
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/oracle/oci-go-sdk/v65/common"
+)
+
+// ConfigurationProvider returns throwaway API key credentials for clients of
+// the fake. The fake only checks that requests are signed, not by whom.
+func (s *Server) ConfigurationProvider() (common.ConfigurationProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return common.NewRawConfigurationProvider(
+		"ocid1.tenancy."+s.opts.Realm+"..ocifake",
+		"ocid1.user."+s.opts.Realm+"..ocifake",
+		string(common.StringToRegion(s.opts.Region)),
+		"00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00",
+		string(keyPEM),
+		nil,
+	), nil
+}
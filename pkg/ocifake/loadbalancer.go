@@ -0,0 +1,247 @@
+package ocifake
+
+import (
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	"net/http"
+	"time"
+)
+
+// loadBalancer is a load balancer and its listeners.
+type loadBalancer struct {
+	id            string
+	name          string
+	compartmentID string
+	listeners     map[string]loadbalancer.Listener
+	timeCreated   time.Time
+}
+
+// workRequest is an asynchronous listener update. Updates are applied when
+// the work request is first polled, so that callers see it in progress until
+// they poll it.
+type workRequest struct {
+	id             string
+	loadBalancerID string
+	compartmentID  string
+	listener       string
+	details        loadbalancer.UpdateListenerDetails
+	state          loadbalancer.WorkRequestLifecycleStateEnum
+	message        string
+	errors         []loadbalancer.WorkRequestError
+	timeAccepted   time.Time
+	timeFinished   time.Time
+}
+
+// CreateLoadBalancer adds an active load balancer with listeners and returns
+// its OCID.
+func (s *Server) CreateLoadBalancer(compartmentID, name string, listeners ...loadbalancer.Listener) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lb := &loadBalancer{
+		id:            s.newOCID("loadbalancer"),
+		name:          name,
+		compartmentID: compartmentID,
+		listeners:     map[string]loadbalancer.Listener{},
+		timeCreated:   s.opts.Now(),
+	}
+	for _, l := range listeners {
+		lb.listeners[stringValue(l.Name)] = l
+	}
+	s.loadBalancers[lb.id] = lb
+	return lb.id
+}
+
+// LoadBalancerListener returns a listener of a load balancer.
+func (s *Server) LoadBalancerListener(loadBalancerID, name string) (loadbalancer.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lb, ok := s.loadBalancers[loadBalancerID]
+	if !ok {
+		return loadbalancer.Listener{}, fmt.Errorf("unknown load balancer %s", loadBalancerID)
+	}
+	l, ok := lb.listeners[name]
+	if !ok {
+		return loadbalancer.Listener{}, fmt.Errorf("load balancer %s has no listener %s", loadBalancerID, name)
+	}
+	return l, nil
+}
+
+// RejectCertificate makes the work requests of listener updates to a
+// certificate fail, as they do when OCI cannot deploy the certificate to the
+// load balancer.
+func (s *Server) RejectCertificate(certificateID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[certificateID] = true
+}
+
+func (s *Server) loadBalancerRoutes() []pattern {
+	return []pattern{
+		op(http.MethodGet, "loadBalancers/*", "GetLoadBalancer", s.getLoadBalancer),
+		op(http.MethodPut, "loadBalancers/*/listeners/*", "UpdateListener", s.updateListener),
+		op(http.MethodGet, "loadBalancerWorkRequests/*", "GetWorkRequest", s.getWorkRequest),
+	}
+}
+
+func (s *Server) getLoadBalancer(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lb, ok := s.loadBalancers[params[0]]
+	if !ok {
+		writeNotFound(w, "load balancer", params[0])
+		return
+	}
+	if s.denied[lb.compartmentID] {
+		writeNotAuthorized(w, lb.compartmentID)
+		return
+	}
+	writeJSON(w, http.StatusOK, loadbalancer.LoadBalancer{
+		Id:             common.String(lb.id),
+		CompartmentId:  common.String(lb.compartmentID),
+		DisplayName:    common.String(lb.name),
+		LifecycleState: loadbalancer.LoadBalancerLifecycleStateActive,
+		TimeCreated:    &common.SDKTime{Time: lb.timeCreated},
+		ShapeName:      common.String("flexible"),
+		Listeners:      lb.listeners,
+	})
+}
+
+func (s *Server) updateListener(w http.ResponseWriter, req *http.Request, params []string) {
+	var details loadbalancer.UpdateListenerDetails
+	if !decodeJSON(w, req, &details) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lb, ok := s.loadBalancers[params[0]]
+	if !ok {
+		writeNotFound(w, "load balancer", params[0])
+		return
+	}
+	if s.denied[lb.compartmentID] {
+		writeNotAuthorized(w, lb.compartmentID)
+		return
+	}
+	if _, ok := lb.listeners[params[1]]; !ok {
+		writeNotFound(w, "listener", params[1])
+		return
+	}
+	if details.DefaultBackendSetName == nil || details.Port == nil || details.Protocol == nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", "defaultBackendSetName, port and protocol are required")
+		return
+	}
+	if ssl := details.SslConfiguration; ssl != nil {
+		if len(ssl.CertificateIds) > 1 {
+			writeError(w, http.StatusBadRequest, "InvalidParameter", "only one certificate ID may be given")
+			return
+		}
+		for _, id := range ssl.CertificateIds {
+			if _, ok := s.certificates[id]; !ok {
+				writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("certificate %s not found", id))
+				return
+			}
+		}
+	}
+	wr := &workRequest{
+		id:             s.newOCID("loadbalancerworkrequest"),
+		loadBalancerID: lb.id,
+		compartmentID:  lb.compartmentID,
+		listener:       params[1],
+		details:        details,
+		state:          loadbalancer.WorkRequestLifecycleStateInProgress,
+		message:        "update listener in progress",
+		timeAccepted:   s.opts.Now(),
+	}
+	s.workRequests[wr.id] = wr
+	w.Header().Set("opc-work-request-id", wr.id)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (s *Server) getWorkRequest(w http.ResponseWriter, _ *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wr, ok := s.workRequests[params[0]]
+	if !ok {
+		writeNotFound(w, "work request", params[0])
+		return
+	}
+	if wr.state == loadbalancer.WorkRequestLifecycleStateInProgress {
+		s.finish(wr)
+	}
+	res := loadbalancer.WorkRequest{
+		Id:             common.String(wr.id),
+		LoadBalancerId: common.String(wr.loadBalancerID),
+		Type:           common.String("UpdateListener"),
+		LifecycleState: wr.state,
+		Message:        common.String(wr.message),
+		TimeAccepted:   &common.SDKTime{Time: wr.timeAccepted},
+		ErrorDetails:   wr.errors,
+		CompartmentId:  common.String(wr.compartmentID),
+	}
+	if res.ErrorDetails == nil {
+		res.ErrorDetails = []loadbalancer.WorkRequestError{}
+	}
+	if !wr.timeFinished.IsZero() {
+		res.TimeFinished = &common.SDKTime{Time: wr.timeFinished}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// finish applies the listener update of wr, or fails it when it deploys a
+// rejected certificate. Callers hold mu.
+func (s *Server) finish(wr *workRequest) {
+	wr.timeFinished = s.opts.Now()
+	lb, ok := s.loadBalancers[wr.loadBalancerID]
+	if !ok {
+		s.fail(wr, loadbalancer.WorkRequestErrorErrorCodeInternalError, "load balancer was deleted")
+		return
+	}
+	d := wr.details
+	if d.SslConfiguration != nil {
+		for _, id := range d.SslConfiguration.CertificateIds {
+			if s.rejected[id] {
+				s.fail(wr, loadbalancer.WorkRequestErrorErrorCodeBadInput, fmt.Sprintf("certificate %s cannot be deployed", id))
+				return
+			}
+		}
+	}
+	l := lb.listeners[wr.listener]
+	l.DefaultBackendSetName = d.DefaultBackendSetName
+	l.Port = d.Port
+	l.Protocol = d.Protocol
+	l.HostnameNames = d.HostnameNames
+	l.PathRouteSetName = d.PathRouteSetName
+	l.RoutingPolicyName = d.RoutingPolicyName
+	l.ConnectionConfiguration = d.ConnectionConfiguration
+	l.RuleSetNames = d.RuleSetNames
+	l.SslConfiguration = nil
+	if ssl := d.SslConfiguration; ssl != nil {
+		l.SslConfiguration = &loadbalancer.SslConfiguration{
+			VerifyDepth:                    ssl.VerifyDepth,
+			VerifyPeerCertificate:          ssl.VerifyPeerCertificate,
+			TrustedCertificateAuthorityIds: ssl.TrustedCertificateAuthorityIds,
+			CertificateIds:                 ssl.CertificateIds,
+			CertificateName:                ssl.CertificateName,
+			ServerOrderPreference:          loadbalancer.SslConfigurationServerOrderPreferenceEnum(ssl.ServerOrderPreference),
+			CipherSuiteName:                ssl.CipherSuiteName,
+			Protocols:                      ssl.Protocols,
+		}
+		if l.SslConfiguration.VerifyDepth == nil {
+			l.SslConfiguration.VerifyDepth = common.Int(1)
+		}
+		if l.SslConfiguration.VerifyPeerCertificate == nil {
+			l.SslConfiguration.VerifyPeerCertificate = common.Bool(false)
+		}
+	}
+	lb.listeners[wr.listener] = l
+	wr.state = loadbalancer.WorkRequestLifecycleStateSucceeded
+	wr.message = "update listener succeeded"
+}
+
+// fail fails wr with an error. Callers hold mu.
+func (s *Server) fail(wr *workRequest, code loadbalancer.WorkRequestErrorErrorCodeEnum, message string) {
+	wr.state = loadbalancer.WorkRequestLifecycleStateFailed
+	wr.message = "update listener failed"
+	wr.errors = []loadbalancer.WorkRequestError{{ErrorCode: code, Message: common.String(message)}}
+}
@@ -0,0 +1,392 @@
+// Package ocifake is an in-process fake of the OCI Certificates, Certificates
+// Management and Load Balancer services.
+//
+// It implements the subset of the REST APIs used by the issuer, signs
+// certificates with an in-memory CA hierarchy and models lifecycle states,
+// certificate versions and revocations, and load balancer listeners updated
+// through work requests. All services are served from the same base URL so
+// one URL serves every endpoint override of an issuer.
+//
+//	srv := ocifake.NewServer(ocifake.Options{})
+//	defer srv.Close()
+//	ca, _ := srv.CreateRootCA("ocid1.compartment.oc1..aaaa", "root")
+package ocifake
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// BasePath is the API version prefix shared by the certificate
+	// services.
+	BasePath = "/20210224"
+	// LoadBalancerBasePath is the API version prefix of the Load Balancer
+	// service.
+	LoadBalancerBasePath = "/20170115"
+
+	// DefaultRealm is the realm of generated OCIDs.
+	DefaultRealm = "oc1"
+	// DefaultRegion is the region key of generated OCIDs.
+	DefaultRegion = "phx"
+	// DefaultPageSize is the page size of list operations without a limit.
+	DefaultPageSize = 50
+)
+
+// Options configures a Server.
+type Options struct {
+	// Realm and Region are encoded in the OCIDs of created resources.
+	Realm  string
+	Region string
+
+	// TLS serves HTTPS with a self-signed certificate, see Server.Certificate.
+	TLS bool
+
+	// Addr is the address to listen on. Defaults to a random local port.
+	Addr string
+
+	// PageSize is the page size of list operations without a limit.
+	PageSize int
+
+	// Now returns the current time, used for validity periods and lifecycle
+	// timestamps.
+	Now func() time.Time
+}
+
+func (o Options) withDefaults() Options {
+	if o.Realm == "" {
+		o.Realm = DefaultRealm
+	}
+	if o.Region == "" {
+		o.Region = DefaultRegion
+	}
+	if o.PageSize == 0 {
+		o.PageSize = DefaultPageSize
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	return o
+}
+
+// Server is a running fake of the OCI certificate and load balancer services.
+type Server struct {
+	*httptest.Server
+	opts Options
+
+	mu           sync.Mutex
+	authorities  map[string]*authority
+	certificates map[string]*certificate
+	// order keeps creation order so list operations are stable.
+	order  []string
+	faults []*Fault
+	calls  map[string]int
+	// denied are compartments the caller has no access to.
+	denied map[string]bool
+	// keysDenied are certificates whose private keys the caller may not
+	// read.
+	keysDenied map[string]bool
+
+	loadBalancers map[string]*loadBalancer
+	workRequests  map[string]*workRequest
+	// rejected are certificates listener updates fail to deploy.
+	rejected map[string]bool
+}
+
+// NewServer starts a fake. Close it when done.
+func NewServer(opts Options) *Server {
+	s := &Server{
+		opts:         opts.withDefaults(),
+		authorities:  map[string]*authority{},
+		certificates: map[string]*certificate{},
+		calls:        map[string]int{},
+		denied:       map[string]bool{},
+		keysDenied:   map[string]bool{},
+
+		loadBalancers: map[string]*loadBalancer{},
+		workRequests:  map[string]*workRequest{},
+		rejected:      map[string]bool{},
+	}
+	s.Server = httptest.NewUnstartedServer(s)
+	if opts.Addr != "" {
+		l, err := net.Listen("tcp", opts.Addr)
+		if err != nil {
+			panic(fmt.Sprintf("ocifake: failed to listen on %s: %v", opts.Addr, err))
+		}
+		_ = s.Server.Listener.Close()
+		s.Server.Listener = l
+	}
+	if opts.TLS {
+		s.Server.StartTLS()
+	} else {
+		s.Server.Start()
+	}
+	return s
+}
+
+// Calls returns how often an operation, named after the SDK client method,
+// was called.
+func (s *Server) Calls(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[operation]
+}
+
+// DenyCompartment makes certificate operations in a compartment fail with
+// 404 NotAuthorizedOrNotFound, as OCI does when the caller's policies do not
+// cover it.
+func (s *Server) DenyCompartment(compartmentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[compartmentID] = true
+}
+
+// DenyPrivateKey makes bundles of a certificate with its private key fail
+// with 404 NotAuthorizedOrNotFound, as OCI does when the caller's policies
+// only let it read the public bundle.
+func (s *Server) DenyPrivateKey(certificateID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keysDenied[certificateID] = true
+}
+
+// writeNotAuthorized writes the error OCI returns for compartments the
+// caller has no access to.
+func writeNotAuthorized(w http.ResponseWriter, compartmentID string) {
+	writeError(w, http.StatusNotFound, "NotAuthorizedOrNotFound",
+		fmt.Sprintf("Authorization failed or requested resource not found for compartment %s", compartmentID))
+}
+
+// Fault makes matching requests fail with an OCI service error.
+type Fault struct {
+	// Operation is the SDK client method to fail, for example
+	// "CreateCertificate". Empty matches every operation.
+	Operation string
+	// Status is the HTTP status code returned.
+	Status int
+	// Code is the OCI error code, for example "TooManyRequests".
+	Code    string
+	Message string
+	// Times is how many requests fail before the fault is removed. Zero
+	// fails requests until ClearFaults is called.
+	Times int
+}
+
+// InjectFault adds a fault. Faults are matched in the order they were added.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.Status == 0 {
+		f.Status = http.StatusInternalServerError
+	}
+	if f.Code == "" {
+		f.Code = http.StatusText(f.Status)
+	}
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// fault returns the fault matching operation, if any. Callers hold mu.
+func (s *Server) fault(operation string) *Fault {
+	for i, f := range s.faults {
+		if f.Operation != "" && f.Operation != operation {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// route is an operation of the fake and its handler.
+type route struct {
+	operation string
+	handle    func(w http.ResponseWriter, req *http.Request, params []string)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if strings.HasPrefix(req.URL.Path, crlPath) {
+		s.serveCRL(w, req)
+		return
+	}
+	var r *route
+	var params []string
+	switch {
+	case strings.HasPrefix(req.URL.Path, BasePath+"/"):
+		r, params = s.match(s.routes(), req.Method, strings.Split(strings.TrimPrefix(req.URL.Path, BasePath+"/"), "/"))
+	case strings.HasPrefix(req.URL.Path, LoadBalancerBasePath+"/"):
+		r, params = s.match(s.loadBalancerRoutes(), req.Method, strings.Split(strings.TrimPrefix(req.URL.Path, LoadBalancerBasePath+"/"), "/"))
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "unknown API version")
+		return
+	}
+	if r == nil {
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("no operation %s %s", req.Method, req.URL.Path))
+		return
+	}
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "Signature ") {
+		writeError(w, http.StatusUnauthorized, "NotAuthenticated", "the request is not signed")
+		return
+	}
+
+	s.mu.Lock()
+	s.calls[r.operation]++
+	f := s.fault(r.operation)
+	s.mu.Unlock()
+	if f != nil {
+		writeError(w, f.Status, f.Code, f.Message)
+		return
+	}
+	r.handle(w, req, params)
+}
+
+// match finds the route of routes for a request path split into segments,
+// returning the path parameters in order.
+func (s *Server) match(routes []pattern, method string, segments []string) (*route, []string) {
+	for _, r := range routes {
+		if r.method != method || len(r.segments) != len(segments) {
+			continue
+		}
+		var params []string
+		matched := true
+		for i, seg := range r.segments {
+			if seg == "*" {
+				params = append(params, segments[i])
+				continue
+			}
+			if seg != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return &r.route, params
+		}
+	}
+	return nil, nil
+}
+
+type pattern struct {
+	method   string
+	segments []string
+	route
+}
+
+// op returns the pattern of an operation on path, with * matching a path
+// parameter.
+func op(method, path, operation string, handle func(http.ResponseWriter, *http.Request, []string)) pattern {
+	return pattern{method: method, segments: strings.Split(path, "/"), route: route{operation: operation, handle: handle}}
+}
+
+func (s *Server) routes() []pattern {
+	return []pattern{
+		// Certificates Management
+		op(http.MethodGet, "certificateAuthorities", "ListCertificateAuthorities", s.listCertificateAuthorities),
+		op(http.MethodGet, "certificateAuthorities/*", "GetCertificateAuthority", s.getCertificateAuthority),
+		op(http.MethodPost, "certificates", "CreateCertificate", s.createCertificate),
+		op(http.MethodGet, "certificates", "ListCertificates", s.listCertificates),
+		op(http.MethodGet, "certificates/*", "GetCertificate", s.getCertificate),
+		op(http.MethodPut, "certificates/*", "UpdateCertificate", s.updateCertificate),
+		op(http.MethodPost, "certificates/*/actions/scheduleDeletion", "ScheduleCertificateDeletion", s.scheduleCertificateDeletion),
+		op(http.MethodPost, "certificates/*/actions/cancelDeletion", "CancelCertificateDeletion", s.cancelCertificateDeletion),
+		op(http.MethodGet, "certificates/*/versions", "ListCertificateVersions", s.listCertificateVersions),
+		op(http.MethodGet, "certificates/*/version/*", "GetCertificateVersion", s.getCertificateVersion),
+		op(http.MethodPost, "certificates/*/version/*/actions/revoke", "RevokeCertificateVersion", s.revokeCertificateVersion),
+		// Certificates
+		op(http.MethodGet, "certificateBundles/*", "GetCertificateBundle", s.getCertificateBundle),
+		op(http.MethodGet, "certificateAuthorityBundles/*", "GetCertificateAuthorityBundle", s.getCertificateAuthorityBundle),
+	}
+}
+
+// newOCID returns a random OCID of the given resource type.
+func (s *Server) newOCID(resourceType string) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	unique := make([]byte, 60)
+	for i := range unique {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			panic(err)
+		}
+		unique[i] = alphabet[n.Int64()]
+	}
+	return fmt.Sprintf("ocid1.%s.%s.%s.%s", resourceType, s.opts.Realm, s.opts.Region, unique)
+}
+
+// serviceError is the body of OCI error responses.
+type serviceError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, serviceError{Code: code, Message: message})
+}
+
+func writeNotFound(w http.ResponseWriter, what, id string) {
+	writeError(w, http.StatusNotFound, "NotAuthorizedOrNotFound", fmt.Sprintf("%s %s not found", what, id))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("opc-request-id", fmt.Sprintf("ocifake-%d", time.Now().UnixNano()))
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func decodeJSON(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", fmt.Sprintf("malformed request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// page returns the slice of n items selected by the limit and page query
+// parameters, and the token of the next page.
+func (s *Server) page(req *http.Request, n int) (start, end int, next string) {
+	limit := s.opts.PageSize
+	if v := req.URL.Query().Get("limit"); v != "" {
+		if _, err := fmt.Sscan(v, &limit); err != nil || limit <= 0 {
+			limit = s.opts.PageSize
+		}
+	}
+	if v := req.URL.Query().Get("page"); v != "" {
+		_, _ = fmt.Sscan(v, &start)
+	}
+	if start > n {
+		start = n
+	}
+	end = start + limit
+	if end >= n {
+		return start, n, ""
+	}
+	return start, end, fmt.Sprint(end)
+}
+
+func writePage(w http.ResponseWriter, next string, items interface{}) {
+	if next != "" {
+		w.Header().Set("opc-next-page", next)
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Items interface{} `json:"items"`
+	}{items})
+}
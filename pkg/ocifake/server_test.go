@@ -0,0 +1,636 @@
+package ocifake
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testCompartmentID = "ocid1.compartment.oc1..aaaaaaaaba3pv6wkcr4jqae5f44n2b2m2yt2j6rx32uzr4h25vqstifsfdsq"
+
+type testClients struct {
+	ca    certificatesmanagement.CertificatesManagementClient
+	certs certificates.CertificatesClient
+	lb    loadbalancer.LoadBalancerClient
+}
+
+func newTestServer(t *testing.T) (*Server, testClients) {
+	t.Helper()
+	srv := NewServer(Options{})
+	t.Cleanup(srv.Close)
+	cp, err := srv.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caClient, err := certificatesmanagement.NewCertificatesManagementClientWithConfigurationProvider(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caClient.Host = srv.URL
+	certClient, err := certificates.NewCertificatesClientWithConfigurationProvider(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certClient.Host = srv.URL
+	lbClient, err := loadbalancer.NewLoadBalancerClientWithConfigurationProvider(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lbClient.Host = srv.URL
+	return srv, testClients{ca: caClient, certs: certClient, lb: lbClient}
+}
+
+func testCSR(t *testing.T, cn string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: cn},
+		DNSNames: []string{cn},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func createFromCSR(ctx context.Context, c testClients, caID, name string, csr string) (certificatesmanagement.CreateCertificateResponse, error) {
+	return c.ca.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          common.String(name),
+			CompartmentId: common.String(testCompartmentID),
+			CertificateConfig: certificatesmanagement.CreateCertificateManagedExternallyIssuedByInternalCaConfigDetails{
+				IssuerCertificateAuthorityId: common.String(caID),
+				CsrPem:                       common.String(csr),
+				VersionName:                  common.String(name),
+			},
+		},
+	})
+}
+
+func parsePEMCertificates(t *testing.T, data string) []*x509.Certificate {
+	t.Helper()
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+func TestServer_IssueFromCSR(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	rootID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subID, err := srv.CreateSubordinateCA(rootID, "issuing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := createFromCSR(ctx, c, subID, "example.com", testCSR(t, "example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.LifecycleState != certificatesmanagement.CertificateLifecycleStateActive {
+		t.Errorf("lifecycle state got = %s, want ACTIVE", created.LifecycleState)
+	}
+
+	bundle, err := c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:          created.Id,
+		CertificateVersionName: common.String("example.com"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := parsePEMCertificates(t, *bundle.GetCertificatePem())[0]
+	chain := parsePEMCertificates(t, *bundle.GetCertChainPem())
+	if len(chain) != 2 {
+		t.Fatalf("chain length got = %d, want 2", len(chain))
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(chain[1])
+	intermediates.AddCert(chain[0])
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots, Intermediates: intermediates}); err != nil {
+		t.Errorf("issued certificate does not verify: %v", err)
+	}
+
+	_, err = c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         created.Id,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypeWithPrivateKey,
+	})
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusBadRequest {
+		t.Errorf("private bundle of an externally managed certificate got err = %v, want 400", err)
+	}
+
+	_, err = createFromCSR(ctx, c, subID, "example.com", testCSR(t, "example.com"))
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusConflict {
+		t.Errorf("duplicate name got err = %v, want 409", err)
+	}
+
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"p224.example.com"}}, p224)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = createFromCSR(ctx, c, subID, "p224", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})))
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusBadRequest {
+		t.Errorf("unsupported key got err = %v, want 400", err)
+	}
+}
+
+func TestServer_IssuedByInternalCA(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	rootID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.ca.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          common.String("managed"),
+			CompartmentId: common.String(testCompartmentID),
+			CertificateConfig: certificatesmanagement.CreateCertificateIssuedByInternalCaConfigDetails{
+				IssuerCertificateAuthorityId: common.String(rootID),
+				Subject:                      &certificatesmanagement.CertificateSubject{CommonName: common.String("managed.example.com")},
+				CertificateProfileType:       certificatesmanagement.CertificateProfileTypeTlsServer,
+				KeyAlgorithm:                 certificatesmanagement.KeyAlgorithmEcdsaP256,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         created.Id,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypeWithPrivateKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withKey, ok := bundle.CertificateBundle.(certificates.CertificateBundleWithPrivateKey)
+	if !ok || withKey.PrivateKeyPem == nil {
+		t.Fatalf("bundle got = %T, want one with a private key", bundle.CertificateBundle)
+	}
+	leaf := parsePEMCertificates(t, *withKey.CertificatePem)[0]
+	if len(leaf.ExtKeyUsage) != 1 || leaf.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Errorf("extended key usage got = %v, want server auth", leaf.ExtKeyUsage)
+	}
+
+	number, err := srv.RenewCertificate(*created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         created.Id,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypeWithPrivateKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewedWithKey := renewed.CertificateBundle.(certificates.CertificateBundleWithPrivateKey)
+	if number != 2 || *renewedWithKey.VersionNumber != number {
+		t.Errorf("renewed version got = %d, current %d, want 2", number, *renewedWithKey.VersionNumber)
+	}
+	if *renewedWithKey.PrivateKeyPem == *withKey.PrivateKeyPem {
+		t.Errorf("renewed version kept the private key")
+	}
+
+	srv.DenyPrivateKey(*created.Id)
+	_, err = c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         created.Id,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypeWithPrivateKey,
+	})
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusNotFound {
+		t.Errorf("private bundle with the key denied got err = %v, want 404", err)
+	}
+	if _, err := c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{CertificateId: created.Id}); err != nil {
+		t.Errorf("public bundle with the key denied got err = %v", err)
+	}
+}
+
+func TestServer_VersionsAndRevocation(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	rootID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := createFromCSR(ctx, c, rootID, "rotate", testCSR(t, "rotate.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ca.UpdateCertificate(ctx, certificatesmanagement.UpdateCertificateRequest{
+		CertificateId: created.Id,
+		UpdateCertificateDetails: certificatesmanagement.UpdateCertificateDetails{
+			CertificateConfig: certificatesmanagement.UpdateCertificateManagedExternallyIssuedByInternalCaConfigDetails{
+				CsrPem:      common.String(testCSR(t, "rotate.example.com")),
+				VersionName: common.String("rotate-2"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := c.ca.ListCertificateVersions(ctx, certificatesmanagement.ListCertificateVersionsRequest{CertificateId: created.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions.Items) != 2 {
+		t.Fatalf("versions got = %d, want 2", len(versions.Items))
+	}
+	if !hasStage(versions.Items[0].Stages, certificatesmanagement.VersionStagePrevious) ||
+		!hasStage(versions.Items[1].Stages, certificatesmanagement.VersionStageCurrent) ||
+		!hasStage(versions.Items[1].Stages, certificatesmanagement.VersionStageLatest) {
+		t.Errorf("stages got = %v and %v", versions.Items[0].Stages, versions.Items[1].Stages)
+	}
+
+	_, err = c.ca.RevokeCertificateVersion(ctx, certificatesmanagement.RevokeCertificateVersionRequest{
+		CertificateId:            created.Id,
+		CertificateVersionNumber: common.Int64(1),
+		RevokeCertificateVersionDetails: certificatesmanagement.RevokeCertificateVersionDetails{
+			RevocationReason: certificatesmanagement.RevocationReasonSuperseded,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := c.ca.GetCertificateVersion(ctx, certificatesmanagement.GetCertificateVersionRequest{
+		CertificateId:            created.Id,
+		CertificateVersionNumber: common.Int64(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.RevocationStatus == nil || version.RevocationStatus.RevocationReason != certificatesmanagement.RevocationReasonSuperseded {
+		t.Errorf("revocation status got = %v", version.RevocationStatus)
+	}
+
+	caCert, err := srv.CertificateAuthority(rootID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get(caCert.CRLDistributionPoints[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	der, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(caCert); err != nil {
+		t.Errorf("CRL signature: %v", err)
+	}
+	serial, _ := new(big.Int).SetString(strings.ReplaceAll(*version.SerialNumber, ":", ""), 16)
+	if len(crl.RevokedCertificates) != 1 || crl.RevokedCertificates[0].SerialNumber.Cmp(serial) != 0 {
+		t.Errorf("CRL entries got = %v, want serial %s", crl.RevokedCertificates, *version.SerialNumber)
+	}
+}
+
+func TestServer_ListPagination(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	rootID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := createFromCSR(ctx, c, rootID, name, testCSR(t, name+".example.com")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	var page *string
+	for {
+		res, err := c.ca.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+			CompartmentId:                common.String(testCompartmentID),
+			IssuerCertificateAuthorityId: common.String(rootID),
+			Limit:                        common.Int(2),
+			Page:                         page,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range res.Items {
+			names = append(names, *item.Name)
+		}
+		if res.OpcNextPage == nil {
+			break
+		}
+		page = res.OpcNextPage
+	}
+	if strings.Join(names, ",") != "a,b,c" {
+		t.Errorf("listed names got = %v, want a,b,c", names)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	rootID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.InjectFault(Fault{Operation: "GetCertificateAuthority", Status: http.StatusTooManyRequests, Code: "TooManyRequests", Times: 1})
+
+	noRetry := common.NoRetryPolicy()
+	req := certificatesmanagement.GetCertificateAuthorityRequest{
+		CertificateAuthorityId: common.String(rootID),
+		RequestMetadata:        common.RequestMetadata{RetryPolicy: &noRetry},
+	}
+	_, err = c.ca.GetCertificateAuthority(ctx, req)
+	var serviceErr common.ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.GetCode() != "TooManyRequests" {
+		t.Fatalf("first call got err = %v, want TooManyRequests", err)
+	}
+	res, err := c.ca.GetCertificateAuthority(ctx, req)
+	if err != nil {
+		t.Fatalf("fault should be removed after one request: %v", err)
+	}
+	if *res.Id != rootID {
+		t.Errorf("id got = %s, want %s", *res.Id, rootID)
+	}
+	if got := srv.Calls("GetCertificateAuthority"); got != 2 {
+		t.Errorf("calls got = %d, want 2", got)
+	}
+
+	if err := srv.SetCertificateAuthorityState(rootID, certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion); err != nil {
+		t.Fatal(err)
+	}
+	_, err = createFromCSR(ctx, c, rootID, "pending-deletion", testCSR(t, "pending.example.com"))
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusConflict {
+		t.Errorf("issuing from a CA pending deletion got err = %v, want 409", err)
+	}
+}
+
+func TestServer_Unsigned(t *testing.T) {
+	srv := NewServer(Options{})
+	defer srv.Close()
+	res, err := http.Get(srv.URL + BasePath + "/certificates?compartmentId=" + testCompartmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status got = %d, want 401", res.StatusCode)
+	}
+}
+
+func TestServer_IssuanceExpiryRule(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	caID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.SetIssuanceExpiryRule(caID, certificatesmanagement.CertificateAuthorityIssuanceExpiryRule{
+		LeafCertificateMaxValidityDuration: common.String("P30D"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.ca.GetCertificateAuthority(ctx, certificatesmanagement.GetCertificateAuthorityRequest{CertificateAuthorityId: common.String(caID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.CertificateAuthorityRules) != 1 {
+		t.Fatalf("rules got = %v, want the expiry rule", res.CertificateAuthorityRules)
+	}
+	rule, ok := res.CertificateAuthorityRules[0].(certificatesmanagement.CertificateAuthorityIssuanceExpiryRule)
+	if !ok || rule.LeafCertificateMaxValidityDuration == nil || *rule.LeafCertificateMaxValidityDuration != "P30D" {
+		t.Errorf("rule got = %#v, want a P30D leaf maximum", res.CertificateAuthorityRules[0])
+	}
+
+	// The default validity of 90 days exceeds the rule.
+	_, err = createFromCSR(ctx, c, caID, "too-long", testCSR(t, "example.com"))
+	var serviceErr common.ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.GetHTTPStatusCode() != http.StatusBadRequest {
+		t.Errorf("CreateCertificate() error = %v, want 400", err)
+	}
+}
+
+func TestServer_DenyCompartment(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	rootID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.DenyCompartment(testCompartmentID)
+
+	_, err = createFromCSR(ctx, c, rootID, "denied", testCSR(t, "denied.example.com"))
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusNotFound {
+		t.Errorf("creating in a denied compartment got err = %v, want 404", err)
+	}
+	_, err = c.ca.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{CompartmentId: common.String(testCompartmentID)})
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetCode() != "NotAuthorizedOrNotFound" {
+		t.Errorf("listing a denied compartment got err = %v, want NotAuthorizedOrNotFound", err)
+	}
+	if _, err := c.ca.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{CompartmentId: common.String("ocid1.compartment.oc1..other")}); err != nil {
+		t.Errorf("listing another compartment got err = %v", err)
+	}
+}
+
+// selfSigned returns a PEM encoded self-signed certificate for cn and its
+// PKCS #8 private key.
+func selfSigned(t *testing.T, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: cn}}, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestServer_Import(t *testing.T) {
+	ctx := context.TODO()
+	_, c := newTestServer(t)
+	certPEM, keyPEM := selfSigned(t, "imported.example.com")
+	created, err := c.ca.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          common.String("imported"),
+			CompartmentId: common.String(testCompartmentID),
+			CertificateConfig: certificatesmanagement.CreateCertificateByImportingConfigDetails{
+				CertificatePem: common.String(certPEM),
+				CertChainPem:   common.String(certPEM),
+				PrivateKeyPem:  common.String(keyPEM),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ConfigType != certificatesmanagement.CertificateConfigTypeImported || created.IssuerCertificateAuthorityId != nil {
+		t.Errorf("imported certificate got config %s, issuer %v", created.ConfigType, created.IssuerCertificateAuthorityId)
+	}
+
+	renewedPEM, renewedKeyPEM := selfSigned(t, "imported.example.com")
+	_, err = c.ca.UpdateCertificate(ctx, certificatesmanagement.UpdateCertificateRequest{
+		CertificateId: created.Id,
+		UpdateCertificateDetails: certificatesmanagement.UpdateCertificateDetails{
+			CertificateConfig: certificatesmanagement.UpdateCertificateByImportingConfigDetails{
+				CertificatePem: common.String(renewedPEM),
+				CertChainPem:   common.String(renewedPEM),
+				PrivateKeyPem:  common.String(renewedKeyPEM),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := c.certs.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{CertificateId: created.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *bundle.GetVersionNumber() != 2 || *bundle.GetCertificatePem() != renewedPEM || *bundle.GetCertChainPem() != renewedPEM {
+		t.Errorf("current bundle got version %d, want the second import", *bundle.GetVersionNumber())
+	}
+
+	// A key that does not match the certificate is refused.
+	_, err = c.ca.UpdateCertificate(ctx, certificatesmanagement.UpdateCertificateRequest{
+		CertificateId: created.Id,
+		UpdateCertificateDetails: certificatesmanagement.UpdateCertificateDetails{
+			CertificateConfig: certificatesmanagement.UpdateCertificateByImportingConfigDetails{
+				CertificatePem: common.String(certPEM),
+				CertChainPem:   common.String(certPEM),
+				PrivateKeyPem:  common.String(renewedKeyPEM),
+			},
+		},
+	})
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusBadRequest {
+		t.Errorf("importing a mismatched key got err = %v, want 400", err)
+	}
+}
+
+func TestServer_UpdateListener(t *testing.T) {
+	ctx := context.TODO()
+	srv, c := newTestServer(t)
+	caID, err := srv.CreateRootCA(testCompartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var certIDs []string
+	for _, name := range []string{"first", "second"} {
+		created, err := createFromCSR(ctx, c, caID, name, testCSR(t, name+".example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		certIDs = append(certIDs, *created.Id)
+	}
+	lbID := srv.CreateLoadBalancer(testCompartmentID, "lb", loadbalancer.Listener{
+		Name:                  common.String("https"),
+		DefaultBackendSetName: common.String("backends"),
+		Port:                  common.Int(443),
+		Protocol:              common.String("HTTP"),
+		SslConfiguration:      &loadbalancer.SslConfiguration{CertificateIds: certIDs[:1]},
+	})
+
+	update := func(certificateID string) loadbalancer.WorkRequest {
+		t.Helper()
+		res, err := c.lb.UpdateListener(ctx, loadbalancer.UpdateListenerRequest{
+			LoadBalancerId: common.String(lbID),
+			ListenerName:   common.String("https"),
+			UpdateListenerDetails: loadbalancer.UpdateListenerDetails{
+				DefaultBackendSetName: common.String("backends"),
+				Port:                  common.Int(443),
+				Protocol:              common.String("HTTP"),
+				SslConfiguration:      &loadbalancer.SslConfigurationDetails{CertificateIds: []string{certificateID}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener, err := srv.LoadBalancerListener(lbID, "https")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if listener.SslConfiguration.CertificateIds[0] != certIDs[0] {
+			t.Errorf("listener updated before its work request was polled")
+		}
+		wr, err := c.lb.GetWorkRequest(ctx, loadbalancer.GetWorkRequestRequest{WorkRequestId: res.OpcWorkRequestId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wr.WorkRequest
+	}
+
+	srv.RejectCertificate(certIDs[1])
+	if wr := update(certIDs[1]); wr.LifecycleState != loadbalancer.WorkRequestLifecycleStateFailed || len(wr.ErrorDetails) != 1 {
+		t.Errorf("update to a rejected certificate got work request %s, errors %v", wr.LifecycleState, wr.ErrorDetails)
+	}
+	delete(srv.rejected, certIDs[1])
+	if wr := update(certIDs[1]); wr.LifecycleState != loadbalancer.WorkRequestLifecycleStateSucceeded {
+		t.Errorf("update got work request %s", wr.LifecycleState)
+	}
+	res, err := c.lb.GetLoadBalancer(ctx, loadbalancer.GetLoadBalancerRequest{LoadBalancerId: common.String(lbID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Listeners["https"].SslConfiguration.CertificateIds; len(got) != 1 || got[0] != certIDs[1] {
+		t.Errorf("listener certificates = %v, want %s", got, certIDs[1])
+	}
+	if *res.CompartmentId != testCompartmentID {
+		t.Errorf("load balancer compartment = %s", *res.CompartmentId)
+	}
+
+	_, err = c.lb.UpdateListener(ctx, loadbalancer.UpdateListenerRequest{
+		LoadBalancerId: common.String(lbID),
+		ListenerName:   common.String("https"),
+		UpdateListenerDetails: loadbalancer.UpdateListenerDetails{
+			DefaultBackendSetName: common.String("backends"),
+			Port:                  common.Int(443),
+			Protocol:              common.String("HTTP"),
+			SslConfiguration:      &loadbalancer.SslConfigurationDetails{CertificateIds: []string{"ocid1.certificate.oc1.phx.unknown"}},
+		},
+	})
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusBadRequest {
+		t.Errorf("update to an unknown certificate got err = %v, want 400", err)
+	}
+}
@@ -0,0 +1,189 @@
+package ocsp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRequestSize bounds the body of a POST request.
+	maxRequestSize = 1 << 16
+	contentType    = "application/ocsp-response"
+)
+
+// Lookup returns the OCI certificate authorities of the issuer registered
+// under name.
+type Lookup func(name string) ([]Issuer, bool)
+
+// Handler serves OCSP requests for every issuer known to its Lookup. Requests
+// for an issuer are served under /<issuer name>, either POSTed or with the
+// base64 encoded request appended to the path as described in RFC 6960
+// appendix A, and answered by the responder of the certificate authority
+// they name.
+type Handler struct {
+	logger logr.Logger
+	lookup Lookup
+	opts   Options
+
+	mu         sync.Mutex
+	responders map[string][]*Responder
+}
+
+// NewHandler returns a Handler resolving issuers through lookup.
+func NewHandler(logger logr.Logger, lookup Lookup, opts Options) *Handler {
+	return &Handler{
+		logger:     logger,
+		lookup:     lookup,
+		opts:       opts,
+		responders: map[string][]*Responder{},
+	}
+}
+
+// Responders returns a responder for each certificate authority of the named
+// issuer. Responders are kept across provisioner updates so the delegated
+// certificate is not reissued every time the issuer is reconciled, and
+// dropped once their authority is removed from the issuer.
+func (h *Handler) Responders(name string) ([]*Responder, bool) {
+	issuers, ok := h.lookup(name)
+	if !ok || len(issuers) == 0 {
+		return nil, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	existing := map[string]*Responder{}
+	for _, r := range h.responders[name] {
+		existing[r.AuthorityID()] = r
+	}
+	responders := make([]*Responder, 0, len(issuers))
+	for _, issuer := range issuers {
+		if issuer.Name == "" {
+			issuer.Name = name
+		}
+		r, ok := existing[issuer.AuthorityID]
+		if ok {
+			r.SetIssuer(issuer)
+		} else {
+			r = NewResponder(h.logger.WithValues("issuer", name, "authority", issuer.AuthorityID), issuer, h.opts)
+		}
+		responders = append(responders, r)
+	}
+	h.responders[name] = responders
+	return responders, true
+}
+
+// respond answers raw with the responder of the certificate authority the
+// request names.
+func (h *Handler) respond(ctx context.Context, responders []*Responder, raw []byte) []byte {
+	req, err := ocsp.ParseRequest(raw)
+	if err != nil {
+		h.logger.V(1).Info("malformed OCSP request", "error", err.Error())
+		return ocsp.MalformedRequestErrorResponse
+	}
+	failed := false
+	for _, r := range responders {
+		caCert, err := r.issuerCertificate(ctx)
+		if err != nil {
+			r.logger.Error(err, "failed to answer OCSP request", "serial", req.SerialNumber.Text(16))
+			failed = true
+			continue
+		}
+		if issuedBy(req, caCert) {
+			return r.answer(ctx, req)
+		}
+	}
+	// The request may be for the authority whose certificate could not be
+	// fetched, so it is not refused as unauthorized.
+	if failed {
+		return ocsp.InternalErrorErrorResponse
+	}
+	return ocsp.UnauthorizedErrorResponse
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if name == "" {
+		http.NotFound(w, req)
+		return
+	}
+
+	var raw []byte
+	switch req.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, "failed reading request", http.StatusBadRequest)
+			return
+		}
+		raw = body
+	case http.MethodGet:
+		decoded, err := base64.StdEncoding.DecodeString(rest)
+		if err != nil {
+			http.Error(w, "malformed request", http.StatusBadRequest)
+			return
+		}
+		raw = decoded
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	responders, ok := h.Responders(name)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	res := h.respond(req.Context(), responders, raw)
+	w.Header().Set("Content-Type", contentType)
+	if req.Method == http.MethodGet {
+		w.Header().Set("Cache-Control", "max-age=60, public, no-transform, must-revalidate")
+	}
+	_, _ = w.Write(res)
+}
+
+// Server runs a Handler as a controller-runtime Runnable.
+type Server struct {
+	Addr    string
+	Handler http.Handler
+}
+
+// Start serves OCSP requests until ctx is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// NeedLeaderElection is true: the issuers a Handler looks up are only loaded
+// by the reconcilers of the elected leader, so other replicas would answer
+// every request with 404. They do not listen on Addr until elected.
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
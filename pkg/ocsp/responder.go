@@ -0,0 +1,747 @@
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshInterval How often the status index and CRL are rebuilt from OCI.
+	DefaultRefreshInterval = time.Minute * 5
+	// DefaultMinRefreshInterval The minimum time between refreshes triggered by unknown serials.
+	DefaultMinRefreshInterval = time.Second * 30
+	// DefaultResponseValidity How long a signed response is valid for.
+	DefaultResponseValidity = time.Hour
+	// DefaultResponderCertificateDuration The validity of the delegated responder certificate.
+	DefaultResponderCertificateDuration = time.Hour * 24 * 7
+	// ResponderTagKey The freeform tag marking OCI certificates used by the responder
+	ResponderTagKey = "ocsp-responder"
+)
+
+var (
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageOCSPSigning    = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}
+	oidExtensionOCSPNoCheck      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+)
+
+// CAClient is the subset of the OCI Certificates Management API used by the
+// responder.
+type CAClient interface {
+	CreateCertificate(ctx context.Context, request certificatesmanagement.CreateCertificateRequest) (response certificatesmanagement.CreateCertificateResponse, err error)
+	ListCertificates(ctx context.Context, request certificatesmanagement.ListCertificatesRequest) (response certificatesmanagement.ListCertificatesResponse, err error)
+	ListCertificateVersions(ctx context.Context, request certificatesmanagement.ListCertificateVersionsRequest) (response certificatesmanagement.ListCertificateVersionsResponse, err error)
+	UpdateCertificate(ctx context.Context, request certificatesmanagement.UpdateCertificateRequest) (response certificatesmanagement.UpdateCertificateResponse, err error)
+}
+
+// CertificateClient is the subset of the OCI Certificates API used by the
+// responder.
+type CertificateClient interface {
+	GetCertificateBundle(ctx context.Context, request certificates.GetCertificateBundleRequest) (response certificates.GetCertificateBundleResponse, err error)
+	GetCertificateAuthorityBundle(ctx context.Context, request certificates.GetCertificateAuthorityBundleRequest) (response certificates.GetCertificateAuthorityBundleResponse, err error)
+}
+
+// Issuer describes the OCI certificate authority a Responder answers for.
+type Issuer struct {
+	Name              string
+	AuthorityID       string
+	CompartmentID     string
+	FreeformTags      map[string]string
+	CAClient          CAClient
+	CertificateClient CertificateClient
+
+	// CompartmentIDs are the other compartments the issuer creates
+	// certificates of the authority in. The status of certificates is looked
+	// up in them and in CompartmentID, where the responder certificate is
+	// created.
+	CompartmentIDs []string
+}
+
+// compartments returns CompartmentID followed by the distinct other
+// CompartmentIDs.
+func (i Issuer) compartments() []string {
+	ids := []string{i.CompartmentID}
+	seen := map[string]bool{i.CompartmentID: true}
+	for _, id := range i.CompartmentIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Options tune the behaviour of a Responder. Zero values fall back to the
+// package defaults.
+type Options struct {
+	RefreshInterval              time.Duration
+	MinRefreshInterval           time.Duration
+	ResponseValidity             time.Duration
+	ResponderCertificateDuration time.Duration
+	// HTTPClient is used to download the CRL published by the CA.
+	HTTPClient *http.Client
+	// Now overrides the clock, for tests.
+	Now func() time.Time
+}
+
+func (o Options) withDefaults() Options {
+	if o.RefreshInterval == 0 {
+		o.RefreshInterval = DefaultRefreshInterval
+	}
+	if o.MinRefreshInterval == 0 {
+		o.MinRefreshInterval = DefaultMinRefreshInterval
+	}
+	if o.ResponseValidity == 0 {
+		o.ResponseValidity = DefaultResponseValidity
+	}
+	if o.ResponderCertificateDuration == 0 {
+		o.ResponderCertificateDuration = DefaultResponderCertificateDuration
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: time.Second * 30}
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	return o
+}
+
+// certificateStatus is the revocation state of one OCI certificate version.
+type certificateStatus struct {
+	stages     []certificatesmanagement.VersionStageEnum
+	revocation *certificatesmanagement.RevocationStatus
+}
+
+// Responder answers RFC 6960 OCSP requests for certificates issued by a
+// single OCI certificate authority. Revocation data is taken from the CRL
+// published by the CA and from the revocation status of OCI certificate
+// versions. Responses are signed by a delegated responder certificate that is
+// issued from the same CA.
+type Responder struct {
+	logger logr.Logger
+	opts   Options
+
+	mu          sync.RWMutex
+	issuer      Issuer
+	caCert      *x509.Certificate
+	index       map[string]certificateStatus
+	crl         *x509.RevocationList
+	refreshedAt time.Time
+
+	signerMu      sync.Mutex
+	signerCert    *x509.Certificate
+	signerKey     crypto.Signer
+	signerRenewAt time.Time
+}
+
+// NewResponder returns a Responder for the given issuer. No calls are made to
+// OCI until the first request is served.
+func NewResponder(logger logr.Logger, issuer Issuer, opts Options) *Responder {
+	return &Responder{
+		logger: logger,
+		opts:   opts.withDefaults(),
+		issuer: issuer,
+	}
+}
+
+// SetIssuer swaps the OCI clients used by the responder. Cached state is kept
+// as long as the authority is unchanged.
+func (r *Responder) SetIssuer(issuer Issuer) {
+	r.mu.Lock()
+	changed := issuer.AuthorityID != r.issuer.AuthorityID
+	if changed {
+		r.caCert = nil
+		r.index = nil
+		r.crl = nil
+		r.refreshedAt = time.Time{}
+	}
+	r.issuer = issuer
+	r.mu.Unlock()
+
+	if changed {
+		r.signerMu.Lock()
+		r.signerCert, r.signerKey, r.signerRenewAt = nil, nil, time.Time{}
+		r.signerMu.Unlock()
+	}
+}
+
+// AuthorityID returns the OCID of the CA the responder answers for.
+func (r *Responder) AuthorityID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.issuer.AuthorityID
+}
+
+// Respond parses a DER encoded OCSP request and returns the DER encoded
+// response. Protocol level failures are returned as OCSP error responses
+// rather than Go errors, so the result can always be written to the client.
+func (r *Responder) Respond(ctx context.Context, raw []byte) []byte {
+	req, err := ocsp.ParseRequest(raw)
+	if err != nil {
+		r.logger.V(1).Info("malformed OCSP request", "error", err.Error())
+		return ocsp.MalformedRequestErrorResponse
+	}
+	return r.answer(ctx, req)
+}
+
+// answer returns the DER encoded response to a parsed request, or an OCSP
+// error response.
+func (r *Responder) answer(ctx context.Context, req *ocsp.Request) []byte {
+	res, err := r.respond(ctx, req)
+	if err != nil {
+		r.logger.Error(err, "failed to answer OCSP request", "serial", req.SerialNumber.Text(16))
+		return ocsp.InternalErrorErrorResponse
+	}
+	return res
+}
+
+func (r *Responder) respond(ctx context.Context, req *ocsp.Request) ([]byte, error) {
+	caCert, err := r.issuerCertificate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !issuedBy(req, caCert) {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	if err := r.refresh(ctx, false); err != nil {
+		return nil, err
+	}
+	tmpl, known := r.status(req.SerialNumber)
+	if !known {
+		// The certificate may have been issued since the last refresh.
+		if err := r.refresh(ctx, true); err != nil {
+			return nil, err
+		}
+		tmpl, _ = r.status(req.SerialNumber)
+	}
+
+	cert, key, err := r.signer(ctx, caCert)
+	if err != nil {
+		return nil, err
+	}
+	now := r.opts.Now().UTC()
+	tmpl.SerialNumber = req.SerialNumber
+	tmpl.IssuerHash = req.HashAlgorithm
+	tmpl.ThisUpdate = now.Truncate(time.Minute)
+	tmpl.NextUpdate = tmpl.ThisUpdate.Add(r.opts.ResponseValidity)
+	tmpl.Certificate = cert
+	return ocsp.CreateResponse(caCert, cert, tmpl, key)
+}
+
+// status returns the response template for serial, and whether the serial
+// was known to the CA.
+func (r *Responder) status(serial *big.Int) (ocsp.Response, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.crl != nil {
+		for _, revoked := range r.crl.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(serial) == 0 {
+				return ocsp.Response{
+					Status:           ocsp.Revoked,
+					RevokedAt:        revoked.RevocationTime,
+					RevocationReason: crlReason(revoked.Extensions),
+				}, true
+			}
+		}
+	}
+	st, ok := r.index[serialKey(serial)]
+	if !ok {
+		return ocsp.Response{Status: ocsp.Unknown}, false
+	}
+	for _, stage := range st.stages {
+		// A failed version never produced a certificate.
+		if stage == certificatesmanagement.VersionStageFailed {
+			return ocsp.Response{Status: ocsp.Unknown}, true
+		}
+	}
+	if st.revocation != nil {
+		res := ocsp.Response{
+			Status:           ocsp.Revoked,
+			RevocationReason: revocationReason(st.revocation.RevocationReason),
+		}
+		if st.revocation.TimeOfRevocation != nil {
+			res.RevokedAt = st.revocation.TimeOfRevocation.Time
+		}
+		return res, true
+	}
+	return ocsp.Response{Status: ocsp.Good}, true
+}
+
+// issuerCertificate returns the current certificate of the OCI CA.
+func (r *Responder) issuerCertificate(ctx context.Context) (*x509.Certificate, error) {
+	r.mu.RLock()
+	caCert, issuer := r.caCert, r.issuer
+	r.mu.RUnlock()
+	if caCert != nil {
+		return caCert, nil
+	}
+
+	res, err := issuer.CertificateClient.GetCertificateAuthorityBundle(ctx, certificates.GetCertificateAuthorityBundleRequest{
+		CertificateAuthorityId: common.String(issuer.AuthorityID),
+		Stage:                  certificates.GetCertificateAuthorityBundleStageCurrent,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching certificate authority bundle: %w", err)
+	}
+	if res.CertificatePem == nil {
+		return nil, fmt.Errorf("certificate authority bundle has no certificate")
+	}
+	caCert, err = parseCertificatePEM([]byte(*res.CertificatePem))
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing certificate authority certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.caCert = caCert
+	r.mu.Unlock()
+	return caCert, nil
+}
+
+// refresh rebuilds the status index and CRL once they are older than the
+// refresh interval. When force is set the shorter minimum interval applies.
+func (r *Responder) refresh(ctx context.Context, force bool) error {
+	r.mu.RLock()
+	issuer, caCert, refreshedAt := r.issuer, r.caCert, r.refreshedAt
+	r.mu.RUnlock()
+
+	age := r.opts.Now().Sub(refreshedAt)
+	if age < r.opts.RefreshInterval && (!force || age < r.opts.MinRefreshInterval) {
+		return nil
+	}
+
+	index, err := r.buildIndex(ctx, issuer)
+	if err != nil {
+		return err
+	}
+	crl, err := r.fetchCRL(ctx, caCert)
+	if err != nil {
+		// A missing CRL only loses revocations that OCI no longer reports on
+		// certificate versions, so keep answering from the index.
+		r.logger.Error(err, "failed fetching certificate revocation list")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.index = index
+	if crl != nil || err == nil {
+		r.crl = crl
+	}
+	r.refreshedAt = r.opts.Now()
+	return nil
+}
+
+// buildIndex lists every certificate version issued by the CA in the
+// issuer's compartments, keyed by serial number.
+func (r *Responder) buildIndex(ctx context.Context, issuer Issuer) (map[string]certificateStatus, error) {
+	index := map[string]certificateStatus{}
+	for _, compartmentID := range issuer.compartments() {
+		if err := r.indexCompartment(ctx, issuer, compartmentID, index); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+func (r *Responder) indexCompartment(ctx context.Context, issuer Issuer, compartmentID string, index map[string]certificateStatus) error {
+	var page *string
+	for {
+		res, err := issuer.CAClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+			CompartmentId:                common.String(compartmentID),
+			IssuerCertificateAuthorityId: common.String(issuer.AuthorityID),
+			Page:                         page,
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing certificates in compartment %s: %w", compartmentID, err)
+		}
+		for _, cert := range res.Items {
+			if cert.Id == nil {
+				continue
+			}
+			if err := r.indexVersions(ctx, issuer, *cert.Id, index); err != nil {
+				return err
+			}
+		}
+		if res.OpcNextPage == nil {
+			return nil
+		}
+		page = res.OpcNextPage
+	}
+}
+
+func (r *Responder) indexVersions(ctx context.Context, issuer Issuer, certificateID string, index map[string]certificateStatus) error {
+	var page *string
+	for {
+		res, err := issuer.CAClient.ListCertificateVersions(ctx, certificatesmanagement.ListCertificateVersionsRequest{
+			CertificateId: common.String(certificateID),
+			Page:          page,
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing versions of certificate %s: %w", certificateID, err)
+		}
+		for _, v := range res.Items {
+			if v.SerialNumber == nil {
+				continue
+			}
+			serial, ok := parseSerial(*v.SerialNumber)
+			if !ok {
+				r.logger.V(1).Info("ignoring certificate version with unparsable serial", "certificate", certificateID, "serial", *v.SerialNumber)
+				continue
+			}
+			index[serialKey(serial)] = certificateStatus{
+				stages:     v.Stages,
+				revocation: v.RevocationStatus,
+			}
+		}
+		if res.OpcNextPage == nil {
+			return nil
+		}
+		page = res.OpcNextPage
+	}
+}
+
+// fetchCRL downloads the CRL from the first HTTP distribution point of the CA
+// certificate. A CA without distribution points has no CRL.
+func (r *Responder) fetchCRL(ctx context.Context, caCert *x509.Certificate) (*x509.RevocationList, error) {
+	if caCert == nil {
+		return nil, nil
+	}
+	var url string
+	for _, dp := range caCert.CRLDistributionPoints {
+		if strings.HasPrefix(dp, "http://") || strings.HasPrefix(dp, "https://") {
+			url = dp
+			break
+		}
+	}
+	if url == "" {
+		return nil, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d fetching %s", res.StatusCode, url)
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := crl.CheckSignatureFrom(caCert); err != nil {
+		return nil, fmt.Errorf("CRL at %s is not signed by the certificate authority: %w", url, err)
+	}
+	return crl, nil
+}
+
+// signer returns the delegated responder certificate and key, issuing a new
+// one from the OCI CA once two thirds of its lifetime has passed.
+func (r *Responder) signer(ctx context.Context, caCert *x509.Certificate) (*x509.Certificate, crypto.Signer, error) {
+	r.signerMu.Lock()
+	defer r.signerMu.Unlock()
+	now := r.opts.Now()
+	if r.signerCert != nil && now.Before(r.signerRenewAt) {
+		return r.signerCert, r.signerKey, nil
+	}
+
+	r.mu.RLock()
+	issuer := r.issuer
+	r.mu.RUnlock()
+
+	cert, key, err := r.issueResponderCertificate(ctx, issuer, caCert, now)
+	if err != nil {
+		if r.signerCert != nil && now.Before(r.signerCert.NotAfter) {
+			r.logger.Error(err, "failed renewing OCSP responder certificate, using existing one")
+			return r.signerCert, r.signerKey, nil
+		}
+		return nil, nil, err
+	}
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	r.signerCert, r.signerKey, r.signerRenewAt = cert, key, cert.NotBefore.Add(lifetime*2/3)
+	r.logger.Info("issued OCSP responder certificate", "serial", cert.SerialNumber.Text(16), "notAfter", cert.NotAfter)
+	return cert, key, nil
+}
+
+// issueResponderCertificate issues a delegated responder certificate as a
+// new current version of the OCI certificate of issuer's authority, creating
+// it the first time, so that restarts and renewals do not leave a new OCI
+// certificate behind each.
+func (r *Responder) issueResponderCertificate(ctx context.Context, issuer Issuer, caCert *x509.Certificate, now time.Time) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csrPEM, err := responderCSR(issuer.Name, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := responderCertificateName(issuer)
+	versionName := fmt.Sprintf("%s-%d", name, now.Unix())
+	start := now.UTC()
+	validity := &certificatesmanagement.Validity{
+		TimeOfValidityNotBefore: &common.SDKTime{Time: start},
+		TimeOfValidityNotAfter:  &common.SDKTime{Time: start.Add(r.opts.ResponderCertificateDuration)},
+	}
+	certificateID, err := responderCertificateID(ctx, issuer, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed looking up OCSP responder certificate: %w", err)
+	}
+	if certificateID == "" {
+		certificateID, err = createResponderCertificate(ctx, issuer, name, versionName, csrPEM, validity)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		_, err = issuer.CAClient.UpdateCertificate(ctx, certificatesmanagement.UpdateCertificateRequest{
+			CertificateId: common.String(certificateID),
+			UpdateCertificateDetails: certificatesmanagement.UpdateCertificateDetails{
+				CertificateConfig: certificatesmanagement.UpdateCertificateManagedExternallyIssuedByInternalCaConfigDetails{
+					CsrPem:      common.String(string(csrPEM)),
+					VersionName: common.String(versionName),
+					Validity:    validity,
+					Stage:       certificatesmanagement.UpdateCertificateConfigDetailsStageCurrent,
+				},
+			},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed renewing OCSP responder certificate %s: %w", certificateID, err)
+		}
+	}
+
+	bundle, err := issuer.CertificateClient.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:          common.String(certificateID),
+		CertificateVersionName: common.String(versionName),
+		CertificateBundleType:  certificates.GetCertificateBundleCertificateBundleTypePublicOnly,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed fetching OCSP responder certificate: %w", err)
+	}
+	if bundle.CertificateBundle == nil || bundle.GetCertificatePem() == nil {
+		return nil, nil, fmt.Errorf("OCSP responder certificate bundle is empty")
+	}
+	cert, err := parseCertificatePEM([]byte(*bundle.GetCertificatePem()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed parsing OCSP responder certificate: %w", err)
+	}
+	if err := cert.CheckSignatureFrom(caCert); err != nil {
+		return nil, nil, fmt.Errorf("OCSP responder certificate is not signed by the certificate authority: %w", err)
+	}
+	if !hasOCSPSigning(cert) {
+		return nil, nil, fmt.Errorf("OCSP responder certificate is missing the OCSPSigning extended key usage")
+	}
+	return cert, key, nil
+}
+
+// responderCertificateName returns the name of the OCI certificate holding
+// the responder certificates of issuer's authority. Authorities of an issuer
+// may share a compartment, where names are unique.
+func responderCertificateName(issuer Issuer) string {
+	return fmt.Sprintf("%s-ocsp-responder-%s", issuer.Name, shortID(issuer.AuthorityID))
+}
+
+// responderCertificateID returns the OCID of the active OCI certificate named
+// name that holds the responder certificates of issuer, or empty when there
+// is none.
+func responderCertificateID(ctx context.Context, issuer Issuer, name string) (string, error) {
+	res, err := issuer.CAClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId:                common.String(issuer.CompartmentID),
+		Name:                         common.String(name),
+		IssuerCertificateAuthorityId: common.String(issuer.AuthorityID),
+		LifecycleState:               certificatesmanagement.ListCertificatesLifecycleStateActive,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range res.Items {
+		if c.Id != nil && c.Name != nil && *c.Name == name && c.FreeformTags[ResponderTagKey] == "true" &&
+			c.ConfigType == certificatesmanagement.CertificateConfigTypeManagedExternallyIssuedByInternalCa {
+			return *c.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// createResponderCertificate creates the OCI certificate named name holding
+// the responder certificates of issuer, with csrPEM as its first version, and
+// returns its OCID.
+func createResponderCertificate(ctx context.Context, issuer Issuer, name, versionName string, csrPEM []byte, validity *certificatesmanagement.Validity) (string, error) {
+	tags := map[string]string{ResponderTagKey: "true"}
+	for k, v := range issuer.FreeformTags {
+		tags[k] = v
+	}
+	created, err := issuer.CAClient.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          common.String(name),
+			CompartmentId: common.String(issuer.CompartmentID),
+			CertificateConfig: certificatesmanagement.CreateCertificateManagedExternallyIssuedByInternalCaConfigDetails{
+				IssuerCertificateAuthorityId: common.String(issuer.AuthorityID),
+				CsrPem:                       common.String(string(csrPEM)),
+				VersionName:                  common.String(versionName),
+				Validity:                     validity,
+			},
+			Description:  common.String(fmt.Sprintf("OCSP responder for %s", issuer.Name)),
+			FreeformTags: tags,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed creating OCSP responder certificate: %w", err)
+	}
+	return *created.Id, nil
+}
+
+// responderCSR builds a CSR asking for a delegated OCSP signing certificate.
+func responderCSR(issuerName string, key crypto.Signer) ([]byte, error) {
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageOCSPSigning})
+	if err != nil {
+		return nil, err
+	}
+	noCheck, err := asn1.Marshal(asn1.NullRawValue)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: fmt.Sprintf("%s OCSP Responder", issuerName)},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionExtendedKeyUsage, Value: eku},
+			{Id: oidExtensionOCSPNoCheck, Value: noCheck},
+		},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// issuedBy reports whether the request CertID names caCert as the issuer.
+func issuedBy(req *ocsp.Request, caCert *x509.Certificate) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(caCert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return false
+	}
+
+	h := req.HashAlgorithm.New()
+	h.Write(caCert.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(keyHash, req.IssuerKeyHash)
+}
+
+func hasOCSPSigning(cert *x509.Certificate) bool {
+	for _, eku := range cert.ExtKeyUsage {
+		if eku == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCertificatePEM(raw []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// parseSerial parses an OCI serial number, which is hex encoded and may be
+// separated by colons.
+func parseSerial(s string) (*big.Int, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ":", "")
+	if _, err := hex.DecodeString(strings.Repeat("0", len(s)%2) + s); err != nil || s == "" {
+		return nil, false
+	}
+	return new(big.Int).SetString(s, 16)
+}
+
+// shortID returns the unique end of an OCID.
+func shortID(ocid string) string {
+	if len(ocid) > 8 {
+		return ocid[len(ocid)-8:]
+	}
+	return ocid
+}
+
+func serialKey(serial *big.Int) string {
+	return serial.Text(16)
+}
+
+// revocationReason maps an OCI revocation reason to its RFC 5280 code.
+func revocationReason(reason certificatesmanagement.RevocationReasonEnum) int {
+	switch reason {
+	case certificatesmanagement.RevocationReasonKeyCompromise:
+		return ocsp.KeyCompromise
+	case certificatesmanagement.RevocationReasonCaCompromise:
+		return ocsp.CACompromise
+	case certificatesmanagement.RevocationReasonAffiliationChanged:
+		return ocsp.AffiliationChanged
+	case certificatesmanagement.RevocationReasonSuperseded:
+		return ocsp.Superseded
+	case certificatesmanagement.RevocationReasonCessationOfOperation:
+		return ocsp.CessationOfOperation
+	case certificatesmanagement.RevocationReasonPrivilegeWithdrawn:
+		return ocsp.PrivilegeWithdrawn
+	case certificatesmanagement.RevocationReasonAaCompromise:
+		return ocsp.AACompromise
+	default:
+		return ocsp.Unspecified
+	}
+}
+
+// crlReason extracts the reason code extension of a CRL entry.
+func crlReason(exts []pkix.Extension) int {
+	for _, ext := range exts {
+		if !ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 21}) {
+			continue
+		}
+		var reason asn1.Enumerated
+		if _, err := asn1.Unmarshal(ext.Value, &reason); err == nil {
+			return int(reason)
+		}
+	}
+	return ocsp.Unspecified
+}
@@ -0,0 +1,486 @@
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAuthorityID   = "ocid1.certificateauthority.oc1.phx.aaaaaaaatest"
+	testCompartmentID = "ocid1.compartment.oc1..test"
+)
+
+// fakeOCI implements CAClient and CertificateClient on top of an in-memory CA.
+type fakeOCI struct {
+	caKey   crypto.Signer
+	caCert  *x509.Certificate
+	serial  int64
+	certs   map[string][]certificatesmanagement.CertificateVersionSummary
+	pems    map[string]string
+	creates int
+	updates int
+	noEKU   bool
+
+	// compartments maps certificate OCIDs to their compartment.
+	compartments map[string]string
+
+	// names and tags hold the name and freeform tags of the certificates
+	// created through CreateCertificate.
+	names map[string]string
+	tags  map[string]map[string]string
+}
+
+func newFakeOCI(t *testing.T, crlPoint string) *fakeOCI {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24 * 365),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	if crlPoint != "" {
+		tmpl.CRLDistributionPoints = []string{crlPoint}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &fakeOCI{
+		caKey:  key,
+		caCert: cert,
+		serial: 100,
+		certs:  map[string][]certificatesmanagement.CertificateVersionSummary{},
+		pems:   map[string]string{},
+
+		compartments: map[string]string{},
+
+		names: map[string]string{},
+		tags:  map[string]map[string]string{},
+	}
+}
+
+func (f *fakeOCI) issue(compartmentID string, pub crypto.PublicKey, ekus []x509.ExtKeyUsage) (string, *x509.Certificate, error) {
+	f.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(f.serial),
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("leaf %d", f.serial)},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour * 24),
+		ExtKeyUsage:  ekus,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, f.caCert, pub, f.caKey)
+	if err != nil {
+		return "", nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id := fmt.Sprintf("ocid1.certificate.oc1.phx.%d", f.serial)
+	f.certs[id] = []certificatesmanagement.CertificateVersionSummary{{
+		CertificateId: common.String(id),
+		VersionNumber: common.Int64(1),
+		Stages:        []certificatesmanagement.VersionStageEnum{certificatesmanagement.VersionStageCurrent},
+		SerialNumber:  common.String(colonHex(cert.SerialNumber)),
+	}}
+	f.pems[id] = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	f.compartments[id] = compartmentID
+	return id, cert, nil
+}
+
+func (f *fakeOCI) revoke(id string, reason certificatesmanagement.RevocationReasonEnum) {
+	f.certs[id][0].RevocationStatus = &certificatesmanagement.RevocationStatus{
+		TimeOfRevocation: &common.SDKTime{Time: time.Now().Add(-time.Minute).Truncate(time.Second)},
+		RevocationReason: reason,
+	}
+}
+
+func (f *fakeOCI) CreateCertificate(_ context.Context, request certificatesmanagement.CreateCertificateRequest) (certificatesmanagement.CreateCertificateResponse, error) {
+	f.creates++
+	cfg := request.CertificateConfig.(certificatesmanagement.CreateCertificateManagedExternallyIssuedByInternalCaConfigDetails)
+	id, _, err := f.issueFromCSR(*request.CompartmentId, *cfg.CsrPem)
+	if err != nil {
+		return certificatesmanagement.CreateCertificateResponse{}, err
+	}
+	f.names[id] = *request.Name
+	f.tags[id] = request.FreeformTags
+	return certificatesmanagement.CreateCertificateResponse{
+		Certificate: certificatesmanagement.Certificate{Id: common.String(id)},
+	}, nil
+}
+
+// UpdateCertificate replaces the certificate with one issued from the CSR of
+// the request, as only the current version is looked at.
+func (f *fakeOCI) UpdateCertificate(_ context.Context, request certificatesmanagement.UpdateCertificateRequest) (certificatesmanagement.UpdateCertificateResponse, error) {
+	f.updates++
+	cfg := request.CertificateConfig.(certificatesmanagement.UpdateCertificateManagedExternallyIssuedByInternalCaConfigDetails)
+	id := *request.CertificateId
+	issued, _, err := f.issueFromCSR(f.compartments[id], *cfg.CsrPem)
+	if err != nil {
+		return certificatesmanagement.UpdateCertificateResponse{}, err
+	}
+	f.certs[id] = f.certs[issued]
+	f.pems[id] = f.pems[issued]
+	delete(f.certs, issued)
+	delete(f.pems, issued)
+	delete(f.compartments, issued)
+	return certificatesmanagement.UpdateCertificateResponse{
+		Certificate: certificatesmanagement.Certificate{Id: common.String(id)},
+	}, nil
+}
+
+// issueFromCSR issues a responder certificate for the key of csrPEM.
+func (f *fakeOCI) issueFromCSR(compartmentID, csrPEM string) (string, *x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return "", nil, err
+	}
+	var ekus []x509.ExtKeyUsage
+	if !f.noEKU {
+		ekus = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+	}
+	return f.issue(compartmentID, csr.PublicKey, ekus)
+}
+
+func (f *fakeOCI) ListCertificates(_ context.Context, request certificatesmanagement.ListCertificatesRequest) (certificatesmanagement.ListCertificatesResponse, error) {
+	res := certificatesmanagement.ListCertificatesResponse{}
+	for id := range f.certs {
+		if f.compartments[id] != *request.CompartmentId || (request.Name != nil && f.names[id] != *request.Name) {
+			continue
+		}
+		summary := certificatesmanagement.CertificateSummary{Id: common.String(id), FreeformTags: f.tags[id]}
+		if name, ok := f.names[id]; ok {
+			summary.Name = common.String(name)
+			summary.ConfigType = certificatesmanagement.CertificateConfigTypeManagedExternallyIssuedByInternalCa
+		}
+		res.Items = append(res.Items, summary)
+	}
+	return res, nil
+}
+
+func (f *fakeOCI) ListCertificateVersions(_ context.Context, request certificatesmanagement.ListCertificateVersionsRequest) (certificatesmanagement.ListCertificateVersionsResponse, error) {
+	res := certificatesmanagement.ListCertificateVersionsResponse{}
+	res.Items = f.certs[*request.CertificateId]
+	return res, nil
+}
+
+func (f *fakeOCI) GetCertificateBundle(_ context.Context, request certificates.GetCertificateBundleRequest) (certificates.GetCertificateBundleResponse, error) {
+	return certificates.GetCertificateBundleResponse{
+		CertificateBundle: certificates.CertificateBundlePublicOnly{
+			CertificateId:  request.CertificateId,
+			CertificatePem: common.String(f.pems[*request.CertificateId]),
+		},
+	}, nil
+}
+
+func (f *fakeOCI) GetCertificateAuthorityBundle(_ context.Context, _ certificates.GetCertificateAuthorityBundleRequest) (certificates.GetCertificateAuthorityBundleResponse, error) {
+	return certificates.GetCertificateAuthorityBundleResponse{
+		CertificateAuthorityBundle: certificates.CertificateAuthorityBundle{
+			CertificateAuthorityId: common.String(testAuthorityID),
+			CertificatePem:         common.String(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.caCert.Raw}))),
+		},
+	}, nil
+}
+
+func colonHex(n *big.Int) string {
+	h := n.Text(16)
+	if len(h)%2 == 1 {
+		h = "0" + h
+	}
+	var parts []string
+	for i := 0; i < len(h); i += 2 {
+		parts = append(parts, strings.ToUpper(h[i:i+2]))
+	}
+	return strings.Join(parts, ":")
+}
+
+func newTestResponder(oci *fakeOCI) *Responder {
+	return NewResponder(logr.Discard(), Issuer{
+		Name:              "issuer1",
+		AuthorityID:       testAuthorityID,
+		CompartmentID:     testCompartmentID,
+		CAClient:          oci,
+		CertificateClient: oci,
+	}, Options{})
+}
+
+func issueLeaf(t *testing.T, oci *fakeOCI) (string, *x509.Certificate) {
+	return issueLeafIn(t, oci, testCompartmentID)
+}
+
+func issueLeafIn(t *testing.T, oci *fakeOCI, compartmentID string) (string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id, cert, err := oci.issue(compartmentID, key.Public(), nil)
+	require.NoError(t, err)
+	return id, cert
+}
+
+func TestResponder_Respond(t *testing.T) {
+	oci := newFakeOCI(t, "")
+	_, good := issueLeaf(t, oci)
+	revokedID, revoked := issueLeaf(t, oci)
+	oci.revoke(revokedID, certificatesmanagement.RevocationReasonKeyCompromise)
+
+	unknown := &x509.Certificate{SerialNumber: big.NewInt(999999), RawIssuer: oci.caCert.RawSubject}
+
+	r := newTestResponder(oci)
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		wantStatus int
+		wantReason int
+	}{
+		{name: "good certificate", cert: good, wantStatus: ocsp.Good},
+		{name: "revoked certificate", cert: revoked, wantStatus: ocsp.Revoked, wantReason: ocsp.KeyCompromise},
+		{name: "unknown serial", cert: unknown, wantStatus: ocsp.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ocsp.CreateRequest(tt.cert, oci.caCert, nil)
+			require.NoError(t, err)
+			raw := r.Respond(context.TODO(), req)
+
+			res, err := ocsp.ParseResponseForCert(raw, tt.cert, oci.caCert)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, res.Status)
+			assert.Equal(t, 0, res.SerialNumber.Cmp(tt.cert.SerialNumber))
+			if tt.wantStatus == ocsp.Revoked {
+				assert.Equal(t, tt.wantReason, res.RevocationReason)
+			}
+			// Responses are signed by a delegated certificate, not the CA.
+			require.NotNil(t, res.Certificate)
+			assert.NoError(t, res.Certificate.CheckSignatureFrom(oci.caCert))
+		})
+	}
+	// The delegated responder certificate is issued once and reused.
+	assert.Equal(t, 1, oci.creates)
+
+	// A restarted responder issues a new version of the same OCI
+	// certificate.
+	restarted := newTestResponder(oci)
+	req, err := ocsp.CreateRequest(good, oci.caCert, nil)
+	require.NoError(t, err)
+	res, err := ocsp.ParseResponseForCert(restarted.Respond(context.TODO(), req), good, oci.caCert)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, res.Status)
+	assert.Equal(t, 1, oci.creates)
+	assert.Equal(t, 1, oci.updates)
+}
+
+func TestResponder_RespondFromCRL(t *testing.T) {
+	var crlDER []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(crlDER)
+	}))
+	defer srv.Close()
+
+	oci := newFakeOCI(t, srv.URL+"/ca.crl")
+	_, leaf := issueLeaf(t, oci)
+
+	var err error
+	crlDER, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{{
+			SerialNumber:   leaf.SerialNumber,
+			RevocationTime: time.Now().Add(-time.Minute).UTC().Truncate(time.Second),
+		}},
+	}, oci.caCert, oci.caKey)
+	require.NoError(t, err)
+
+	r := newTestResponder(oci)
+	req, err := ocsp.CreateRequest(leaf, oci.caCert, nil)
+	require.NoError(t, err)
+	res, err := ocsp.ParseResponseForCert(r.Respond(context.TODO(), req), leaf, oci.caCert)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Revoked, res.Status)
+}
+
+func TestResponder_RespondErrors(t *testing.T) {
+	oci := newFakeOCI(t, "")
+	_, leaf := issueLeaf(t, oci)
+
+	t.Run("malformed request", func(t *testing.T) {
+		r := newTestResponder(oci)
+		assert.Equal(t, ocsp.MalformedRequestErrorResponse, r.Respond(context.TODO(), []byte("junk")))
+	})
+	t.Run("other issuer", func(t *testing.T) {
+		other := newFakeOCI(t, "")
+		req, err := ocsp.CreateRequest(leaf, other.caCert, nil)
+		require.NoError(t, err)
+		r := newTestResponder(oci)
+		assert.Equal(t, ocsp.UnauthorizedErrorResponse, r.Respond(context.TODO(), req))
+	})
+	t.Run("responder certificate without OCSPSigning", func(t *testing.T) {
+		noEKU := newFakeOCI(t, "")
+		noEKU.noEKU = true
+		_, leaf := issueLeaf(t, noEKU)
+		req, err := ocsp.CreateRequest(leaf, noEKU.caCert, nil)
+		require.NoError(t, err)
+		r := newTestResponder(noEKU)
+		assert.Equal(t, ocsp.InternalErrorErrorResponse, r.Respond(context.TODO(), req))
+	})
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	oci := newFakeOCI(t, "")
+	_, leaf := issueLeaf(t, oci)
+	h := NewHandler(logr.Discard(), func(name string) ([]Issuer, bool) {
+		if name != "issuer1" {
+			return nil, false
+		}
+		return []Issuer{{
+			AuthorityID:       testAuthorityID,
+			CompartmentID:     testCompartmentID,
+			CAClient:          oci,
+			CertificateClient: oci,
+		}}, true
+	}, Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	req, err := ocsp.CreateRequest(leaf, oci.caCert, nil)
+	require.NoError(t, err)
+
+	t.Run("post", func(t *testing.T) {
+		res, err := http.Post(srv.URL+"/issuer1", "application/ocsp-request", bytes.NewReader(req))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, contentType, res.Header.Get("Content-Type"))
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(res.Body)
+		parsed, err := ocsp.ParseResponseForCert(body.Bytes(), leaf, oci.caCert)
+		require.NoError(t, err)
+		assert.Equal(t, ocsp.Good, parsed.Status)
+	})
+	t.Run("get", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/issuer1/" + base64.StdEncoding.EncodeToString(req))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(res.Body)
+		parsed, err := ocsp.ParseResponseForCert(body.Bytes(), leaf, oci.caCert)
+		require.NoError(t, err)
+		assert.Equal(t, ocsp.Good, parsed.Status)
+	})
+	t.Run("unknown issuer", func(t *testing.T) {
+		res, err := http.Post(srv.URL+"/issuer2", "application/ocsp-request", bytes.NewReader(req))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+	// Looking the issuer up again keeps the responder and its certificate.
+	r1, _ := h.Responders("issuer1")
+	r2, _ := h.Responders("issuer1")
+	assert.Same(t, r1[0], r2[0])
+	assert.Equal(t, 1, oci.creates)
+}
+
+func TestHandler_ServeHTTP_authorities(t *testing.T) {
+	const secondaryID = "ocid1.certificateauthority.oc1.phx.aaaaaaaasecondary"
+	const routedCompartmentID = "ocid1.compartment.oc1..routed"
+	primary := newFakeOCI(t, "")
+	secondary := newFakeOCI(t, "")
+	_, primaryLeaf := issueLeaf(t, primary)
+	_, routedLeaf := issueLeafIn(t, secondary, routedCompartmentID)
+	unrelated := newFakeOCI(t, "")
+	_, unrelatedLeaf := issueLeaf(t, unrelated)
+
+	issuers := []Issuer{
+		{AuthorityID: testAuthorityID, CompartmentID: testCompartmentID, CAClient: primary, CertificateClient: primary},
+		{AuthorityID: secondaryID, CompartmentID: testCompartmentID, CompartmentIDs: []string{routedCompartmentID}, CAClient: secondary, CertificateClient: secondary},
+	}
+	h := NewHandler(logr.Discard(), func(name string) ([]Issuer, bool) {
+		return issuers, name == "issuer1"
+	}, Options{})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	post := func(t *testing.T, leaf *x509.Certificate, ca *fakeOCI) []byte {
+		req, err := ocsp.CreateRequest(leaf, ca.caCert, nil)
+		require.NoError(t, err)
+		res, err := http.Post(srv.URL+"/issuer1", "application/ocsp-request", bytes.NewReader(req))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(res.Body)
+		return body.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		leaf *x509.Certificate
+		ca   *fakeOCI
+	}{
+		{name: "primary authority", leaf: primaryLeaf, ca: primary},
+		{name: "secondary authority in a routed compartment", leaf: routedLeaf, ca: secondary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ocsp.ParseResponseForCert(post(t, tt.leaf, tt.ca), tt.leaf, tt.ca.caCert)
+			require.NoError(t, err)
+			assert.Equal(t, ocsp.Good, parsed.Status)
+			assert.NoError(t, parsed.Certificate.CheckSignatureFrom(tt.ca.caCert))
+		})
+	}
+	t.Run("other authority", func(t *testing.T) {
+		assert.Equal(t, ocsp.UnauthorizedErrorResponse, post(t, unrelatedLeaf, unrelated))
+	})
+	t.Run("authority removed", func(t *testing.T) {
+		issuers = issuers[:1]
+		responders, ok := h.Responders("issuer1")
+		require.True(t, ok)
+		require.Len(t, responders, 1)
+		assert.Equal(t, testAuthorityID, responders[0].AuthorityID())
+		assert.Equal(t, ocsp.UnauthorizedErrorResponse, post(t, routedLeaf, secondary))
+	})
+}
+
+func Test_parseSerial(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{in: "0A:1B", want: 0x0a1b, ok: true},
+		{in: "a1b", want: 0xa1b, ok: true},
+		{in: "", ok: false},
+		{in: "zz", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSerial(tt.in)
+		assert.Equal(t, tt.ok, ok, tt.in)
+		if ok {
+			assert.Equal(t, tt.want, got.Int64(), tt.in)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package policy evaluates CSRs against the issuance policy of an issuer.
+package policy
+
+import (
+	"crypto/x509"
+	"fmt"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Rules of an IssuancePolicy, named by their field.
+const (
+	RuleDNSNames       = "dnsNames"
+	RuleIPAddresses    = "ipAddresses"
+	RuleURIs           = "uris"
+	RuleEmailAddresses = "emailAddresses"
+	RuleSubject        = "subject"
+	RuleAllowWildcards = "allowWildcards"
+	RuleMaxDuration    = "maxDuration"
+)
+
+// Violation is a breach of one rule of a policy.
+type Violation struct {
+	// Rule is the policy field breached, such as dnsNames or
+	// subject.organizations.
+	Rule    string
+	Message string
+}
+
+// Violations are the reasons a request breaks a policy.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Evaluate checks the names and subject of csr, and the duration requested
+// for it, against policy. It returns Violations listing every breach, or nil
+// when the request is allowed. Invalid patterns, which the webhook refuses,
+// are reported as errors.
+func Evaluate(policy *ocicav1alpha1.IssuancePolicy, csr *x509.CertificateRequest, duration time.Duration) error {
+	if policy == nil {
+		return nil
+	}
+	var violations Violations
+	check := func(rule, kind string, values []string, names *ocicav1alpha1.NamePolicy, star string, fold bool) error {
+		m, err := newMatcher(names, star, fold)
+		if err != nil {
+			return fmt.Errorf("invalid %s policy: %v", kind, err)
+		}
+		for _, v := range values {
+			if reason := m.denies(v); reason != "" {
+				violations = append(violations, Violation{rule, fmt.Sprintf("%s %q %s", kind, v, reason)})
+			}
+		}
+		return nil
+	}
+
+	if !policy.AllowWildcards {
+		for _, name := range append([]string{csr.Subject.CommonName}, csr.DNSNames...) {
+			if strings.Contains(name, "*") {
+				violations = append(violations, Violation{RuleAllowWildcards, fmt.Sprintf("wildcard %q is not allowed", name)})
+			}
+		}
+	}
+	// Clients may still trust a hostname in the common name, so it must pass
+	// the DNS name rules too.
+	dnsNames := csr.DNSNames
+	if cn := csr.Subject.CommonName; isHostname(cn) && !containsFold(dnsNames, cn) {
+		dnsNames = append([]string{cn}, dnsNames...)
+	}
+	if err := check(RuleDNSNames, "DNS name", dnsNames, policy.DNSNames, `[^.]*`, true); err != nil {
+		return err
+	}
+	uris := make([]string, len(csr.URIs))
+	for i, uri := range csr.URIs {
+		uris[i] = uri.String()
+	}
+	if err := check(RuleURIs, "URI", uris, policy.URIs, `.*`, false); err != nil {
+		return err
+	}
+	if err := check(RuleEmailAddresses, "email address", csr.EmailAddresses, policy.EmailAddresses, `.*`, true); err != nil {
+		return err
+	}
+	if policy.IPAddresses != nil {
+		allow, err := parseCIDRs(policy.IPAddresses.Allow)
+		if err != nil {
+			return fmt.Errorf("invalid IP address policy: %v", err)
+		}
+		deny, err := parseCIDRs(policy.IPAddresses.Deny)
+		if err != nil {
+			return fmt.Errorf("invalid IP address policy: %v", err)
+		}
+		for _, ip := range csr.IPAddresses {
+			if n := contains(deny, ip); n != nil {
+				violations = append(violations, Violation{RuleIPAddresses, fmt.Sprintf("IP address %s is in denied range %s", ip, n)})
+			} else if len(allow) > 0 && contains(allow, ip) == nil {
+				violations = append(violations, Violation{RuleIPAddresses, fmt.Sprintf("IP address %s is not in an allowed range", ip)})
+			}
+		}
+	}
+	if subject := policy.Subject; subject != nil {
+		var commonName []string
+		if csr.Subject.CommonName != "" {
+			commonName = []string{csr.Subject.CommonName}
+		}
+		for _, attr := range []struct {
+			field  string
+			kind   string
+			values []string
+			policy *ocicav1alpha1.NamePolicy
+		}{
+			{"commonName", "common name", commonName, subject.CommonName},
+			{"organizations", "organization", csr.Subject.Organization, subject.Organizations},
+			{"organizationalUnits", "organizational unit", csr.Subject.OrganizationalUnit, subject.OrganizationalUnits},
+			{"countries", "country", csr.Subject.Country, subject.Countries},
+			{"localities", "locality", csr.Subject.Locality, subject.Localities},
+			{"provinces", "province", csr.Subject.Province, subject.Provinces},
+		} {
+			if err := check(RuleSubject+"."+attr.field, attr.kind, attr.values, attr.policy, `.*`, false); err != nil {
+				return err
+			}
+		}
+	}
+	if policy.MaxDuration != nil && duration > policy.MaxDuration.Duration {
+		violations = append(violations, Violation{RuleMaxDuration, fmt.Sprintf("duration %s is longer than the maximum of %s", duration, policy.MaxDuration.Duration)})
+	}
+
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+// isHostname reports whether s looks like a DNS name of at least two labels,
+// possibly with a wildcard label, rather than a free-form common name.
+func isHostname(s string) bool {
+	labels := strings.Split(strings.TrimSuffix(s, "."), ".")
+	if len(labels) < 2 || net.ParseIP(s) != nil {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '*' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matcher applies a NamePolicy. The zero matcher allows everything.
+type matcher struct {
+	allow, deny []*regexp.Regexp
+	fold        bool
+}
+
+// newMatcher compiles the patterns of policy. In globs, * matches the regular
+// expression star. Globs and regular expressions ignore case when fold is
+// set.
+func newMatcher(policy *ocicav1alpha1.NamePolicy, star string, fold bool) (matcher, error) {
+	m := matcher{fold: fold}
+	if policy == nil {
+		return m, nil
+	}
+	prefix := ""
+	if fold {
+		prefix = "(?i)"
+	}
+	for _, globs := range []struct {
+		patterns []string
+		into     *[]*regexp.Regexp
+	}{{policy.Allow, &m.allow}, {policy.Deny, &m.deny}} {
+		for _, glob := range globs.patterns {
+			parts := strings.Split(glob, "*")
+			for i := range parts {
+				parts[i] = regexp.QuoteMeta(parts[i])
+			}
+			*globs.into = append(*globs.into, regexp.MustCompile(prefix+"^"+strings.Join(parts, star)+"$"))
+		}
+	}
+	for _, regexes := range []struct {
+		patterns []string
+		into     *[]*regexp.Regexp
+	}{{policy.AllowRegex, &m.allow}, {policy.DenyRegex, &m.deny}} {
+		for _, pattern := range regexes.patterns {
+			re, err := regexp.Compile(prefix + pattern)
+			if err != nil {
+				return m, err
+			}
+			*regexes.into = append(*regexes.into, re)
+		}
+	}
+	return m, nil
+}
+
+// denies returns why v is denied, or "" when it is allowed.
+func (m matcher) denies(v string) string {
+	if m.fold {
+		v = strings.ToLower(v)
+	}
+	for _, re := range m.deny {
+		if re.MatchString(v) {
+			return "is denied"
+		}
+	}
+	if len(m.allow) == 0 {
+		return ""
+	}
+	for _, re := range m.allow {
+		if re.MatchString(v) {
+			return ""
+		}
+	}
+	return "is not allowed"
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// contains returns the first of nets containing ip.
+func contains(nets []*net.IPNet, ip net.IP) *net.IPNet {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return n
+		}
+	}
+	return nil
+}
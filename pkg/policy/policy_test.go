@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	policy := &ocicav1alpha1.IssuancePolicy{
+		DNSNames: &ocicav1alpha1.NamePolicy{
+			Allow:     []string{"*.example.com", "example.com"},
+			DenyRegex: []string{`^admin\.`},
+		},
+		IPAddresses:    &ocicav1alpha1.CIDRPolicy{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.0/24"}},
+		URIs:           &ocicav1alpha1.NamePolicy{Allow: []string{"spiffe://cluster.local/ns/*"}},
+		EmailAddresses: &ocicav1alpha1.NamePolicy{Deny: []string{"*@gmail.com"}},
+		Subject: &ocicav1alpha1.SubjectPolicy{
+			Organizations: &ocicav1alpha1.NamePolicy{Allow: []string{"Example*"}},
+			Countries:     &ocicav1alpha1.NamePolicy{AllowRegex: []string{"^(GB|US)$"}},
+		},
+		MaxDuration: &metav1.Duration{Duration: 30 * 24 * time.Hour},
+	}
+	mustParseURL := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	tests := []struct {
+		name           string
+		policy         *ocicav1alpha1.IssuancePolicy
+		csr            x509.CertificateRequest
+		duration       time.Duration
+		wantViolations int
+		wantRule       string
+	}{
+		{name: "no policy", csr: x509.CertificateRequest{DNSNames: []string{"*.anything.org"}}, duration: time.Hour * 24 * 365},
+		{
+			name:   "allowed",
+			policy: policy,
+			csr: x509.CertificateRequest{
+				Subject:        pkix.Name{CommonName: "web.example.com", Organization: []string{"Example Ltd"}, Country: []string{"GB"}},
+				DNSNames:       []string{"web.example.com", "Example.com"},
+				IPAddresses:    []net.IP{net.ParseIP("10.1.2.3")},
+				URIs:           []*url.URL{mustParseURL("spiffe://cluster.local/ns/default/sa/web")},
+				EmailAddresses: []string{"ops@example.com"},
+			},
+			duration: 24 * time.Hour,
+		},
+		{name: "DNS name outside the allowed domains", policy: policy, csr: x509.CertificateRequest{DNSNames: []string{"example.org"}}, wantViolations: 1, wantRule: RuleDNSNames},
+		{
+			name:           "common name outside the allowed domains",
+			policy:         policy,
+			csr:            x509.CertificateRequest{Subject: pkix.Name{CommonName: "evil.example.org"}, DNSNames: []string{"web.example.com"}},
+			wantViolations: 1,
+			wantRule:       RuleDNSNames,
+		},
+		{name: "denied common name", policy: policy, csr: x509.CertificateRequest{Subject: pkix.Name{CommonName: "ADMIN.example.com"}}, wantViolations: 1},
+		{name: "common name that is not a hostname", policy: policy, csr: x509.CertificateRequest{Subject: pkix.Name{CommonName: "Web Server"}}},
+		{
+			name:     "regular expressions ignore case",
+			policy:   &ocicav1alpha1.IssuancePolicy{DNSNames: &ocicav1alpha1.NamePolicy{AllowRegex: []string{`^[a-z]+\.Example\.com$`}}},
+			csr:      x509.CertificateRequest{DNSNames: []string{"WEB.example.COM"}},
+			duration: time.Hour,
+		},
+		{name: "glob matches a single label", policy: policy, csr: x509.CertificateRequest{DNSNames: []string{"a.b.example.com"}}, wantViolations: 1},
+		{name: "denied DNS name", policy: policy, csr: x509.CertificateRequest{DNSNames: []string{"admin.example.com"}}, wantViolations: 1},
+		{name: "wildcard", policy: policy, csr: x509.CertificateRequest{DNSNames: []string{"*.example.com"}}, wantViolations: 1, wantRule: RuleAllowWildcards},
+		{
+			name:     "allowed wildcard",
+			policy:   &ocicav1alpha1.IssuancePolicy{AllowWildcards: true, DNSNames: policy.DNSNames},
+			csr:      x509.CertificateRequest{DNSNames: []string{"*.example.com"}},
+			duration: time.Hour,
+		},
+		{name: "denied IP", policy: policy, csr: x509.CertificateRequest{IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}, wantViolations: 1, wantRule: RuleIPAddresses},
+		{name: "IP outside the allowed ranges", policy: policy, csr: x509.CertificateRequest{IPAddresses: []net.IP{net.ParseIP("192.168.0.1")}}, wantViolations: 1},
+		{name: "URI", policy: policy, csr: x509.CertificateRequest{URIs: []*url.URL{mustParseURL("https://example.com")}}, wantViolations: 1},
+		{name: "email", policy: policy, csr: x509.CertificateRequest{EmailAddresses: []string{"me@GMail.com"}}, wantViolations: 1},
+		{
+			name:           "subject",
+			policy:         policy,
+			csr:            x509.CertificateRequest{Subject: pkix.Name{Organization: []string{"Other"}, Country: []string{"FR"}}},
+			wantViolations: 2,
+			wantRule:       "subject.organizations",
+		},
+		{name: "duration", policy: policy, duration: 90 * 24 * time.Hour, wantViolations: 1, wantRule: RuleMaxDuration},
+		{
+			name:           "every violation is reported",
+			policy:         policy,
+			csr:            x509.CertificateRequest{DNSNames: []string{"example.org", "admin.example.com"}},
+			duration:       90 * 24 * time.Hour,
+			wantViolations: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Evaluate(tt.policy, &tt.csr, tt.duration)
+			var violations Violations
+			if err != nil && !errors.As(err, &violations) {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if len(violations) != tt.wantViolations {
+				t.Errorf("Evaluate() violations got = %q, want %d", violations, tt.wantViolations)
+			}
+			if tt.wantRule != "" && len(violations) > 0 && violations[0].Rule != tt.wantRule {
+				t.Errorf("Evaluate() rule got = %s, want %s", violations[0].Rule, tt.wantRule)
+			}
+		})
+	}
+
+	invalid := &ocicav1alpha1.IssuancePolicy{DNSNames: &ocicav1alpha1.NamePolicy{AllowRegex: []string{"("}}}
+	var violations Violations
+	if err := Evaluate(invalid, &x509.CertificateRequest{}, time.Hour); err == nil || errors.As(err, &violations) {
+		t.Errorf("Evaluate() of an invalid policy got err = %v, want an error other than Violations", err)
+	}
+}
@@ -0,0 +1,70 @@
+package provisioner
+
+import (
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+)
+
+const (
+	// SecretTenancyKey The API key Secret key holding the tenancy OCID
+	SecretTenancyKey = "tenancy"
+	// SecretUserKey The API key Secret key holding the user OCID
+	SecretUserKey = "user"
+	// SecretFingerprintKey The API key Secret key holding the key fingerprint
+	SecretFingerprintKey = "fingerprint"
+	// SecretRegionKey The optional API key Secret key holding the region, the
+	// certificate authority region is used when it is missing
+	SecretRegionKey = "region"
+	// SecretPrivateKeyKey The API key Secret key holding the PEM encoded private key
+	SecretPrivateKeyKey = "privateKey"
+	// SecretPassphraseKey The optional API key Secret key holding the private key passphrase
+	SecretPassphraseKey = "passphrase"
+)
+
+// ConfigurationProvider returns the OCI credentials selected by the issuer's
+// auth configuration. secret holds the data of the API key Secret in APIKey
+// mode and is ignored otherwise.
+func ConfigurationProvider(spec ocicav1alpha1.OCICAClusterIssuerSpec, secret map[string][]byte) (common.ConfigurationProvider, error) {
+	mode := ocicav1alpha1.AuthModeConfigFile
+	profile := ocicav1alpha1.DefaultAuthProfile
+	if spec.Auth != nil {
+		if spec.Auth.Mode != "" {
+			mode = spec.Auth.Mode
+		}
+		if spec.Auth.Profile != "" {
+			profile = spec.Auth.Profile
+		}
+	}
+
+	switch mode {
+	case ocicav1alpha1.AuthModeConfigFile:
+		if profile == ocicav1alpha1.DefaultAuthProfile {
+			return common.DefaultConfigProvider(), nil
+		}
+		return common.CustomProfileConfigProvider("", profile), nil
+	case ocicav1alpha1.AuthModeInstancePrincipal:
+		return auth.InstancePrincipalConfigurationProvider()
+	case ocicav1alpha1.AuthModeAPIKey:
+		for _, key := range []string{SecretTenancyKey, SecretUserKey, SecretFingerprintKey, SecretPrivateKeyKey} {
+			if len(secret[key]) == 0 {
+				return nil, fmt.Errorf("API key secret is missing %q", key)
+			}
+		}
+		var passphrase *string
+		if p, ok := secret[SecretPassphraseKey]; ok {
+			passphrase = common.String(string(p))
+		}
+		return common.NewRawConfigurationProvider(
+			string(secret[SecretTenancyKey]),
+			string(secret[SecretUserKey]),
+			string(secret[SecretRegionKey]),
+			string(secret[SecretFingerprintKey]),
+			string(secret[SecretPrivateKeyKey]),
+			passphrase,
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", mode)
+	}
+}
@@ -0,0 +1,41 @@
+package provisioner
+
+import (
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"testing"
+)
+
+func TestConfigurationProvider(t *testing.T) {
+	apiKey := map[string][]byte{
+		SecretTenancyKey:     []byte("ocid1.tenancy.oc1..test"),
+		SecretUserKey:        []byte("ocid1.user.oc1..test"),
+		SecretFingerprintKey: []byte("00:11"),
+		SecretRegionKey:      []byte("us-phoenix-1"),
+		SecretPrivateKeyKey:  []byte("key"),
+	}
+	tests := []struct {
+		name    string
+		auth    *ocicav1alpha1.OCIAuth
+		secret  map[string][]byte
+		wantErr bool
+	}{
+		{name: "default", auth: nil, wantErr: false},
+		{name: "config file profile", auth: &ocicav1alpha1.OCIAuth{Mode: ocicav1alpha1.AuthModeConfigFile, Profile: "OTHER"}, wantErr: false},
+		{name: "api key", auth: &ocicav1alpha1.OCIAuth{Mode: ocicav1alpha1.AuthModeAPIKey, SecretName: "oci"}, secret: apiKey, wantErr: false},
+		{name: "api key missing data", auth: &ocicav1alpha1.OCIAuth{Mode: ocicav1alpha1.AuthModeAPIKey, SecretName: "oci"}, secret: map[string][]byte{}, wantErr: true},
+		{name: "unknown mode", auth: &ocicav1alpha1.OCIAuth{Mode: "Magic"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := ocicav1alpha1.OCICAClusterIssuerSpec{Auth: tt.auth}
+			got, err := ConfigurationProvider(spec, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ConfigurationProvider() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got == nil {
+				t.Errorf("ConfigurationProvider() returned no provider")
+			}
+		})
+	}
+}
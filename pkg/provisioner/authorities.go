@@ -0,0 +1,233 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Authority is a certificate authority of an issuer and the provisioner
+// signing with it.
+type Authority struct {
+	ID          string
+	Priority    int32
+	Weight      int32
+	Provisioner *Provisioner
+
+	// Err is why the last validation of the authority failed, or nil when
+	// it is available.
+	Err error
+}
+
+// Authorities are the certificate authorities of an issuer, ordered by
+// priority, and choose which of them signs each request.
+type Authorities struct {
+	selection   ocicav1alpha1.AuthoritySelection
+	authorities []*Authority
+	// next counts the requests of round-robin selection.
+	next uint32
+	// intn returns a random number in [0, n) for weighted selection.
+	intn func(n int) int
+
+	// retired are authorities that no longer sign but whose certificates
+	// are still in use.
+	retired *Authorities
+}
+
+// newAuthorities returns authorities sorted by priority, keeping the order of
+// those of the same priority.
+func newAuthorities(selection ocicav1alpha1.AuthoritySelection, authorities []*Authority) *Authorities {
+	sort.SliceStable(authorities, func(i, j int) bool {
+		return authorities[i].Priority < authorities[j].Priority
+	})
+	return &Authorities{selection: selection, authorities: authorities, intn: rand.Intn}
+}
+
+// NewAuthorities returns a provisioner for each certificate authority of iss,
+// each signing in the compartment and region of its authority.
+func NewAuthorities(logger logr.Logger, iss ocicav1alpha1.OCICAClusterIssuer, configProvider common.ConfigurationProvider, transport Transport) (*Authorities, error) {
+	var authorities []*Authority
+	for _, ca := range iss.Spec.CertificateAuthorities() {
+		authorityIss := iss.DeepCopy()
+		authorityIss.Spec.AuthorityID = ca.AuthorityID
+		authorityIss.Spec.CompartmentID = ca.CompartmentID
+		authorityIss.Spec.Region = ca.Region
+		authorityIss.Spec.Authorities = nil
+		p, err := New(logger.WithValues("authority", ca.AuthorityID), *authorityIss, configProvider, transport)
+		if err != nil {
+			return nil, fmt.Errorf("certificate authority %s: %w", ca.AuthorityID, err)
+		}
+		authorities = append(authorities, &Authority{ID: ca.AuthorityID, Priority: ca.Priority, Weight: ca.Weight, Provisioner: p})
+	}
+	return newAuthorities(iss.Spec.AuthoritySelection, authorities), nil
+}
+
+// Validate validates every authority, recording why those that fail are
+// unavailable. It returns an error when none is available.
+func (a *Authorities) Validate(ctx context.Context) error {
+	var failures []string
+	for _, authority := range a.authorities {
+		authority.Err = authority.Provisioner.Validate(ctx)
+		if authority.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", authority.ID, authority.Err))
+		}
+	}
+	switch {
+	case len(failures) < len(a.authorities):
+		return nil
+	case len(a.authorities) == 1:
+		return a.authorities[0].Err
+	}
+	return fmt.Errorf("no certificate authority is available: %s", strings.Join(failures, "; "))
+}
+
+// Status returns the health of each authority found by Validate.
+func (a *Authorities) Status() []ocicav1alpha1.AuthorityStatus {
+	status := make([]ocicav1alpha1.AuthorityStatus, 0, len(a.authorities))
+	for _, authority := range a.authorities {
+		s := ocicav1alpha1.AuthorityStatus{AuthorityID: authority.ID, Ready: authority.Err == nil}
+		if authority.Err != nil {
+			s.Message = authority.Err.Error()
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// Unavailable returns the OCIDs of the authorities Validate found
+// unavailable.
+func (a *Authorities) Unavailable() []string {
+	var ids []string
+	for _, authority := range a.authorities {
+		if authority.Err != nil {
+			ids = append(ids, authority.ID)
+		}
+	}
+	return ids
+}
+
+// Primary returns the available authority of the lowest priority, which
+// signs everything but CertificateRequests, or nil when none is available.
+func (a *Authorities) Primary() *Authority {
+	for _, authority := range a.authorities {
+		if authority.Err == nil {
+			return authority
+		}
+	}
+	return nil
+}
+
+// Candidates returns the available authorities in the order a request should
+// try them: the one chosen by the selection strategy first, then the others
+// by priority for failover.
+func (a *Authorities) Candidates() []*Authority {
+	var available []*Authority
+	for _, authority := range a.authorities {
+		if authority.Err == nil {
+			available = append(available, authority)
+		}
+	}
+	if len(available) < 2 {
+		return available
+	}
+	first := 0
+	switch a.selection {
+	case ocicav1alpha1.AuthoritySelectionRoundRobin:
+		first = int((atomic.AddUint32(&a.next, 1) - 1) % uint32(len(available)))
+	case ocicav1alpha1.AuthoritySelectionWeighted:
+		first = a.weighted(available)
+	}
+	candidates := append([]*Authority{available[first]}, available[:first]...)
+	return append(candidates, available[first+1:]...)
+}
+
+// TrustAnchors returns the distinct root certificates of all authorities,
+// including those that are unavailable, in order of priority. It fails when
+// one of them cannot be fetched, so that a root is never dropped from a trust
+// bundle because of an outage.
+func (a *Authorities) TrustAnchors(ctx context.Context) ([][]byte, error) {
+	var anchors [][]byte
+	seen := map[string]bool{}
+	for _, authority := range a.authorities {
+		anchor, err := authority.Provisioner.TrustAnchor(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("certificate authority %s: %w", authority.ID, err)
+		}
+		if !seen[string(anchor)] {
+			seen[string(anchor)] = true
+			anchors = append(anchors, anchor)
+		}
+	}
+	return anchors, nil
+}
+
+// Retire adds authorities that no longer sign but whose certificates are
+// still in use, so that the OCSP responder keeps answering for them.
+func (a *Authorities) Retire(retired *Authorities) {
+	a.retired = retired
+}
+
+// Retired returns the authorities added by Retire, or nil.
+func (a *Authorities) Retired() *Authorities {
+	return a.retired
+}
+
+// AddRoutedCompartments adds compartments the issuer routes certificates to
+// that its spec does not name, such as those namespaces choose with an
+// annotation, to those certificates are looked up in.
+func (a *Authorities) AddRoutedCompartments(ids []string) {
+	for _, authority := range a.all() {
+		authority.Provisioner.routedCompartmentIDs = append(authority.Provisioner.routedCompartmentIDs, ids...)
+	}
+}
+
+// LastExpiry returns when the last certificate signed by any of the
+// authorities expires, or the zero time when they signed none.
+func (a *Authorities) LastExpiry(ctx context.Context) (time.Time, error) {
+	var last time.Time
+	for _, authority := range a.authorities {
+		expiry, err := authority.Provisioner.LastExpiry(ctx)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("certificate authority %s: %w", authority.ID, err)
+		}
+		if expiry.After(last) {
+			last = expiry
+		}
+	}
+	return last, nil
+}
+
+// all returns the authorities followed by the retired ones.
+func (a *Authorities) all() []*Authority {
+	if a.retired == nil {
+		return a.authorities
+	}
+	return append(append([]*Authority(nil), a.authorities...), a.retired.authorities...)
+}
+
+// weighted returns the index of an authority of available picked in
+// proportion to its weight, or 0 when none has a weight.
+func (a *Authorities) weighted(available []*Authority) int {
+	total := 0
+	for _, authority := range available {
+		total += int(authority.Weight)
+	}
+	if total <= 0 {
+		return 0
+	}
+	n := a.intn(total)
+	for i, authority := range available {
+		n -= int(authority.Weight)
+		if n < 0 {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,165 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuthorities_Candidates(t *testing.T) {
+	unavailable := errors.New("certificate authority is PENDING_DELETION")
+	newTestAuthorities := func(selection ocicav1alpha1.AuthoritySelection) *Authorities {
+		return newAuthorities(selection, []*Authority{
+			{ID: "c", Priority: 1, Weight: 1},
+			{ID: "a", Weight: 1},
+			{ID: "down", Weight: 5, Err: unavailable},
+			{ID: "b", Weight: 2},
+		})
+	}
+	ids := func(candidates []*Authority) []string {
+		var ids []string
+		for _, c := range candidates {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	if got := ids(newTestAuthorities(ocicav1alpha1.AuthoritySelectionPriority).Candidates()); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Priority Candidates() = %v, want a, b, c", got)
+	}
+	if got := ids(newTestAuthorities("").Candidates()); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("default Candidates() = %v, want a, b, c", got)
+	}
+
+	roundRobin := newTestAuthorities(ocicav1alpha1.AuthoritySelectionRoundRobin)
+	for _, want := range [][]string{{"a", "b", "c"}, {"b", "a", "c"}, {"c", "a", "b"}, {"a", "b", "c"}} {
+		if got := ids(roundRobin.Candidates()); !reflect.DeepEqual(got, want) {
+			t.Errorf("RoundRobin Candidates() = %v, want %v", got, want)
+		}
+	}
+
+	// Weights of a, b and c are 1, 2 and 1, so draws 0 to 3 pick a, b, b
+	// and c.
+	weighted := newTestAuthorities(ocicav1alpha1.AuthoritySelectionWeighted)
+	for draw, want := range [][]string{{"a", "b", "c"}, {"b", "a", "c"}, {"b", "a", "c"}, {"c", "a", "b"}} {
+		weighted.intn = func(n int) int {
+			if n != 4 {
+				t.Fatalf("weighted draw from %d, want 4", n)
+			}
+			return draw
+		}
+		if got := ids(weighted.Candidates()); !reflect.DeepEqual(got, want) {
+			t.Errorf("Weighted Candidates() with draw %d = %v, want %v", draw, got, want)
+		}
+	}
+
+	standby := newAuthorities(ocicav1alpha1.AuthoritySelectionWeighted, []*Authority{{ID: "a"}, {ID: "b"}})
+	standby.intn = func(int) int {
+		t.Fatal("weighted draw without weights")
+		return 0
+	}
+	if got := ids(standby.Candidates()); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Weighted Candidates() without weights = %v, want a, b", got)
+	}
+}
+
+func TestNewAuthorities(t *testing.T) {
+	ctx := context.TODO()
+	fake := ocifake.NewServer(ocifake.Options{})
+	defer fake.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	primaryID, err := fake.CreateRootCA(compartmentID, "primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondaryID, err := fake.CreateRootCA(compartmentID, "secondary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fake.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+		CompartmentID: compartmentID,
+		Authorities: []ocicav1alpha1.CertificateAuthority{
+			{AuthorityID: secondaryID, CompartmentID: "ocid1.compartment.oc1..secondary", Priority: 1},
+			{AuthorityID: primaryID},
+		},
+		Compartments: &ocicav1alpha1.CompartmentRouting{
+			Namespaces: map[string]string{"team-a": "ocid1.compartment.oc1..team-a"},
+		},
+		EndpointOverride: &ocicav1alpha1.EndpointOverride{CertificatesManagement: fake.URL, Certificates: fake.URL},
+	}}
+	authorities, err := NewAuthorities(logr.Discard(), iss, configProvider, Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := authorities.Validate(ctx); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	primary := authorities.Primary()
+	if primary == nil || primary.ID != primaryID || primary.Provisioner.AuthorityID() != primaryID || primary.Provisioner.compartmentID != compartmentID {
+		t.Fatalf("Primary() = %+v, want %s in the issuer compartment", primary, primaryID)
+	}
+	if secondary := authorities.Candidates()[1]; secondary.Provisioner.compartmentID != "ocid1.compartment.oc1..secondary" {
+		t.Errorf("secondary compartment = %s", secondary.Provisioner.compartmentID)
+	}
+
+	authorities.AddRoutedCompartments([]string{"ocid1.compartment.oc1..annotated"})
+	issuers := authorities.OCSPIssuers()
+	if len(issuers) != 2 || issuers[0].AuthorityID != primaryID || issuers[1].AuthorityID != secondaryID ||
+		issuers[1].CompartmentID != "ocid1.compartment.oc1..secondary" {
+		t.Fatalf("OCSPIssuers() = %+v, want %s then %s", issuers, primaryID, secondaryID)
+	}
+	for _, issuer := range issuers {
+		want := []string{issuer.CompartmentID, "ocid1.compartment.oc1..team-a", "ocid1.compartment.oc1..annotated"}
+		got := map[string]bool{issuer.CompartmentID: true}
+		for _, id := range issuer.CompartmentIDs {
+			got[id] = true
+		}
+		for _, id := range want {
+			if !got[id] {
+				t.Errorf("OCSPIssuers() for %s looks up compartments %v, want %s", issuer.AuthorityID, issuer.CompartmentIDs, id)
+			}
+		}
+	}
+
+	if err := fake.SetCertificateAuthorityState(primaryID, certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion); err != nil {
+		t.Fatal(err)
+	}
+	if err := authorities.Validate(ctx); err != nil {
+		t.Fatalf("Validate() with a disabled authority error = %v", err)
+	}
+	if primary := authorities.Primary(); primary == nil || primary.ID != secondaryID {
+		t.Errorf("Primary() with a disabled authority = %+v, want %s", primary, secondaryID)
+	}
+	if got := authorities.Unavailable(); !reflect.DeepEqual(got, []string{primaryID}) {
+		t.Errorf("Unavailable() = %v, want %s", got, primaryID)
+	}
+	// Certificates of an unavailable authority are still answered for.
+	if issuers := authorities.OCSPIssuers(); len(issuers) != 2 || issuers[0].AuthorityID != primaryID {
+		t.Errorf("OCSPIssuers() with a disabled authority = %+v, want %s first", issuers, primaryID)
+	}
+	status := authorities.Status()
+	if len(status) != 2 || status[0].AuthorityID != primaryID || status[0].Ready || status[0].Message == "" || !status[1].Ready {
+		t.Errorf("Status() = %+v, want %s unavailable", status, primaryID)
+	}
+
+	if err := fake.SetCertificateAuthorityState(secondaryID, certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion); err != nil {
+		t.Fatal(err)
+	}
+	err = authorities.Validate(ctx)
+	if err == nil || !strings.Contains(err.Error(), primaryID) || !strings.Contains(err.Error(), secondaryID) {
+		t.Errorf("Validate() with no available authority error = %v, want both authorities", err)
+	}
+	if primary := authorities.Primary(); primary != nil {
+		t.Errorf("Primary() with no available authority = %s", primary.ID)
+	}
+}
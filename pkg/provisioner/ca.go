@@ -0,0 +1,164 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"strings"
+	"time"
+)
+
+// ErrPolicyViolation is returned by Sign when the issuer's policy refuses a
+// request.
+var ErrPolicyViolation = errors.New("refused by issuer policy")
+
+var oidExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+
+// basicConstraints is the basic constraints extension of RFC 5280, 4.2.1.9.
+type basicConstraints struct {
+	IsCA       bool `asn1:"optional"`
+	MaxPathLen int  `asn1:"optional,default:-1"`
+}
+
+// requestedBasicConstraints returns the basic constraints requested by csr.
+// MaxPathLen is -1 when the CSR sets no path length constraint.
+func requestedBasicConstraints(csr *x509.CertificateRequest) (basicConstraints, error) {
+	constraints := basicConstraints{MaxPathLen: -1}
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(oidExtensionBasicConstraints) {
+			continue
+		}
+		if rest, err := asn1.Unmarshal(ext.Value, &constraints); err != nil {
+			return constraints, fmt.Errorf("invalid basic constraints in CSR: %v", err)
+		} else if len(rest) != 0 {
+			return constraints, fmt.Errorf("invalid basic constraints in CSR: trailing data")
+		}
+	}
+	return constraints, nil
+}
+
+// checkCARequest checks that a CSR only asks for a CA certificate when cr sets
+// isCA, and that such requests are allowed by the issuer and the path length
+// of the issuing certificate authority.
+func (p *Provisioner) checkCARequest(ctx context.Context, cr *cmapi.CertificateRequest, csr *x509.CertificateRequest) error {
+	constraints, err := requestedBasicConstraints(csr)
+	if err != nil {
+		return err
+	}
+	if !cr.Spec.IsCA {
+		if constraints.IsCA {
+			return fmt.Errorf("%w: CSR requests a CA certificate but isCA is not set", ErrPolicyViolation)
+		}
+		return nil
+	}
+
+	policy := p.iss.Spec.SubordinateCA
+	if policy == nil || !policy.Allow {
+		return fmt.Errorf("%w: issuer does not allow CA certificates", ErrPolicyViolation)
+	}
+	if !constraints.IsCA {
+		return fmt.Errorf("%w: isCA is set but the CSR does not request a CA certificate", ErrPolicyViolation)
+	}
+	if policy.MaxPathLength != nil {
+		if constraints.MaxPathLen < 0 {
+			return fmt.Errorf("%w: CSR has no path length constraint, the issuer allows at most %d", ErrPolicyViolation, *policy.MaxPathLength)
+		}
+		if constraints.MaxPathLen > *policy.MaxPathLength {
+			return fmt.Errorf("%w: CSR path length constraint %d exceeds the issuer maximum of %d",
+				ErrPolicyViolation, constraints.MaxPathLen, *policy.MaxPathLength)
+		}
+	}
+
+	res, err := p.certificateClient.GetCertificateAuthorityBundle(ctx, certificates.GetCertificateAuthorityBundleRequest{
+		CertificateAuthorityId: common.String(p.iss.Spec.AuthorityID),
+	})
+	if err != nil {
+		return err
+	}
+	if res.CertificatePem == nil {
+		return fmt.Errorf("certificate authority bundle has no certificate")
+	}
+	ca, err := pki.DecodeX509CertificateBytes([]byte(*res.CertificatePem))
+	if err != nil {
+		return err
+	}
+	switch {
+	case ca.MaxPathLen == 0 && ca.MaxPathLenZero:
+		return fmt.Errorf("%w: certificate authority %s does not allow subordinate CAs", ErrPolicyViolation, p.iss.Spec.AuthorityID)
+	case ca.MaxPathLen > 0 && (constraints.MaxPathLen < 0 || constraints.MaxPathLen >= ca.MaxPathLen):
+		return fmt.Errorf("%w: certificate authority %s only allows subordinate CAs with a path length constraint below %d",
+			ErrPolicyViolation, p.iss.Spec.AuthorityID, ca.MaxPathLen)
+	}
+	return nil
+}
+
+// checkCA checks that cert is a CA certificate able to sign certificates when
+// cr sets isCA, and a leaf certificate otherwise.
+func checkCA(cert *x509.Certificate, cr *cmapi.CertificateRequest) error {
+	isCA := cert.BasicConstraintsValid && cert.IsCA
+	if isCA != cr.Spec.IsCA {
+		return fmt.Errorf("issued certificate has isCA %t, want %t", isCA, cr.Spec.IsCA)
+	}
+	if isCA && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("issued CA certificate does not allow certificate signing")
+	}
+	return nil
+}
+
+// TrustAnchor returns the PEM encoded root certificate of the certificate
+// authority, which clients trust to verify the certificates it signs.
+func (p *Provisioner) TrustAnchor(ctx context.Context) ([]byte, error) {
+	res, err := p.certificateClient.GetCertificateAuthorityBundle(ctx, certificates.GetCertificateAuthorityBundleRequest{
+		CertificateAuthorityId: common.String(p.iss.Spec.AuthorityID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.CertificatePem == nil {
+		return nil, fmt.Errorf("certificate authority bundle has no certificate")
+	}
+	if res.CertChainPem == nil {
+		return []byte(strings.TrimSpace(*res.CertificatePem) + "\n"), nil
+	}
+	_, root, err := splitBundle(res.CertificatePem, res.CertChainPem)
+	return root, err
+}
+
+// LastExpiry returns when the last certificate signed by p's authority
+// expires, out of the current versions of the certificates in the
+// compartments the issuer routes certificates to, or the zero time when there
+// are none.
+func (p *Provisioner) LastExpiry(ctx context.Context) (time.Time, error) {
+	var last time.Time
+	for _, compartmentID := range p.compartments() {
+		var page *string
+		for {
+			res, err := p.caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+				CompartmentId:                common.String(compartmentID),
+				IssuerCertificateAuthorityId: common.String(p.iss.Spec.AuthorityID),
+				Page:                         page,
+			})
+			if err != nil {
+				return time.Time{}, fmt.Errorf("cannot list certificates in compartment %s: %w", compartmentID, err)
+			}
+			for _, cert := range res.Items {
+				v := cert.CurrentVersionSummary
+				if v != nil && v.Validity != nil && v.Validity.TimeOfValidityNotAfter != nil && v.Validity.TimeOfValidityNotAfter.After(last) {
+					last = v.Validity.TimeOfValidityNotAfter.Time
+				}
+			}
+			if res.OpcNextPage == nil {
+				break
+			}
+			page = res.OpcNextPage
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,171 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestProvisioner_Sign_CA(t *testing.T) {
+	ctx := context.TODO()
+	fake := ocifake.NewServer(ocifake.Options{})
+	defer fake.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	rootID, err := fake.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fake.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// csr returns a CSR asking for a CA certificate with pathLen, or a leaf
+	// certificate when pathLen is nil. -1 asks for no path length constraint.
+	csr := func(pathLen *int) []byte {
+		tmpl := &x509.CertificateRequest{Subject: pkix.Name{CommonName: "subordinate"}}
+		if pathLen != nil {
+			tmpl.ExtraExtensions = []pkix.Extension{
+				{
+					Id:       oidExtensionBasicConstraints,
+					Critical: true,
+					Value:    mustMarshal(t, basicConstraints{IsCA: true, MaxPathLen: *pathLen}),
+				},
+				{
+					// digitalSignature and keyCertSign
+					Id:       asn1.ObjectIdentifier{2, 5, 29, 15},
+					Critical: true,
+					Value:    mustMarshal(t, asn1.BitString{Bytes: []byte{0x84}, BitLength: 6}),
+				},
+			}
+		}
+		der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	}
+
+	tests := []struct {
+		name       string
+		policy     *ocicav1alpha1.SubordinateCAPolicy
+		isCA       bool
+		csr        []byte
+		wantPolicy bool
+	}{
+		{name: "allowed", policy: &ocicav1alpha1.SubordinateCAPolicy{Allow: true, MaxPathLength: intPtr(0)}, isCA: true, csr: csr(intPtr(0))},
+		{name: "unconstrained", policy: &ocicav1alpha1.SubordinateCAPolicy{Allow: true}, isCA: true, csr: csr(intPtr(-1))},
+		{name: "not allowed", isCA: true, csr: csr(intPtr(0)), wantPolicy: true},
+		{name: "CSR without isCA", policy: &ocicav1alpha1.SubordinateCAPolicy{Allow: true}, csr: csr(intPtr(0)), wantPolicy: true},
+		{name: "isCA without CA CSR", policy: &ocicav1alpha1.SubordinateCAPolicy{Allow: true}, isCA: true, csr: csr(nil), wantPolicy: true},
+		{
+			name:       "missing path length",
+			policy:     &ocicav1alpha1.SubordinateCAPolicy{Allow: true, MaxPathLength: intPtr(1)},
+			isCA:       true,
+			csr:        csr(intPtr(-1)),
+			wantPolicy: true,
+		},
+		{
+			name:       "path length too long",
+			policy:     &ocicav1alpha1.SubordinateCAPolicy{Allow: true, MaxPathLength: intPtr(0)},
+			isCA:       true,
+			csr:        csr(intPtr(1)),
+			wantPolicy: true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(logr.Discard(), ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+				CompartmentID:    compartmentID,
+				AuthorityID:      rootID,
+				EndpointOverride: &ocicav1alpha1.EndpointOverride{CertificatesManagement: fake.URL, Certificates: fake.URL},
+				SubordinateCA:    tt.policy,
+			}}, configProvider, Transport{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cr := &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "ca-" + string(rune('a'+i))},
+				Spec:       cmapi.CertificateRequestSpec{Request: tt.csr, IsCA: tt.isCA},
+			}
+			if tt.isCA {
+				cr.Spec.Usages = []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageCertSign}
+			}
+			cert, ca, err := p.Sign(ctx, cr, SignOptions{}, logr.Discard())
+			if tt.wantPolicy {
+				if !errors.Is(err, ErrPolicyViolation) {
+					t.Fatalf("Sign() error = %v, want a policy violation", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			// The subordinate can sign leaf certificates chaining to the root,
+			// as the cert-manager CA issuer would.
+			subordinate, err := x509.ParseCertificate(mustDecodePEM(t, cert))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !subordinate.IsCA {
+				t.Fatalf("issued certificate is not a CA")
+			}
+			leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+				SerialNumber: big.NewInt(1),
+				DNSNames:     []string{"leaf.example.com"},
+				NotBefore:    time.Now().Add(-time.Minute),
+				NotAfter:     time.Now().Add(time.Hour),
+				ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			}, subordinate, leafKey.Public(), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			leaf, err := x509.ParseCertificate(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roots := x509.NewCertPool()
+			roots.AppendCertsFromPEM(ca)
+			intermediates := x509.NewCertPool()
+			intermediates.AddCert(subordinate)
+			if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "leaf.example.com", Roots: roots, Intermediates: intermediates}); err != nil {
+				t.Errorf("leaf signed by the subordinate does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func mustDecodePEM(t *testing.T, data []byte) []byte {
+	t.Helper()
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no PEM data")
+	}
+	return block.Bytes
+}
@@ -0,0 +1,126 @@
+package provisioner
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"strings"
+)
+
+// ErrIncompatibleCSR is returned by Sign when OCI cannot issue a certificate
+// for the CSR of a request.
+var ErrIncompatibleCSR = errors.New("incompatible CSR")
+
+// maxSubjectAlternativeNames is the most subject alternative names of all
+// types a CSR may carry.
+const maxSubjectAlternativeNames = 100
+
+// csrSignatureAlgorithms are the CSR signature algorithms OCI accepts.
+var csrSignatureAlgorithms = map[x509.SignatureAlgorithm]certificatesmanagement.SignatureAlgorithmEnum{
+	x509.SHA256WithRSA:   certificatesmanagement.SignatureAlgorithmSha256WithRsa,
+	x509.SHA384WithRSA:   certificatesmanagement.SignatureAlgorithmSha384WithRsa,
+	x509.SHA512WithRSA:   certificatesmanagement.SignatureAlgorithmSha512WithRsa,
+	x509.ECDSAWithSHA256: certificatesmanagement.SignatureAlgorithmSha256WithEcdsa,
+	x509.ECDSAWithSHA384: certificatesmanagement.SignatureAlgorithmSha384WithEcdsa,
+	x509.ECDSAWithSHA512: certificatesmanagement.SignatureAlgorithmSha512WithEcdsa,
+}
+
+// subjectLimits are the upper bounds of RFC 5280, appendix A.1, on the
+// subject attributes OCI copies into certificates.
+var subjectLimits = []struct {
+	name   string
+	values func(pkix.Name) []string
+	max    int
+}{
+	{"common name", func(n pkix.Name) []string { return []string{n.CommonName} }, 64},
+	{"serial number", func(n pkix.Name) []string { return []string{n.SerialNumber} }, 64},
+	{"organization", func(n pkix.Name) []string { return n.Organization }, 64},
+	{"organizational unit", func(n pkix.Name) []string { return n.OrganizationalUnit }, 64},
+	{"locality", func(n pkix.Name) []string { return n.Locality }, 128},
+	{"province", func(n pkix.Name) []string { return n.Province }, 128},
+	{"street address", func(n pkix.Name) []string { return n.StreetAddress }, 128},
+	{"postal code", func(n pkix.Name) []string { return n.PostalCode }, 40},
+	{"country", func(n pkix.Name) []string { return n.Country }, 2},
+}
+
+// keyAlgorithm returns the OCI key algorithm of pub.
+func keyAlgorithm(pub interface{}) (certificatesmanagement.KeyAlgorithmEnum, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		switch pub.N.BitLen() {
+		case 2048:
+			return certificatesmanagement.KeyAlgorithmRsa2048, nil
+		case 4096:
+			return certificatesmanagement.KeyAlgorithmRsa4096, nil
+		}
+		return "", fmt.Errorf("%w: %d bit RSA keys are not supported, use 2048 or 4096 bits", ErrIncompatibleCSR, pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return certificatesmanagement.KeyAlgorithmEcdsaP256, nil
+		case elliptic.P384():
+			return certificatesmanagement.KeyAlgorithmEcdsaP384, nil
+		}
+		return "", fmt.Errorf("%w: ECDSA curve %s is not supported, use P-256 or P-384", ErrIncompatibleCSR, pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "", fmt.Errorf("%w: Ed25519 keys are not supported, use RSA or ECDSA", ErrIncompatibleCSR)
+	}
+	return "", fmt.Errorf("%w: unknown public key type %T", ErrIncompatibleCSR, pub)
+}
+
+// signingKeyType returns the type of key, RSA or ECDSA, signing with
+// algorithm, or empty when unknown.
+func signingKeyType(algorithm certificatesmanagement.SignatureAlgorithmEnum) string {
+	switch {
+	case strings.HasSuffix(string(algorithm), "_WITH_RSA"):
+		return "RSA"
+	case strings.HasSuffix(string(algorithm), "_WITH_ECDSA"):
+		return "ECDSA"
+	}
+	return ""
+}
+
+// checkCSR checks that OCI can issue a certificate for csr with a certificate
+// authority signing with signingAlgorithm: that csr is signed with an
+// algorithm OCI accepts, holds a key of a supported algorithm and size of the
+// type of the key of the certificate authority, and that its subject and
+// subject alternative names fit the limits of a certificate.
+func checkCSR(csr *x509.CertificateRequest, signingAlgorithm certificatesmanagement.SignatureAlgorithmEnum) error {
+	if _, ok := csrSignatureAlgorithms[csr.SignatureAlgorithm]; !ok {
+		supported := certificatesmanagement.GetSignatureAlgorithmEnumStringValues()
+		return fmt.Errorf("%w: signature algorithm %s is not supported, use one of %s",
+			ErrIncompatibleCSR, csr.SignatureAlgorithm, strings.Join(supported, ", "))
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("%w: invalid signature: %v", ErrIncompatibleCSR, err)
+	}
+	algorithm, err := keyAlgorithm(csr.PublicKey)
+	if err != nil {
+		return err
+	}
+	if keyType := signingKeyType(signingAlgorithm); keyType != "" && !strings.HasPrefix(string(algorithm), keyType) {
+		return fmt.Errorf("%w: %s keys cannot be signed by the certificate authority, which signs with %s, use an %s key",
+			ErrIncompatibleCSR, algorithm, signingAlgorithm, keyType)
+	}
+	for _, limit := range subjectLimits {
+		for _, v := range limit.values(csr.Subject) {
+			if len(v) > limit.max {
+				return fmt.Errorf("%w: subject %s %q is longer than %d characters", ErrIncompatibleCSR, limit.name, v, limit.max)
+			}
+		}
+	}
+	sans := len(csr.DNSNames) + len(csr.IPAddresses) + len(csr.EmailAddresses) + len(csr.URIs)
+	if sans > maxSubjectAlternativeNames {
+		return fmt.Errorf("%w: %d subject alternative names requested, at most %d are supported", ErrIncompatibleCSR, sans, maxSubjectAlternativeNames)
+	}
+	if sans == 0 && csr.Subject.CommonName == "" {
+		return fmt.Errorf("%w: neither a common name nor subject alternative names requested", ErrIncompatibleCSR)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package provisioner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"strings"
+	"testing"
+)
+
+func Test_checkCSR(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manyNames := make([]string, maxSubjectAlternativeNames+1)
+	for i := range manyNames {
+		manyNames[i] = fmt.Sprintf("host-%d.example.com", i)
+	}
+
+	tests := []struct {
+		name    string
+		key     crypto.Signer
+		tmpl    x509.CertificateRequest
+		signing certificatesmanagement.SignatureAlgorithmEnum
+		wantErr bool
+	}{
+		{name: "ECDSA P-256", key: p256, tmpl: x509.CertificateRequest{DNSNames: []string{"example.com"}}},
+		{name: "RSA 2048", key: rsa2048, tmpl: x509.CertificateRequest{Subject: pkix.Name{CommonName: "example"}}},
+		{
+			name:    "RSA key for an RSA CA",
+			key:     rsa2048,
+			tmpl:    x509.CertificateRequest{DNSNames: []string{"example.com"}},
+			signing: certificatesmanagement.SignatureAlgorithmSha384WithRsa,
+		},
+		{
+			name:    "RSA key for an ECDSA CA",
+			key:     rsa2048,
+			tmpl:    x509.CertificateRequest{DNSNames: []string{"example.com"}},
+			signing: certificatesmanagement.SignatureAlgorithmSha256WithEcdsa,
+			wantErr: true,
+		},
+		{
+			name:    "ECDSA key for an RSA CA",
+			key:     p256,
+			tmpl:    x509.CertificateRequest{DNSNames: []string{"example.com"}},
+			signing: certificatesmanagement.SignatureAlgorithmSha256WithRsa,
+			wantErr: true,
+		},
+		{name: "RSA 1024", key: rsa1024, tmpl: x509.CertificateRequest{DNSNames: []string{"example.com"}}, wantErr: true},
+		{name: "ECDSA P-521", key: p521, tmpl: x509.CertificateRequest{DNSNames: []string{"example.com"}}, wantErr: true},
+		{name: "Ed25519", key: ed, tmpl: x509.CertificateRequest{DNSNames: []string{"example.com"}}, wantErr: true},
+		{
+			name:    "SHA-1 signature",
+			key:     rsa2048,
+			tmpl:    x509.CertificateRequest{DNSNames: []string{"example.com"}, SignatureAlgorithm: x509.SHA1WithRSA},
+			wantErr: true,
+		},
+		{
+			name:    "RSA-PSS signature",
+			key:     rsa2048,
+			tmpl:    x509.CertificateRequest{DNSNames: []string{"example.com"}, SignatureAlgorithm: x509.SHA256WithRSAPSS},
+			wantErr: true,
+		},
+		{
+			name:    "long common name",
+			key:     p256,
+			tmpl:    x509.CertificateRequest{Subject: pkix.Name{CommonName: strings.Repeat("a", 65)}},
+			wantErr: true,
+		},
+		{
+			name:    "long country",
+			key:     p256,
+			tmpl:    x509.CertificateRequest{Subject: pkix.Name{CommonName: "example", Country: []string{"USA"}}},
+			wantErr: true,
+		},
+		{name: "too many names", key: p256, tmpl: x509.CertificateRequest{DNSNames: manyNames}, wantErr: true},
+		{name: "no names", key: p256, tmpl: x509.CertificateRequest{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := x509.CreateCertificateRequest(rand.Reader, &tt.tmpl, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			csr, err := x509.ParseCertificateRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkCSR(csr, tt.signing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkCSR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrIncompatibleCSR) {
+				t.Errorf("checkCSR() error = %v, want ErrIncompatibleCSR", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/cert-manager/cert-manager/pkg/util/pki"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/william20111/oci-privateca-issuer/pkg/naming"
+	"net/http"
+)
+
+// SecretKind is the kind exported Secrets are named as in certificate names
+// and descriptions.
+const SecretKind = "Secret"
+
+// ErrNotExported is returned by CheckExportedCertificate when an OCI
+// certificate is not the one a Secret was exported to.
+var ErrNotExported = errors.New("certificate was not exported from this Secret")
+
+// ErrUntrustedCertificate is returned by VerifyImported when a certificate
+// does not chain up to the root of any of the issuer's authorities.
+var ErrUntrustedCertificate = errors.New("certificate was not issued by the issuer's certificate authorities")
+
+// ImportedCertificate is a certificate and private key imported into OCI
+// Certificates.
+type ImportedCertificate struct {
+	// Certificate is the PEM encoded certificate, Chain the PEM encoded
+	// certificates that issued it and PrivateKey its PEM encoded private
+	// key.
+	Certificate []byte
+	Chain       []byte
+	PrivateKey  []byte
+}
+
+// VerifyImported checks that c chains up, through the certificates of its
+// chain, to the root of one of the authorities or of the retired ones, so
+// that self-signed certificates and those of other CAs are never imported
+// under the issuer's name.
+func (a *Authorities) VerifyImported(ctx context.Context, c ImportedCertificate) error {
+	anchors, err := a.TrustAnchors(ctx)
+	if err != nil {
+		return err
+	}
+	if a.retired != nil {
+		retired, err := a.retired.TrustAnchors(ctx)
+		if err != nil {
+			return err
+		}
+		anchors = append(anchors, retired...)
+	}
+	leaf, err := pki.DecodeX509CertificateBytes(c.Certificate)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	for _, anchor := range anchors {
+		roots.AppendCertsFromPEM(anchor)
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(c.Chain)
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUntrustedCertificate, err)
+	}
+	return nil
+}
+
+// ImportCertificate imports c as a new OCI certificate named after the Secret
+// namespace/name and returns its OCID. An imported certificate of the same
+// name, left by an earlier attempt whose OCID was not recorded, is updated
+// with c instead of failing.
+func (p *Provisioner) ImportCertificate(ctx context.Context, namespace, name string, c ImportedCertificate, opts SignOptions) (string, error) {
+	compartmentID, certificateName, description, err := p.exportNames(namespace, name, opts)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := p.caClient.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          &certificateName,
+			CompartmentId: &compartmentID,
+			CertificateConfig: certificatesmanagement.CreateCertificateByImportingConfigDetails{
+				CertificatePem: common.String(string(c.Certificate)),
+				CertChainPem:   common.String(string(c.Chain)),
+				PrivateKeyPem:  common.String(string(c.PrivateKey)),
+			},
+			Description:  &description,
+			FreeformTags: certificateTags(namespace),
+		},
+	})
+	if err == nil {
+		return *res.Id, nil
+	}
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusConflict {
+		return "", err
+	}
+	id, err := p.findCertificate(ctx, compartmentID, certificateName, func(s certificatesmanagement.CertificateSummary) bool {
+		return s.ConfigType == certificatesmanagement.CertificateConfigTypeImported &&
+			s.FreeformTags[OCICertManagerTagKey] == OCICertManagerTagValue
+	}, err)
+	if err != nil {
+		return "", err
+	}
+	return id, p.UpdateImportedCertificate(ctx, id, c)
+}
+
+// exportNames returns the compartment, name and description of the OCI
+// certificate the Secret namespace/name is exported to with opts.
+func (p *Provisioner) exportNames(namespace, name string, opts SignOptions) (string, string, string, error) {
+	compartmentID := p.iss.Spec.CompartmentID
+	if opts.CompartmentID != "" {
+		compartmentID = opts.CompartmentID
+	}
+	names := naming.Data{
+		ClusterID: opts.ClusterID,
+		Kind:      SecretKind,
+		Namespace: namespace,
+		Name:      name,
+		Issuer:    p.iss.Name,
+	}
+	certificateName, err := p.templates.Name(names)
+	if err != nil {
+		return "", "", "", err
+	}
+	description, err := p.templates.Description(names)
+	if err != nil {
+		return "", "", "", err
+	}
+	return compartmentID, certificateName, description, nil
+}
+
+// CheckExportedCertificate checks that certificateID is the OCI certificate
+// the Secret namespace/name is exported to with opts: an imported certificate
+// tagged by the controller, named after the Secret and in the compartment opts
+// route it to. Secrets record the OCID in an annotation that anyone who may
+// edit them can change, so it is checked before the certificate is updated or
+// served.
+func (p *Provisioner) CheckExportedCertificate(ctx context.Context, certificateID, namespace, name string, opts SignOptions) error {
+	compartmentID, certificateName, _, err := p.exportNames(namespace, name, opts)
+	if err != nil {
+		return err
+	}
+	res, err := p.caClient.GetCertificate(ctx, certificatesmanagement.GetCertificateRequest{
+		CertificateId: common.String(certificateID),
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.ConfigType != certificatesmanagement.CertificateConfigTypeImported:
+		return fmt.Errorf("%w: %s is %s, not imported", ErrNotExported, certificateID, res.ConfigType)
+	case res.FreeformTags[OCICertManagerTagKey] != OCICertManagerTagValue:
+		return fmt.Errorf("%w: %s is not tagged %s=%s", ErrNotExported, certificateID, OCICertManagerTagKey, OCICertManagerTagValue)
+	case res.Name == nil || *res.Name != certificateName:
+		return fmt.Errorf("%w: %s is not named %s", ErrNotExported, certificateID, certificateName)
+	case res.CompartmentId == nil || *res.CompartmentId != compartmentID:
+		return fmt.Errorf("%w: %s is not in compartment %s", ErrNotExported, certificateID, compartmentID)
+	}
+	return nil
+}
+
+// ExportedLeaf returns the current version of the OCI certificate
+// certificateID, without its private key.
+func (p *Provisioner) ExportedLeaf(ctx context.Context, certificateID string) (*x509.Certificate, error) {
+	res, err := p.certificateClient.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         common.String(certificateID),
+		Stage:                 certificates.GetCertificateBundleStageCurrent,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypePublicOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.CertificateBundle == nil || res.CertificateBundle.GetCertificatePem() == nil {
+		return nil, fmt.Errorf("certificate %s bundle is incomplete", certificateID)
+	}
+	return pki.DecodeX509CertificateBytes([]byte(*res.CertificateBundle.GetCertificatePem()))
+}
+
+// UpdateImportedCertificate imports c as the current version of the imported
+// certificate certificateID.
+func (p *Provisioner) UpdateImportedCertificate(ctx context.Context, certificateID string, c ImportedCertificate) error {
+	_, err := p.caClient.UpdateCertificate(ctx, certificatesmanagement.UpdateCertificateRequest{
+		CertificateId: common.String(certificateID),
+		UpdateCertificateDetails: certificatesmanagement.UpdateCertificateDetails{
+			CertificateConfig: certificatesmanagement.UpdateCertificateByImportingConfigDetails{
+				CertificatePem: common.String(string(c.Certificate)),
+				CertChainPem:   common.String(string(c.Chain)),
+				PrivateKeyPem:  common.String(string(c.PrivateKey)),
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,80 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"net/http"
+)
+
+// ErrOtherNamespace is returned by CertificateCompartment when the OCI
+// certificate was created by the controller for another namespace.
+var ErrOtherNamespace = errors.New("certificate was created for another namespace")
+
+// CertificateCompartment returns the compartment of the OCI certificate
+// certificateID, after checking that the controller did not create it for a
+// namespace other than namespace. Certificates the controller creates are
+// tagged with their namespace; those without the tag were created before it
+// and are refused to every namespace, as their names are templated and do
+// not tell their namespace reliably.
+func (p *Provisioner) CertificateCompartment(ctx context.Context, certificateID, namespace string) (string, error) {
+	res, err := p.caClient.GetCertificate(ctx, certificatesmanagement.GetCertificateRequest{
+		CertificateId: common.String(certificateID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.FreeformTags[OCICertManagerTagKey] == OCICertManagerTagValue && res.FreeformTags[OCINamespaceTagKey] != namespace {
+		owner := res.FreeformTags[OCINamespaceTagKey]
+		if owner == "" {
+			owner = "an unknown namespace"
+		}
+		return "", fmt.Errorf("%w: %s was created for %s", ErrOtherNamespace, certificateID, owner)
+	}
+	if res.CompartmentId == nil {
+		return "", fmt.Errorf("certificate %s has no compartment", certificateID)
+	}
+	return *res.CompartmentId, nil
+}
+
+// CertificateBundle returns the current version of the certificate
+// certificateID, with its private key when OCI holds one and the credentials
+// may read it.
+func (p *Provisioner) CertificateBundle(ctx context.Context, certificateID string) (*ManagedCertificate, error) {
+	withKey, err := p.ManagedCertificateBundle(ctx, certificateID)
+	if err == nil {
+		return withKey, nil
+	}
+	// OCI refuses private bundles of certificates issued from a CSR with
+	// 400, and private bundles the policies do not cover like missing
+	// certificates; the public bundle tells them apart.
+	serviceErr, ok := common.IsServiceError(err)
+	if !ok || (serviceErr.GetHTTPStatusCode() != http.StatusBadRequest && serviceErr.GetHTTPStatusCode() != http.StatusNotFound) {
+		return nil, err
+	}
+	res, err := p.certificateClient.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         common.String(certificateID),
+		Stage:                 certificates.GetCertificateBundleStageCurrent,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypePublicOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bundle := res.CertificateBundle
+	if bundle.GetVersionNumber() == nil || bundle.GetValidity() == nil || bundle.GetValidity().TimeOfValidityNotAfter == nil {
+		return nil, fmt.Errorf("certificate %s bundle is incomplete", certificateID)
+	}
+	cert, ca, err := splitBundle(bundle.GetCertificatePem(), bundle.GetCertChainPem())
+	if err != nil {
+		return nil, err
+	}
+	return &ManagedCertificate{
+		Version:     *bundle.GetVersionNumber(),
+		Certificate: cert,
+		CA:          ca,
+		NotAfter:    bundle.GetValidity().TimeOfValidityNotAfter.Time,
+	}, nil
+}
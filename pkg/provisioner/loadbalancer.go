@@ -0,0 +1,130 @@
+package provisioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"strings"
+)
+
+// ErrListenerNotFound is returned for listeners a load balancer does not have.
+var ErrListenerNotFound = errors.New("listener not found")
+
+// LoadBalancerListener is a listener of an OCI load balancer.
+type LoadBalancerListener struct {
+	// CompartmentID is the compartment of the load balancer.
+	CompartmentID string
+	// Certificate is the certificate the listener serves, or nil when it
+	// does not terminate TLS.
+	Certificate *ocicav1alpha1.ListenerCertificate
+
+	listener loadbalancer.Listener
+}
+
+// WorkRequestStatus is the outcome of a load balancer work request.
+type WorkRequestStatus struct {
+	// Done is set once the work request finished, and Failure to its errors
+	// when it failed.
+	Done    bool
+	Failure string
+}
+
+// LoadBalancerListener returns the listener name of the load balancer
+// loadBalancerID.
+func (p *Provisioner) LoadBalancerListener(ctx context.Context, loadBalancerID, name string) (*LoadBalancerListener, error) {
+	res, err := p.lbClient.GetLoadBalancer(ctx, loadbalancer.GetLoadBalancerRequest{
+		LoadBalancerId: common.String(loadBalancerID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	l, ok := res.Listeners[name]
+	if !ok {
+		return nil, fmt.Errorf("load balancer %s: %w: %s", loadBalancerID, ErrListenerNotFound, name)
+	}
+	listener := &LoadBalancerListener{listener: l}
+	if res.CompartmentId != nil {
+		listener.CompartmentID = *res.CompartmentId
+	}
+	if ssl := l.SslConfiguration; ssl != nil {
+		listener.Certificate = &ocicav1alpha1.ListenerCertificate{CertificateIDs: ssl.CertificateIds}
+		if ssl.CertificateName != nil {
+			listener.Certificate.CertificateName = *ssl.CertificateName
+		}
+	}
+	return listener, nil
+}
+
+// SetListenerCertificate updates listener l of the load balancer
+// loadBalancerID to serve cert, keeping its other settings, and returns the
+// OCID of the work request applying the update.
+func (p *Provisioner) SetListenerCertificate(ctx context.Context, loadBalancerID string, l *LoadBalancerListener, cert ocicav1alpha1.ListenerCertificate) (string, error) {
+	current := l.listener
+	if current.SslConfiguration == nil {
+		return "", fmt.Errorf("listener %s does not terminate TLS", *current.Name)
+	}
+	ssl := current.SslConfiguration
+	details := loadbalancer.UpdateListenerDetails{
+		DefaultBackendSetName:   current.DefaultBackendSetName,
+		Port:                    current.Port,
+		Protocol:                current.Protocol,
+		HostnameNames:           current.HostnameNames,
+		PathRouteSetName:        current.PathRouteSetName,
+		RoutingPolicyName:       current.RoutingPolicyName,
+		ConnectionConfiguration: current.ConnectionConfiguration,
+		RuleSetNames:            current.RuleSetNames,
+		SslConfiguration: &loadbalancer.SslConfigurationDetails{
+			VerifyDepth:                    ssl.VerifyDepth,
+			VerifyPeerCertificate:          ssl.VerifyPeerCertificate,
+			TrustedCertificateAuthorityIds: ssl.TrustedCertificateAuthorityIds,
+			CertificateIds:                 cert.CertificateIDs,
+			Protocols:                      ssl.Protocols,
+			CipherSuiteName:                ssl.CipherSuiteName,
+			ServerOrderPreference:          loadbalancer.SslConfigurationDetailsServerOrderPreferenceEnum(ssl.ServerOrderPreference),
+		},
+	}
+	if cert.CertificateName != "" {
+		details.SslConfiguration.CertificateName = common.String(cert.CertificateName)
+	}
+	res, err := p.lbClient.UpdateListener(ctx, loadbalancer.UpdateListenerRequest{
+		LoadBalancerId:        common.String(loadBalancerID),
+		ListenerName:          current.Name,
+		UpdateListenerDetails: details,
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.OpcWorkRequestId == nil {
+		return "", fmt.Errorf("listener update of load balancer %s returned no work request", loadBalancerID)
+	}
+	return *res.OpcWorkRequestId, nil
+}
+
+// LoadBalancerWorkRequest returns the status of the load balancer work request
+// id.
+func (p *Provisioner) LoadBalancerWorkRequest(ctx context.Context, id string) (WorkRequestStatus, error) {
+	res, err := p.lbClient.GetWorkRequest(ctx, loadbalancer.GetWorkRequestRequest{WorkRequestId: common.String(id)})
+	if err != nil {
+		return WorkRequestStatus{}, err
+	}
+	switch res.LifecycleState {
+	case loadbalancer.WorkRequestLifecycleStateSucceeded:
+		return WorkRequestStatus{Done: true}, nil
+	case loadbalancer.WorkRequestLifecycleStateFailed:
+		var failures []string
+		for _, e := range res.ErrorDetails {
+			if e.Message != nil {
+				failures = append(failures, *e.Message)
+			}
+		}
+		if len(failures) == 0 {
+			failures = append(failures, fmt.Sprintf("work request %s failed", id))
+		}
+		return WorkRequestStatus{Done: true, Failure: strings.Join(failures, "; ")}, nil
+	default:
+		return WorkRequestStatus{}, nil
+	}
+}
@@ -0,0 +1,178 @@
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/isoduration"
+	"github.com/william20111/oci-privateca-issuer/pkg/naming"
+	"net/http"
+	"time"
+)
+
+// ManagedCertificateKind is the kind OCIManagedCertificates are named as in
+// certificate names and descriptions.
+const ManagedCertificateKind = "OCIManagedCertificate"
+
+// ManagedCertificate is a version of a certificate whose private key was
+// generated by OCI.
+type ManagedCertificate struct {
+	// Version is the number of the version.
+	Version int64
+	// Certificate is the PEM encoded certificate followed by any
+	// intermediates, and CA the root certificate.
+	Certificate []byte
+	CA          []byte
+	// PrivateKey is the PEM encoded private key, nil for bundles read
+	// without it.
+	PrivateKey []byte
+	NotAfter   time.Time
+}
+
+// CreateManagedCertificate creates the OCI certificate of mc, issued by the
+// issuer's certificate authority with a private key generated by OCI, and
+// returns its OCID. A certificate of the same name issued by the same
+// certificate authority, left by an earlier attempt whose OCID was not
+// recorded, is returned instead of failing.
+func (p *Provisioner) CreateManagedCertificate(ctx context.Context, mc *ocicav1alpha1.OCIManagedCertificate, opts SignOptions) (string, error) {
+	compartmentID := p.iss.Spec.CompartmentID
+	if opts.CompartmentID != "" {
+		compartmentID = opts.CompartmentID
+	}
+	names := naming.Data{
+		ClusterID: opts.ClusterID,
+		Kind:      ManagedCertificateKind,
+		Namespace: mc.Namespace,
+		Name:      mc.Name,
+		Issuer:    p.iss.Name,
+	}
+	name, err := p.templates.Name(names)
+	if err != nil {
+		return "", err
+	}
+	description, err := p.templates.Description(names)
+	if err != nil {
+		return "", err
+	}
+	notBefore, notAfter, err := p.validityFor("ocimanagedcertificate", mc.Name, mc.Spec.Duration, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	profile := certificatesmanagement.CertificateProfileTypeTlsServerOrClient
+	if mc.Spec.Profile != "" {
+		profile = certificatesmanagement.CertificateProfileTypeEnum(mc.Spec.Profile)
+	}
+	keyAlgorithm := certificatesmanagement.KeyAlgorithmRsa2048
+	if mc.Spec.KeyAlgorithm != "" {
+		keyAlgorithm = certificatesmanagement.KeyAlgorithmEnum(mc.Spec.KeyAlgorithm)
+	}
+	var sans []certificatesmanagement.CertificateSubjectAlternativeName
+	for _, dnsName := range mc.Spec.DNSNames {
+		sans = append(sans, certificatesmanagement.CertificateSubjectAlternativeName{
+			Type:  certificatesmanagement.CertificateSubjectAlternativeNameTypeDns,
+			Value: common.String(dnsName),
+		})
+	}
+	for _, ip := range mc.Spec.IPAddresses {
+		sans = append(sans, certificatesmanagement.CertificateSubjectAlternativeName{
+			Type:  certificatesmanagement.CertificateSubjectAlternativeNameTypeIp,
+			Value: common.String(ip),
+		})
+	}
+	var rules []certificatesmanagement.CertificateRule
+	if renewal := mc.Spec.Renewal; renewal != nil {
+		rules = append(rules, certificatesmanagement.CertificateRenewalRule{
+			RenewalInterval:      common.String(isoduration.Format(renewal.Interval.Duration)),
+			AdvanceRenewalPeriod: common.String(isoduration.Format(renewal.AdvancePeriod.Duration)),
+		})
+	}
+
+	res, err := p.caClient.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
+			Name:          &name,
+			CompartmentId: &compartmentID,
+			CertificateConfig: certificatesmanagement.CreateCertificateIssuedByInternalCaConfigDetails{
+				IssuerCertificateAuthorityId: &p.iss.Spec.AuthorityID,
+				Subject:                      &certificatesmanagement.CertificateSubject{CommonName: common.String(mc.Spec.CommonName)},
+				SubjectAlternativeNames:      sans,
+				CertificateProfileType:       profile,
+				KeyAlgorithm:                 keyAlgorithm,
+				Validity: &certificatesmanagement.Validity{
+					TimeOfValidityNotAfter:  &common.SDKTime{Time: notAfter},
+					TimeOfValidityNotBefore: &common.SDKTime{Time: notBefore},
+				},
+			},
+			CertificateRules: rules,
+			Description:      &description,
+			FreeformTags:     certificateTags(mc.Namespace),
+		},
+	})
+	if err == nil {
+		return *res.Id, nil
+	}
+	if serviceErr, ok := common.IsServiceError(err); !ok || serviceErr.GetHTTPStatusCode() != http.StatusConflict {
+		return "", err
+	}
+	return p.findCertificate(ctx, compartmentID, name, func(c certificatesmanagement.CertificateSummary) bool {
+		return c.ConfigType == certificatesmanagement.CertificateConfigTypeIssuedByInternalCa &&
+			c.IssuerCertificateAuthorityId != nil && *c.IssuerCertificateAuthorityId == p.iss.Spec.AuthorityID
+	}, err)
+}
+
+// ManagedCertificateBundle returns the current version of the certificate
+// certificateID with its private key.
+func (p *Provisioner) ManagedCertificateBundle(ctx context.Context, certificateID string) (*ManagedCertificate, error) {
+	res, err := p.certificateClient.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
+		CertificateId:         common.String(certificateID),
+		Stage:                 certificates.GetCertificateBundleStageCurrent,
+		CertificateBundleType: certificates.GetCertificateBundleCertificateBundleTypeWithPrivateKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bundle, ok := res.CertificateBundle.(certificates.CertificateBundleWithPrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("certificate %s bundle has no private key", certificateID)
+	}
+	if bundle.VersionNumber == nil || bundle.PrivateKeyPem == nil || bundle.Validity == nil || bundle.Validity.TimeOfValidityNotAfter == nil {
+		return nil, fmt.Errorf("certificate %s bundle is incomplete", certificateID)
+	}
+	cert, ca, err := splitBundle(bundle.CertificatePem, bundle.CertChainPem)
+	if err != nil {
+		return nil, err
+	}
+	return &ManagedCertificate{
+		Version:     *bundle.VersionNumber,
+		Certificate: cert,
+		CA:          ca,
+		PrivateKey:  []byte(*bundle.PrivateKeyPem),
+		NotAfter:    bundle.Validity.TimeOfValidityNotAfter.Time,
+	}, nil
+}
+
+// DeleteManagedCertificate schedules the deletion of the OCI certificate
+// certificateID after OCI's default waiting period. Certificates already
+// deleted or scheduled for deletion are left alone.
+func (p *Provisioner) DeleteManagedCertificate(ctx context.Context, certificateID string) error {
+	res, err := p.caClient.GetCertificate(ctx, certificatesmanagement.GetCertificateRequest{
+		CertificateId: common.String(certificateID),
+	})
+	if serviceErr, ok := common.IsServiceError(err); ok && serviceErr.GetHTTPStatusCode() == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch res.LifecycleState {
+	case certificatesmanagement.CertificateLifecycleStatePendingDeletion, certificatesmanagement.CertificateLifecycleStateDeleted:
+		return nil
+	}
+	_, err = p.caClient.ScheduleCertificateDeletion(ctx, certificatesmanagement.ScheduleCertificateDeletionRequest{
+		CertificateId: common.String(certificateID),
+	})
+	return err
+}
@@ -2,6 +2,9 @@ package provisioner
 
 import (
 	"context"
+	"crypto"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	"github.com/cert-manager/cert-manager/pkg/util/pki"
@@ -9,77 +12,195 @@ import (
 	"github.com/oracle/oci-go-sdk/v65/certificates"
 	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
 	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
 	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/naming"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
 	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 const (
 	// DefaultDurationInterval The default validity duration, if not provided.
-	DefaultDurationInterval = time.Hour * 24 * 7
+	DefaultDurationInterval = ocicav1alpha1.DefaultCertificateDuration
 	// OCICertManagerTagKey The default tag key on a certificate
 	OCICertManagerTagKey = "cert-manager"
 	// OCICertManagerTagValue The default tag value on a certificate
-	OCICertManagerTagValue          = "true"
-	OCICertificatePrivateBundleType = "CERTIFICATE_CONTENT_WITH_PRIVATE_KEY"
+	OCICertManagerTagValue = "true"
+	// OCINamespaceTagKey The tag key holding the namespace a certificate was
+	// created for
+	OCINamespaceTagKey = "cert-manager-namespace"
 )
 
 // GenericProvisioner abstracts over the Provisioner type for mocking purposes
 type GenericProvisioner interface {
-	Sign(ctx context.Context, cr *cmapi.CertificateRequest, log logr.Logger) ([]byte, []byte, error)
+	Sign(ctx context.Context, cr *cmapi.CertificateRequest, opts SignOptions, log logr.Logger) ([]byte, []byte, error)
+}
+
+// SignOptions adjust how a single CertificateRequest is signed.
+type SignOptions struct {
+	// CompartmentID is the compartment the OCI certificate is created in.
+	// Defaults to the issuer's compartment.
+	CompartmentID string
+
+	// ClusterID identifies the cluster in the name and description of the
+	// OCI certificate.
+	ClusterID string
+
+	// NameSuffix is appended to the name of the OCI certificate, so that
+	// shadow certificates do not collide with those they are compared with.
+	NameSuffix string
 }
 
 type ociCAClient interface {
 	CreateCertificate(ctx context.Context, request certificatesmanagement.CreateCertificateRequest) (response certificatesmanagement.CreateCertificateResponse, err error)
+	GetCertificate(ctx context.Context, request certificatesmanagement.GetCertificateRequest) (response certificatesmanagement.GetCertificateResponse, err error)
 	GetCertificateAuthority(ctx context.Context, request certificatesmanagement.GetCertificateAuthorityRequest) (response certificatesmanagement.GetCertificateAuthorityResponse, err error)
+	ListCertificates(ctx context.Context, request certificatesmanagement.ListCertificatesRequest) (response certificatesmanagement.ListCertificatesResponse, err error)
+	ListCertificateVersions(ctx context.Context, request certificatesmanagement.ListCertificateVersionsRequest) (response certificatesmanagement.ListCertificateVersionsResponse, err error)
+	UpdateCertificate(ctx context.Context, request certificatesmanagement.UpdateCertificateRequest) (response certificatesmanagement.UpdateCertificateResponse, err error)
+	ScheduleCertificateDeletion(ctx context.Context, request certificatesmanagement.ScheduleCertificateDeletionRequest) (response certificatesmanagement.ScheduleCertificateDeletionResponse, err error)
 }
 
 type ociCertificateClient interface {
 	GetCertificateBundle(ctx context.Context, request certificates.GetCertificateBundleRequest) (response certificates.GetCertificateBundleResponse, err error)
+	GetCertificateAuthorityBundle(ctx context.Context, request certificates.GetCertificateAuthorityBundleRequest) (response certificates.GetCertificateAuthorityBundleResponse, err error)
 }
 
-// Collection stores cached Provisioners, stored by namespaced names of the
-// issuer.
+type ociLoadBalancerClient interface {
+	GetLoadBalancer(ctx context.Context, request loadbalancer.GetLoadBalancerRequest) (response loadbalancer.GetLoadBalancerResponse, err error)
+	UpdateListener(ctx context.Context, request loadbalancer.UpdateListenerRequest) (response loadbalancer.UpdateListenerResponse, err error)
+	GetWorkRequest(ctx context.Context, request loadbalancer.GetWorkRequestRequest) (response loadbalancer.GetWorkRequestResponse, err error)
+}
+
+// Collection stores cached Provisioners, one for each certificate authority
+// of an issuer, stored by namespaced names of the issuer.
 type Collection struct {
 	m sync.Map
 }
 
-// Store adds a provisioner to the collection.
+// Store adds the provisioner of an issuer with a single certificate authority
+// to the collection.
 func (c *Collection) Store(namespacedName types.NamespacedName, provisioner *Provisioner) {
-	c.m.Store(namespacedName, provisioner)
+	c.StoreAuthorities(namespacedName, newAuthorities(ocicav1alpha1.AuthoritySelectionPriority, []*Authority{
+		{ID: provisioner.AuthorityID(), Weight: 1, Provisioner: provisioner},
+	}))
+}
+
+// StoreAuthorities adds the provisioners of every certificate authority of an
+// issuer to the collection.
+func (c *Collection) StoreAuthorities(namespacedName types.NamespacedName, authorities *Authorities) {
+	c.m.Store(namespacedName, authorities)
+}
+
+// Delete removes the provisioners stored for an issuer.
+func (c *Collection) Delete(namespacedName types.NamespacedName) {
+	c.m.Delete(namespacedName)
+}
+
+// Load returns the provisioner of the primary certificate authority stored
+// for an issuer, if any.
+func (c *Collection) Load(namespacedName types.NamespacedName) (*Provisioner, bool) {
+	authorities, ok := c.LoadAuthorities(namespacedName)
+	if !ok {
+		return nil, false
+	}
+	primary := authorities.Primary()
+	if primary == nil {
+		return nil, false
+	}
+	return primary.Provisioner, true
+}
+
+// LoadAuthorities returns the provisioners of every certificate authority
+// stored for an issuer, if any.
+func (c *Collection) LoadAuthorities(namespacedName types.NamespacedName) (*Authorities, bool) {
+	v, ok := c.m.Load(namespacedName)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Authorities), true
 }
 
+var _ GenericProvisioner = &Provisioner{}
+
 type Provisioner struct {
 	caClient          ociCAClient
 	certificateClient ociCertificateClient
+	lbClient          ociLoadBalancerClient
 	logger            logr.Logger
 	iss               ocicav1alpha1.OCICAClusterIssuer
 	compartmentID     string
 	tenancyID         string
+	limits            authorityLimits
+	templates         *naming.Templates
+
+	// routedCompartmentIDs are the compartments certificates are routed to
+	// besides those named in the issuer spec.
+	routedCompartmentIDs []string
 }
 
-func New(logger logr.Logger, iss ocicav1alpha1.OCICAClusterIssuer) (*Provisioner, error) {
-	configProvider, err := common.ConfigurationProviderFromFileWithProfile("/Users/wfleming/.oci/config", "DEFAULT", "")
+func New(logger logr.Logger, iss ocicav1alpha1.OCICAClusterIssuer, configProvider common.ConfigurationProvider, transport Transport) (*Provisioner, error) {
+	authority, err := ocid.ParseType(iss.Spec.AuthorityID, ocid.CertificateAuthority)
+	if err != nil {
+		return nil, err
+	}
+	configProvider, err = withAuthorityRegion(configProvider, authority)
 	if err != nil {
 		return nil, err
 	}
 	caClient, err := certificatesmanagement.NewCertificatesManagementClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, err
+	}
 	certClient, err := certificates.NewCertificatesClientWithConfigurationProvider(configProvider)
 	if err != nil {
 		return nil, err
 	}
+	lbClient, err := loadbalancer.NewLoadBalancerClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, err
+	}
+	if err := setEndpoints(iss.Spec, authority, configProvider, &caClient, &certClient, &lbClient); err != nil {
+		return nil, err
+	}
+	if err := transport.apply(iss.Name, &caClient.BaseClient, &certClient.BaseClient, &lbClient.BaseClient); err != nil {
+		return nil, err
+	}
+	templates, err := naming.Parse(iss.Spec.NameTemplate, iss.Spec.DescriptionTemplate)
+	if err != nil {
+		return nil, err
+	}
 	p := &Provisioner{
 		logger:            logger,
 		caClient:          caClient,
 		certificateClient: certClient,
+		lbClient:          lbClient,
 		iss:               iss,
 		compartmentID:     iss.Spec.CompartmentID,
 		tenancyID:         iss.Spec.TenancyID,
+		templates:         templates,
 	}
 	return p, nil
 }
 
+// AuthorityID returns the OCID of the certificate authority p signs with.
+func (p *Provisioner) AuthorityID() string {
+	return p.iss.Spec.AuthorityID
+}
+
+// CompartmentID returns the compartment p creates certificates in when they
+// are not routed elsewhere.
+func (p *Provisioner) CompartmentID() string {
+	return p.compartmentID
+}
+
+// Validate checks that the issuer's certificate authority exists and can
+// issue certificates.
 func (p *Provisioner) Validate(ctx context.Context) error {
 	res, err := p.caClient.GetCertificateAuthority(ctx, certificatesmanagement.GetCertificateAuthorityRequest{
 		CertificateAuthorityId: common.String(p.iss.Spec.AuthorityID),
@@ -88,61 +209,373 @@ func (p *Provisioner) Validate(ctx context.Context) error {
 		p.logger.Error(err, "cant get certificate authority")
 		return err
 	}
-	if res.Id != &p.iss.Spec.AuthorityID {
+	if res.Id == nil || *res.Id != p.iss.Spec.AuthorityID {
 		return fmt.Errorf("cant find the certificate authority")
 	}
+	if res.LifecycleState != certificatesmanagement.CertificateAuthorityLifecycleStateActive {
+		return fmt.Errorf("certificate authority %s is %s", p.iss.Spec.AuthorityID, res.LifecycleState)
+	}
+	limits, err := newAuthorityLimits(res.CertificateAuthority)
+	if err != nil {
+		return err
+	}
+	for _, compartmentID := range compartmentIDs(p.iss.Spec) {
+		if err := p.checkCompartmentReadable(ctx, compartmentID); err != nil {
+			return err
+		}
+	}
+	p.limits = limits
 	return nil
 }
 
-func (p *Provisioner) Sign(ctx context.Context, cr *cmapi.CertificateRequest, log logr.Logger) ([]byte, error) {
-	_, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.Request)
+// compartmentIDs returns every compartment spec creates certificates in,
+// except those chosen by namespace annotations, sorted and without
+// duplicates.
+func compartmentIDs(spec ocicav1alpha1.OCICAClusterIssuerSpec) []string {
+	ids := map[string]bool{spec.CompartmentID: true}
+	for _, override := range spec.NamespaceOverrides {
+		if override.CompartmentID != "" {
+			ids[override.CompartmentID] = true
+		}
+	}
+	if spec.Compartments != nil {
+		for _, id := range spec.Compartments.Namespaces {
+			ids[id] = true
+		}
+		if spec.Compartments.Default != "" {
+			ids[spec.Compartments.Default] = true
+		}
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// compartments returns every compartment the issuer routes certificates to.
+func (p *Provisioner) compartments() []string {
+	ids := compartmentIDs(p.iss.Spec)
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range p.routedCompartmentIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// checkCompartmentReadable lists certificates in a compartment, which OCI
+// refuses unless the policies of the credentials let them read certificates
+// there. OCI has no way to check whether certificates may be created without
+// creating one, so Sign fails with ErrNotAuthorized when they may not.
+func (p *Provisioner) checkCompartmentReadable(ctx context.Context, compartmentID string) error {
+	_, err := p.caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId: common.String(compartmentID),
+		Limit:         common.Int(1),
+	})
+	if err != nil {
+		p.logger.Error(err, "cant list certificates in compartment", "compartment", compartmentID)
+		return fmt.Errorf("cannot list certificates in compartment %s: %w", compartmentID, err)
+	}
+	return nil
+}
+
+// findCertificate returns the OCID of the active certificate named name in
+// compartmentID that match accepts, which was left by an earlier attempt
+// whose OCID was not recorded. It returns conflict when there is none.
+func (p *Provisioner) findCertificate(ctx context.Context, compartmentID, name string,
+	match func(certificatesmanagement.CertificateSummary) bool, conflict error) (string, error) {
+	res, err := p.caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId:  common.String(compartmentID),
+		Name:           common.String(name),
+		LifecycleState: certificatesmanagement.ListCertificatesLifecycleStateActive,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range res.Items {
+		if match(c) {
+			p.logger.Info("found existing certificate", "name", name, "certificateID", *c.Id)
+			return *c.Id, nil
+		}
+	}
+	return "", fmt.Errorf("certificate %q exists in compartment %s and was not created for this object: %w", name, compartmentID, conflict)
+}
+
+// CertificatePlan is the OCI certificate Sign creates for a request.
+type CertificatePlan struct {
+	AuthorityID   string    `json:"authorityID"`
+	CompartmentID string    `json:"compartmentID"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Profile       string    `json:"profile"`
+	NotBefore     time.Time `json:"notBefore"`
+	NotAfter      time.Time `json:"notAfter"`
+}
+
+// Plan runs the checks Sign runs on cr and returns the certificate it would
+// create, without creating it.
+func (p *Provisioner) Plan(ctx context.Context, cr *cmapi.CertificateRequest, opts SignOptions) (*CertificatePlan, error) {
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.Request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode CSR for signing: %s", err)
 	}
-	var expiry time.Time
-	start := time.Now().UTC()
-	if cr.Spec.Duration == nil {
-		expiry = start.Add(DefaultDurationInterval)
-	} else {
-		expiry = start.Add(cr.Spec.Duration.Duration)
+	if err := checkCSR(csr, p.limits.signingAlgorithm); err != nil {
+		return nil, err
+	}
+	// OCI takes the usages of certificates it signs from the CSR, which
+	// cert-manager builds from spec.usages, so the profile is only checked
+	// against the issued certificate.
+	profile, err := CertificateProfile(cr.Spec.Usages, cr.Spec.IsCA)
+	if err != nil {
+		return nil, err
 	}
+	if err := p.checkCARequest(ctx, cr, csr); err != nil {
+		return nil, err
+	}
+	compartmentID := p.iss.Spec.CompartmentID
+	if opts.CompartmentID != "" {
+		compartmentID = opts.CompartmentID
+	}
+	notBefore, notAfter, err := p.validity(cr, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	names := naming.Data{
+		ClusterID:   opts.ClusterID,
+		Kind:        cmapi.CertificateRequestKind,
+		Namespace:   cr.Namespace,
+		Name:        cr.Name,
+		Certificate: certificateName(cr),
+		Issuer:      p.iss.Name,
+	}
+	name, err := p.templates.Name(names)
+	if err != nil {
+		return nil, err
+	}
+	description, err := p.templates.Description(names)
+	if err != nil {
+		return nil, err
+	}
+	return &CertificatePlan{
+		AuthorityID:   p.iss.Spec.AuthorityID,
+		CompartmentID: compartmentID,
+		Name:          naming.SanitizeName(name + opts.NameSuffix),
+		Description:   description,
+		Profile:       string(profile),
+		NotBefore:     notBefore,
+		NotAfter:      notAfter,
+	}, nil
+}
+
+// ErrCertificateExists is returned by Sign when the name of the certificate
+// of a request is taken by a certificate it cannot resume from, such as one
+// issued by another certificate authority of the issuer.
+var ErrCertificateExists = errors.New("certificate already exists")
+
+// ErrNotAuthorized is returned by Sign when OCI refuses to create the
+// certificate of a request, as it does when the policies of the issuer's
+// credentials let them read but not manage certificates in its compartment.
+var ErrNotAuthorized = errors.New("not authorized to create certificates")
 
-	certificateSignResponse, err := p.caClient.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
+// Sign issues a certificate for the CSR of cr. It returns the PEM encoded
+// certificate followed by any intermediates, and the root certificate. A
+// certificate created for cr by an earlier attempt whose response was lost
+// is resumed from instead of failing.
+func (p *Provisioner) Sign(ctx context.Context, cr *cmapi.CertificateRequest, opts SignOptions, log logr.Logger) ([]byte, []byte, error) {
+	plan, err := p.Plan(ctx, cr, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := plan.Name
+
+	res, err := p.caClient.CreateCertificate(ctx, certificatesmanagement.CreateCertificateRequest{
 		CreateCertificateDetails: certificatesmanagement.CreateCertificateDetails{
-			Name:          &cr.Name,
-			CompartmentId: &p.iss.Spec.CompartmentID,
+			Name:          &name,
+			CompartmentId: &plan.CompartmentID,
 			CertificateConfig: certificatesmanagement.CreateCertificateManagedExternallyIssuedByInternalCaConfigDetails{
-				IssuerCertificateAuthorityId: &p.iss.Spec.AuthorityID,
+				IssuerCertificateAuthorityId: &plan.AuthorityID,
 				CsrPem:                       common.String(string(cr.Spec.Request)),
-				VersionName:                  &cr.Name,
+				VersionName:                  &name,
 				Validity: &certificatesmanagement.Validity{
-					TimeOfValidityNotAfter:  &common.SDKTime{Time: expiry},
-					TimeOfValidityNotBefore: &common.SDKTime{Time: start},
+					TimeOfValidityNotAfter:  &common.SDKTime{Time: plan.NotAfter},
+					TimeOfValidityNotBefore: &common.SDKTime{Time: plan.NotBefore},
 				},
 			},
-			Description: common.String(cr.Name),
-			FreeformTags: map[string]string{
-				OCICertManagerTagKey: OCICertManagerTagValue,
-			},
+			Description:  &plan.Description,
+			FreeformTags: certificateTags(cr.Namespace),
 		},
 	})
+	var certificateID string
+	switch serviceErr, ok := common.IsServiceError(err); {
+	case err == nil:
+		certificateID = *res.Id
+	case ok && serviceErr.GetHTTPStatusCode() == http.StatusConflict:
+		if certificateID, err = p.signedCertificate(ctx, plan); err != nil {
+			return nil, nil, err
+		}
+		if certificateID == "" {
+			return nil, nil, fmt.Errorf("%w: certificate %q exists in compartment %s and was not created for this request by %s: %s",
+				ErrCertificateExists, name, plan.CompartmentID, plan.AuthorityID, serviceErr.GetMessage())
+		}
+		log.Info("resuming from existing certificate", "certificateID", certificateID)
+	case ok && (serviceErr.GetHTTPStatusCode() == http.StatusNotFound || serviceErr.GetHTTPStatusCode() == http.StatusUnauthorized):
+		// OCI does not tell missing permissions from missing resources,
+		// and the issuer only became ready once its authority was found.
+		return nil, nil, fmt.Errorf("%w in compartment %s with certificate authority %s: %s",
+			ErrNotAuthorized, plan.CompartmentID, plan.AuthorityID, serviceErr.GetMessage())
+	default:
+		return nil, nil, err
+	}
+	return p.signedBundle(ctx, cr, plan, certificateID, log)
+}
+
+// Signed returns the certificate an earlier Sign of cr with opts created, for
+// when its response was lost, or nil when there is none.
+func (p *Provisioner) Signed(ctx context.Context, cr *cmapi.CertificateRequest, opts SignOptions, log logr.Logger) ([]byte, []byte, error) {
+	plan, err := p.Plan(ctx, cr, opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	certificateID, err := p.signedCertificate(ctx, plan)
+	if err != nil || certificateID == "" {
+		return nil, nil, err
+	}
+	log.Info("found existing certificate", "certificateID", certificateID)
+	return p.signedBundle(ctx, cr, plan, certificateID, log)
+}
 
+// DeleteSigned schedules the deletion of the certificate an earlier Sign of
+// cr with opts created, if there is one.
+func (p *Provisioner) DeleteSigned(ctx context.Context, cr *cmapi.CertificateRequest, opts SignOptions) error {
+	plan, err := p.Plan(ctx, cr, opts)
+	if err != nil {
+		return err
+	}
+	certificateID, err := p.signedCertificate(ctx, plan)
+	if err != nil || certificateID == "" {
+		return err
+	}
+	return p.DeleteManagedCertificate(ctx, certificateID)
+}
+
+// signedCertificate returns the OCID of the certificate of plan, created from
+// a CSR by the certificate authority of p and tagged by the issuer, or empty
+// when there is none.
+func (p *Provisioner) signedCertificate(ctx context.Context, plan *CertificatePlan) (string, error) {
+	res, err := p.caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId:                common.String(plan.CompartmentID),
+		Name:                         common.String(plan.Name),
+		IssuerCertificateAuthorityId: common.String(plan.AuthorityID),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range res.Items {
+		switch c.LifecycleState {
+		case certificatesmanagement.CertificateLifecycleStateActive, certificatesmanagement.CertificateLifecycleStateCreating:
+		default:
+			continue
+		}
+		if c.ConfigType == certificatesmanagement.CertificateConfigTypeManagedExternallyIssuedByInternalCa &&
+			c.IssuerCertificateAuthorityId != nil && *c.IssuerCertificateAuthorityId == plan.AuthorityID &&
+			c.FreeformTags[OCICertManagerTagKey] == OCICertManagerTagValue {
+			return *c.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// signedBundle returns the certificate chain and root of the version of
+// certificateID signed for cr, after checking that it certifies the key of
+// the CSR of cr with the requested usages.
+func (p *Provisioner) signedBundle(ctx context.Context, cr *cmapi.CertificateRequest, plan *CertificatePlan, certificateID string, log logr.Logger) ([]byte, []byte, error) {
+	profile := certificatesmanagement.CertificateProfileTypeEnum(plan.Profile)
 	res, err := p.certificateClient.GetCertificateBundle(ctx, certificates.GetCertificateBundleRequest{
-		CertificateId:          certificateSignResponse.Id,
-		CertificateVersionName: common.String(cr.Name),
-		CertificateBundleType:  OCICertificatePrivateBundleType,
+		CertificateId:          &certificateID,
+		CertificateVersionName: &plan.Name,
+		CertificateBundleType:  certificates.GetCertificateBundleCertificateBundleTypePublicOnly,
 	})
 	if err != nil {
 		p.logger.Error(err, "failed fetching certificate")
-		return nil, err
+		return nil, nil, err
+	}
+	log.V(1).Info("certificate issued", "certificateID", certificateID, "profile", profile)
+	cert, ca, err := splitBundle(res.GetCertificatePem(), res.GetCertChainPem())
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf, err := pki.DecodeX509CertificateBytes(cert)
+	if err != nil {
+		return nil, nil, err
 	}
+	csr, err := pki.DecodeX509CertificateRequestBytes(cr.Spec.Request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !key.Equal(csr.PublicKey) {
+		return nil, nil, fmt.Errorf("%w: certificate %s does not certify the key of the CSR", ErrCertificateExists, certificateID)
+	}
+	if err := checkExtKeyUsages(leaf, profile); err != nil {
+		return nil, nil, err
+	}
+	if err := checkCA(leaf, cr); err != nil {
+		return nil, nil, err
+	}
+	return cert, ca, nil
+}
+
+// certificateName returns the name of the cert-manager Certificate cr was
+// created for, or empty for requests created directly.
+// certificateTags returns the freeform tags of the certificates created for
+// objects in namespace.
+func certificateTags(namespace string) map[string]string {
+	return map[string]string{
+		OCICertManagerTagKey: OCICertManagerTagValue,
+		OCINamespaceTagKey:   namespace,
+	}
+}
+
+func certificateName(cr *cmapi.CertificateRequest) string {
+	if name := cr.Annotations[cmapi.CertificateNameKey]; name != "" {
+		return name
+	}
+	for _, owner := range cr.OwnerReferences {
+		if owner.Kind == cmapi.CertificateKind {
+			return owner.Name
+		}
+	}
+	return ""
+}
 
-	chainPem := res.GetCertChainPem()
-	if chainPem != nil {
-		return nil, fmt.Errorf("failed parsing certificate chain")
+// splitBundle returns the certificate followed by the intermediates of chain,
+// and the last certificate of chain as the CA.
+func splitBundle(certPem, chainPem *string) ([]byte, []byte, error) {
+	if certPem == nil || chainPem == nil {
+		return nil, nil, fmt.Errorf("certificate bundle is missing the certificate or its chain")
+	}
+	var chain [][]byte
+	rest := []byte(*chainPem)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		chain = append(chain, pem.EncodeToMemory(block))
+	}
+	if len(chain) == 0 {
+		return nil, nil, fmt.Errorf("failed parsing certificate chain")
+	}
+	cert := []byte(strings.TrimSpace(*certPem) + "\n")
+	for _, intermediate := range chain[:len(chain)-1] {
+		cert = append(cert, intermediate...)
 	}
-	return []byte(*chainPem), nil
+	return cert, chain[len(chain)-1], nil
 }
@@ -0,0 +1,298 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"testing"
+)
+
+func TestProvisioner_Sign(t *testing.T) {
+	ctx := context.TODO()
+	fake := ocifake.NewServer(ocifake.Options{})
+	defer fake.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	rootID, err := fake.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subordinateID, err := fake.CreateSubordinateCA(rootID, "subordinate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fake.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "example.com"},
+		DNSNames: []string{"example.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		authorityID       string
+		requestName       string
+		wantIntermediates int
+	}{
+		{name: "root CA", authorityID: rootID, requestName: "root"},
+		{name: "subordinate CA", authorityID: subordinateID, requestName: "subordinate", wantIntermediates: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(logr.Discard(), ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+				CompartmentID: compartmentID,
+				AuthorityID:   tt.authorityID,
+				EndpointOverride: &ocicav1alpha1.EndpointOverride{
+					CertificatesManagement: fake.URL,
+					Certificates:           fake.URL,
+				},
+			}}, configProvider, Transport{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Validate(ctx); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			cr := &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.requestName},
+				Spec:       cmapi.CertificateRequestSpec{Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+			}
+			cert, ca, err := p.Sign(ctx, cr, SignOptions{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			var chain []*x509.Certificate
+			for rest := cert; ; {
+				var block *pem.Block
+				block, rest = pem.Decode(rest)
+				if block == nil {
+					break
+				}
+				c, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				chain = append(chain, c)
+			}
+			if got := len(chain) - 1; got != tt.wantIntermediates {
+				t.Fatalf("intermediates got = %d, want %d", got, tt.wantIntermediates)
+			}
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(ca) {
+				t.Fatalf("CA holds no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, c := range chain[1:] {
+				intermediates.AddCert(c)
+			}
+			if _, err := chain[0].Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots, Intermediates: intermediates}); err != nil {
+				t.Errorf("certificate does not verify: %v", err)
+			}
+		})
+	}
+
+	// The CSR asks for server auth only, while the request's usages map to
+	// the server or client profile.
+	serverCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames:        []string{"example.com"},
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{2, 5, 29, 37}, Value: mustMarshal(t, []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 1}})}},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(logr.Discard(), ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+		CompartmentID:    compartmentID,
+		AuthorityID:      rootID,
+		EndpointOverride: &ocicav1alpha1.EndpointOverride{CertificatesManagement: fake.URL, Certificates: fake.URL},
+	}}, configProvider, Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Sign(ctx, &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "mismatch"},
+		Spec: cmapi.CertificateRequestSpec{
+			Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: serverCSR}),
+			Usages:  []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageClientAuth},
+		},
+	}, SignOptions{}, logr.Discard()); err == nil {
+		t.Errorf("Sign() of a certificate missing requested usages should fail")
+	}
+
+	// Certificates are created in the compartment of the sign options, named
+	// after the cluster, namespace and request by default.
+	otherCompartmentID := "ocid1.compartment.oc1..bbbb"
+	if _, _, err := p.Sign(ctx, &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "other-compartment"},
+		Spec:       cmapi.CertificateRequestSpec{Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+	}, SignOptions{CompartmentID: otherCompartmentID, ClusterID: "prod"}, logr.Discard()); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	list, err := p.caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId: common.String(otherCompartmentID),
+		Name:          common.String("prod_CertificateRequest_ns1_other-compartment"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("certificates in the sign options compartment got = %d, want 1", len(list.Items))
+	}
+
+	// Requests with the same name in different namespaces do not collide.
+	for _, namespace := range []string{"ns1", "ns2"} {
+		if _, _, err := p.Sign(ctx, &cmapi.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "same-name"},
+			Spec:       cmapi.CertificateRequestSpec{Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+		}, SignOptions{}, logr.Discard()); err != nil {
+			t.Fatalf("Sign() in %s error = %v", namespace, err)
+		}
+	}
+
+	// Names and descriptions follow the templates of the issuer.
+	templated, err := New(logr.Discard(), ocicav1alpha1.OCICAClusterIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "issuer1"},
+		Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+			CompartmentID:       compartmentID,
+			AuthorityID:         rootID,
+			EndpointOverride:    &ocicav1alpha1.EndpointOverride{CertificatesManagement: fake.URL, Certificates: fake.URL},
+			NameTemplate:        "{{ .Issuer }}.{{ .Namespace }}.{{ .Certificate }}",
+			DescriptionTemplate: "Certificate {{ .Namespace }}/{{ .Certificate }}",
+		},
+	}, configProvider, Transport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := templated.Sign(ctx, &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns1",
+			Name:        "web-1",
+			Annotations: map[string]string{cmapi.CertificateNameKey: "web"},
+		},
+		Spec: cmapi.CertificateRequestSpec{Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+	}, SignOptions{}, logr.Discard()); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	list, err = p.caClient.ListCertificates(ctx, certificatesmanagement.ListCertificatesRequest{
+		CompartmentId: common.String(compartmentID),
+		Name:          common.String("issuer1.ns1.web"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Description == nil || *list.Items[0].Description != "Certificate ns1/web" {
+		t.Errorf("templated certificates got = %+v, want one described as Certificate ns1/web", list.Items)
+	}
+
+	// Credentials that may read but not manage certificates in a compartment
+	// are only refused when the certificate is created.
+	fake.InjectFault(ocifake.Fault{Operation: "CreateCertificate", Status: http.StatusNotFound, Code: "NotAuthorizedOrNotFound", Times: 1})
+	if _, _, err := p.Sign(ctx, &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "read-only"},
+		Spec:       cmapi.CertificateRequestSpec{Request: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+	}, SignOptions{}, logr.Discard()); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("Sign() without permission to create certificates error = %v, want %v", err, ErrNotAuthorized)
+	}
+
+	if err := fake.SetCertificateAuthorityState(rootID, certificatesmanagement.CertificateAuthorityLifecycleStatePendingDeletion); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Validate(ctx); err == nil {
+		t.Errorf("Validate() of a CA pending deletion should fail")
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestProvisioner_Validate_compartments(t *testing.T) {
+	ctx := context.TODO()
+	fake := ocifake.NewServer(ocifake.Options{})
+	defer fake.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	rootID, err := fake.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fake.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.DenyCompartment("ocid1.compartment.oc1..denied")
+
+	tests := []struct {
+		name         string
+		compartments *ocicav1alpha1.CompartmentRouting
+		overrides    []ocicav1alpha1.NamespaceOverride
+		wantErr      bool
+	}{
+		{name: "issuer compartment"},
+		{
+			name: "accessible mapped compartments",
+			compartments: &ocicav1alpha1.CompartmentRouting{
+				Namespaces: map[string]string{"team-a": "ocid1.compartment.oc1..teama"},
+				Default:    "ocid1.compartment.oc1..shared",
+			},
+		},
+		{
+			name:         "denied mapped compartment",
+			compartments: &ocicav1alpha1.CompartmentRouting{Namespaces: map[string]string{"team-b": "ocid1.compartment.oc1..denied"}},
+			wantErr:      true,
+		},
+		{
+			name:         "denied default compartment",
+			compartments: &ocicav1alpha1.CompartmentRouting{Default: "ocid1.compartment.oc1..denied"},
+			wantErr:      true,
+		},
+		{
+			name:      "denied override compartment",
+			overrides: []ocicav1alpha1.NamespaceOverride{{CompartmentID: "ocid1.compartment.oc1..denied"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(logr.Discard(), ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+				CompartmentID:      compartmentID,
+				AuthorityID:        rootID,
+				EndpointOverride:   &ocicav1alpha1.EndpointOverride{CertificatesManagement: fake.URL, Certificates: fake.URL},
+				Compartments:       tt.compartments,
+				NamespaceOverrides: tt.overrides,
+			}}, configProvider, Transport{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Validate(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package provisioner
+
+import (
+	"github.com/william20111/oci-privateca-issuer/pkg/ocsp"
+)
+
+// OCSPIssuer describes the issuer's CA for the OCSP responder.
+func (p *Provisioner) OCSPIssuer() ocsp.Issuer {
+	return ocsp.Issuer{
+		Name:          p.iss.Name,
+		AuthorityID:   p.iss.Spec.AuthorityID,
+		CompartmentID: p.compartmentID,
+		FreeformTags: map[string]string{
+			OCICertManagerTagKey: OCICertManagerTagValue,
+		},
+		CAClient:          p.caClient,
+		CertificateClient: p.certificateClient,
+		CompartmentIDs:    p.compartments(),
+	}
+}
+
+// OCSPIssuers describes every certificate authority of the issuer for the
+// OCSP responder, including those that are unavailable or retired, as the
+// certificates they issued are still in use.
+func (a *Authorities) OCSPIssuers() []ocsp.Issuer {
+	issuers := make([]ocsp.Issuer, 0, len(a.authorities))
+	for _, authority := range a.all() {
+		issuers = append(issuers, authority.Provisioner.OCSPIssuer())
+	}
+	return issuers
+}
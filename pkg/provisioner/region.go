@@ -0,0 +1,79 @@
+package provisioner
+
+import (
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
+)
+
+// regionConfigurationProvider reports the region of the certificate
+// authority instead of the region of the wrapped credentials, which may be
+// configured for another region of the tenancy.
+type regionConfigurationProvider struct {
+	common.ConfigurationProvider
+	region common.Region
+}
+
+// withAuthorityRegion wraps configProvider so the OCI clients target the
+// region encoded in the CA OCID, whatever region the credentials configure.
+func withAuthorityRegion(configProvider common.ConfigurationProvider, authority ocid.OCID) (common.ConfigurationProvider, error) {
+	region, err := authority.OCIRegion()
+	if err != nil {
+		return nil, err
+	}
+	return regionConfigurationProvider{ConfigurationProvider: configProvider, region: region}, nil
+}
+
+func (p regionConfigurationProvider) Region() (string, error) {
+	return string(p.region), nil
+}
+
+// setEndpoints points the OCI clients at the region and realm of the issuer.
+// The region in the spec wins over the region of the certificate authority.
+// Realms the SDK does not know, and explicit realm domains, get their hosts
+// built from the realm domain, and endpoint overrides replace the hosts
+// entirely.
+func setEndpoints(spec ocicav1alpha1.OCICAClusterIssuerSpec, authority ocid.OCID, configProvider common.ConfigurationProvider,
+	caClient *certificatesmanagement.CertificatesManagementClient, certClient *certificates.CertificatesClient, lbClient *loadbalancer.LoadBalancerClient) error {
+	region := spec.Region
+	if region == "" {
+		var err error
+		if region, err = configProvider.Region(); err != nil {
+			return err
+		}
+	}
+	caClient.SetRegion(region)
+	certClient.SetRegion(region)
+	lbClient.SetRegion(region)
+
+	domain := spec.RealmDomain
+	if realm, err := common.StringToRegion(region).RealmID(); domain == "" && (err != nil || realm != authority.Realm) {
+		var ok bool
+		if domain, ok = ocid.RealmDomain(authority.Realm); !ok {
+			return fmt.Errorf("unknown realm %q, set realmDomain on the issuer", authority.Realm)
+		}
+	}
+	if domain != "" {
+		regionName := string(common.StringToRegion(region))
+		caClient.Host = fmt.Sprintf("https://certificatesmanagement.%s.oci.%s", regionName, domain)
+		certClient.Host = fmt.Sprintf("https://certificates.%s.oci.%s", regionName, domain)
+		lbClient.Host = fmt.Sprintf("https://iaas.%s.%s", regionName, domain)
+	}
+
+	if spec.EndpointOverride != nil {
+		if spec.EndpointOverride.CertificatesManagement != "" {
+			caClient.Host = spec.EndpointOverride.CertificatesManagement
+		}
+		if spec.EndpointOverride.Certificates != "" {
+			certClient.Host = spec.EndpointOverride.Certificates
+		}
+		if spec.EndpointOverride.LoadBalancer != "" {
+			lbClient.Host = spec.EndpointOverride.LoadBalancer
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package provisioner
+
+import (
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/loadbalancer"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocid"
+	"testing"
+)
+
+func Test_withAuthorityRegion(t *testing.T) {
+	authority, err := ocid.Parse("ocid1.certificateauthority.oc1.phx.aaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		region string
+		want   string
+	}{
+		{name: "credentials in another region", region: "eu-frankfurt-1", want: string(common.RegionPHX)},
+		{name: "credentials without region", region: "", want: string(common.RegionPHX)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := common.NewRawConfigurationProvider("tenancy", "user", tt.region, "fingerprint", "key", nil)
+			p, err := withAuthorityRegion(base, authority)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Region()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Region() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setEndpoints(t *testing.T) {
+	tests := []struct {
+		name          string
+		authority     string
+		spec          ocicav1alpha1.OCICAClusterIssuerSpec
+		wantCAHost    string
+		wantCertsHost string
+		wantLBHost    string
+		wantErr       bool
+	}{
+		{
+			name:          "authority region",
+			authority:     "ocid1.certificateauthority.oc1.phx.aaaa",
+			wantCAHost:    "https://certificatesmanagement.us-phoenix-1.oci.oraclecloud.com",
+			wantCertsHost: "https://certificates.us-phoenix-1.oci.oraclecloud.com",
+			wantLBHost:    "https://iaas.us-phoenix-1.oraclecloud.com",
+		},
+		{
+			name:          "spec region",
+			authority:     "ocid1.certificateauthority.oc1.phx.aaaa",
+			spec:          ocicav1alpha1.OCICAClusterIssuerSpec{Region: "iad"},
+			wantCAHost:    "https://certificatesmanagement.us-ashburn-1.oci.oraclecloud.com",
+			wantCertsHost: "https://certificates.us-ashburn-1.oci.oraclecloud.com",
+			wantLBHost:    "https://iaas.us-ashburn-1.oraclecloud.com",
+		},
+		{
+			name:          "known realm of unknown region",
+			authority:     "ocid1.certificateauthority.oc4.xyz.aaaa",
+			wantCAHost:    "https://certificatesmanagement.xyz.oci.oraclegovcloud.uk",
+			wantCertsHost: "https://certificates.xyz.oci.oraclegovcloud.uk",
+			wantLBHost:    "https://iaas.xyz.oraclegovcloud.uk",
+		},
+		{
+			name:      "unknown realm",
+			authority: "ocid1.certificateauthority.oc42.xyz.aaaa",
+			wantErr:   true,
+		},
+		{
+			name:          "realm domain",
+			authority:     "ocid1.certificateauthority.oc42.xyz.aaaa",
+			spec:          ocicav1alpha1.OCICAClusterIssuerSpec{RealmDomain: "example.com"},
+			wantCAHost:    "https://certificatesmanagement.xyz.oci.example.com",
+			wantCertsHost: "https://certificates.xyz.oci.example.com",
+			wantLBHost:    "https://iaas.xyz.example.com",
+		},
+		{
+			name:      "endpoint override",
+			authority: "ocid1.certificateauthority.oc1.phx.aaaa",
+			spec: ocicav1alpha1.OCICAClusterIssuerSpec{EndpointOverride: &ocicav1alpha1.EndpointOverride{
+				Certificates: "http://localhost:8080",
+				LoadBalancer: "http://localhost:8081",
+			}},
+			wantCAHost:    "https://certificatesmanagement.us-phoenix-1.oci.oraclecloud.com",
+			wantCertsHost: "http://localhost:8080",
+			wantLBHost:    "http://localhost:8081",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authority, err := ocid.Parse(tt.authority)
+			if err != nil {
+				t.Fatal(err)
+			}
+			base := common.NewRawConfigurationProvider("tenancy", "user", "", "fingerprint", "key", nil)
+			cp, err := withAuthorityRegion(base, authority)
+			if err != nil {
+				t.Fatal(err)
+			}
+			caClient := certificatesmanagement.CertificatesManagementClient{}
+			certClient := certificates.CertificatesClient{}
+			lbClient := loadbalancer.LoadBalancerClient{}
+			err = setEndpoints(tt.spec, authority, cp, &caClient, &certClient, &lbClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if caClient.Host != tt.wantCAHost {
+				t.Errorf("certificates management host got = %s, want %s", caClient.Host, tt.wantCAHost)
+			}
+			if certClient.Host != tt.wantCertsHost {
+				t.Errorf("certificates host got = %s, want %s", certClient.Host, tt.wantCertsHost)
+			}
+			if lbClient.Host != tt.wantLBHost {
+				t.Errorf("load balancer host got = %s, want %s", lbClient.Host, tt.wantLBHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package provisioner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"golang.org/x/net/http/httpproxy"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds each OCI request, as the default client of the SDK
+// does, so that a stalled connection does not hold a reconcile forever.
+const requestTimeout = 60 * time.Second
+
+// httpClients holds the HTTP client of each issuer with transport settings,
+// so that connections are reused across reconciles.
+var httpClients = struct {
+	sync.Mutex
+	m map[string]cachedClient
+}{m: map[string]cachedClient{}}
+
+type cachedClient struct {
+	key    string
+	client *http.Client
+}
+
+// Transport configures how the OCI clients reach OCI, for clusters that only
+// have egress through a proxy or that intercept TLS.
+type Transport struct {
+	// ProxyURL is the HTTP(S) proxy used for OCI requests. The proxy
+	// environment variables are used when it is empty.
+	ProxyURL string
+	// NoProxy lists hosts, domains and CIDRs reached without the proxy.
+	NoProxy []string
+	// CABundle holds PEM encoded certificates trusted in addition to the
+	// system roots.
+	CABundle []byte
+}
+
+// Merge returns t overlaid with the settings of other. The proxy of other
+// replaces the one of t, and the CA bundles of both are trusted.
+func (t Transport) Merge(other Transport) Transport {
+	if other.ProxyURL != "" {
+		t.ProxyURL = other.ProxyURL
+	}
+	if len(other.NoProxy) > 0 {
+		t.NoProxy = other.NoProxy
+	}
+	if len(other.CABundle) > 0 {
+		bundle := make([]byte, 0, len(t.CABundle)+len(other.CABundle)+1)
+		bundle = append(bundle, t.CABundle...)
+		if len(bundle) > 0 && bundle[len(bundle)-1] != '\n' {
+			bundle = append(bundle, '\n')
+		}
+		t.CABundle = append(bundle, other.CABundle...)
+	}
+	return t
+}
+
+// IsZero reports whether t leaves the SDK defaults in place.
+func (t Transport) IsZero() bool {
+	return t.ProxyURL == "" && len(t.NoProxy) == 0 && len(t.CABundle) == 0
+}
+
+// HTTPClient returns an HTTP client applying the proxy and trust settings,
+// with a timeout on each request.
+func (t Transport) HTTPClient() (*http.Client, error) {
+	proxy := httpproxy.FromEnvironment()
+	if t.ProxyURL != "" {
+		u, err := url.Parse(t.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: must be an http or https URL", t.ProxyURL)
+		}
+		proxy.HTTPProxy = t.ProxyURL
+		proxy.HTTPSProxy = t.ProxyURL
+	}
+	if len(t.NoProxy) > 0 {
+		proxy.NoProxy = strings.Join(t.NoProxy, ",")
+	}
+	proxyFunc := proxy.ProxyFunc()
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(t.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(t.CABundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: requestTimeout}, nil
+}
+
+// key identifies the settings of t.
+func (t Transport) key() string {
+	return strings.Join([]string{t.ProxyURL, strings.Join(t.NoProxy, ","), string(t.CABundle)}, "\x00")
+}
+
+// cachedHTTPClient returns the HTTP client of the issuer called name,
+// building it again only when t changed since the last call.
+func (t Transport) cachedHTTPClient(name string) (*http.Client, error) {
+	key := t.key()
+	httpClients.Lock()
+	defer httpClients.Unlock()
+	cached, ok := httpClients.m[name]
+	if ok && cached.key == key {
+		return cached.client, nil
+	}
+	client, err := t.HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		cached.client.CloseIdleConnections()
+	}
+	httpClients.m[name] = cachedClient{key: key, client: client}
+	return client, nil
+}
+
+// apply sets the HTTP client of the OCI clients of the issuer called name
+// when t changes the defaults.
+func (t Transport) apply(name string, clients ...*common.BaseClient) error {
+	if t.IsZero() {
+		return nil
+	}
+	httpClient, err := t.cachedHTTPClient(name)
+	if err != nil {
+		return err
+	}
+	for _, c := range clients {
+		c.HTTPClient = httpClient
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package provisioner
+
+import (
+	"context"
+	"encoding/pem"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificates"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// connectProxy is an HTTP CONNECT proxy sending every tunnel to target.
+type connectProxy struct {
+	target string
+
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.hosts = append(p.hosts, req.Host)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(conn, upstream)
+		conn.Close()
+	}()
+}
+
+func (p *connectProxy) tunnels() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.hosts...)
+}
+
+func certificatePEM(srv *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+func TestTransport_HTTPClient(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	proxy := &connectProxy{target: srv.Listener.Addr().String()}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+
+	tests := []struct {
+		name       string
+		transport  Transport
+		url        string
+		wantErr    bool
+		wantTunnel bool
+	}{
+		{name: "untrusted", transport: Transport{}, url: srv.URL, wantErr: true},
+		{name: "CA bundle", transport: Transport{CABundle: certificatePEM(srv)}, url: srv.URL},
+		{
+			name:       "proxy",
+			transport:  Transport{ProxyURL: proxySrv.URL, CABundle: certificatePEM(srv)},
+			url:        "https://example.com",
+			wantTunnel: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(proxy.tunnels())
+			client, err := tt.transport.HTTPClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := client.Get(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				res.Body.Close()
+			}
+			if tunnelled := len(proxy.tunnels()) > before; tunnelled != tt.wantTunnel {
+				t.Errorf("tunnelled through proxy = %v, want %v", tunnelled, tt.wantTunnel)
+			}
+		})
+	}
+
+	noProxy, err := Transport{ProxyURL: proxySrv.URL, NoProxy: []string{".example.com"}}.HTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://certificates.us-phoenix-1.oci.example.com", nil)
+	if u, err := noProxy.Transport.(*http.Transport).Proxy(req); err != nil || u != nil {
+		t.Errorf("proxy for a no proxy domain got = %v, %v, want none", u, err)
+	}
+
+	if _, err := (Transport{CABundle: []byte("not a certificate")}).HTTPClient(); err == nil {
+		t.Errorf("HTTPClient() with an invalid CA bundle should fail")
+	}
+	if _, err := (Transport{ProxyURL: "proxy:3128"}).HTTPClient(); err == nil {
+		t.Errorf("HTTPClient() with an invalid proxy URL should fail")
+	}
+}
+
+func TestTransport_cachedHTTPClient(t *testing.T) {
+	transport := Transport{ProxyURL: "http://proxy:3128"}
+	first, err := transport.cachedHTTPClient("cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Timeout != requestTimeout {
+		t.Errorf("Timeout got = %s, want %s", first.Timeout, requestTimeout)
+	}
+	again, err := transport.cachedHTTPClient("cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Errorf("cachedHTTPClient() built a new client for the same settings")
+	}
+	changed, err := Transport{ProxyURL: "http://other:3128"}.cachedHTTPClient("cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Errorf("cachedHTTPClient() reused the client after the settings changed")
+	}
+}
+
+func TestTransport_Merge(t *testing.T) {
+	global := Transport{ProxyURL: "http://global:3128", NoProxy: []string{"10.0.0.0/8"}, CABundle: []byte("a")}
+	got := global.Merge(Transport{ProxyURL: "http://issuer:3128", CABundle: []byte("b")})
+	if got.ProxyURL != "http://issuer:3128" {
+		t.Errorf("ProxyURL got = %s, want the issuer proxy", got.ProxyURL)
+	}
+	if len(got.NoProxy) != 1 {
+		t.Errorf("NoProxy got = %v, want the global list", got.NoProxy)
+	}
+	if string(got.CABundle) != "a\nb" {
+		t.Errorf("CABundle got = %q, want both bundles", got.CABundle)
+	}
+}
+
+// TestNew_transport checks that both OCI clients reach a TLS intercepting
+// proxy, here the fake OCI service behind a CONNECT proxy.
+func TestNew_transport(t *testing.T) {
+	ctx := context.TODO()
+	fake := ocifake.NewServer(ocifake.Options{TLS: true})
+	defer fake.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	authorityID, err := fake.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fake.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := &connectProxy{target: fake.Listener.Addr().String()}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+
+	iss := ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+		CompartmentID: compartmentID,
+		AuthorityID:   authorityID,
+		EndpointOverride: &ocicav1alpha1.EndpointOverride{
+			CertificatesManagement: "https://example.com",
+			Certificates:           "https://example.com",
+		},
+	}}
+	noRetry := common.NoRetryPolicy()
+
+	untrusted, err := New(logr.Discard(), iss, configProvider, Transport{ProxyURL: proxySrv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := untrusted.caClient.GetCertificateAuthority(ctx, certificatesmanagement.GetCertificateAuthorityRequest{
+		CertificateAuthorityId: common.String(authorityID),
+		RequestMetadata:        common.RequestMetadata{RetryPolicy: &noRetry},
+	}); err == nil {
+		t.Errorf("GetCertificateAuthority() without the proxy CA should fail")
+	}
+
+	p, err := New(logr.Discard(), iss, configProvider, Transport{ProxyURL: proxySrv.URL, CABundle: certificatePEM(fake.Server)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.caClient.GetCertificateAuthority(ctx, certificatesmanagement.GetCertificateAuthorityRequest{
+		CertificateAuthorityId: common.String(authorityID),
+	}); err != nil {
+		t.Errorf("GetCertificateAuthority() error = %v", err)
+	}
+	if _, err := p.certificateClient.GetCertificateAuthorityBundle(ctx, certificates.GetCertificateAuthorityBundleRequest{
+		CertificateAuthorityId: common.String(authorityID),
+	}); err != nil {
+		t.Errorf("GetCertificateAuthorityBundle() error = %v", err)
+	}
+	if got := len(proxy.tunnels()); got < 2 {
+		t.Errorf("tunnels got = %d, want both clients to use the proxy", got)
+	}
+}
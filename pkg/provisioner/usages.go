@@ -0,0 +1,111 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedUsages is returned by Sign when the requested key usages
+// cannot be expressed by an OCI certificate profile.
+var ErrUnsupportedUsages = errors.New("unsupported key usages")
+
+// profileExtKeyUsages are the extended key usages of certificates issued with
+// each OCI certificate profile.
+var profileExtKeyUsages = map[certificatesmanagement.CertificateProfileTypeEnum][]x509.ExtKeyUsage{
+	certificatesmanagement.CertificateProfileTypeTlsServerOrClient: {x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	certificatesmanagement.CertificateProfileTypeTlsServer:         {x509.ExtKeyUsageServerAuth},
+	certificatesmanagement.CertificateProfileTypeTlsClient:         {x509.ExtKeyUsageClientAuth},
+	certificatesmanagement.CertificateProfileTypeTlsCodeSign:       {x509.ExtKeyUsageCodeSigning},
+}
+
+// profileKeyUsages are the key usages every OCI certificate profile allows.
+var profileKeyUsages = map[cmapi.KeyUsage]bool{
+	cmapi.UsageSigning:          true,
+	cmapi.UsageDigitalSignature: true,
+	cmapi.UsageKeyEncipherment:  true,
+	cmapi.UsageKeyAgreement:     true,
+}
+
+// caKeyUsages are the key usages additionally allowed for CA certificates.
+var caKeyUsages = map[cmapi.KeyUsage]bool{
+	cmapi.UsageCertSign: true,
+	cmapi.UsageCRLSign:  true,
+}
+
+// CertificateProfile returns the OCI certificate profile issuing certificates
+// with usages. Requests without extended key usages get OCI's default,
+// TLS_SERVER_OR_CLIENT. Certificate and CRL signing are only allowed for CA
+// certificates. The error wraps ErrUnsupportedUsages when no profile matches.
+func CertificateProfile(usages []cmapi.KeyUsage, isCA bool) (certificatesmanagement.CertificateProfileTypeEnum, error) {
+	var server, client, codeSigning bool
+	var unsupported []string
+	for _, usage := range usages {
+		switch usage {
+		case cmapi.UsageServerAuth:
+			server = true
+		case cmapi.UsageClientAuth:
+			client = true
+		case cmapi.UsageCodeSigning:
+			codeSigning = true
+		default:
+			if !profileKeyUsages[usage] && !(isCA && caKeyUsages[usage]) {
+				unsupported = append(unsupported, string(usage))
+			}
+		}
+	}
+	switch {
+	case len(unsupported) > 0:
+		sort.Strings(unsupported)
+		return "", fmt.Errorf("%w: %s cannot be issued by OCI", ErrUnsupportedUsages, strings.Join(unsupported, ", "))
+	case codeSigning && (server || client):
+		return "", fmt.Errorf("%w: no OCI certificate profile combines code signing with server or client auth", ErrUnsupportedUsages)
+	case codeSigning:
+		return certificatesmanagement.CertificateProfileTypeTlsCodeSign, nil
+	case server && !client:
+		return certificatesmanagement.CertificateProfileTypeTlsServer, nil
+	case client && !server:
+		return certificatesmanagement.CertificateProfileTypeTlsClient, nil
+	}
+	return certificatesmanagement.CertificateProfileTypeTlsServerOrClient, nil
+}
+
+// checkExtKeyUsages checks that cert has exactly the extended key usages of
+// profile.
+func checkExtKeyUsages(cert *x509.Certificate, profile certificatesmanagement.CertificateProfileTypeEnum) error {
+	want := profileExtKeyUsages[profile]
+	got := map[x509.ExtKeyUsage]bool{}
+	for _, usage := range cert.ExtKeyUsage {
+		got[usage] = true
+	}
+	match := len(got) == len(want) && len(cert.UnknownExtKeyUsage) == 0
+	for _, usage := range want {
+		match = match && got[usage]
+	}
+	if !match {
+		return fmt.Errorf("issued certificate has extended key usages [%s], want [%s] for profile %s",
+			extKeyUsageNames(cert.ExtKeyUsage), extKeyUsageNames(want), profile)
+	}
+	return nil
+}
+
+func extKeyUsageNames(usages []x509.ExtKeyUsage) string {
+	names := make([]string, len(usages))
+	for i, usage := range usages {
+		switch usage {
+		case x509.ExtKeyUsageServerAuth:
+			names[i] = string(cmapi.UsageServerAuth)
+		case x509.ExtKeyUsageClientAuth:
+			names[i] = string(cmapi.UsageClientAuth)
+		case x509.ExtKeyUsageCodeSigning:
+			names[i] = string(cmapi.UsageCodeSigning)
+		default:
+			names[i] = fmt.Sprintf("extended key usage %d", usage)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,91 @@
+package provisioner
+
+import (
+	"crypto/x509"
+	"errors"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"testing"
+)
+
+func TestCertificateProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		usages  []cmapi.KeyUsage
+		isCA    bool
+		want    certificatesmanagement.CertificateProfileTypeEnum
+		wantErr bool
+	}{
+		{name: "none", want: certificatesmanagement.CertificateProfileTypeTlsServerOrClient},
+		{
+			name:   "cert-manager defaults",
+			usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment},
+			want:   certificatesmanagement.CertificateProfileTypeTlsServerOrClient,
+		},
+		{
+			name:   "server and client",
+			usages: []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageClientAuth},
+			want:   certificatesmanagement.CertificateProfileTypeTlsServerOrClient,
+		},
+		{
+			name:   "server",
+			usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageServerAuth},
+			want:   certificatesmanagement.CertificateProfileTypeTlsServer,
+		},
+		{
+			name:   "client",
+			usages: []cmapi.KeyUsage{cmapi.UsageSigning, cmapi.UsageClientAuth},
+			want:   certificatesmanagement.CertificateProfileTypeTlsClient,
+		},
+		{
+			name:   "code signing",
+			usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageCodeSigning},
+			want:   certificatesmanagement.CertificateProfileTypeTlsCodeSign,
+		},
+		{name: "code signing and server", usages: []cmapi.KeyUsage{cmapi.UsageCodeSigning, cmapi.UsageServerAuth}, wantErr: true},
+		{name: "email protection", usages: []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageEmailProtection}, wantErr: true},
+		{name: "cert sign", usages: []cmapi.KeyUsage{cmapi.UsageCertSign}, wantErr: true},
+		{
+			name:   "CA",
+			usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageCertSign, cmapi.UsageCRLSign},
+			isCA:   true,
+			want:   certificatesmanagement.CertificateProfileTypeTlsServerOrClient,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CertificateProfile(tt.usages, tt.isCA)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CertificateProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnsupportedUsages) {
+				t.Errorf("CertificateProfile() error = %v, want ErrUnsupportedUsages", err)
+			}
+			if got != tt.want {
+				t.Errorf("CertificateProfile() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkExtKeyUsages(t *testing.T) {
+	tests := []struct {
+		name    string
+		usages  []x509.ExtKeyUsage
+		profile certificatesmanagement.CertificateProfileTypeEnum
+		wantErr bool
+	}{
+		{name: "match", usages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}, profile: certificatesmanagement.CertificateProfileTypeTlsServerOrClient},
+		{name: "missing", usages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, profile: certificatesmanagement.CertificateProfileTypeTlsServerOrClient, wantErr: true},
+		{name: "extra", usages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, profile: certificatesmanagement.CertificateProfileTypeTlsClient, wantErr: true},
+		{name: "none", profile: certificatesmanagement.CertificateProfileTypeTlsCodeSign, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExtKeyUsages(&x509.Certificate{ExtKeyUsage: tt.usages}, tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkExtKeyUsages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
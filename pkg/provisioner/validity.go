@@ -0,0 +1,118 @@
+package provisioner
+
+import (
+	"fmt"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/isoduration"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// authorityLimits are the bounds the certificate authority puts on the
+// certificates it issues.
+type authorityLimits struct {
+	// signingAlgorithm is the algorithm the certificate authority signs
+	// with, which names the type of its key, or empty when unknown.
+	signingAlgorithm certificatesmanagement.SignatureAlgorithmEnum
+	// leafMaxValidity is the longest validity of leaf certificates, or 0
+	// when the certificate authority has no issuance expiry rule.
+	leafMaxValidity time.Duration
+	notBefore       time.Time
+	notAfter        time.Time
+}
+
+// newAuthorityLimits reads the signing algorithm, the issuance expiry rule and
+// the validity of the current version of ca.
+func newAuthorityLimits(ca certificatesmanagement.CertificateAuthority) (authorityLimits, error) {
+	limits := authorityLimits{signingAlgorithm: ca.SigningAlgorithm}
+	for _, rule := range ca.CertificateAuthorityRules {
+		var expiry *certificatesmanagement.CertificateAuthorityIssuanceExpiryRule
+		switch r := rule.(type) {
+		case certificatesmanagement.CertificateAuthorityIssuanceExpiryRule:
+			expiry = &r
+		case *certificatesmanagement.CertificateAuthorityIssuanceExpiryRule:
+			expiry = r
+		}
+		if expiry == nil || expiry.LeafCertificateMaxValidityDuration == nil {
+			continue
+		}
+		d, err := isoduration.Parse(*expiry.LeafCertificateMaxValidityDuration)
+		if err != nil {
+			return limits, fmt.Errorf("certificate authority leaf certificate max validity: %v", err)
+		}
+		if limits.leafMaxValidity == 0 || d < limits.leafMaxValidity {
+			limits.leafMaxValidity = d
+		}
+	}
+	if v := ca.CurrentVersion; v != nil && v.Validity != nil {
+		if v.Validity.TimeOfValidityNotBefore != nil {
+			limits.notBefore = v.Validity.TimeOfValidityNotBefore.Time
+		}
+		if v.Validity.TimeOfValidityNotAfter != nil {
+			limits.notAfter = v.Validity.TimeOfValidityNotAfter.Time
+		}
+	}
+	return limits, nil
+}
+
+// validity returns the NotBefore and NotAfter of the certificate issued for
+// cr at now. NotBefore is backdated by the issuer's skew allowance, and
+// NotAfter is fitted to the certificate authority limits according to the
+// issuer's validity policy.
+func (p *Provisioner) validity(cr *cmapi.CertificateRequest, now time.Time) (time.Time, time.Time, error) {
+	return p.validityFor("certificaterequest", cr.Name, cr.Spec.Duration, now)
+}
+
+// validityFor returns the NotBefore and NotAfter of a certificate requested
+// for duration at now, by the object of kind and name logged when it is
+// clamped.
+func (p *Provisioner) validityFor(kind, name string, requested *metav1.Duration, now time.Time) (time.Time, time.Time, error) {
+	policy := p.iss.Spec.Validity
+	if policy == nil {
+		policy = &ocicav1alpha1.ValidityPolicy{}
+	}
+	backdate := ocicav1alpha1.DefaultValidityBackdate
+	if policy.Backdate != nil {
+		backdate = policy.Backdate.Duration
+	}
+	minDuration := ocicav1alpha1.MinimumCertificateDuration
+	if policy.MinDuration != nil {
+		minDuration = policy.MinDuration.Duration
+	}
+	duration := DefaultDurationInterval
+	if requested != nil {
+		duration = requested.Duration
+	} else if p.iss.Spec.DefaultDuration != nil {
+		duration = p.iss.Spec.DefaultDuration.Duration
+	}
+
+	notBefore := now.Add(-backdate)
+	if notBefore.Before(p.limits.notBefore) {
+		notBefore = p.limits.notBefore
+	}
+	notAfter := now.Add(duration)
+
+	var limit time.Time
+	if p.limits.leafMaxValidity > 0 {
+		limit = notBefore.Add(p.limits.leafMaxValidity)
+	}
+	if !p.limits.notAfter.IsZero() && (limit.IsZero() || p.limits.notAfter.Before(limit)) {
+		limit = p.limits.notAfter
+	}
+	if !limit.IsZero() && notAfter.After(limit) {
+		if policy.OnExceeded == ocicav1alpha1.ValidityExceededReject {
+			return notBefore, notAfter, fmt.Errorf("%w: requested duration %s ends after %s, the latest the certificate authority allows",
+				ErrPolicyViolation, duration, limit.Format(time.RFC3339))
+		}
+		p.logger.Info("clamped certificate validity to the certificate authority limits",
+			kind, name, "requested", duration, "notAfter", limit)
+		notAfter = limit
+	}
+	if notAfter.Sub(now) < minDuration {
+		return notBefore, notAfter, fmt.Errorf("%w: certificate would be valid for %s, less than the minimum of %s",
+			ErrPolicyViolation, notAfter.Sub(now).Round(time.Second), minDuration)
+	}
+	return notBefore, notAfter, nil
+}
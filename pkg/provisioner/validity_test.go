@@ -0,0 +1,221 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	cmapi "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	"github.com/oracle/oci-go-sdk/v65/certificatesmanagement"
+	"github.com/oracle/oci-go-sdk/v65/common"
+	ocicav1alpha1 "github.com/william20111/oci-privateca-issuer/pkg/api/v1alpha1"
+	"github.com/william20111/oci-privateca-issuer/pkg/ocifake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newAuthorityLimits(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.AddDate(10, 0, 0)
+	limits, err := newAuthorityLimits(certificatesmanagement.CertificateAuthority{
+		CertificateAuthorityRules: []certificatesmanagement.CertificateAuthorityRule{
+			certificatesmanagement.CertificateAuthorityIssuanceExpiryRule{LeafCertificateMaxValidityDuration: common.String("P90D")},
+			&certificatesmanagement.CertificateAuthorityIssuanceExpiryRule{LeafCertificateMaxValidityDuration: common.String("P30D")},
+		},
+		CurrentVersion: &certificatesmanagement.CertificateAuthorityVersionSummary{
+			Validity: &certificatesmanagement.Validity{
+				TimeOfValidityNotBefore: &common.SDKTime{Time: notBefore},
+				TimeOfValidityNotAfter:  &common.SDKTime{Time: notAfter},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := authorityLimits{leafMaxValidity: 30 * 24 * time.Hour, notBefore: notBefore, notAfter: notAfter}
+	if limits != want {
+		t.Errorf("newAuthorityLimits() got = %+v, want %+v", limits, want)
+	}
+
+	if _, err := newAuthorityLimits(certificatesmanagement.CertificateAuthority{
+		CertificateAuthorityRules: []certificatesmanagement.CertificateAuthorityRule{
+			certificatesmanagement.CertificateAuthorityIssuanceExpiryRule{LeafCertificateMaxValidityDuration: common.String("90 days")},
+		},
+	}); err == nil {
+		t.Error("newAuthorityLimits() accepted an invalid duration")
+	}
+}
+
+func TestProvisioner_validity(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	duration := func(d time.Duration) *metav1.Duration {
+		return &metav1.Duration{Duration: d}
+	}
+
+	tests := []struct {
+		name          string
+		policy        *ocicav1alpha1.ValidityPolicy
+		limits        authorityLimits
+		duration      *metav1.Duration
+		wantNotBefore time.Time
+		wantNotAfter  time.Time
+		wantPolicy    bool
+	}{
+		{
+			name:          "defaults",
+			wantNotBefore: now.Add(-ocicav1alpha1.DefaultValidityBackdate),
+			wantNotAfter:  now.Add(DefaultDurationInterval),
+		},
+		{
+			name:          "backdate",
+			policy:        &ocicav1alpha1.ValidityPolicy{Backdate: duration(time.Hour)},
+			duration:      duration(day),
+			wantNotBefore: now.Add(-time.Hour),
+			wantNotAfter:  now.Add(day),
+		},
+		{
+			name:          "backdate stops at the CA",
+			limits:        authorityLimits{notBefore: now.Add(-time.Minute)},
+			duration:      duration(day),
+			wantNotBefore: now.Add(-time.Minute),
+			wantNotAfter:  now.Add(day),
+		},
+		{
+			name:          "clamped to leaf maximum",
+			policy:        &ocicav1alpha1.ValidityPolicy{Backdate: duration(0)},
+			limits:        authorityLimits{leafMaxValidity: 30 * day},
+			duration:      duration(90 * day),
+			wantNotBefore: now,
+			wantNotAfter:  now.Add(30 * day),
+		},
+		{
+			name:          "clamped to CA expiry",
+			policy:        &ocicav1alpha1.ValidityPolicy{Backdate: duration(0)},
+			limits:        authorityLimits{leafMaxValidity: 30 * day, notAfter: now.Add(10 * day)},
+			duration:      duration(90 * day),
+			wantNotBefore: now,
+			wantNotAfter:  now.Add(10 * day),
+		},
+		{
+			name:       "rejected",
+			policy:     &ocicav1alpha1.ValidityPolicy{OnExceeded: ocicav1alpha1.ValidityExceededReject},
+			limits:     authorityLimits{leafMaxValidity: 30 * day},
+			duration:   duration(90 * day),
+			wantPolicy: true,
+		},
+		{
+			name:       "clamped below minimum",
+			limits:     authorityLimits{notAfter: now.Add(30 * time.Minute)},
+			duration:   duration(day),
+			wantPolicy: true,
+		},
+		{
+			name:       "requested below minimum",
+			policy:     &ocicav1alpha1.ValidityPolicy{MinDuration: duration(2 * time.Hour)},
+			duration:   duration(90 * time.Minute),
+			wantPolicy: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{
+				logger: logr.Discard(),
+				iss:    ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{Validity: tt.policy}},
+				limits: tt.limits,
+			}
+			cr := &cmapi.CertificateRequest{Spec: cmapi.CertificateRequestSpec{Duration: tt.duration}}
+			notBefore, notAfter, err := p.validity(cr, now)
+			if tt.wantPolicy {
+				if !errors.Is(err, ErrPolicyViolation) {
+					t.Fatalf("validity() error = %v, want a policy violation", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validity() error = %v", err)
+			}
+			if !notBefore.Equal(tt.wantNotBefore) || !notAfter.Equal(tt.wantNotAfter) {
+				t.Errorf("validity() got = %s - %s, want %s - %s", notBefore, notAfter, tt.wantNotBefore, tt.wantNotAfter)
+			}
+		})
+	}
+}
+
+func TestProvisioner_Sign_validity(t *testing.T) {
+	ctx := context.TODO()
+	fake := ocifake.NewServer(ocifake.Options{})
+	defer fake.Close()
+	compartmentID := "ocid1.compartment.oc1..aaaa"
+	rootID, err := fake.CreateRootCA(compartmentID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.SetIssuanceExpiryRule(rootID, certificatesmanagement.CertificateAuthorityIssuanceExpiryRule{
+		LeafCertificateMaxValidityDuration: common.String("P30D"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	configProvider, err := fake.ConfigurationProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []ocicav1alpha1.ValidityExceededAction{ocicav1alpha1.ValidityExceededClamp, ocicav1alpha1.ValidityExceededReject} {
+		t.Run(string(action), func(t *testing.T) {
+			p, err := New(logr.Discard(), ocicav1alpha1.OCICAClusterIssuer{Spec: ocicav1alpha1.OCICAClusterIssuerSpec{
+				CompartmentID:    compartmentID,
+				AuthorityID:      rootID,
+				EndpointOverride: &ocicav1alpha1.EndpointOverride{CertificatesManagement: fake.URL, Certificates: fake.URL},
+				Validity:         &ocicav1alpha1.ValidityPolicy{OnExceeded: action},
+			}}, configProvider, Transport{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Validate(ctx); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			start := time.Now()
+			cert, _, err := p.Sign(ctx, &cmapi.CertificateRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "validity-" + strings.ToLower(string(action))},
+				Spec: cmapi.CertificateRequestSpec{
+					Request:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}),
+					Duration: &metav1.Duration{Duration: 90 * 24 * time.Hour},
+				},
+			}, SignOptions{}, logr.Discard())
+			if action == ocicav1alpha1.ValidityExceededReject {
+				if !errors.Is(err, ErrPolicyViolation) {
+					t.Fatalf("Sign() error = %v, want a policy violation", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			leaf, err := x509.ParseCertificate(mustDecodePEM(t, cert))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := leaf.NotAfter.Sub(leaf.NotBefore); got > 30*24*time.Hour {
+				t.Errorf("certificate is valid for %s, longer than the CA allows", got)
+			}
+			if !leaf.NotBefore.Before(start.Add(-time.Minute)) {
+				t.Errorf("NotBefore %s is not backdated", leaf.NotBefore)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "* * * * *"},
+		{spec: "0 22 * * SAT"},
+		{spec: "*/15 8-18/2 1,15 jan-mar 1-5"},
+		{spec: "0 0 * * 7"},
+		{spec: "", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "* * * foo *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{spec: "* * * * *", after: time.Date(2024, 5, 1, 10, 0, 30, 0, time.UTC), want: time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC)},
+		{spec: "0 22 * * sat", after: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), want: time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC)},
+		{spec: "0 22 * * 6", after: time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC), want: time.Date(2024, 5, 11, 22, 0, 0, 0, time.UTC)},
+		{spec: "*/20 9-17 * * 1-5", after: time.Date(2024, 5, 3, 17, 50, 0, 0, time.UTC), want: time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 * 0", after: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), want: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", after: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 30 2 *", after: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), want: time.Time{}},
+		{spec: "30 2 * * *", after: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), want: time.Date(2024, 3, 11, 2, 30, 0, 0, newYork)},
+		{spec: "0 9 * * *", after: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork), want: time.Date(2024, 3, 10, 9, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_Active(t *testing.T) {
+	saturdays, err := Parse("0 22 * * sat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly, err := Parse("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		duration time.Duration
+		at       time.Time
+		want     time.Time
+		wantOK   bool
+	}{
+		{
+			name:     "before the window",
+			schedule: saturdays,
+			duration: 4 * time.Hour,
+			at:       time.Date(2024, 5, 4, 21, 59, 0, 0, time.UTC),
+		},
+		{
+			name:     "when the window opens",
+			schedule: saturdays,
+			duration: 4 * time.Hour,
+			at:       time.Date(2024, 5, 4, 22, 0, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 5, 2, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			name:     "in the window",
+			schedule: saturdays,
+			duration: 4 * time.Hour,
+			at:       time.Date(2024, 5, 5, 1, 30, 0, 0, time.UTC),
+			want:     time.Date(2024, 5, 5, 2, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+		{
+			name:     "when the window closes",
+			schedule: saturdays,
+			duration: 4 * time.Hour,
+			at:       time.Date(2024, 5, 5, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "overlapping windows",
+			schedule: hourly,
+			duration: 90 * time.Minute,
+			at:       time.Date(2024, 5, 5, 2, 0, 0, 0, time.UTC),
+			wantOK:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.schedule.Active(tt.at, tt.duration)
+			if ok != tt.wantOK {
+				t.Fatalf("Active() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !tt.want.IsZero() && !got.Equal(tt.want) {
+				t.Errorf("Active() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}